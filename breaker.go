@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// Circuit breaker defaults, overridable per server
+const (
+	DefaultCircuitThreshold = 5                // Consecutive failures before opening
+	DefaultCircuitCooldown  = 30 * time.Second // How long to fail fast before a trial request
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// CircuitBreaker fails fast for a server that keeps failing. After threshold
+// consecutive failures it opens for cooldown; then one trial request is let
+// through (half-open), which closes the circuit on success or reopens it.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	state     string
+	failures  int
+	openedAt  time.Time
+	trial     bool // A half-open trial request is in flight
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+}
+
+// BreakerStatus is a breaker's state as reported by the daemon
+type BreakerStatus struct {
+	State    string `json:"state"`
+	Failures int    `json:"failures"`
+	RetryIn  string `json:"retry_in,omitempty"` // Time until a trial request is allowed
+}
+
+// NewCircuitBreaker creates a closed breaker; non-positive values use defaults
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = DefaultCircuitThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultCircuitCooldown
+	}
+	return &CircuitBreaker{
+		state:     CircuitClosed,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// breakerForServer creates a breaker using the server's configured thresholds
+func breakerForServer(serverConfig ServerConfig) *CircuitBreaker {
+	return NewCircuitBreaker(serverConfig.CircuitThreshold, time.Duration(serverConfig.CircuitCooldown)*time.Second)
+}
+
+// Allow reports whether a request may proceed, returning CONNECTION_FAILED
+// while the circuit is open or a half-open trial is already in flight
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if wait := b.cooldown - b.now().Sub(b.openedAt); wait > 0 {
+			return codedErrorf(ErrConnectionFailed, "circuit open after %d consecutive failures; retrying in %s", b.failures, wait.Round(time.Second))
+		}
+		b.state = CircuitHalfOpen
+		b.trial = true
+		return nil
+	case CircuitHalfOpen:
+		if b.trial {
+			return codedErrorf(ErrConnectionFailed, "circuit half-open; trial request in progress")
+		}
+		b.trial = true
+		return nil
+	}
+	return nil
+}
+
+// RecordSuccess closes the circuit
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = CircuitClosed
+	b.failures = 0
+	b.trial = false
+}
+
+// RecordFailure counts a failure, opening the circuit at the threshold or
+// immediately if a half-open trial failed
+func (b *CircuitBreaker) RecordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trial = false
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Status returns the breaker's current state
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{State: b.state, Failures: b.failures}
+	if b.state == CircuitOpen {
+		if wait := b.cooldown - b.now().Sub(b.openedAt); wait > 0 {
+			status.RetryIn = wait.Round(time.Second).String()
+		}
+	}
+	return status
+}
+
+// isServerFailure reports whether err means the server itself is unhealthy
+// (network errors, timeouts, 5xx) rather than a problem with the request
+func isServerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= 500
+	}
+	return false
+}
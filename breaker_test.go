@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestBreaker returns a breaker with a controllable clock
+func newTestBreaker(threshold int, cooldown time.Duration) (*CircuitBreaker, *time.Time) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewCircuitBreaker(threshold, cooldown)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestCircuitBreaker_Defaults(t *testing.T) {
+	b := NewCircuitBreaker(0, 0)
+	if b.threshold != DefaultCircuitThreshold {
+		t.Errorf("Expected threshold %d, got %d", DefaultCircuitThreshold, b.threshold)
+	}
+	if b.cooldown != DefaultCircuitCooldown {
+		t.Errorf("Expected cooldown %v, got %v", DefaultCircuitCooldown, b.cooldown)
+	}
+
+	b = breakerForServer(ServerConfig{CircuitThreshold: 2, CircuitCooldown: 10})
+	if b.threshold != 2 || b.cooldown != 10*time.Second {
+		t.Errorf("Expected configured threshold/cooldown, got %d/%v", b.threshold, b.cooldown)
+	}
+}
+
+func TestCircuitBreaker_Lifecycle(t *testing.T) {
+	b, now := newTestBreaker(3, 30*time.Second)
+
+	// Closed: failures below the threshold still pass through
+	for i := 0; i < 2; i++ {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("Expected closed breaker to allow, got %v", err)
+		}
+		b.RecordFailure()
+	}
+	if s := b.Status(); s.State != CircuitClosed || s.Failures != 2 {
+		t.Fatalf("Expected closed with 2 failures, got %+v", s)
+	}
+
+	// Third failure opens it
+	b.RecordFailure()
+	if s := b.Status(); s.State != CircuitOpen || s.RetryIn != "30s" {
+		t.Fatalf("Expected open with 30s retry, got %+v", s)
+	}
+	err := b.Allow()
+	if err == nil {
+		t.Fatal("Expected open breaker to fail fast")
+	}
+	if code := errorCodeOf(err, ""); code != ErrConnectionFailed {
+		t.Errorf("Expected %s, got %s", ErrConnectionFailed, code)
+	}
+
+	// After the cooldown a single trial is allowed
+	*now = now.Add(31 * time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Expected trial request after cooldown, got %v", err)
+	}
+	if s := b.Status(); s.State != CircuitHalfOpen {
+		t.Fatalf("Expected half-open, got %+v", s)
+	}
+	if err := b.Allow(); err == nil {
+		t.Fatal("Expected concurrent request to fail during trial")
+	}
+
+	// A successful trial closes it
+	b.RecordSuccess()
+	if s := b.Status(); s.State != CircuitClosed || s.Failures != 0 {
+		t.Fatalf("Expected closed and reset, got %+v", s)
+	}
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Expected closed breaker to allow, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_HalfOpenFailureReopens(t *testing.T) {
+	b, now := newTestBreaker(1, 10*time.Second)
+
+	b.RecordFailure()
+	*now = now.Add(11 * time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Expected trial request, got %v", err)
+	}
+	b.RecordFailure()
+
+	if s := b.Status(); s.State != CircuitOpen {
+		t.Fatalf("Expected failed trial to reopen, got %+v", s)
+	}
+	if err := b.Allow(); err == nil {
+		t.Error("Expected reopened breaker to fail fast")
+	}
+}
+
+func TestCircuitBreaker_SuccessResetsCount(t *testing.T) {
+	b, _ := newTestBreaker(3, time.Second)
+
+	b.RecordFailure()
+	b.RecordFailure()
+	b.RecordSuccess()
+	b.RecordFailure()
+	b.RecordFailure()
+
+	if s := b.Status(); s.State != CircuitClosed {
+		t.Errorf("Expected non-consecutive failures to keep breaker closed, got %+v", s)
+	}
+}
+
+func TestIsServerFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"5xx", &HTTPError{StatusCode: 503}, true},
+		{"4xx", &HTTPError{StatusCode: 404}, false},
+		{"tool error", errors.New("tool failed"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isServerFailure(tt.err); got != tt.want {
+				t.Errorf("isServerFailure(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
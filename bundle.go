@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// bundleVersion is the format version written by --export
+const bundleVersion = 1
+
+// ConfigBundle is a portable export of the mcpx configuration. Tokens are
+// only included with --include-secrets.
+type ConfigBundle struct {
+	Version       int                           `json:"version"`
+	Servers       map[string]ServerConfig       `json:"servers"`
+	Registrations map[string]ClientRegistration `json:"registrations,omitempty"`
+	Tokens        map[string]TokenData          `json:"tokens,omitempty"`
+}
+
+// ImportResult summarizes what --import changed
+type ImportResult struct {
+	Added       []string `json:"added"`
+	Replaced    []string `json:"replaced,omitempty"`
+	Unchanged   []string `json:"unchanged,omitempty"`
+	NeedSecrets []string `json:"need_secrets,omitempty"` // Servers with redacted values to fill in
+}
+
+// buildBundle collects the current config and registrations, redacting
+// secrets unless includeSecrets is set
+func buildBundle(includeSecrets bool) (*ConfigBundle, error) {
+	config, err := LoadGlobalConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	regs, err := LoadRegistrations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load registrations: %w", err)
+	}
+
+	bundle := &ConfigBundle{
+		Version:       bundleVersion,
+		Servers:       config.Servers,
+		Registrations: regs,
+	}
+
+	if includeSecrets {
+		tokens, err := LoadTokens()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tokens: %w", err)
+		}
+		bundle.Tokens = tokens
+		return bundle, nil
+	}
+
+	for name, cfg := range bundle.Servers {
+		bundle.Servers[name] = redactServerConfig(cfg)
+	}
+	for name, reg := range bundle.Registrations {
+		if reg.ClientSecret != "" {
+			reg.ClientSecret = redactedValue
+			bundle.Registrations[name] = reg
+		}
+	}
+	return bundle, nil
+}
+
+// exportConfig writes a bundle to path (mode 0600, as it may hold secrets)
+func exportConfig(path string, includeSecrets bool) (*ConfigBundle, error) {
+	bundle, err := buildBundle(includeSecrets)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(path, data, 0600); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// importConfig merges a bundle into the current config. Servers that already
+// exist with a different config are conflicts: without force nothing is
+// written and an EXISTS error names them; with force the bundle wins.
+func importConfig(path string, force bool) (*ImportResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var bundle ConfigBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, codedErrorf(ErrInvalidJSON, "invalid bundle %s: %v", path, err)
+	}
+	if bundle.Version > bundleVersion {
+		return nil, codedErrorf(ErrInvalidArgs, "bundle version %d is newer than supported (%d)", bundle.Version, bundleVersion)
+	}
+
+	result := &ImportResult{Added: []string{}}
+	err = UpdateConfig(func(config *Config) error {
+		var conflicts []string
+		for name, incoming := range bundle.Servers {
+			existing, exists := config.Servers[name]
+			if exists && !sameServerConfig(existing, incoming) {
+				conflicts = append(conflicts, name)
+			}
+		}
+		sort.Strings(conflicts)
+		if len(conflicts) > 0 && !force {
+			return codedErrorf(ErrExists, "servers already exist with different config: %s (use --force to overwrite)", strings.Join(conflicts, ", "))
+		}
+
+		for name, incoming := range bundle.Servers {
+			existing, exists := config.Servers[name]
+			switch {
+			case !exists:
+				result.Added = append(result.Added, name)
+			case sameServerConfig(existing, incoming):
+				result.Unchanged = append(result.Unchanged, name)
+				continue
+			default:
+				result.Replaced = append(result.Replaced, name)
+			}
+			config.Servers[name] = incoming
+			if hasRedactedValues(incoming) {
+				result.NeedSecrets = append(result.NeedSecrets, name)
+			}
+		}
+		return config.Validate()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for name, reg := range bundle.Registrations {
+		if reg.ClientSecret == redactedValue {
+			continue // Re-registers on next --auth
+		}
+		if err := SaveRegistration(name, reg); err != nil {
+			return nil, fmt.Errorf("failed to save registration: %w", err)
+		}
+	}
+
+	if len(bundle.Tokens) > 0 {
+		tokens, err := LoadTokens()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tokens: %w", err)
+		}
+		for name, token := range bundle.Tokens {
+			tokens[name] = token
+		}
+		if err := SaveTokens(tokens); err != nil {
+			return nil, fmt.Errorf("failed to save tokens: %w", err)
+		}
+	}
+
+	sort.Strings(result.Added)
+	sort.Strings(result.Replaced)
+	sort.Strings(result.Unchanged)
+	sort.Strings(result.NeedSecrets)
+	return result, nil
+}
+
+// sensitiveKeyParts mark header and env names whose values are secrets
+var sensitiveKeyParts = []string{"auth", "token", "secret", "key", "password", "cookie", "credential"}
+
+func isSensitiveKey(name string) bool {
+	lower := strings.ToLower(name)
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRedactedValues reports whether cfg still holds redaction placeholders
+func hasRedactedValues(cfg ServerConfig) bool {
+	data, _ := json.Marshal(cfg)
+	return strings.Contains(string(data), redactedValue)
+}
+
+func sameServerConfig(a, b ServerConfig) bool {
+	da, _ := json.Marshal(a)
+	db, _ := json.Marshal(b)
+	return string(da) == string(db)
+}
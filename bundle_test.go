@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	tmpDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	original := &Config{Servers: map[string]ServerConfig{
+		"api": {
+			URL:     "https://api.example.com/mcp",
+			Headers: map[string]string{"Authorization": "Bearer secret", "X-Project": "demo"},
+			Aliases: []string{"a"},
+		},
+		"local": {
+			URL:   "http://localhost:8931/mcp",
+			Local: &LocalConfig{Command: "npx", Env: []string{"API_KEY=abc", "DEBUG=1"}},
+		},
+	}}
+	if err := SaveConfig(original); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	SaveRegistration("api", ClientRegistration{ClientID: "client-1", ClientSecret: "shh"})
+	SaveTokens(map[string]TokenData{"api": {AccessToken: "tok"}})
+
+	bundlePath := filepath.Join(tmpDir, "bundle.json")
+	if _, err := exportConfig(bundlePath, false); err != nil {
+		t.Fatalf("exportConfig failed: %v", err)
+	}
+
+	raw, _ := os.ReadFile(bundlePath)
+	for _, secret := range []string{"Bearer secret", "API_KEY=abc", "shh", "tok"} {
+		if strings.Contains(string(raw), secret) {
+			t.Errorf("Expected %q redacted from export", secret)
+		}
+	}
+	if !strings.Contains(string(raw), "demo") || !strings.Contains(string(raw), "DEBUG=1") {
+		t.Error("Expected non-secret values kept in export")
+	}
+
+	// Import into a fresh config
+	os.Remove(ConfigFile)
+	os.Remove(RegFile)
+	result, err := importConfig(bundlePath, false)
+	if err != nil {
+		t.Fatalf("importConfig failed: %v", err)
+	}
+	if strings.Join(result.Added, ",") != "api,local" {
+		t.Errorf("Expected api and local added, got %v", result.Added)
+	}
+	if strings.Join(result.NeedSecrets, ",") != "api,local" {
+		t.Errorf("Expected redacted servers flagged, got %v", result.NeedSecrets)
+	}
+
+	config, _ := LoadConfig()
+	if config.Servers["api"].Headers["X-Project"] != "demo" || len(config.Servers["api"].Aliases) != 1 {
+		t.Errorf("Round-trip lost config: %+v", config.Servers["api"])
+	}
+	regs, _ := LoadRegistrations()
+	if _, ok := regs["api"]; ok {
+		t.Error("Expected redacted registration not to be imported")
+	}
+}
+
+func TestExportImport_IncludeSecrets(t *testing.T) {
+	tmpDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	SaveConfig(&Config{Servers: map[string]ServerConfig{
+		"api": {URL: "https://api.example.com", Headers: map[string]string{"Authorization": "Bearer secret"}},
+	}})
+	SaveTokens(map[string]TokenData{"api": {AccessToken: "tok"}})
+
+	bundlePath := filepath.Join(tmpDir, "bundle.json")
+	if _, err := exportConfig(bundlePath, true); err != nil {
+		t.Fatalf("exportConfig failed: %v", err)
+	}
+
+	os.Remove(ConfigFile)
+	ClearTokens()
+	if _, err := importConfig(bundlePath, false); err != nil {
+		t.Fatalf("importConfig failed: %v", err)
+	}
+
+	config, _ := LoadConfig()
+	if config.Servers["api"].Headers["Authorization"] != "Bearer secret" {
+		t.Error("Expected secrets preserved with --include-secrets")
+	}
+	tokens, _ := LoadTokens()
+	if tokens["api"].AccessToken != "tok" {
+		t.Error("Expected tokens imported")
+	}
+}
+
+func TestImport_Conflicts(t *testing.T) {
+	tmpDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	SaveConfig(&Config{Servers: map[string]ServerConfig{
+		"shared": {URL: "https://new.example.com"},
+		"same":   {URL: "https://same.example.com"},
+		"fresh":  {URL: "https://fresh.example.com"},
+	}})
+	bundlePath := filepath.Join(tmpDir, "bundle.json")
+	if _, err := exportConfig(bundlePath, false); err != nil {
+		t.Fatalf("exportConfig failed: %v", err)
+	}
+
+	SaveConfig(&Config{Servers: map[string]ServerConfig{
+		"shared": {URL: "https://old.example.com"},
+		"same":   {URL: "https://same.example.com"},
+	}})
+
+	_, err := importConfig(bundlePath, false)
+	if errorCodeOf(err, "") != ErrExists || !strings.Contains(err.Error(), "shared") {
+		t.Fatalf("Expected EXISTS conflict naming 'shared', got %v", err)
+	}
+	config, _ := LoadConfig()
+	if _, ok := config.Servers["fresh"]; ok {
+		t.Error("Expected nothing imported when conflicts abort")
+	}
+
+	result, err := importConfig(bundlePath, true)
+	if err != nil {
+		t.Fatalf("importConfig --force failed: %v", err)
+	}
+	if strings.Join(result.Replaced, ",") != "shared" || strings.Join(result.Unchanged, ",") != "same" || strings.Join(result.Added, ",") != "fresh" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	config, _ = LoadConfig()
+	if config.Servers["shared"].URL != "https://new.example.com" {
+		t.Error("Expected --force to overwrite conflicting server")
+	}
+}
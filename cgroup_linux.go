@@ -0,0 +1,156 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	// cgroupRoot is where the cgroup v2 hierarchy is mounted
+	cgroupRoot = "/sys/fs/cgroup"
+	// procSelfCgroup lists the cgroups of the daemon process
+	procSelfCgroup = "/proc/self/cgroup"
+)
+
+// daemonCgroup is the leaf the daemon moves itself into so that its cgroup
+// can hand the memory controller down to the servers' cgroups
+const daemonCgroup = "mcpx-daemon"
+
+// localCgroup is a cgroup v2 group holding one local server's process tree,
+// so that memory_limit_mb caps the tree as a whole rather than each process
+type localCgroup struct {
+	dir string
+}
+
+// newLocalCgroup creates the cgroup for a server with memory_limit_mb set.
+// It returns nil without an error when there is nothing to do: no memory
+// limit, or no cgroup v2 hierarchy. An error means cgroup v2 is there but
+// the daemon can't use it, typically because the memory controller isn't
+// delegated to the daemon's cgroup.
+func newLocalCgroup(name string, cfg LocalConfig) (*localCgroup, error) {
+	if cfg.MemoryLimitMB <= 0 {
+		return nil, nil
+	}
+	base, err := cgroupBase()
+	if err != nil || base == "" {
+		return nil, err
+	}
+	if err := enableMemoryController(base); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(base, "mcpx-"+name)
+	if err := os.Mkdir(dir, 0755); err != nil && !os.IsExist(err) {
+		return nil, err
+	}
+	if err := writeCgroupFile(dir, "memory.max", strconv.Itoa(cfg.MemoryLimitMB<<20)); err != nil {
+		os.Remove(dir)
+		return nil, err
+	}
+	// Without swap accounting the file doesn't exist and there is nothing to cap
+	writeCgroupFile(dir, "memory.swap.max", "0")
+	return &localCgroup{dir: dir}, nil
+}
+
+// add moves pid into the cgroup; processes it starts afterwards stay there
+func (c *localCgroup) add(pid int) error {
+	return writeCgroupFile(c.dir, "cgroup.procs", strconv.Itoa(pid))
+}
+
+// oomKilled reports whether the kernel killed a process in the cgroup for
+// going over memory.max
+func (c *localCgroup) oomKilled() bool {
+	data, err := os.ReadFile(filepath.Join(c.dir, "memory.events"))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if field, count, ok := strings.Cut(line, " "); ok && field == "oom_kill" {
+			n, _ := strconv.Atoi(count)
+			return n > 0
+		}
+	}
+	return false
+}
+
+// remove deletes the cgroup. It fails while processes remain in it, such as
+// children that outlived the server; they keep the limit until they exit.
+func (c *localCgroup) remove() {
+	os.Remove(c.dir)
+}
+
+// cgroupBase returns the cgroup v2 directory servers' cgroups are created
+// in: the daemon's own cgroup, or its parent once the daemon has moved into
+// daemonCgroup. It returns "" when the daemon isn't in a cgroup v2 hierarchy.
+func cgroupBase() (string, error) {
+	data, err := os.ReadFile(procSelfCgroup)
+	if err != nil {
+		return "", nil
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		path, ok := strings.CutPrefix(line, "0::")
+		if !ok {
+			continue
+		}
+		dir := filepath.Join(cgroupRoot, path)
+		if _, err := os.Stat(filepath.Join(dir, "cgroup.controllers")); err != nil {
+			return "", nil
+		}
+		if filepath.Base(dir) == daemonCgroup {
+			dir = filepath.Dir(dir)
+		}
+		return dir, nil
+	}
+	return "", nil
+}
+
+// enableMemoryController lets base's child cgroups use the memory
+// controller. A cgroup that holds processes can't do that ("no internal
+// processes"), so if enabling fails the daemon first moves itself into a
+// daemonCgroup leaf under base and tries again.
+func enableMemoryController(base string) error {
+	if hasCgroupController(base, "cgroup.subtree_control", "memory") {
+		return nil
+	}
+	if !hasCgroupController(base, "cgroup.controllers", "memory") {
+		return errors.New("the memory controller is not delegated to " + base)
+	}
+	if writeCgroupFile(base, "cgroup.subtree_control", "+memory") == nil {
+		return nil
+	}
+
+	leaf := filepath.Join(base, daemonCgroup)
+	if err := os.Mkdir(leaf, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	if err := writeCgroupFile(leaf, "cgroup.procs", strconv.Itoa(os.Getpid())); err != nil {
+		return fmt.Errorf("failed to move the daemon into %s: %w", leaf, err)
+	}
+	return writeCgroupFile(base, "cgroup.subtree_control", "+memory")
+}
+
+// hasCgroupController reports whether the space-separated controller list
+// in dir/file includes controller
+func hasCgroupController(dir, file, controller string) bool {
+	data, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return false
+	}
+	for _, c := range strings.Fields(string(data)) {
+		if c == controller {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCgroupFile writes one value to a cgroup interface file
+func writeCgroupFile(dir, file, value string) error {
+	return os.WriteFile(filepath.Join(dir, file), []byte(value), 0644)
+}
@@ -0,0 +1,84 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeCgroupV2 points the cgroup code at a directory laid out like a cgroup
+// v2 mount, with the daemon in /user.slice/app delegated the given controllers
+func fakeCgroupV2(t *testing.T, controllers string) string {
+	t.Helper()
+	root := t.TempDir()
+	base := filepath.Join(root, "user.slice", "app")
+	os.MkdirAll(base, 0755)
+	os.WriteFile(filepath.Join(base, "cgroup.controllers"), []byte(controllers+"\n"), 0644)
+	os.WriteFile(filepath.Join(base, "cgroup.subtree_control"), []byte("\n"), 0644)
+	self := filepath.Join(root, "self-cgroup")
+	os.WriteFile(self, []byte("0::/user.slice/app\n"), 0644)
+
+	oldRoot, oldSelf := cgroupRoot, procSelfCgroup
+	cgroupRoot, procSelfCgroup = root, self
+	t.Cleanup(func() { cgroupRoot, procSelfCgroup = oldRoot, oldSelf })
+	return base
+}
+
+func TestNewLocalCgroup(t *testing.T) {
+	base := fakeCgroupV2(t, "cpu memory pids")
+
+	cgroup, err := newLocalCgroup("files", LocalConfig{MemoryLimitMB: 64})
+	if err != nil || cgroup == nil {
+		t.Fatalf("newLocalCgroup failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(base, "cgroup.subtree_control")); string(data) != "+memory" {
+		t.Errorf("Expected the memory controller enabled for child cgroups, got %q", data)
+	}
+	if cgroup.dir != filepath.Join(base, "mcpx-files") {
+		t.Errorf("Expected the cgroup under the daemon's, got %s", cgroup.dir)
+	}
+	if data, _ := os.ReadFile(filepath.Join(cgroup.dir, "memory.max")); string(data) != "67108864" {
+		t.Errorf("Expected memory.max of 64 MB, got %q", data)
+	}
+
+	if err := cgroup.add(1234); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(cgroup.dir, "cgroup.procs")); string(data) != "1234" {
+		t.Errorf("Expected the pid written to cgroup.procs, got %q", data)
+	}
+
+	// Without a memory limit there is nothing to create
+	if cgroup, err := newLocalCgroup("other", LocalConfig{CPULimit: 5}); cgroup != nil || err != nil {
+		t.Errorf("Expected no cgroup without memory_limit_mb, got %v, %v", cgroup, err)
+	}
+}
+
+func TestNewLocalCgroup_Unavailable(t *testing.T) {
+	fakeCgroupV2(t, "cpu pids")
+	if _, err := newLocalCgroup("files", LocalConfig{MemoryLimitMB: 64}); err == nil || !strings.Contains(err.Error(), "not delegated") {
+		t.Errorf("Expected an error when the memory controller isn't delegated, got %v", err)
+	}
+
+	// A cgroup v1 host has no "0::" entry and quietly falls back to rlimits
+	os.WriteFile(procSelfCgroup, []byte("4:memory:/docker/abc\n"), 0644)
+	if cgroup, err := newLocalCgroup("files", LocalConfig{MemoryLimitMB: 64}); cgroup != nil || err != nil {
+		t.Errorf("Expected no cgroup and no error without cgroup v2, got %v, %v", cgroup, err)
+	}
+}
+
+func TestLocalProcess_ExitLimitReason_CgroupOOM(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "memory.events"), []byte("low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n"), 0644)
+
+	cmd := exec.Command("/bin/sh", "-c", "kill -KILL $$")
+	cmd.Run()
+	proc := &LocalProcess{Name: "test", Cmd: cmd, Config: LocalConfig{MemoryLimitMB: 64}, cgroup: &localCgroup{dir: dir}}
+	if reason := proc.exitLimitReason(); reason != "killed for exceeding memory_limit_mb (64 MB)" {
+		t.Errorf("Expected a cgroup OOM kill reported without hedging, got %q", reason)
+	}
+}
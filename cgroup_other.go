@@ -0,0 +1,17 @@
+//go:build !linux
+
+package main
+
+// localCgroup is a Linux cgroup; elsewhere memory_limit_mb is only an rlimit
+type localCgroup struct{}
+
+// newLocalCgroup returns nil: cgroups only exist on Linux
+func newLocalCgroup(name string, cfg LocalConfig) (*localCgroup, error) {
+	return nil, nil
+}
+
+func (c *localCgroup) add(pid int) error { return nil }
+
+func (c *localCgroup) oomKilled() bool { return false }
+
+func (c *localCgroup) remove() {}
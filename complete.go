@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Completion reference types defined by the MCP completion/complete method
+const (
+	RefPrompt   = "ref/prompt"
+	RefResource = "ref/resource"
+)
+
+// CompletionRef identifies what an argument belongs to: a prompt (by name)
+// or a resource template (by URI)
+type CompletionRef struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	URI  string `json:"uri,omitempty"`
+}
+
+// parseCompletionRef builds a ref from a CLI argument: anything that looks
+// like a URI (template) is a resource ref, everything else a prompt name
+func parseCompletionRef(s string) CompletionRef {
+	if strings.Contains(s, "://") {
+		return CompletionRef{Type: RefResource, URI: s}
+	}
+	return CompletionRef{Type: RefPrompt, Name: s}
+}
+
+// Complete asks the server to suggest values for argName of ref, given the
+// partial value typed so far. Requires the completions capability.
+func (c *MCPClient) Complete(ref CompletionRef, argName, partial string) ([]string, error) {
+	return c.CompleteContext(context.Background(), ref, argName, partial)
+}
+
+// CompleteContext is Complete, giving up when ctx is done
+func (c *MCPClient) CompleteContext(ctx context.Context, ref CompletionRef, argName, partial string) ([]string, error) {
+	if err := c.InitializeContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := c.requireCapability("completions"); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.requestWithReinit(ctx, "completion/complete", map[string]any{
+		"ref": ref,
+		"argument": map[string]any{
+			"name":  argName,
+			"value": partial,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, fmt.Errorf("completion failed: %s", resp.Error.Message)
+	}
+
+	completion, _ := resp.Result["completion"].(map[string]any)
+	raw, _ := completion["values"].([]any)
+	values := make([]string, 0, len(raw))
+	for _, v := range raw {
+		if s, ok := v.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values, nil
+}
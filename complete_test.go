@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newCompletionServer serves completion/complete, recording the params it
+// received. capabilities is returned from initialize.
+func newCompletionServer(t *testing.T, capabilities map[string]any) (*httptest.Server, *map[string]any) {
+	t.Helper()
+	var received map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ID == "" {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		var result map[string]any
+		switch req.Method {
+		case "initialize":
+			result = map[string]any{"protocolVersion": ProtocolVersion, "capabilities": capabilities}
+		case "completion/complete":
+			received, _ = req.Params.(map[string]any)
+			result = map[string]any{"completion": map[string]any{
+				"values":  []any{"python", "pytorch", "pyside"},
+				"total":   3,
+				"hasMore": false,
+			}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}))
+	t.Cleanup(server.Close)
+	return server, &received
+}
+
+func TestMCPClient_Complete(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server, received := newCompletionServer(t, map[string]any{"completions": map[string]any{}})
+	client, err := NewMCPClient("code", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+
+	values, err := client.Complete(parseCompletionRef("code_review"), "language", "py")
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	if strings.Join(values, ",") != "python,pytorch,pyside" {
+		t.Errorf("Unexpected values: %v", values)
+	}
+
+	ref, _ := (*received)["ref"].(map[string]any)
+	if ref["type"] != RefPrompt || ref["name"] != "code_review" {
+		t.Errorf("Expected prompt ref, got %v", ref)
+	}
+	argument, _ := (*received)["argument"].(map[string]any)
+	if argument["name"] != "language" || argument["value"] != "py" {
+		t.Errorf("Expected argument language=py, got %v", argument)
+	}
+}
+
+func TestMCPClient_Complete_RequiresCapability(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server, received := newCompletionServer(t, map[string]any{"tools": map[string]any{}})
+	client, err := NewMCPClient("code", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+
+	_, err = client.Complete(parseCompletionRef("code_review"), "language", "py")
+	if err == nil || !strings.Contains(err.Error(), "completions") {
+		t.Errorf("Expected missing completions capability error, got %v", err)
+	}
+	if *received != nil {
+		t.Error("Expected no completion/complete request without the capability")
+	}
+}
+
+func TestParseCompletionRef(t *testing.T) {
+	if ref := parseCompletionRef("file:///{path}"); ref.Type != RefResource || ref.URI != "file:///{path}" {
+		t.Errorf("Expected resource ref, got %+v", ref)
+	}
+	if ref := parseCompletionRef("summarize"); ref.Type != RefPrompt || ref.Name != "summarize" {
+		t.Errorf("Expected prompt ref, got %+v", ref)
+	}
+}
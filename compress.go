@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// acceptEncoding is advertised on every request. Setting it ourselves turns
+// off Go's implicit gzip handling, so decodeBody must handle each of these.
+const acceptEncoding = "gzip, deflate, br"
+
+// decodeBody returns a reader that decompresses resp.Body according to its
+// Content-Encoding. Closing it closes the underlying body.
+func decodeBody(resp *http.Response) (io.ReadCloser, error) {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+
+	var reader io.Reader
+	switch encoding {
+	case "", "identity":
+		return resp.Body, nil
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip response: %w", err)
+		}
+		reader = gz
+	case "deflate":
+		reader = newDeflateReader(resp.Body)
+	case "br":
+		reader = brotli.NewReader(resp.Body)
+	default:
+		return nil, fmt.Errorf("unsupported response encoding: %s", encoding)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{reader, resp.Body}, nil
+}
+
+// newDeflateReader reads HTTP "deflate", which should be zlib-wrapped but is
+// sent as raw DEFLATE by some servers. The zlib header is sniffed to decide.
+func newDeflateReader(body io.Reader) io.Reader {
+	buffered := bufio.NewReader(body)
+	header, err := buffered.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		if zr, err := zlib.NewReader(buffered); err == nil {
+			return zr
+		}
+	}
+	return flate.NewReader(buffered)
+}
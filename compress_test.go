@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compressBody encodes data with the named Content-Encoding
+func compressBody(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	default:
+		t.Fatalf("unknown encoding %s", encoding)
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestMCPClient_Request_DecompressesResponses(t *testing.T) {
+	jsonBody := `{"jsonrpc":"2.0","id":"1","result":{"rows":"` + strings.Repeat("x", 4096) + `"}}`
+	sseBody := "event: message\ndata: " + jsonBody + "\n\n"
+
+	tests := []struct {
+		name        string
+		encoding    string // Content-Encoding header
+		compress    string // Encoder used for the body
+		contentType string
+		body        string
+	}{
+		{"gzip json", "gzip", "gzip", "application/json", jsonBody},
+		{"gzip sse", "gzip", "gzip", "text/event-stream", sseBody},
+		{"deflate", "deflate", "deflate", "application/json", jsonBody},
+		{"raw deflate", "deflate", "raw-deflate", "application/json", jsonBody},
+		{"brotli", "br", "br", "application/json", jsonBody},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var acceptEncodingHeader string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				acceptEncodingHeader = r.Header.Get("Accept-Encoding")
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Header().Set("Content-Encoding", tt.encoding)
+				w.Write(compressBody(t, tt.compress, []byte(tt.body)))
+			}))
+			defer server.Close()
+
+			client, err := NewMCPClient("test", ServerConfig{URL: server.URL})
+			if err != nil {
+				t.Fatalf("NewMCPClient failed: %v", err)
+			}
+
+			resp, _, err := client.Request("tools/call", nil)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			if rows, _ := resp.Result["rows"].(string); len(rows) != 4096 {
+				t.Errorf("Expected decoded result, got %d bytes", len(rows))
+			}
+			if acceptEncodingHeader != acceptEncoding {
+				t.Errorf("Expected Accept-Encoding %q, got %q", acceptEncoding, acceptEncodingHeader)
+			}
+		})
+	}
+}
+
+func TestDecodeBody_UnsupportedEncoding(t *testing.T) {
+	resp := &http.Response{
+		Header: http.Header{"Content-Encoding": []string{"zstd"}},
+		Body:   io.NopCloser(strings.NewReader("data")),
+	}
+	if _, err := decodeBody(resp); err == nil {
+		t.Error("Expected error for unsupported encoding")
+	}
+}
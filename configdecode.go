@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// StrictConfigEnv makes unknown fields in config files an error rather than a warning
+const StrictConfigEnv = "MCPX_STRICT_CONFIG"
+
+// warnedUnknownFields avoids repeating the same unknown-field warning
+var warnedUnknownFields sync.Map
+
+// decodeConfig parses a config file's contents, allowing JSONC comments and
+// trailing commas. Type mismatches and syntax
+// errors are reported with the offending field and line. Unknown fields
+// (usually typos like "header" for "headers") are warned about on stderr,
+// or rejected when MCPX_STRICT_CONFIG is set.
+func decodeConfig(path string, data []byte) (*Config, error) {
+	data = stripJSONC(data)
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, describeConfigError(path, data, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var strict Config
+	if err := decoder.Decode(&strict); err != nil {
+		err = describeConfigError(path, data, err)
+		if os.Getenv(StrictConfigEnv) != "" {
+			return nil, err
+		}
+		if _, warned := warnedUnknownFields.LoadOrStore(err.Error(), true); !warned {
+			fmt.Fprintf(os.Stderr, "Warning: %v (set %s=1 to make this an error)\n", err, StrictConfigEnv)
+		}
+	}
+
+	return &config, nil
+}
+
+// stripJSONC blanks out // and /* */ comments and trailing commas so
+// hand-edited JSONC parses as JSON. Removed bytes become spaces (newlines are
+// kept), so offsets and line numbers in later errors still match the file.
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+
+	inString := false
+	lastComma := -1 // Offset of a comma that may turn out to be trailing
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+			lastComma = -1
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			out[i], out[i+1] = ' ', ' '
+			for i += 2; i < len(out); i++ {
+				if out[i] == '*' && i+1 < len(out) && out[i+1] == '/' {
+					out[i], out[i+1] = ' ', ' '
+					i++
+					break
+				}
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+		case c == ',':
+			lastComma = i
+		case c == '}' || c == ']':
+			if lastComma >= 0 {
+				out[lastComma] = ' '
+			}
+			lastComma = -1
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		default:
+			lastComma = -1
+		}
+	}
+	return out
+}
+
+// describeConfigError rewrites a JSON decoding error to name the file, line
+// and field at fault
+func describeConfigError(path string, data []byte, err error) error {
+	name := filepath.Base(path)
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		line, col := lineAndColumn(data, syntaxErr.Offset)
+		return fmt.Errorf("%s line %d, column %d: invalid JSON: %v", name, line, col, syntaxErr)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		line, _ := lineAndColumn(data, typeErr.Offset)
+		return fmt.Errorf("%s line %d: %q must be %s, got %s", name, line, typeErr.Field, jsonKindOf(typeErr.Type), typeErr.Value)
+	}
+
+	// encoding/json has no typed error for unknown fields
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field = strings.Trim(field, `"`)
+		msg := fmt.Sprintf("%s line %d: unknown field %q", name, unknownFieldLine(data, field), field)
+		if suggestion := suggestConfigField(field); suggestion != "" {
+			msg += fmt.Sprintf(" (did you mean %q?)", suggestion)
+		}
+		return errors.New(msg)
+	}
+
+	return fmt.Errorf("%s: %w", name, err)
+}
+
+// lineAndColumn converts a byte offset into a 1-based line and column
+func lineAndColumn(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// unknownFieldLine finds the line of the first "field": key in data
+func unknownFieldLine(data []byte, field string) int {
+	key := []byte(`"` + field + `"`)
+	for offset := 0; ; {
+		i := bytes.Index(data[offset:], key)
+		if i < 0 {
+			return 0
+		}
+		end := offset + i + len(key)
+		if rest := bytes.TrimLeft(data[end:], " \t\r\n"); len(rest) > 0 && rest[0] == ':' {
+			line, _ := lineAndColumn(data, int64(offset+i))
+			return line
+		}
+		offset = end
+	}
+}
+
+// jsonKindOf names the JSON kind a Go type decodes from
+func jsonKindOf(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Pointer:
+		return jsonKindOf(t.Elem())
+	}
+	return t.String()
+}
+
+// suggestConfigField returns the known config field closest to a misspelled
+// one, or "" if nothing is close
+func suggestConfigField(field string) string {
+	best, bestDistance := "", 3
+	for _, known := range configFieldNames() {
+		if d := editDistance(strings.ToLower(field), known); d < bestDistance {
+			best, bestDistance = known, d
+		}
+	}
+	return best
+}
+
+// configFieldNames lists the JSON field names used anywhere in the config
+func configFieldNames() []string {
+	var names []string
+	seen := make(map[reflect.Type]bool)
+	var walk func(reflect.Type)
+	walk = func(t reflect.Type) {
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || seen[t] {
+			return
+		}
+		seen[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			names = append(names, name)
+			walk(f.Type)
+		}
+	}
+	walk(reflect.TypeOf(Config{}))
+	return names
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_UnknownField(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	data := `{
+  "servers": {
+    "api": {
+      "url": "https://example.com/mcp",
+      "header": {"Authorization": "Bearer x"}
+    }
+  }
+}`
+	if err := os.WriteFile(ConfigFile, []byte(data), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	// Lenient by default: the config still loads
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected lenient load, got %v", err)
+	}
+	if config.Servers["api"].URL != "https://example.com/mcp" {
+		t.Errorf("Unexpected config: %+v", config.Servers)
+	}
+
+	t.Setenv(StrictConfigEnv, "1")
+	_, err = LoadConfig()
+	if err == nil {
+		t.Fatal("Expected strict mode to reject unknown field")
+	}
+	msg := err.Error()
+	for _, want := range []string{"servers.json line 5", `unknown field "header"`, `did you mean "headers"?`} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected %q in error, got: %s", want, msg)
+		}
+	}
+}
+
+func TestLoadConfig_WrongType(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	data := `{
+  "servers": {
+    "api": {
+      "url": "https://example.com/mcp",
+      "headers": ["Authorization: Bearer x"]
+    }
+  }
+}`
+	if err := os.WriteFile(ConfigFile, []byte(data), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	_, err := LoadConfig()
+	if err == nil {
+		t.Fatal("Expected error for headers given as an array")
+	}
+	msg := err.Error()
+	for _, want := range []string{"line 5", `"servers.api.headers" must be an object, got array`} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Expected %q in error, got: %s", want, msg)
+		}
+	}
+}
+
+func TestLoadConfig_SyntaxError(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	data := "{\n  \"servers\": {\n    \"api\": {\"url\" \"x\"}\n  }\n}"
+	if err := os.WriteFile(ConfigFile, []byte(data), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	_, err := LoadConfig()
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("Expected syntax error on line 3, got %v", err)
+	}
+}
+
+func TestLoadConfig_JSONC(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	data := `{
+  // Servers I use day to day
+  "servers": {
+    "api": {
+      "url": "https://example.com/mcp", // trailing comment
+      "headers": {"X-Path": "/* not a comment */ // nor this"},
+    },
+    /* "old": {"url": "https://old.example.com"}, */
+  },
+}`
+	if err := os.WriteFile(ConfigFile, []byte(data), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(config.Servers) != 1 {
+		t.Fatalf("Expected 1 server, got %+v", config.Servers)
+	}
+	api := config.Servers["api"]
+	if api.URL != "https://example.com/mcp" {
+		t.Errorf("Expected URL preserved, got %q", api.URL)
+	}
+	if got := api.Headers["X-Path"]; got != "/* not a comment */ // nor this" {
+		t.Errorf("Expected comment markers inside strings preserved, got %q", got)
+	}
+
+	// Saving writes plain JSON
+	if err := SaveConfig(config); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	saved, _ := os.ReadFile(ConfigFile)
+	if strings.Contains(string(saved), "// Servers") || strings.Contains(string(saved), ",\n  }") {
+		t.Errorf("Expected plain JSON on save, got %s", saved)
+	}
+}
+
+func TestLoadConfig_PlainJSON(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	data := `{"servers": {"api": {"url": "https://example.com/mcp?a=1", "headers": {"Authorization": "Bearer x"}}}}`
+	if err := os.WriteFile(ConfigFile, []byte(data), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if config.Servers["api"].URL != "https://example.com/mcp?a=1" {
+		t.Errorf("Unexpected config: %+v", config.Servers)
+	}
+}
+
+func TestStripJSONC_KeepsLineNumbers(t *testing.T) {
+	data := "{\n  /* one\n  two */\n  \"a\": 1,\n}"
+	out := stripJSONC([]byte(data))
+	if len(out) != len(data) {
+		t.Fatalf("Expected length %d, got %d", len(data), len(out))
+	}
+	if strings.Count(string(out), "\n") != strings.Count(data, "\n") {
+		t.Errorf("Expected newlines preserved, got %q", out)
+	}
+	if strings.Contains(string(out), ",") {
+		t.Errorf("Expected trailing comma removed, got %q", out)
+	}
+}
+
+func TestSuggestConfigField(t *testing.T) {
+	tests := map[string]string{
+		"header":       "headers",
+		"sesion_based": "session_based",
+		"completely":   "",
+	}
+	for field, want := range tests {
+		if got := suggestConfigField(field); got != want {
+			t.Errorf("suggestConfigField(%q) = %q, want %q", field, got, want)
+		}
+	}
+}
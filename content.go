@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Output formats for tool call results (--format)
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// SavedBlob records a binary content block written to disk by --save-blobs
+type SavedBlob struct {
+	Index    int    `json:"index"` // Position in the result's content array
+	Path     string `json:"path"`
+	MimeType string `json:"mime_type,omitempty"`
+	Bytes    int    `json:"bytes"`
+}
+
+// contentBlocks returns the content array of a tools/call result
+func contentBlocks(result map[string]any) []map[string]any {
+	raw, _ := result["content"].([]any)
+	blocks := make([]map[string]any, 0, len(raw))
+	for _, item := range raw {
+		if block, ok := item.(map[string]any); ok {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// blobData returns the base64 payload and mime type of an image, audio or
+// embedded blob resource block
+func blobData(block map[string]any) (data, mimeType string, ok bool) {
+	switch block["type"] {
+	case "image", "audio":
+		data, _ = block["data"].(string)
+		mimeType, _ = block["mimeType"].(string)
+	case "resource":
+		resource, _ := block["resource"].(map[string]any)
+		data, _ = resource["blob"].(string)
+		mimeType, _ = resource["mimeType"].(string)
+	}
+	return data, mimeType, data != ""
+}
+
+// formatResultText renders a tool result for --format text. Text blocks are
+// printed as-is; binary blocks become a one-line note with their mime type
+// and decoded size (and saved path, if any). Results without content blocks
+// fall back to indented JSON.
+func formatResultText(result map[string]any, saved []SavedBlob) string {
+	blocks := contentBlocks(result)
+	if len(blocks) == 0 {
+		out, _ := json.MarshalIndent(result, "", "  ")
+		return string(out)
+	}
+
+	paths := make(map[int]string, len(saved))
+	for _, s := range saved {
+		paths[s.Index] = s.Path
+	}
+
+	lines := make([]string, 0, len(blocks))
+	for i, block := range blocks {
+		if data, mimeType, ok := blobData(block); ok {
+			if mimeType == "" {
+				mimeType = "application/octet-stream"
+			}
+			note := fmt.Sprintf("[%s %s, %d bytes", block["type"], mimeType, decodedLen(data))
+			if path, ok := paths[i]; ok {
+				note += ", saved to " + path
+			}
+			lines = append(lines, note+"]")
+			continue
+		}
+
+		switch block["type"] {
+		case "text":
+			text, _ := block["text"].(string)
+			lines = append(lines, text)
+		case "resource":
+			resource, _ := block["resource"].(map[string]any)
+			if text, ok := resource["text"].(string); ok {
+				lines = append(lines, text)
+				continue
+			}
+			uri, _ := resource["uri"].(string)
+			lines = append(lines, fmt.Sprintf("[resource %s]", uri))
+		case "resource_link":
+			uri, _ := block["uri"].(string)
+			lines = append(lines, fmt.Sprintf("[resource_link %s]", uri))
+		default:
+			out, _ := json.Marshal(block)
+			lines = append(lines, string(out))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// decodedLen returns the size of base64 data once decoded
+func decodedLen(data string) int {
+	if decoded, err := base64.StdEncoding.DecodeString(data); err == nil {
+		return len(decoded)
+	}
+	return base64.StdEncoding.DecodedLen(len(data))
+}
+
+// saveBlobs decodes every binary content block in result and writes it to a
+// new file in dir named after the tool, with an extension from its mime type
+func saveBlobs(result map[string]any, dir, toolName string) ([]SavedBlob, error) {
+	var saved []SavedBlob
+	for i, block := range contentBlocks(result) {
+		data, mimeType, ok := blobData(block)
+		if !ok {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return saved, fmt.Errorf("content block %d is not valid base64: %w", i, err)
+		}
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return saved, err
+		}
+		prefix := strings.ReplaceAll(toolName, string(os.PathSeparator), "_")
+		f, err := os.CreateTemp(dir, fmt.Sprintf("%s-%d-*%s", prefix, i, blobExtension(mimeType)))
+		if err != nil {
+			return saved, err
+		}
+		_, err = f.Write(decoded)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return saved, fmt.Errorf("failed to write %s: %w", f.Name(), err)
+		}
+
+		saved = append(saved, SavedBlob{Index: i, Path: f.Name(), MimeType: mimeType, Bytes: len(decoded)})
+	}
+	return saved, nil
+}
+
+// blobExtension picks a file extension for a mime type (".bin" if unknown)
+func blobExtension(mimeType string) string {
+	switch mimeType {
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	}
+	if exts, err := mime.ExtensionsByType(mimeType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
+
+// truncateResult caps a tool result's serialized size at maxBytes (0 means
+// no limit). Content blocks are kept in order until the limit is reached:
+// the text block that crosses it is cut short and the blocks after it are
+// dropped, as is structuredContent, which can't be cut meaningfully. A
+// truncated result is marked with "truncated": true.
+func truncateResult(result map[string]any, maxBytes int) map[string]any {
+	if maxBytes <= 0 || result == nil || jsonSize(result) <= maxBytes {
+		return result
+	}
+
+	out := make(map[string]any, len(result)+1)
+	for k, v := range result {
+		if k != "content" && k != "structuredContent" {
+			out[k] = v
+		}
+	}
+	out["truncated"] = true
+	out["content"] = []any{}
+	budget := maxBytes - jsonSize(out)
+
+	var kept []any
+	for _, block := range contentBlocks(result) {
+		size := jsonSize(block)
+		if len(kept) > 0 {
+			size++ // separating comma
+		}
+		if size <= budget {
+			kept = append(kept, block)
+			budget -= size
+			continue
+		}
+		if text, ok := block["text"].(string); ok && block["type"] == "text" {
+			if cut := truncateText(block, text, budget-(size-jsonSize(block))); cut != nil {
+				kept = append(kept, cut)
+			}
+		}
+		break
+	}
+	if kept != nil {
+		out["content"] = kept
+	}
+	return out
+}
+
+// truncateText returns a copy of a text block with its text cut so the block
+// serializes to at most budget bytes, or nil if even an empty text won't fit
+func truncateText(block map[string]any, text string, budget int) map[string]any {
+	cut := make(map[string]any, len(block))
+	for k, v := range block {
+		cut[k] = v
+	}
+	cut["text"] = ""
+	// Escaped characters (<, &, control characters) make the text longer as
+	// JSON than in bytes, so the first guess can exceed the text itself
+	keep := min(budget-jsonSize(cut), len(text))
+	for keep > 0 {
+		// Back up to a rune boundary so the text stays valid UTF-8
+		for keep > 0 && keep < len(text) && !utf8.RuneStart(text[keep]) {
+			keep--
+		}
+		cut["text"] = text[:keep]
+		over := jsonSize(cut) - budget
+		if over <= 0 {
+			return cut
+		}
+		keep -= over // escaping made it longer than its byte count
+	}
+	cut["text"] = ""
+	if jsonSize(cut) > budget {
+		return nil
+	}
+	return cut
+}
+
+// jsonSize returns the length of v encoded as JSON
+func jsonSize(v any) int {
+	out, _ := json.Marshal(v)
+	return len(out)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// imageResult is a tools/call result with a text block and a base64 PNG
+func imageResult(png []byte) map[string]any {
+	return map[string]any{
+		"content": []any{
+			map[string]any{"type": "text", "text": "Here is the chart"},
+			map[string]any{"type": "image", "mimeType": "image/png", "data": base64.StdEncoding.EncodeToString(png)},
+		},
+	}
+}
+
+func TestFormatResultText_ImageBlock(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\nfake-image-bytes")
+
+	text := formatResultText(imageResult(png), nil)
+
+	lines := strings.Split(text, "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %q", text)
+	}
+	if lines[0] != "Here is the chart" {
+		t.Errorf("Expected text block verbatim, got %q", lines[0])
+	}
+	want := "[image image/png, 24 bytes]"
+	if lines[1] != want {
+		t.Errorf("Expected %q, got %q", want, lines[1])
+	}
+}
+
+func TestFormatResultText_NoContent(t *testing.T) {
+	text := formatResultText(map[string]any{"structuredContent": map[string]any{"n": 1}}, nil)
+	if !strings.Contains(text, `"structuredContent"`) {
+		t.Errorf("Expected JSON fallback, got %q", text)
+	}
+}
+
+func TestSaveBlobs(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "blobs")
+	png := []byte("\x89PNG\r\n\x1a\nfake-image-bytes")
+	result := imageResult(png)
+
+	saved, err := saveBlobs(result, dir, "render_chart")
+	if err != nil {
+		t.Fatalf("saveBlobs failed: %v", err)
+	}
+	if len(saved) != 1 {
+		t.Fatalf("Expected 1 saved blob, got %+v", saved)
+	}
+
+	blob := saved[0]
+	if blob.Index != 1 || blob.MimeType != "image/png" || blob.Bytes != len(png) {
+		t.Errorf("Unexpected saved blob: %+v", blob)
+	}
+	if filepath.Ext(blob.Path) != ".png" || !strings.HasPrefix(filepath.Base(blob.Path), "render_chart-1-") {
+		t.Errorf("Unexpected blob path %s", blob.Path)
+	}
+	data, err := os.ReadFile(blob.Path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(data) != string(png) {
+		t.Errorf("Expected decoded image bytes, got %q", data)
+	}
+
+	// The text note points at the saved file
+	if text := formatResultText(result, saved); !strings.Contains(text, "saved to "+blob.Path) {
+		t.Errorf("Expected saved path in text output, got %q", text)
+	}
+}
+
+func TestSaveBlobs_EmbeddedResource(t *testing.T) {
+	result := map[string]any{
+		"content": []any{
+			map[string]any{"type": "resource", "resource": map[string]any{
+				"uri":      "file:///report.pdf",
+				"mimeType": "application/pdf",
+				"blob":     base64.StdEncoding.EncodeToString([]byte("%PDF-1.7")),
+			}},
+		},
+	}
+
+	saved, err := saveBlobs(result, t.TempDir(), "export")
+	if err != nil {
+		t.Fatalf("saveBlobs failed: %v", err)
+	}
+	if len(saved) != 1 || filepath.Ext(saved[0].Path) != ".pdf" {
+		t.Errorf("Expected one .pdf blob, got %+v", saved)
+	}
+}
+
+func TestTruncateResult_OverLimit(t *testing.T) {
+	result := map[string]any{
+		"content": []any{
+			map[string]any{"type": "text", "text": "short header"},
+			map[string]any{"type": "text", "text": strings.Repeat("row ", 5000)},
+			map[string]any{"type": "text", "text": "footer"},
+		},
+		"structuredContent": map[string]any{"rows": 5000},
+	}
+
+	truncated := truncateResult(result, 1024)
+
+	if truncated["truncated"] != true {
+		t.Fatalf("Expected truncated marker, got %v", truncated["truncated"])
+	}
+	if size := jsonSize(truncated); size > 1024 {
+		t.Errorf("Expected at most 1024 bytes, got %d", size)
+	}
+	if _, ok := truncated["structuredContent"]; ok {
+		t.Error("Expected structuredContent to be dropped")
+	}
+	blocks := contentBlocks(truncated)
+	if len(blocks) != 2 {
+		t.Fatalf("Expected the header and a cut second block, got %d blocks", len(blocks))
+	}
+	if blocks[0]["text"] != "short header" {
+		t.Errorf("Expected the first block intact, got %v", blocks[0]["text"])
+	}
+	text, _ := blocks[1]["text"].(string)
+	if text == "" || !strings.HasPrefix(strings.Repeat("row ", 5000), text) || len(text) >= 5000*4 {
+		t.Errorf("Expected a non-empty prefix of the long text, got %d bytes", len(text))
+	}
+
+	// The original result is left alone
+	if len(contentBlocks(result)) != 3 {
+		t.Error("Expected the original result to be unchanged")
+	}
+}
+
+func TestTruncateResult_EscapedAndMultiByteText(t *testing.T) {
+	for _, text := range []string{
+		strings.Repeat("<", 100),
+		strings.Repeat("a&b<c>", 40),
+		strings.Repeat("é日本\x01", 30),
+		strings.Repeat("<é\n\t\"&", 25),
+	} {
+		result := map[string]any{"content": []any{map[string]any{"type": "text", "text": text}}}
+		for limit := 1; limit <= jsonSize(result)+1; limit++ {
+			truncated := truncateResult(result, limit)
+			blocks := contentBlocks(truncated)
+			if len(blocks) == 0 {
+				continue
+			}
+			cut, _ := blocks[0]["text"].(string)
+			if !strings.HasPrefix(text, cut) || !utf8.ValidString(cut) {
+				t.Fatalf("limit %d: expected a valid UTF-8 prefix of %q, got %q", limit, text, cut)
+			}
+			if size := jsonSize(truncated); size > limit {
+				t.Fatalf("limit %d: expected at most %d bytes, got %d", limit, limit, size)
+			}
+		}
+	}
+}
+
+func TestTruncateResult_UnderLimit(t *testing.T) {
+	result := map[string]any{"content": []any{map[string]any{"type": "text", "text": "small"}}}
+
+	for _, limit := range []int{0, 1024} {
+		if got := truncateResult(result, limit); got["truncated"] != nil || !reflect.DeepEqual(got, result) {
+			t.Errorf("limit %d: expected the result unchanged, got %v", limit, got)
+		}
+	}
+}
@@ -2,39 +2,71 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
+	"os/exec"
 	"os/signal"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // DaemonCommand represents a command sent to the daemon
 type DaemonCommand struct {
-	Action    string         `json:"action"`
-	Server    string         `json:"server,omitempty"`
-	Tool      string         `json:"tool,omitempty"`
-	Arguments map[string]any `json:"arguments,omitempty"`
+	Action     string         `json:"action"`
+	Server     string         `json:"server,omitempty"`
+	Tool       string         `json:"tool,omitempty"`
+	Arguments  map[string]any `json:"arguments,omitempty"`
+	NoValidate bool           `json:"no_validate,omitempty"` // Skip inputSchema checks for "call"
+	Meta       map[string]any `json:"meta,omitempty"`        // Sent as the "call" request's _meta (e.g. progressToken)
+	MaxBytes   int            `json:"max_bytes,omitempty"`   // Truncate the "call" result beyond this size (overrides max_response_bytes)
+	Query      string         `json:"query,omitempty"`       // Keyword for "find"
+	URI        string         `json:"uri,omitempty"`         // Resource URI for "subscribe"
+	Level      string         `json:"level,omitempty"`       // Server log level for "set-log-level"
 }
 
 // CachedTools holds cached tool information
 type CachedTools struct {
-	Tools   []Tool
-	Expires time.Time
+	Tools   []Tool    `json:"tools"`
+	Expires time.Time `json:"expires"`
+	URL     string    `json:"url"` // Server URL the tools were listed from
 }
 
 // MCPDaemon is the daemon server
 type MCPDaemon struct {
 	config       *Config
 	clients      map[string]*MCPClient
+	tokenExpiry  map[string]float64   // OAuth token expiry per client, for proactive refresh
+	tokenRetry   map[string]time.Time // Earliest retry of a proactive refresh, per client
 	toolsCache   map[string]*CachedTools
+	breakers     map[string]*CircuitBreaker // Per-server circuit breakers, by canonical name
+	health       map[string]*serverHealth   // Outcome of the last request to each server
+	subscribers  map[string]int             // Streaming "subscribe" connections per server+uri
+	subMu        sync.Mutex                 // Guards subscribers; held across subscribe requests
 	localManager *LocalManager
 	mu           sync.RWMutex
-	running      bool
+	readOnly     bool // Global read-only mode: reject all tool calls
+	maxConns     int  // Connections handled at once; more are rejected as busy
 	listener     net.Listener
+	conns        sync.WaitGroup // Connections being handled, drained on shutdown
+	cacheMu      sync.Mutex     // Serializes writes of ToolsCacheFile
+	flights      flightGroup    // Coalesces identical in-flight tools/list and idempotent tool calls
+
+	// Cancelled when shutdown starts, to stop accepting connections and end
+	// subscription streams
+	stopping context.Context
+	stop     context.CancelFunc
 }
 
 // NewMCPDaemon creates a new daemon instance
@@ -44,43 +76,114 @@ func NewMCPDaemon() (*MCPDaemon, error) {
 		return nil, err
 	}
 
+	stopping, stop := context.WithCancel(context.Background())
 	return &MCPDaemon{
 		config:       config,
 		clients:      make(map[string]*MCPClient),
-		toolsCache:   make(map[string]*CachedTools),
+		tokenExpiry:  make(map[string]float64),
+		tokenRetry:   make(map[string]time.Time),
+		toolsCache:   loadToolsCache(config),
+		breakers:     make(map[string]*CircuitBreaker),
+		health:       make(map[string]*serverHealth),
+		subscribers:  make(map[string]int),
 		localManager: NewLocalManager(),
-		running:      true,
+		maxConns:     DefaultMaxConnections,
+		stopping:     stopping,
+		stop:         stop,
 	}, nil
 }
 
 // getClient gets or creates a persistent MCP client for a server
 func (d *MCPDaemon) getClient(serverName string) (*MCPClient, error) {
+	return d.getClientContext(context.Background(), serverName)
+}
+
+// getClientContext is getClient for a request, whose ID ctx carries into the
+// log. Token lookups and refreshes happen outside d.mu, since a refresh is an
+// HTTP request and would otherwise stall every other server.
+func (d *MCPDaemon) getClientContext(ctx context.Context, serverName string) (*MCPClient, error) {
 	d.mu.Lock()
-	defer d.mu.Unlock()
+	canonical, serverConfig, ok := d.config.Lookup(serverName)
+	if !ok {
+		d.mu.Unlock()
+		return nil, fmt.Errorf("server '%s' not configured", serverName)
+	}
+	serverName = canonical
 
 	if client, ok := d.clients[serverName]; ok {
+		// Refresh before the token goes stale in a long-lived daemon. Claim
+		// the refresh by pushing back the retry time, so concurrent requests
+		// don't all refresh, and a failed refresh is retried after a backoff.
+		refresh := tokenExpiring(d.tokenExpiry[serverName]) && !time.Now().Before(d.tokenRetry[serverName])
+		if refresh {
+			d.tokenRetry[serverName] = time.Now().Add(tokenRefreshBackoff)
+		}
+		d.mu.Unlock()
+
+		if refresh {
+			d.refreshToken(ctx, serverName, serverConfig, client)
+		}
 		return client, nil
 	}
+	d.mu.Unlock()
 
-	serverConfig, ok := d.config.Servers[serverName]
-	if !ok {
-		return nil, fmt.Errorf("server '%s' not configured", serverName)
+	client, err := NewMCPClient(serverName, serverConfig)
+	if err != nil {
+		return nil, err
 	}
-
-	client := NewMCPClient(serverName, serverConfig)
-
-	// Get OAuth token if available
-	token, _ := GetTokenForServer(serverName, serverConfig)
-	if token != "" {
-		client.SetOAuthToken(token)
+	tokenData, _ := GetTokenDataForServer(serverName, serverConfig)
+	if tokenData.AccessToken != "" {
+		client.SetOAuthToken(tokenData.AccessToken)
 	}
 
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if existing, ok := d.clients[serverName]; ok {
+		// Another request created the client while this one fetched a token
+		client.Close()
+		return existing, nil
+	}
 	d.clients[serverName] = client
+	d.tokenExpiry[serverName] = tokenData.ExpiresAt
 	return client, nil
 }
 
+// tokenRefreshBackoff is how long the daemon waits before retrying a
+// proactive token refresh that failed; the old token is used meanwhile
+const tokenRefreshBackoff = 30 * time.Second
+
+// refreshToken replaces the client's OAuth token with a refreshed one and
+// records its expiry. On failure the previous token and expiry are kept, so
+// the refresh is retried once the backoff set by getClient has passed.
+func (d *MCPDaemon) refreshToken(ctx context.Context, serverName string, serverConfig ServerConfig, client *MCPClient) {
+	tokenData, _ := GetTokenDataForServer(serverName, serverConfig)
+	if tokenData.AccessToken == "" {
+		logger.Warn("proactive token refresh failed; will retry", requestFields(ctx, "server", serverName, "retry_in", tokenRefreshBackoff)...)
+		return
+	}
+	client.SetOAuthToken(tokenData.AccessToken)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.tokenExpiry[serverName] = tokenData.ExpiresAt
+	delete(d.tokenRetry, serverName)
+}
+
+// resolveServer maps a server name or alias to its canonical name
+func (d *MCPDaemon) resolveServer(serverName string) string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if canonical, _, ok := d.config.Lookup(serverName); ok {
+		return canonical
+	}
+	return serverName
+}
+
 // getTools gets tools for a server with caching
-func (d *MCPDaemon) getTools(serverName string) ([]Tool, error) {
+func (d *MCPDaemon) getTools(ctx context.Context, serverName string) ([]Tool, error) {
+	serverName = d.resolveServer(serverName)
+
 	d.mu.RLock()
 	if cached, ok := d.toolsCache[serverName]; ok {
 		if time.Now().Before(cached.Expires) {
@@ -90,34 +193,202 @@ func (d *MCPDaemon) getTools(serverName string) ([]Tool, error) {
 	}
 	d.mu.RUnlock()
 
-	client, err := d.getClient(serverName)
+	// Concurrent misses for the same server share one tools/list request
+	value, _, err := d.flights.Do(ctx, flightKey(serverName, "tools/list", nil), func(ctx context.Context) (any, error) {
+		client, err := d.getClientContext(ctx, serverName)
+		if err != nil {
+			return nil, err
+		}
+
+		var tools []Tool
+		err = d.withBreaker(serverName, func() error {
+			tools, err = client.ListToolsContext(ctx)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		d.mu.Lock()
+		d.toolsCache[serverName] = &CachedTools{
+			Tools:   tools,
+			Expires: time.Now().Add(ToolsCacheTTL),
+			URL:     d.config.Servers[serverName].URL,
+		}
+		d.mu.Unlock()
+		d.saveToolsCache()
+		return tools, nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return value.([]Tool), nil
+}
+
+// findTools searches every configured server's tools, using cached lists and
+// fetching where absent. Unreachable servers are skipped with a warning.
+func (d *MCPDaemon) findTools(ctx context.Context, keyword string) ([]ToolMatch, []ServerWarning) {
+	d.mu.RLock()
+	names := make([]string, 0, len(d.config.Servers))
+	for name := range d.config.Servers {
+		names = append(names, name)
+	}
+	d.mu.RUnlock()
+
+	toolsByServer, warnings := fetchAllTools(names, findConcurrency, func(name string) ([]Tool, error) {
+		return d.getTools(ctx, name)
+	})
+	return searchTools(keyword, toolsByServer), warnings
+}
+
+// isReadOnly reports whether tool calls are blocked for a server
+func (d *MCPDaemon) isReadOnly(serverName string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.readOnly {
+		return true
+	}
+	_, serverConfig, ok := d.config.Lookup(serverName)
+	return ok && serverConfig.ReadOnly
+}
+
+// responseLimit returns the byte cap for a server's tool results, preferring
+// the caller's limit over the server's max_response_bytes
+func (d *MCPDaemon) responseLimit(serverName string, limit int) int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	_, serverConfig, _ := d.config.Lookup(serverName)
+	return serverConfig.responseLimit(limit)
+}
 
-	tools, err := client.ListTools()
+// prepareArguments applies the server's default_args and pre_call_command to
+// a call's arguments
+func (d *MCPDaemon) prepareArguments(ctx context.Context, serverName, toolName string, arguments map[string]any) (map[string]any, error) {
+	client, err := d.getClientContext(ctx, serverName)
 	if err != nil {
 		return nil, err
 	}
+	return client.PrepareArguments(ctx, toolName, arguments)
+}
 
-	d.mu.Lock()
-	d.toolsCache[serverName] = &CachedTools{
-		Tools:   tools,
-		Expires: time.Now().Add(ToolsCacheTTL),
+// callTool calls a tool on a server with arguments from prepareArguments;
+// cancelling ctx cancels the call on the server. On servers with
+// coalesce_calls, identical concurrent calls to a tool annotated read-only or
+// idempotent share one request.
+func (d *MCPDaemon) callTool(ctx context.Context, serverName, toolName string, arguments, requestMeta map[string]any) (map[string]any, CallMetadata, error) {
+	if !d.coalesceCall(ctx, serverName, toolName) {
+		return d.callToolOnce(ctx, serverName, toolName, arguments, requestMeta)
 	}
-	d.mu.Unlock()
 
-	return tools, nil
+	type callResult struct {
+		result map[string]any
+		meta   CallMetadata
+	}
+	key := flightKey(d.resolveServer(serverName), "tools/call", []any{toolName, arguments, requestMeta})
+	value, shared, err := d.flights.Do(ctx, key, func(ctx context.Context) (any, error) {
+		result, meta, err := d.callToolOnce(ctx, serverName, toolName, arguments, requestMeta)
+		return callResult{result, meta}, err
+	})
+	if shared {
+		logger.Debug("coalesced tool call", requestFields(ctx, "server", serverName, "tool", toolName)...)
+	}
+	r, _ := value.(callResult)
+	return r.result, r.meta, err
 }
 
-// callTool calls a tool on a server
-func (d *MCPDaemon) callTool(serverName, toolName string, arguments map[string]any) (map[string]any, error) {
-	client, err := d.getClient(serverName)
+// coalesceCall reports whether calls to a tool may be shared: the server
+// opts in with coalesce_calls and the tool says repeating it is harmless
+func (d *MCPDaemon) coalesceCall(ctx context.Context, serverName, toolName string) bool {
+	d.mu.RLock()
+	_, serverConfig, _ := d.config.Lookup(serverName)
+	d.mu.RUnlock()
+	if !serverConfig.CoalesceCalls {
+		return false
+	}
+
+	tools, err := d.getTools(ctx, serverName)
 	if err != nil {
-		return nil, err
+		return false
 	}
+	return idempotentTool(findTool(tools, toolName))
+}
 
-	return client.CallTool(toolName, arguments)
+// callToolOnce sends one tools/call request through the server's breaker.
+// The arguments come from prepareArguments.
+func (d *MCPDaemon) callToolOnce(ctx context.Context, serverName, toolName string, arguments, requestMeta map[string]any) (map[string]any, CallMetadata, error) {
+	client, err := d.getClientContext(ctx, serverName)
+	if err != nil {
+		return nil, CallMetadata{}, err
+	}
+
+	var result map[string]any
+	var meta CallMetadata
+	err = d.withBreaker(d.resolveServer(serverName), func() error {
+		result, meta, err = client.CallPreparedTool(ctx, toolName, arguments, requestMeta)
+		return err
+	})
+	return result, meta, err
+}
+
+// breakerFor returns the server's circuit breaker, creating it on first use
+func (d *MCPDaemon) breakerFor(serverName string) *CircuitBreaker {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if b, ok := d.breakers[serverName]; ok {
+		return b
+	}
+	b := breakerForServer(d.config.Servers[serverName])
+	d.breakers[serverName] = b
+	return b
+}
+
+// withBreaker runs fn unless the server's circuit is open, recording the
+// outcome. Only server-side failures count; request errors (bad arguments,
+// unknown tools) still prove the server is up.
+func (d *MCPDaemon) withBreaker(serverName string, fn func() error) error {
+	b := d.breakerFor(serverName)
+	if err := b.Allow(); err != nil {
+		return err
+	}
+
+	err := fn()
+	if isServerFailure(err) {
+		b.RecordFailure()
+	} else {
+		b.RecordSuccess()
+	}
+	d.recordHealth(serverName, err)
+	return err
+}
+
+// breakerStatus reports the state of every breaker that has seen traffic
+func (d *MCPDaemon) breakerStatus() map[string]BreakerStatus {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	status := make(map[string]BreakerStatus, len(d.breakers))
+	for name, b := range d.breakers {
+		status[name] = b.Status()
+	}
+	return status
+}
+
+// validateCall checks prepared arguments against the tool's cached
+// inputSchema. If tools can't be listed, validation is skipped and the call
+// reports the error.
+func (d *MCPDaemon) validateCall(ctx context.Context, serverName, toolName string, arguments map[string]any) error {
+	tools, err := d.getTools(ctx, serverName)
+	if err != nil {
+		return nil
+	}
+	tool := findTool(tools, toolName)
+	if tool == nil {
+		return codedErrorf(ErrUnknownTool, "Tool '%s' not found on '%s'", toolName, serverName)
+	}
+	return validateArguments(tool.Parameters, arguments)
 }
 
 // reloadConfig reloads the configuration
@@ -127,37 +398,77 @@ func (d *MCPDaemon) reloadConfig() error {
 		return err
 	}
 
+	defer d.saveToolsCache() // After unlocking
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
 	oldConfig := d.config
 	d.config = config
+	pruneToolsCache(d.toolsCache, config)
 
-	// Handle client updates based on config changes
-	for name, client := range d.clients {
-		newServerConfig, exists := d.config.Servers[name]
-		if !exists {
-			// Server was removed - close and delete client
-			client.Close()
-			delete(d.clients, name)
-			delete(d.toolsCache, name)
+	// Any change to a server (URL, headers, TLS, tool lists, default_headers,
+	// ...) drops its client and cached tools; both are rebuilt from the new
+	// config on the next request
+	for name, oldServerConfig := range oldConfig.Servers {
+		if newServerConfig, exists := config.Servers[name]; exists && reflect.DeepEqual(oldServerConfig, newServerConfig) {
 			continue
 		}
-
-		// Check if server config changed significantly (URL or persistent mode)
-		oldServerConfig := oldConfig.Servers[name]
-		if oldServerConfig.URL != newServerConfig.URL ||
-			oldServerConfig.SessionBased != newServerConfig.SessionBased {
-			// Config changed - close old client, will be recreated on next request
+		if client, ok := d.clients[name]; ok {
 			client.Close()
 			delete(d.clients, name)
-			delete(d.toolsCache, name)
 		}
+		delete(d.tokenExpiry, name)
+		delete(d.tokenRetry, name)
+		delete(d.toolsCache, name)
+		delete(d.breakers, name)
 	}
 
 	return nil
 }
 
+// watchConfig polls ConfigFile and reloads when its mtime changes. A change
+// is only applied once the mtime has been stable for a full interval, so
+// editors writing temp files and renaming don't trigger repeated reloads.
+func (d *MCPDaemon) watchConfig(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := configModTime()
+	pending := false
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if mod := configModTime(); !mod.Equal(last) {
+			last = mod
+			pending = true
+			continue
+		}
+		if !pending {
+			continue
+		}
+		pending = false
+
+		if err := d.reloadConfig(); err != nil {
+			logger.Error("config changed but reload failed", "error", err)
+			continue
+		}
+		logger.Info("config changed on disk, reloaded")
+	}
+}
+
+// configModTime returns the config file's mtime (zero if missing)
+func configModTime() time.Time {
+	info, err := os.Stat(ConfigFile)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
 // closeAllClients closes all MCP clients (for shutdown)
 func (d *MCPDaemon) closeAllClients() {
 	d.mu.Lock()
@@ -170,22 +481,25 @@ func (d *MCPDaemon) closeAllClients() {
 	d.toolsCache = make(map[string]*CachedTools)
 }
 
-// startLocalServers starts all servers with local configuration
-func (d *MCPDaemon) startLocalServers() {
+// startLocalServers starts all servers with local configuration and reports
+// which came up
+func (d *MCPDaemon) startLocalServers() StartupReport {
 	d.mu.RLock()
 	servers := d.config.Servers
 	d.mu.RUnlock()
 
+	results := make(map[string]error)
 	for name, cfg := range servers {
 		if cfg.Local != nil {
-			fmt.Fprintf(os.Stderr, "[%s] Starting local server '%s'...\n",
-				time.Now().Format("15:04:05"), name)
-			if err := d.localManager.StartServer(name, cfg); err != nil {
-				fmt.Fprintf(os.Stderr, "[%s] Failed to start '%s': %v\n",
-					time.Now().Format("15:04:05"), name, err)
+			logger.Info("starting local server", "server", name)
+			err := d.localManager.StartServer(name, cfg)
+			if err != nil {
+				logger.Error("failed to start local server", "server", name, "error", err, "required", cfg.Local.Required)
 			}
+			results[name] = err
 		}
 	}
+	return newStartupReport(results, servers)
 }
 
 // stopLocalServers stops all locally-managed servers
@@ -200,6 +514,12 @@ func (d *MCPDaemon) getProcessStatus() []ProcessInfo {
 
 // handleCommand handles a daemon command
 func (d *MCPDaemon) handleCommand(cmd DaemonCommand) Response {
+	return d.handleCommandContext(context.Background(), cmd)
+}
+
+// handleCommandContext handles a daemon command, abandoning server requests
+// when ctx is done (the client hung up)
+func (d *MCPDaemon) handleCommandContext(ctx context.Context, cmd DaemonCommand) Response {
 	switch cmd.Action {
 	case "ping":
 		return okResponse("pong")
@@ -217,7 +537,7 @@ func (d *MCPDaemon) handleCommand(cmd DaemonCommand) Response {
 			servers = append(servers, ServerInfo{
 				Name: name,
 				URL:  cfg.URL,
-			})
+			}.Redact())
 		}
 		d.mu.RUnlock()
 		return okResponse(map[string]any{"servers": servers})
@@ -226,27 +546,107 @@ func (d *MCPDaemon) handleCommand(cmd DaemonCommand) Response {
 		if cmd.Server == "" {
 			return errResponse(ErrInvalidArgs, "server name required")
 		}
-		tools, err := d.getTools(cmd.Server)
+		tools, err := d.getTools(ctx, cmd.Server)
 		if err != nil {
-			return errResponse(ErrMCPError, err.Error())
+			return errResponseFor(err)
 		}
 		return okResponse(map[string]any{
 			"server": cmd.Server,
 			"tools":  tools,
 		})
 
+	case "ping-server":
+		if cmd.Server == "" {
+			return errResponse(ErrInvalidArgs, "server name required")
+		}
+		client, err := d.getClientContext(ctx, cmd.Server)
+		if err != nil {
+			return errResponseFor(err)
+		}
+		rtt, err := client.PingContext(ctx)
+		if err != nil {
+			return errResponseFor(err)
+		}
+		return okResponse(newPingResult(d.resolveServer(cmd.Server), client, rtt))
+
+	case "set-log-level":
+		if cmd.Server == "" || cmd.Level == "" {
+			return errResponse(ErrInvalidArgs, "server name and level required")
+		}
+		client, err := d.getClientContext(ctx, cmd.Server)
+		if err != nil {
+			return errResponseFor(err)
+		}
+		if err := client.SetLogLevel(ctx, cmd.Level); err != nil {
+			return errResponseFor(err)
+		}
+		return okResponse(map[string]any{"server": d.resolveServer(cmd.Server), "level": cmd.Level})
+
+	case "logs":
+		if cmd.Server == "" {
+			return errResponse(ErrInvalidArgs, "server name required")
+		}
+		client, err := d.getClientContext(ctx, cmd.Server)
+		if err != nil {
+			return errResponseFor(err)
+		}
+		return okResponse(map[string]any{
+			"server": d.resolveServer(cmd.Server),
+			"logs":   client.ServerLogs(),
+		})
+
+	case "find":
+		if cmd.Query == "" {
+			return errResponse(ErrInvalidArgs, "search keyword required")
+		}
+		matches, warnings := d.findTools(ctx, cmd.Query)
+		return okResponse(map[string]any{
+			"query":    cmd.Query,
+			"matches":  matches,
+			"warnings": warnings,
+		})
+
+	case "describe":
+		if cmd.Server == "" || cmd.Tool == "" {
+			return errResponse(ErrInvalidArgs, "server and tool names required")
+		}
+		tools, err := d.getTools(ctx, cmd.Server)
+		if err != nil {
+			return errResponseFor(err)
+		}
+		tool := findTool(tools, cmd.Tool)
+		if tool == nil {
+			return errResponse(ErrUnknownTool, fmt.Sprintf("Tool '%s' not found on '%s'", cmd.Tool, cmd.Server))
+		}
+		return okResponse(describeTool(d.resolveServer(cmd.Server), *tool))
+
 	case "call":
 		if cmd.Server == "" || cmd.Tool == "" {
 			return errResponse(ErrInvalidArgs, "server and tool names required")
 		}
-		result, err := d.callTool(cmd.Server, cmd.Tool, cmd.Arguments)
+		if d.isReadOnly(cmd.Server) {
+			return errResponse(ErrReadOnly, fmt.Sprintf("read-only mode: tool calls to '%s' are disabled", cmd.Server))
+		}
+		// A pre_call_command may add required arguments, so validate its output
+		arguments, err := d.prepareArguments(ctx, cmd.Server, cmd.Tool, cmd.Arguments)
 		if err != nil {
-			return errResponse(ErrMCPError, err.Error())
+			return errResponseFor(err)
 		}
+		if !cmd.NoValidate {
+			if err := d.validateCall(ctx, cmd.Server, cmd.Tool, arguments); err != nil {
+				return errResponseFor(err)
+			}
+		}
+		result, meta, err := d.callTool(ctx, cmd.Server, cmd.Tool, arguments, cmd.Meta)
+		if err != nil {
+			return errResponseFor(err)
+		}
+		result = truncateResult(result, d.responseLimit(cmd.Server, cmd.MaxBytes))
 		return okResponse(map[string]any{
 			"server": cmd.Server,
 			"tool":   cmd.Tool,
 			"result": result,
+			"meta":   meta,
 		})
 
 	case "status":
@@ -262,15 +662,16 @@ func (d *MCPDaemon) handleCommand(cmd DaemonCommand) Response {
 		}
 		d.mu.RUnlock()
 		return okResponse(map[string]any{
-			"daemon":     "running",
-			"servers":    serverCount,
-			"local":      localCount,
-			"processes":  processes,
+			"daemon":        "running",
+			"servers":       serverCount,
+			"local":         localCount,
+			"processes":     processes,
+			"breakers":      d.breakerStatus(),
+			"server_status": d.serverStatus(),
 		})
 
 	case "shutdown":
-		d.running = false
-		d.stopLocalServers()
+		d.shutdown()
 		return okResponse("shutting down")
 
 	default:
@@ -284,43 +685,167 @@ func (d *MCPDaemon) handleConnection(conn net.Conn) {
 
 	start := time.Now()
 	reader := bufio.NewReader(conn)
+	requestID := newRequestID()
 
 	// Read command (single JSON object)
 	var cmd DaemonCommand
 	decoder := json.NewDecoder(reader)
 	if err := decoder.Decode(&cmd); err != nil {
 		response := errResponse(ErrParseError, err.Error())
+		response.RequestID = requestID
 		json.NewEncoder(conn).Encode(response)
-		fmt.Fprintf(os.Stderr, "[%s] ERROR parse: %v\n", time.Now().Format("15:04:05"), err)
+		logger.Error("parse error", "request_id", requestID, "error", err)
+		return
+	}
+
+	// Clients send one command and then only read, so EOF means they hung up
+	ctx, cancel := context.WithCancel(withRequestID(context.Background(), requestID))
+	defer cancel()
+	go func() {
+		io.Copy(io.Discard, reader)
+		cancel()
+	}()
+
+	// Subscriptions stream many responses over the connection. They never
+	// finish on their own, so shutdown ends them instead of draining them.
+	if cmd.Action == "subscribe" {
+		defer context.AfterFunc(d.stopping, cancel)()
+		response := d.streamSubscription(ctx, conn, cmd)
+		d.logRequest(ctx, cmd, response, time.Since(start))
 		return
 	}
 
 	// Handle command
-	response := d.handleCommand(cmd)
+	response := d.handleCommandContext(ctx, cmd)
+	response.RequestID = requestID
 
 	// Log request
-	elapsed := time.Since(start)
-	status := "OK"
-	if !response.OK {
-		status = "ERR"
-	}
-	if cmd.Action == "call" {
-		fmt.Fprintf(os.Stderr, "[%s] %s %s/%s %s (%v)\n",
-			time.Now().Format("15:04:05"), status, cmd.Server, cmd.Tool, cmd.Action, elapsed)
-	} else if cmd.Server != "" {
-		fmt.Fprintf(os.Stderr, "[%s] %s %s %s (%v)\n",
-			time.Now().Format("15:04:05"), status, cmd.Server, cmd.Action, elapsed)
-	} else if cmd.Action != "ping" {
-		fmt.Fprintf(os.Stderr, "[%s] %s %s (%v)\n",
-			time.Now().Format("15:04:05"), status, cmd.Action, elapsed)
-	}
+	d.logRequest(ctx, cmd, response, time.Since(start))
 
 	// Send response
 	json.NewEncoder(conn).Encode(response)
 }
 
+// newRequestID returns a short ID that tags a daemon request's log lines
+// and its response
+func newRequestID() string {
+	return uuid.New().String()[:8]
+}
+
+// streamSubscription subscribes to a resource and writes each update to conn
+// as a newline-delimited Response until the client disconnects. The first
+// Response reports whether the subscription succeeded and is returned.
+func (d *MCPDaemon) streamSubscription(ctx context.Context, conn net.Conn, cmd DaemonCommand) Response {
+	encoder := json.NewEncoder(conn)
+	send := func(resp Response) error {
+		resp.RequestID = requestIDFrom(ctx)
+		return encoder.Encode(resp)
+	}
+	fail := func(resp Response) Response {
+		send(resp)
+		return resp
+	}
+
+	if cmd.Server == "" || cmd.URI == "" {
+		return fail(errResponse(ErrInvalidArgs, "server name and resource uri required"))
+	}
+	client, err := d.getClientContext(ctx, cmd.Server)
+	if err != nil {
+		return fail(errResponseFor(err))
+	}
+	server := d.resolveServer(cmd.Server)
+
+	events, cancel := client.Events()
+	defer cancel()
+
+	if err := d.subscribe(server, client, cmd.URI); err != nil {
+		return fail(errResponseFor(err))
+	}
+	defer d.unsubscribe(server, client, cmd.URI)
+
+	response := okResponse(map[string]any{"server": server, "uri": cmd.URI, "subscribed": true})
+	if err := send(response); err != nil {
+		return response
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return response
+		case n := <-events:
+			uri := notificationURI(n)
+			if n.Method != "notifications/resources/updated" || uri != cmd.URI {
+				continue
+			}
+			if err := send(okResponse(newResourceUpdate(server, n))); err != nil {
+				return response
+			}
+		}
+	}
+}
+
+// subscribe subscribes the server's client to uri unless another connection
+// already has. Subscriptions are shared and reference counted.
+func (d *MCPDaemon) subscribe(server string, client *MCPClient, uri string) error {
+	key := server + "\x00" + uri
+
+	d.subMu.Lock()
+	defer d.subMu.Unlock()
+	if d.subscribers[key] == 0 {
+		if err := client.Subscribe(uri); err != nil {
+			return err
+		}
+	}
+	d.subscribers[key]++
+	return nil
+}
+
+// unsubscribe drops a connection's interest in uri, unsubscribing on the last
+func (d *MCPDaemon) unsubscribe(server string, client *MCPClient, uri string) {
+	key := server + "\x00" + uri
+
+	d.subMu.Lock()
+	d.subscribers[key]--
+	last := d.subscribers[key] <= 0
+	if last {
+		delete(d.subscribers, key)
+	}
+	d.subMu.Unlock()
+
+	if last {
+		client.Unsubscribe(uri)
+	}
+}
+
+// logRequest logs a handled command: failures at WARN, pings at DEBUG
+func (d *MCPDaemon) logRequest(ctx context.Context, cmd DaemonCommand, response Response, elapsed time.Duration) {
+	fields := requestFields(ctx, "action", cmd.Action)
+	if cmd.Server != "" {
+		fields = append(fields, "server", cmd.Server)
+	}
+	if cmd.Tool != "" {
+		fields = append(fields, "tool", cmd.Tool)
+	}
+	fields = append(fields, "elapsed", elapsed)
+
+	switch {
+	case !response.OK:
+		if response.Error != nil {
+			fields = append(fields, "code", response.Error.Code)
+		}
+		logger.Warn("request failed", fields...)
+	case cmd.Action == "ping":
+		logger.Debug("request", fields...)
+	default:
+		logger.Info("request", fields...)
+	}
+}
+
 // Run starts the daemon
 func (d *MCPDaemon) Run() error {
+	// The launching --daemon waits on this for the startup report
+	readyFile := openReadyFile()
+
 	// Create config directory if needed
 	if err := os.MkdirAll(ConfigDir, 0755); err != nil {
 		return err
@@ -347,51 +872,191 @@ func (d *MCPDaemon) Run() error {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
 
+	defer signal.Stop(sigChan)
+
 	go func() {
-		<-sigChan
-		d.running = false
-		listener.Close()
+		sig := <-sigChan
+		logger.Info("received signal, shutting down", "signal", sig)
+		d.shutdown()
 	}()
 
-	fmt.Printf("MCP daemon started (pid %d)\n", os.Getpid())
-	fmt.Printf("Socket: %s\n", SocketPath)
+	logger.Info("MCP daemon started", "pid", os.Getpid(), "socket", SocketPath)
+
+	// Clean up local servers orphaned by a previous daemon, then start fresh
+	for _, name := range d.localManager.ReapOrphans() {
+		logger.Warn("killed orphaned local server", "server", name)
+	}
+	report := d.startLocalServers()
+	signalReady(readyFile, report)
+	startErr := report.requiredError()
+	if startErr != nil {
+		logger.Error("shutting down: required local servers failed to start", "error", startErr)
+		d.stop()
+	}
 
-	// Start local servers
-	d.startLocalServers()
+	// Pick up hand edits to servers.json
+	watchStop := make(chan struct{})
+	watchDone := make(chan struct{})
+	go func() {
+		d.watchConfig(watchStop, ConfigPollInterval)
+		close(watchDone)
+	}()
 
-	// Accept connections
-	for d.running {
+	// Accept connections, each holding a slot while it is handled
+	slots := make(chan struct{}, d.maxConns)
+	for d.running() {
 		conn, err := listener.Accept()
 		if err != nil {
-			if d.running {
-				fmt.Fprintf(os.Stderr, "Accept error: %v\n", err)
+			if d.running() {
+				logger.Error("accept error", "error", err)
 			}
 			continue
 		}
 
+		select {
+		case slots <- struct{}{}:
+		default:
+			go d.rejectBusy(conn)
+			continue
+		}
+
 		// Handle connection in goroutine (concurrent)
-		go d.handleConnection(conn)
+		d.conns.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				d.conns.Done()
+			}()
+			d.handleConnection(conn)
+		}()
 	}
 
-	// Cleanup
+	// Cleanup: let in-flight requests finish before their servers go away
+	close(watchStop)
+	<-watchDone
+	d.drainConnections(ShutdownDrainTimeout)
+	d.saveToolsCache()
 	d.stopLocalServers()
 	d.closeAllClients()
 	listener.Close()
 	os.Remove(SocketPath)
 	os.Remove(PIDFile)
 
-	fmt.Println("MCP daemon stopped")
-	return nil
+	logger.Info("MCP daemon stopped")
+	return startErr
+}
+
+// rejectBusy answers a connection accepted while every slot is taken with a
+// DAEMON_BUSY error. The command is read first so the client isn't cut off
+// mid-write.
+func (d *MCPDaemon) rejectBusy(conn net.Conn) {
+	defer conn.Close()
+	requestID := newRequestID()
+	logger.Warn("rejecting connection: daemon busy", "request_id", requestID, "max_connections", d.maxConns)
+
+	conn.SetDeadline(time.Now().Add(time.Second))
+	var cmd DaemonCommand
+	json.NewDecoder(conn).Decode(&cmd)
+	msg := fmt.Sprintf("Daemon is busy handling %d connections; retry shortly", d.maxConns)
+	response := errResponse(ErrDaemonBusy, msg)
+	response.RequestID = requestID
+	json.NewEncoder(conn).Encode(response)
+}
+
+// shutdown stops accepting connections and ends subscription streams. Run
+// then waits for in-flight requests before closing clients and stopping
+// local servers.
+func (d *MCPDaemon) shutdown() {
+	d.stop()
+	if d.listener != nil {
+		d.listener.Close()
+	}
+}
+
+// running reports whether the daemon is still accepting connections
+func (d *MCPDaemon) running() bool {
+	return d.stopping.Err() == nil
+}
+
+// drainConnections waits up to timeout for connections being handled to
+// finish, and reports whether they all did
+func (d *MCPDaemon) drainConnections(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		d.conns.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		logger.Warn("shutting down with requests still in flight", "waited", timeout)
+		return false
+	}
 }
 
 // IsDaemonRunning checks if the daemon is running
 func IsDaemonRunning() bool {
+	return pingDaemon()
+}
+
+// DaemonState describes the daemon as seen through its PID file and socket
+type DaemonState struct {
+	Running    bool     // The socket answers a ping
+	PID        int      // PID from PIDFile if that process is alive, else 0
+	Responding bool     // False if the process is alive but the socket is not answering
+	Cleaned    []string // Stale files removed by CheckDaemon
+}
+
+// CheckDaemon reports whether the daemon is running, checking both that the
+// PID in PIDFile is a live process and that the socket answers. When neither
+// holds, the PID file and socket are leftovers from a crashed daemon and are
+// removed. A live process with a silent socket is left alone, since it may
+// still be starting.
+func CheckDaemon() DaemonState {
+	pid := readPIDFile()
+	alive := pid > 0 && processAlive(pid)
+	state := DaemonState{Running: pingDaemon()}
+	if alive {
+		state.PID = pid
+	}
+	state.Responding = state.Running || !alive
+
+	if !alive {
+		if _, err := os.Stat(PIDFile); err == nil && os.Remove(PIDFile) == nil {
+			state.Cleaned = append(state.Cleaned, PIDFile)
+		}
+		if !state.Running {
+			if _, err := os.Stat(SocketPath); err == nil && os.Remove(SocketPath) == nil {
+				state.Cleaned = append(state.Cleaned, SocketPath)
+			}
+		}
+	}
+	return state
+}
+
+// readPIDFile returns the PID recorded in PIDFile, or 0 if there is none
+func readPIDFile() int {
+	data, err := os.ReadFile(PIDFile)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// pingDaemon reports whether the daemon answers a ping on its socket
+func pingDaemon() bool {
 	if _, err := os.Stat(SocketPath); os.IsNotExist(err) {
 		return false
 	}
 
 	// Try to ping
-	conn, err := net.Dial("unix", SocketPath)
+	conn, err := dialDaemon(5 * time.Second)
 	if err != nil {
 		return false
 	}
@@ -410,13 +1075,28 @@ func IsDaemonRunning() bool {
 	return resp.OK
 }
 
+// dialDaemon connects to the daemon socket. A socket file nobody is
+// listening on was left by a crashed daemon: connecting to it is refused
+// immediately, so it is removed and a DAEMON_NOT_RUNNING error returned.
+func dialDaemon(timeout time.Duration) (net.Conn, error) {
+	conn, err := net.DialTimeout("unix", SocketPath, timeout)
+	if err != nil && errors.Is(err, syscall.ECONNREFUSED) {
+		os.Remove(SocketPath)
+		return nil, codedErrorf(ErrDaemonNotRunning, "Daemon not running (stale socket cleaned up). Start with --daemon")
+	}
+	return conn, err
+}
+
 // DaemonSend sends a command to the daemon
 func DaemonSend(cmd DaemonCommand) (Response, error) {
 	if _, err := os.Stat(SocketPath); os.IsNotExist(err) {
 		return errResponse(ErrDaemonNotRunning, "Daemon not running. Start with --daemon"), nil
 	}
 
-	conn, err := net.DialTimeout("unix", SocketPath, 30*time.Second)
+	conn, err := dialDaemon(30 * time.Second)
+	if errorCodeOf(err, "") == ErrDaemonNotRunning {
+		return errResponse(ErrDaemonNotRunning, err.Error()), nil
+	}
 	if err != nil {
 		return Response{}, err
 	}
@@ -439,49 +1119,140 @@ func DaemonSend(cmd DaemonCommand) (Response, error) {
 	return resp, nil
 }
 
-// StartDaemonBackground starts the daemon in the background
-func StartDaemonBackground() error {
+// DaemonStream sends a streaming command (such as "subscribe") and passes
+// each response to fn until the daemon closes the connection, fn returns
+// false, or stop is closed.
+func DaemonStream(cmd DaemonCommand, stop <-chan struct{}, fn func(Response) bool) error {
+	if _, err := os.Stat(SocketPath); os.IsNotExist(err) {
+		fn(errResponse(ErrDaemonNotRunning, "Daemon not running. Start with --daemon"))
+		return nil
+	}
+
+	conn, err := dialDaemon(30 * time.Second)
+	if errorCodeOf(err, "") == ErrDaemonNotRunning {
+		fn(errResponse(ErrDaemonNotRunning, err.Error()))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	go func() {
+		<-stop
+		conn.Close()
+	}()
+
+	if err := json.NewEncoder(conn).Encode(cmd); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(conn)
+	for {
+		var resp Response
+		if err := decoder.Decode(&resp); err != nil {
+			select {
+			case <-stop:
+				return nil
+			default:
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if !fn(resp) {
+			return nil
+		}
+	}
+}
+
+// StartDaemonBackground starts the daemon in the background and waits up to
+// timeout for its local servers to start, printing which did. It fails if a
+// required local server didn't come up (the daemon then exits).
+// args are forwarded to the --daemon-foreground process.
+func StartDaemonBackground(args []string, timeout time.Duration) error {
 	if IsDaemonRunning() {
 		fmt.Println("Daemon already running")
 		return nil
 	}
 
-	// Fork to background using syscall
-	// For Go, we use a simpler approach - start a new process
 	executable, err := os.Executable()
 	if err != nil {
 		return err
 	}
 
-	// Start daemon process
-	cmd := &syscall.ProcAttr{
-		Dir:   "/",
-		Env:   os.Environ(),
-		Files: []uintptr{0, 0, 0}, // stdin, stdout, stderr
-		Sys: &syscall.SysProcAttr{
-			Setsid: true,
-		},
+	// The daemon logs to stderr; keep that (and stdout) in the log file
+	if err := os.MkdirAll(ConfigDir, 0755); err != nil {
+		return err
 	}
+	logFile, err := os.OpenFile(LogFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open daemon log: %w", err)
+	}
+	defer logFile.Close()
 
-	pid, err := syscall.ForkExec(executable, []string{executable, "--daemon-foreground"}, cmd)
+	// The daemon writes its startup report to this pipe (fd 3)
+	ready, readyW, err := os.Pipe()
 	if err != nil {
 		return err
 	}
+	defer ready.Close()
 
-	// Wait briefly and check if daemon started
-	time.Sleep(500 * time.Millisecond)
-	if IsDaemonRunning() {
-		fmt.Printf("Daemon started (pid %d)\n", pid)
-	} else {
-		return fmt.Errorf("failed to start daemon")
+	cmd, err := daemonCommand(executable, append([]string{"--daemon-foreground"}, args...), logFile, readyW)
+	if err != nil {
+		readyW.Close()
+		return err
+	}
+	err = cmd.Start()
+	readyW.Close()
+	if err != nil {
+		return err
+	}
+	pid := cmd.Process.Pid
+	cmd.Process.Release()
+
+	report, err := readStartupReport(ready, timeout)
+	if err != nil {
+		return err
+	}
+	if err := report.requiredError(); err != nil {
+		return fmt.Errorf("%w; daemon stopped", err)
 	}
 
+	fmt.Printf("Daemon started (pid %d)\n", pid)
+	if len(report.Started) > 0 {
+		fmt.Printf("Local servers started: %s\n", strings.Join(report.Started, ", "))
+	}
+	failed := make([]string, 0, len(report.Failed))
+	for name := range report.Failed {
+		failed = append(failed, name)
+	}
+	sort.Strings(failed)
+	for _, name := range failed {
+		fmt.Printf("Local server %s failed to start: %s\n", name, report.Failed[name])
+	}
 	return nil
 }
 
+// daemonCommand builds the detached daemon process: stdin is /dev/null,
+// stdout and stderr are appended to log, and ready is passed as fd 3
+func daemonCommand(executable string, args []string, log, ready *os.File) (*exec.Cmd, error) {
+	cmd := exec.Command(executable, args...)
+	cmd.Dir = "/"
+	cmd.Env = append(os.Environ(), ReadyFDEnv+"=3")
+	cmd.Stdout = log
+	cmd.Stderr = log
+	cmd.ExtraFiles = []*os.File{ready}
+	if err := detachProcess(cmd); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
 // StopDaemon stops the daemon
 func StopDaemon() error {
-	if !IsDaemonRunning() {
+	if !CheckDaemon().Running {
 		fmt.Println("Daemon not running")
 		return nil
 	}
@@ -492,7 +1263,17 @@ func StopDaemon() error {
 	}
 
 	if resp.OK {
-		fmt.Println("Daemon stopped")
+		// The daemon finishes in-flight requests first; it is gone once its
+		// socket is removed
+		deadline := time.Now().Add(ShutdownDrainTimeout + 10*time.Second)
+		for time.Now().Before(deadline) {
+			if _, err := os.Stat(SocketPath); os.IsNotExist(err) {
+				fmt.Println("Daemon stopped")
+				return nil
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		fmt.Println("Daemon is still shutting down")
 	} else if resp.Error != nil {
 		fmt.Printf("Error: %s\n", resp.Error.Message)
 	}
@@ -502,12 +1283,19 @@ func StopDaemon() error {
 
 // GetDaemonStatus returns the daemon status
 func GetDaemonStatus() {
-	if IsDaemonRunning() {
+	state := CheckDaemon()
+	switch {
+	case state.Running:
 		fmt.Println("Daemon is running")
-		if data, err := os.ReadFile(PIDFile); err == nil {
-			fmt.Printf("PID: %s\n", string(data))
+		if state.PID > 0 {
+			fmt.Printf("PID: %d\n", state.PID)
 		}
-	} else {
+	case !state.Responding:
+		fmt.Printf("Daemon process %d is alive but not responding on %s\n", state.PID, SocketPath)
+	default:
 		fmt.Println("Daemon is not running")
 	}
+	for _, path := range state.Cleaned {
+		fmt.Printf("Removed stale %s\n", path)
+	}
 }
@@ -1,7 +1,22 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -125,7 +140,7 @@ func TestNewMCPDaemon(t *testing.T) {
 		t.Error("Expected toolsCache map to be initialized")
 	}
 
-	if !daemon.running {
+	if !daemon.running() {
 		t.Error("Expected daemon to be in running state")
 	}
 }
@@ -294,7 +309,7 @@ func TestMCPDaemon_HandleCommand_Shutdown(t *testing.T) {
 		t.Fatalf("NewMCPDaemon failed: %v", err)
 	}
 
-	if !daemon.running {
+	if !daemon.running() {
 		t.Error("Expected daemon to be running initially")
 	}
 
@@ -304,7 +319,7 @@ func TestMCPDaemon_HandleCommand_Shutdown(t *testing.T) {
 		t.Error("Expected OK response for shutdown")
 	}
 
-	if daemon.running {
+	if daemon.running() {
 		t.Error("Expected daemon to stop running after shutdown")
 	}
 }
@@ -503,6 +518,94 @@ func TestMCPDaemon_ReloadConfig_URLChange(t *testing.T) {
 	}
 }
 
+func TestMCPDaemon_ReloadConfig_HeaderEdit(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	received := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ID == "" {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		result := map[string]any{"protocolVersion": ProtocolVersion, "capabilities": map[string]any{"tools": map[string]any{}}}
+		if req.Method == "tools/call" {
+			received <- r.Header.Get("X-Env")
+			result = map[string]any{"content": []any{}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}))
+	defer server.Close()
+
+	config := &Config{Servers: map[string]ServerConfig{
+		"server1": {URL: server.URL, Headers: map[string]string{"X-Env": "staging"}},
+	}}
+	SaveConfig(config)
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+	call := DaemonCommand{Action: "call", Server: "server1", Tool: "query", NoValidate: true}
+	if resp := daemon.handleCommand(call); !resp.OK {
+		t.Fatalf("Call failed: %+v", resp.Error)
+	}
+	if got := <-received; got != "staging" {
+		t.Fatalf("Expected X-Env staging, got %q", got)
+	}
+	daemon.toolsCache["server1"] = &CachedTools{Tools: []Tool{{Name: "query"}}, Expires: time.Now().Add(time.Minute), URL: server.URL}
+
+	// Same URL, different header (as --edit --header would write)
+	config.Servers["server1"] = ServerConfig{URL: server.URL, Headers: map[string]string{"X-Env": "production"}}
+	SaveConfig(config)
+	if err := daemon.reloadConfig(); err != nil {
+		t.Fatalf("reloadConfig failed: %v", err)
+	}
+
+	if _, ok := daemon.toolsCache["server1"]; ok {
+		t.Error("Expected the edited server's tool cache to be dropped")
+	}
+	if resp := daemon.handleCommand(call); !resp.OK {
+		t.Fatalf("Call failed: %+v", resp.Error)
+	}
+	if got := <-received; got != "production" {
+		t.Errorf("Expected the edited header on the next call, got %q", got)
+	}
+}
+
+func TestMCPDaemon_ReloadConfig_UnchangedServerKeepsClient(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	config := &Config{Servers: map[string]ServerConfig{
+		"server1": {URL: "https://one.example.com", Headers: map[string]string{"X-Env": "staging"}},
+		"server2": {URL: "https://two.example.com"},
+	}}
+	SaveConfig(config)
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+	client, err := daemon.getClient("server1")
+	if err != nil {
+		t.Fatalf("getClient failed: %v", err)
+	}
+
+	// Editing another server leaves this one's client alone
+	config.Servers["server2"] = ServerConfig{URL: "https://two.example.com", ReadOnly: true}
+	SaveConfig(config)
+	if err := daemon.reloadConfig(); err != nil {
+		t.Fatalf("reloadConfig failed: %v", err)
+	}
+	if daemon.clients["server1"] != client {
+		t.Error("Expected the unchanged server's client to be kept")
+	}
+}
+
 func TestMCPDaemon_HandleCommand_ToolsUnconfiguredServer(t *testing.T) {
 	_, cleanup := setupTestConfig(t)
 	defer cleanup()
@@ -539,3 +642,1464 @@ func TestIsDaemonRunning_NoSocket(t *testing.T) {
 		t.Error("Expected daemon to not be running when socket doesn't exist")
 	}
 }
+
+func TestMCPDaemon_GetClient_Alias(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	config := &Config{
+		Servers: map[string]ServerConfig{
+			"database": {URL: "https://db.example.com/mcp", Aliases: []string{"db"}},
+		},
+	}
+	if err := SaveConfig(config); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+
+	byAlias, err := daemon.getClient("db")
+	if err != nil {
+		t.Fatalf("getClient by alias failed: %v", err)
+	}
+	byName, err := daemon.getClient("database")
+	if err != nil {
+		t.Fatalf("getClient by name failed: %v", err)
+	}
+
+	if byAlias != byName {
+		t.Error("Expected alias and name to share the same client")
+	}
+}
+
+func TestMCPDaemon_HandleCommand_CallDeniedTool(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	config := &Config{
+		Servers: map[string]ServerConfig{
+			"server1": {URL: "https://server1.example.com", DenyTools: []string{"drop_*"}},
+		},
+	}
+	if err := SaveConfig(config); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+
+	resp := daemon.handleCommand(DaemonCommand{Action: "call", Server: "server1", Tool: "drop_table"})
+	if resp.OK {
+		t.Fatal("Expected denied tool call to fail")
+	}
+	if resp.Error.Code != ErrUnknownTool {
+		t.Errorf("Expected error code %s, got %s", ErrUnknownTool, resp.Error.Code)
+	}
+}
+
+func TestMCPDaemon_HandleCommand_ReadOnly(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req MCPRequest
+		json.Unmarshal(body, &req)
+
+		w.Header().Set("Content-Type", "application/json")
+		result := map[string]any{}
+		if req.Method == "tools/list" {
+			result["tools"] = []map[string]any{{"name": "query"}}
+		}
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Servers: map[string]ServerConfig{
+			"locked": {URL: server.URL, ReadOnly: true},
+			"open":   {URL: server.URL},
+		},
+	}
+	if err := SaveConfig(config); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+
+	resp := daemon.handleCommand(DaemonCommand{Action: "call", Server: "locked", Tool: "query"})
+	if resp.OK || resp.Error.Code != ErrReadOnly {
+		t.Errorf("Expected %s for call on read-only server, got %+v", ErrReadOnly, resp)
+	}
+
+	resp = daemon.handleCommand(DaemonCommand{Action: "tools", Server: "locked"})
+	if !resp.OK {
+		t.Errorf("Expected tools listing to succeed in read-only mode: %+v", resp.Error)
+	}
+
+	resp = daemon.handleCommand(DaemonCommand{Action: "call", Server: "open", Tool: "query"})
+	if !resp.OK {
+		t.Errorf("Expected call on writable server to succeed: %+v", resp.Error)
+	}
+
+	// Global read-only mode blocks every server
+	daemon.readOnly = true
+	resp = daemon.handleCommand(DaemonCommand{Action: "call", Server: "open", Tool: "query"})
+	if resp.OK || resp.Error.Code != ErrReadOnly {
+		t.Errorf("Expected %s in global read-only mode, got %+v", ErrReadOnly, resp)
+	}
+}
+
+func TestMCPDaemon_HandleCommand_NeedsAuth(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Servers: map[string]ServerConfig{
+			"secure": {URL: server.URL},
+		},
+	}
+	if err := SaveConfig(config); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+
+	resp := daemon.handleCommand(DaemonCommand{Action: "call", Server: "secure", Tool: "query"})
+	if resp.OK {
+		t.Fatal("Expected call to fail with 401")
+	}
+	if resp.Error.Code != ErrAuthExpired {
+		t.Errorf("Expected error code %s, got %s", ErrAuthExpired, resp.Error.Code)
+	}
+	if !resp.NeedsAuth {
+		t.Error("Expected needsAuth to be set")
+	}
+
+	data, _ := json.Marshal(resp)
+	if !strings.Contains(string(data), `"needsAuth":true`) {
+		t.Errorf("Expected needsAuth in JSON, got %s", data)
+	}
+}
+
+func TestMCPDaemon_RefreshesNearExpiryToken(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "refreshed-token",
+			"expires_in":   3600,
+		})
+	}))
+	defer tokenServer.Close()
+
+	var receivedAuth string
+	mcpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req MCPRequest
+		json.Unmarshal(body, &req)
+		if req.Method == "tools/call" {
+			receivedAuth = r.Header.Get("Authorization")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]any{}})
+	}))
+	defer mcpServer.Close()
+
+	config := &Config{
+		Servers: map[string]ServerConfig{
+			"oauth-server": {
+				URL:   mcpServer.URL,
+				OAuth: &OAuthConfig{TokenURL: tokenServer.URL},
+			},
+		},
+	}
+	if err := SaveConfig(config); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	// Token is valid when the client is first created
+	SaveTokens(map[string]TokenData{
+		"oauth-server": {
+			AccessToken:  "original-token",
+			RefreshToken: "refresh-token",
+			ExpiresAt:    float64(time.Now().Unix() + 3600),
+		},
+	})
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+	if _, err := daemon.getClient("oauth-server"); err != nil {
+		t.Fatalf("getClient failed: %v", err)
+	}
+
+	// Time passes: the token is now within the expiry buffer
+	nearExpiry := float64(time.Now().Unix() + 30)
+	SaveTokens(map[string]TokenData{
+		"oauth-server": {
+			AccessToken:  "original-token",
+			RefreshToken: "refresh-token",
+			ExpiresAt:    nearExpiry,
+		},
+	})
+	daemon.tokenExpiry["oauth-server"] = nearExpiry
+
+	resp := daemon.handleCommand(DaemonCommand{Action: "call", Server: "oauth-server", Tool: "query"})
+	if !resp.OK {
+		t.Fatalf("Call failed: %+v", resp.Error)
+	}
+
+	if receivedAuth != "Bearer refreshed-token" {
+		t.Errorf("Expected refreshed token on call, got %q", receivedAuth)
+	}
+	if tokenExpiring(daemon.tokenExpiry["oauth-server"]) {
+		t.Error("Expected recorded expiry to move forward after refresh")
+	}
+}
+
+func TestMCPDaemon_RetriesFailedTokenRefresh(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	var failRefresh atomic.Bool
+	failRefresh.Store(true)
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failRefresh.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "refreshed-token", "expires_in": 3600})
+	}))
+	defer tokenServer.Close()
+
+	SaveConfig(&Config{Servers: map[string]ServerConfig{
+		"oauth-server": {URL: "http://127.0.0.1:1", OAuth: &OAuthConfig{TokenURL: tokenServer.URL}},
+	}})
+	nearExpiry := float64(time.Now().Unix() + 30)
+	SaveTokens(map[string]TokenData{
+		"oauth-server": {AccessToken: "original-token", RefreshToken: "refresh-token", ExpiresAt: float64(time.Now().Unix() + 3600)},
+	})
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+	client, err := daemon.getClient("oauth-server")
+	if err != nil {
+		t.Fatalf("getClient failed: %v", err)
+	}
+	SaveTokens(map[string]TokenData{
+		"oauth-server": {AccessToken: "original-token", RefreshToken: "refresh-token", ExpiresAt: nearExpiry},
+	})
+	daemon.tokenExpiry["oauth-server"] = nearExpiry
+
+	var logs bytes.Buffer
+	origLogger := logger
+	logger = NewLogger(&logs, LevelInfo)
+	defer func() { logger = origLogger }()
+
+	// A failed refresh keeps the old token and expiry and backs off
+	daemon.getClientContext(withRequestID(context.Background(), "req-1"), "oauth-server")
+	if !strings.Contains(logs.String(), "request_id=req-1") {
+		t.Errorf("Expected the refresh warning tagged with the request ID, got %q", logs.String())
+	}
+	if daemon.tokenExpiry["oauth-server"] != nearExpiry {
+		t.Errorf("Expected the expiry to be kept after a failed refresh, got %v", daemon.tokenExpiry["oauth-server"])
+	}
+	if client.oauthToken != "original-token" {
+		t.Errorf("Expected the old token to stay in use, got %q", client.oauthToken)
+	}
+	if !daemon.tokenRetry["oauth-server"].After(time.Now()) {
+		t.Error("Expected a retry time in the future")
+	}
+
+	// Once the backoff has passed the refresh is tried again
+	failRefresh.Store(false)
+	daemon.tokenRetry["oauth-server"] = time.Now().Add(-time.Second)
+	daemon.getClient("oauth-server")
+	if client.oauthToken != "refreshed-token" {
+		t.Errorf("Expected the retried refresh to apply, got %q", client.oauthToken)
+	}
+	if tokenExpiring(daemon.tokenExpiry["oauth-server"]) {
+		t.Error("Expected recorded expiry to move forward after refresh")
+	}
+}
+
+func TestMCPDaemon_WatchConfigReloads(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	if err := SaveConfig(&Config{Servers: map[string]ServerConfig{"old": {URL: "http://old"}}}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+
+	// Stop the watcher before cleanup restores the config paths it reads
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	defer func() {
+		close(stop)
+		<-done
+	}()
+	go func() {
+		daemon.watchConfig(stop, 10*time.Millisecond)
+		close(done)
+	}()
+
+	// Let the watcher record the initial mtime before editing
+	time.Sleep(30 * time.Millisecond)
+	if err := SaveConfig(&Config{Servers: map[string]ServerConfig{
+		"old": {URL: "http://old"},
+		"new": {URL: "http://new"},
+	}}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	// Ensure the mtime moves even on coarse-grained filesystems
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(ConfigFile, future, future)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		daemon.mu.RLock()
+		_, ok := daemon.config.Servers["new"]
+		daemon.mu.RUnlock()
+		if ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected daemon to pick up new server without manual reload")
+}
+
+func TestMCPDaemon_CallValidatesArguments(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		result := map[string]any{}
+		switch req.Method {
+		case "tools/list":
+			result["tools"] = []any{map[string]any{
+				"name":        "query",
+				"inputSchema": queryToolSchema(),
+			}}
+		case "tools/call":
+			called = true
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}))
+	defer server.Close()
+
+	if err := SaveConfig(&Config{Servers: map[string]ServerConfig{"db": {URL: server.URL}}}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+
+	resp := daemon.handleCommand(DaemonCommand{Action: "call", Server: "db", Tool: "query", Arguments: map[string]any{"limit": 5}})
+	if resp.OK || resp.Error.Code != ErrSchemaError {
+		t.Fatalf("Expected SCHEMA_ERROR, got %+v", resp)
+	}
+	if !strings.Contains(resp.Error.Message, "'query'") {
+		t.Errorf("Expected message naming the missing argument, got %q", resp.Error.Message)
+	}
+	if called {
+		t.Error("Expected invalid call not to reach the server")
+	}
+
+	resp = daemon.handleCommand(DaemonCommand{Action: "call", Server: "db", Tool: "query", Arguments: map[string]any{"limit": 5}, NoValidate: true})
+	if !resp.OK || !called {
+		t.Errorf("Expected --no-validate call to reach the server, got %+v", resp)
+	}
+}
+
+func TestMCPDaemon_CallValidatesPreCallCommandOutput(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	received := make(chan map[string]any, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		result := map[string]any{}
+		switch req.Method {
+		case "tools/list":
+			result["tools"] = []any{map[string]any{
+				"name":        "query",
+				"inputSchema": queryToolSchema(),
+			}}
+		case "tools/call":
+			params, _ := req.Params.(map[string]any)
+			args, _ := params["arguments"].(map[string]any)
+			received <- args
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}))
+	defer server.Close()
+
+	// The hook supplies the required "query" argument the caller left out
+	if err := SaveConfig(&Config{Servers: map[string]ServerConfig{
+		"db": {URL: server.URL, PreCallCommand: `sed 's/^{/{"query":"select 1",/'`},
+	}}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+
+	resp := daemon.handleCommand(DaemonCommand{Action: "call", Server: "db", Tool: "query", Arguments: map[string]any{"limit": 5}})
+	if !resp.OK {
+		t.Fatalf("Expected the hook's arguments to pass validation, got %+v", resp.Error)
+	}
+	if args := <-received; args["query"] != "select 1" {
+		t.Errorf("Expected the hook's arguments sent, got %v", args)
+	}
+}
+
+func TestMCPDaemon_Find(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	newToolServer := func(tools ...map[string]any) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req MCPRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			result := map[string]any{}
+			if req.Method == "tools/list" {
+				result["tools"] = tools
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+		}))
+	}
+
+	logs := newToolServer(map[string]any{"name": "search_logs", "description": "Search log lines"})
+	defer logs.Close()
+	db := newToolServer(
+		map[string]any{"name": "search", "description": "Full-text search"},
+		map[string]any{"name": "list_tables"},
+	)
+	defer db.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	down.Close()
+
+	if err := SaveConfig(&Config{Servers: map[string]ServerConfig{
+		"logs": {URL: logs.URL},
+		"db":   {URL: db.URL},
+		"down": {URL: down.URL},
+	}}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+
+	resp := daemon.handleCommand(DaemonCommand{Action: "find", Query: "search"})
+	if !resp.OK {
+		t.Fatalf("find failed: %+v", resp.Error)
+	}
+	data := resp.Data.(map[string]any)
+
+	matches := data["matches"].([]ToolMatch)
+	if len(matches) != 2 {
+		t.Fatalf("Expected matches on two servers, got %+v", matches)
+	}
+	if matches[0].Server != "db" || matches[0].Tool != "search" {
+		t.Errorf("Expected exact-name match first, got %+v", matches[0])
+	}
+	if matches[1].Server != "logs" || matches[1].Tool != "search_logs" {
+		t.Errorf("Expected logs/search_logs second, got %+v", matches[1])
+	}
+
+	warnings := data["warnings"].([]ServerWarning)
+	if len(warnings) != 1 || warnings[0].Server != "down" {
+		t.Errorf("Expected unreachable server skipped with warning, got %+v", warnings)
+	}
+}
+
+func TestMCPDaemon_PingServer(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server := newInitializeServer(t, ProtocolVersion)
+	defer server.Close()
+
+	if err := SaveConfig(&Config{Servers: map[string]ServerConfig{"api": {URL: server.URL}}}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+
+	resp := daemon.handleCommand(DaemonCommand{Action: "ping-server", Server: "api"})
+	if !resp.OK {
+		t.Fatalf("ping-server failed: %+v", resp.Error)
+	}
+	result := resp.Data.(PingResult)
+	if result.Server != "api" || result.ProtocolVersion != ProtocolVersion {
+		t.Errorf("Unexpected ping result: %+v", result)
+	}
+
+	resp = daemon.handleCommand(DaemonCommand{Action: "ping-server", Server: "missing"})
+	if resp.OK {
+		t.Error("Expected ping of unknown server to fail")
+	}
+}
+
+func TestMCPDaemon_CircuitBreakerFailsFast(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	var hits int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Servers: map[string]ServerConfig{
+			"flaky": {URL: server.URL, CircuitThreshold: 2, CircuitCooldown: 60},
+		},
+	}
+	if err := SaveConfig(config); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if resp := daemon.handleCommand(DaemonCommand{Action: "call", Server: "flaky", Tool: "query", NoValidate: true}); resp.OK {
+			t.Fatal("Expected call to fail with 503")
+		}
+	}
+	before := hits
+
+	resp := daemon.handleCommand(DaemonCommand{Action: "call", Server: "flaky", Tool: "query", NoValidate: true})
+	if resp.OK {
+		t.Fatal("Expected open circuit to fail")
+	}
+	if resp.Error.Code != ErrConnectionFailed {
+		t.Errorf("Expected error code %s, got %s", ErrConnectionFailed, resp.Error.Code)
+	}
+	if hits != before {
+		t.Errorf("Expected no request while circuit is open, got %d more", hits-before)
+	}
+
+	status := daemon.handleCommand(DaemonCommand{Action: "status"})
+	data, _ := json.Marshal(status)
+	if !strings.Contains(string(data), `"flaky":{"state":"open"`) {
+		t.Errorf("Expected open breaker in status, got %s", data)
+	}
+}
+
+func TestMCPDaemon_CancelsCallWhenClientDisconnects(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server, calls, cancelled := newSlowToolServer(t)
+
+	config := &Config{
+		Servers: map[string]ServerConfig{
+			"slow": {URL: server.URL},
+		},
+	}
+	if err := SaveConfig(config); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+
+	clientConn, daemonConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		daemon.handleConnection(daemonConn)
+		close(done)
+	}()
+
+	json.NewEncoder(clientConn).Encode(DaemonCommand{Action: "call", Server: "slow", Tool: "long_job", NoValidate: true})
+
+	var callID string
+	select {
+	case callID = <-calls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected tool call to reach the server")
+	}
+	clientConn.Close()
+
+	select {
+	case params := <-cancelled:
+		if params["requestId"] != callID {
+			t.Errorf("Expected cancellation of %s, got %v", callID, params["requestId"])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected notifications/cancelled after client disconnect")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected handler to return after cancellation")
+	}
+}
+
+func TestMCPDaemon_CallIncludesMeta(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	config := &Config{
+		Servers: map[string]ServerConfig{
+			"meta": {URL: newMetaToolServer(t).URL},
+		},
+	}
+	if err := SaveConfig(config); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+
+	resp := daemon.handleCommand(DaemonCommand{Action: "call", Server: "meta", Tool: "summarize", NoValidate: true})
+	if !resp.OK {
+		t.Fatalf("Expected call to succeed, got %+v", resp.Error)
+	}
+
+	data := resp.Data.(map[string]any)
+	meta, ok := data["meta"].(CallMetadata)
+	if !ok {
+		t.Fatalf("Expected meta in response, got %T", data["meta"])
+	}
+	if meta.ElapsedMs <= 0 || meta.Meta["usage"] == nil {
+		t.Errorf("Unexpected meta: %+v", meta)
+	}
+	if _, ok := data["result"].(map[string]any)["content"]; !ok {
+		t.Error("Expected result to be unchanged")
+	}
+}
+
+func TestMCPDaemon_StatusReportsServerHealth(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	config := &Config{
+		Servers: map[string]ServerConfig{
+			"healthy": {URL: newMetaToolServer(t).URL},
+			"down":    {URL: down.URL},
+			"idle":    {URL: "http://127.0.0.1:1", Local: &LocalConfig{Command: "true"}},
+		},
+	}
+	if err := SaveConfig(config); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if err := SaveTokens(map[string]TokenData{
+		"healthy": {AccessToken: "tok", ExpiresAt: float64(time.Now().Add(time.Hour).Unix())},
+		"down":    {AccessToken: "old", ExpiresAt: float64(time.Now().Add(-time.Hour).Unix())},
+	}); err != nil {
+		t.Fatalf("Failed to save tokens: %v", err)
+	}
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+
+	if resp := daemon.handleCommand(DaemonCommand{Action: "call", Server: "healthy", Tool: "summarize", NoValidate: true}); !resp.OK {
+		t.Fatalf("Expected call to succeed, got %+v", resp.Error)
+	}
+	daemon.handleCommand(DaemonCommand{Action: "call", Server: "down", Tool: "query", NoValidate: true})
+
+	resp := daemon.handleCommand(DaemonCommand{Action: "status"})
+	data, _ := json.Marshal(resp.Data)
+	var payload struct {
+		ServerStatus []ServerStatus `json:"server_status"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatalf("Failed to decode status: %v", err)
+	}
+
+	byName := make(map[string]ServerStatus)
+	for _, s := range payload.ServerStatus {
+		byName[s.Name] = s
+	}
+	if len(byName) != 3 {
+		t.Fatalf("Expected all 3 configured servers in status, got %s", data)
+	}
+
+	healthy := byName["healthy"]
+	if healthy.Type != "remote" || healthy.Reachable == nil || !*healthy.Reachable || healthy.LastCheck == "" || healthy.LastError != "" {
+		t.Errorf("Unexpected healthy status: %+v", healthy)
+	}
+	if healthy.Token != TokenValid {
+		t.Errorf("Expected valid token, got %s", healthy.Token)
+	}
+
+	downStatus := byName["down"]
+	if downStatus.Reachable == nil || *downStatus.Reachable || !strings.Contains(downStatus.LastError, "503") || downStatus.LastErrorAt == "" {
+		t.Errorf("Expected unreachable server with last error, got %+v", downStatus)
+	}
+	if downStatus.Token != TokenExpired {
+		t.Errorf("Expected expired token, got %s", downStatus.Token)
+	}
+
+	idle := byName["idle"]
+	if idle.Type != "local" || idle.Reachable != nil || idle.Token != TokenNone || idle.CachedTools != 0 {
+		t.Errorf("Expected unchecked local server, got %+v", idle)
+	}
+}
+
+func TestMCPDaemon_RequiredLocalServerFailsStartup(t *testing.T) {
+	tmpDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+	origSocket, origPID, origLogs, origState := SocketPath, PIDFile, LogsDir, LocalState
+	SocketPath = filepath.Join(tmpDir, "daemon.sock")
+	PIDFile = filepath.Join(tmpDir, "daemon.pid")
+	LogsDir = filepath.Join(tmpDir, "logs")
+	LocalState = filepath.Join(tmpDir, "local.json")
+	defer func() { SocketPath, PIDFile, LogsDir, LocalState = origSocket, origPID, origLogs, origState }()
+
+	SaveConfig(&Config{Servers: map[string]ServerConfig{
+		"optional": {URL: "http://localhost:1", Local: &LocalConfig{Command: "mcpx-test-missing-optional"}},
+		"browser":  {URL: "http://localhost:1", Local: &LocalConfig{Command: "mcpx-test-missing-command", Required: true}},
+	}})
+
+	// Stand in for the --daemon parent waiting on the ready pipe
+	ready, readyW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe failed: %v", err)
+	}
+	defer ready.Close()
+	t.Setenv(ReadyFDEnv, fmt.Sprint(readyW.Fd()))
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+	runErr := make(chan error, 1)
+	go func() { runErr <- daemon.Run() }()
+
+	report, err := readStartupReport(ready, 5*time.Second)
+	if err != nil {
+		t.Fatalf("readStartupReport failed: %v", err)
+	}
+	if len(report.RequiredFailed) != 1 || report.RequiredFailed[0] != "browser" {
+		t.Errorf("Expected browser reported as a failed required server, got %+v", report)
+	}
+	if _, ok := report.Failed["optional"]; !ok || len(report.Started) != 0 {
+		t.Errorf("Expected optional failure reported and nothing started, got %+v", report)
+	}
+
+	select {
+	case err := <-runErr:
+		if err == nil || !strings.Contains(err.Error(), "browser") {
+			t.Errorf("Expected Run to fail naming the required server, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the daemon to stop after a required server failed")
+	}
+	if _, err := os.Stat(SocketPath); !os.IsNotExist(err) {
+		t.Error("Expected the socket to be removed")
+	}
+}
+
+func TestReadStartupReport_DaemonExited(t *testing.T) {
+	r, w, _ := os.Pipe()
+	w.Close()
+	if _, err := readStartupReport(r, time.Second); err == nil || !strings.Contains(err.Error(), "exited") {
+		t.Errorf("Expected an exited-during-startup error, got %v", err)
+	}
+
+	r, w, _ = os.Pipe()
+	defer w.Close()
+	if _, err := readStartupReport(r, 50*time.Millisecond); err == nil || !strings.Contains(err.Error(), "within") {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+}
+
+func TestDaemonCommand_OutputToLogFile(t *testing.T) {
+	dir := t.TempDir()
+	logFile, err := os.OpenFile(filepath.Join(dir, "daemon.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	defer logFile.Close()
+	ready, readyW, _ := os.Pipe()
+	defer ready.Close()
+
+	// Stands in for --daemon-foreground: logs to both streams, then reports ready
+	script := `echo "started on stdout"; echo "logged on stderr" >&2; echo '{"started": ["browser"]}' >&"$` + ReadyFDEnv + `"`
+	cmd, err := daemonCommand("/bin/sh", []string{"-c", script}, logFile, readyW)
+	if err != nil {
+		t.Fatalf("daemonCommand failed: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	readyW.Close()
+
+	report, err := readStartupReport(ready, 5*time.Second)
+	if err != nil {
+		t.Fatalf("readStartupReport failed: %v", err)
+	}
+	if len(report.Started) != 1 || report.Started[0] != "browser" {
+		t.Errorf("Expected the report on fd 3, got %+v", report)
+	}
+	cmd.Wait()
+
+	data, _ := os.ReadFile(logFile.Name())
+	if !strings.Contains(string(data), "started on stdout") || !strings.Contains(string(data), "logged on stderr") {
+		t.Errorf("Expected stdout and stderr in the log file, got %q", data)
+	}
+	if cmd.SysProcAttr == nil || !cmd.SysProcAttr.Setsid {
+		t.Error("Expected the daemon to start in its own session")
+	}
+}
+
+func TestCheckDaemon_StalePIDFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	origSocket, origPID := SocketPath, PIDFile
+	SocketPath = filepath.Join(tmpDir, "daemon.sock")
+	PIDFile = filepath.Join(tmpDir, "daemon.pid")
+	defer func() { SocketPath, PIDFile = origSocket, origPID }()
+
+	// A PID left behind by a process that has since exited
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to run true: %v", err)
+	}
+	os.WriteFile(PIDFile, []byte(fmt.Sprintf("%d", cmd.Process.Pid)), 0644)
+
+	state := CheckDaemon()
+	if state.Running || state.PID != 0 {
+		t.Errorf("Expected a stale PID to be reported as not running, got %+v", state)
+	}
+	if _, err := os.Stat(PIDFile); !os.IsNotExist(err) {
+		t.Error("Expected stale PID file to be removed")
+	}
+	if len(state.Cleaned) != 1 || state.Cleaned[0] != PIDFile {
+		t.Errorf("Expected only the PID file to be cleaned, got %v", state.Cleaned)
+	}
+
+	// A live process with no socket may still be starting and is left alone
+	os.WriteFile(PIDFile, []byte(fmt.Sprintf("%d", os.Getpid())), 0644)
+	state = CheckDaemon()
+	if state.Running || state.Responding || state.PID != os.Getpid() {
+		t.Errorf("Expected a live but silent daemon, got %+v", state)
+	}
+	if _, err := os.Stat(PIDFile); err != nil {
+		t.Error("Expected PID file of a live process to be kept")
+	}
+}
+
+func TestDaemonSend_StaleSocket(t *testing.T) {
+	// Unix socket paths are length-limited, so avoid the long t.TempDir path
+	sockDir, err := os.MkdirTemp("", "mcpx")
+	if err != nil {
+		t.Fatalf("MkdirTemp failed: %v", err)
+	}
+	defer os.RemoveAll(sockDir)
+	origSocket := SocketPath
+	SocketPath = filepath.Join(sockDir, "d.sock")
+	defer func() { SocketPath = origSocket }()
+
+	// Leave the socket file behind with nothing listening, as a crash would
+	listener, err := net.Listen("unix", SocketPath)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+
+	start := time.Now()
+	resp, err := DaemonSend(DaemonCommand{Action: "ping"})
+	if err != nil {
+		t.Fatalf("DaemonSend failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected a stale socket to fail fast, took %s", elapsed)
+	}
+	if resp.OK || resp.Error.Code != ErrDaemonNotRunning || !strings.Contains(resp.Error.Message, "stale socket cleaned up") {
+		t.Errorf("Expected DAEMON_NOT_RUNNING for a stale socket, got %+v", resp.Error)
+	}
+	if _, err := os.Stat(SocketPath); !os.IsNotExist(err) {
+		t.Error("Expected stale socket to be removed")
+	}
+
+	// IsDaemonRunning cleans up the same way
+	listener, _ = net.Listen("unix", SocketPath)
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+	if IsDaemonRunning() {
+		t.Error("Expected IsDaemonRunning to be false for a stale socket")
+	}
+	if _, err := os.Stat(SocketPath); !os.IsNotExist(err) {
+		t.Error("Expected IsDaemonRunning to remove the stale socket")
+	}
+}
+
+func TestMCPDaemon_SIGTERMStopsLocalServers(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+	// Unix socket paths are length-limited, so avoid the long t.TempDir path
+	sockDir, err := os.MkdirTemp("", "mcpx")
+	if err != nil {
+		t.Fatalf("MkdirTemp failed: %v", err)
+	}
+	defer os.RemoveAll(sockDir)
+	origSocket, origPID, origLogs, origState := SocketPath, PIDFile, LogsDir, LocalState
+	SocketPath = filepath.Join(sockDir, "d.sock")
+	PIDFile = filepath.Join(sockDir, "d.pid")
+	LogsDir = filepath.Join(sockDir, "logs")
+	LocalState = filepath.Join(sockDir, "local.json")
+	defer func() { SocketPath, PIDFile, LogsDir, LocalState = origSocket, origPID, origLogs, origState }()
+
+	SaveConfig(&Config{Servers: map[string]ServerConfig{
+		"sleeper": {Local: &LocalConfig{Command: "sleep", Args: []string{"60"}, Stdio: true}},
+	}})
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+	runErr := make(chan error, 1)
+	go func() { runErr <- daemon.Run() }()
+
+	// Run installs its signal handler before it serves pings
+	deadline := time.Now().Add(5 * time.Second)
+	for !IsDaemonRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("Daemon did not start")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	proc, ok := daemon.localManager.GetProcess("sleeper")
+	if !ok {
+		t.Fatal("Expected the local server to be started")
+	}
+	pid := proc.Cmd.Process.Pid
+
+	syscall.Kill(os.Getpid(), syscall.SIGTERM)
+
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected SIGTERM to stop the daemon")
+	}
+	if processAlive(pid) {
+		t.Error("Expected the local server to be stopped")
+	}
+	for _, path := range []string{SocketPath, PIDFile} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", path)
+		}
+	}
+}
+
+func TestMCPDaemon_ShutdownDrainsInFlightCalls(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+	// Unix socket paths are length-limited, so avoid the long t.TempDir path
+	sockDir, err := os.MkdirTemp("", "mcpx")
+	if err != nil {
+		t.Fatalf("MkdirTemp failed: %v", err)
+	}
+	defer os.RemoveAll(sockDir)
+	origSocket, origPID, origState := SocketPath, PIDFile, LocalState
+	SocketPath = filepath.Join(sockDir, "d.sock")
+	PIDFile = filepath.Join(sockDir, "d.pid")
+	LocalState = filepath.Join(sockDir, "local.json")
+	defer func() { SocketPath, PIDFile, LocalState = origSocket, origPID, origState }()
+
+	calls := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ID == "" {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		result := map[string]any{"protocolVersion": ProtocolVersion, "capabilities": map[string]any{"tools": map[string]any{}}}
+		if req.Method == "tools/call" {
+			calls <- struct{}{}
+			<-release
+			result = map[string]any{"content": []map[string]any{{"type": "text", "text": "finished"}}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}))
+	defer server.Close()
+	SaveConfig(&Config{Servers: map[string]ServerConfig{"slow": {URL: server.URL}}})
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+	runErr := make(chan error, 1)
+	go func() { runErr <- daemon.Run() }()
+	deadline := time.Now().Add(5 * time.Second)
+	for !IsDaemonRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("Daemon did not start")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	callResp := make(chan Response, 1)
+	go func() {
+		resp, _ := DaemonSend(DaemonCommand{Action: "call", Server: "slow", Tool: "long_job", NoValidate: true})
+		callResp <- resp
+	}()
+	select {
+	case <-calls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the call to reach the server")
+	}
+
+	if resp, err := DaemonSend(DaemonCommand{Action: "shutdown"}); err != nil || !resp.OK {
+		t.Fatalf("Expected shutdown to be accepted, got %+v, %v", resp, err)
+	}
+	select {
+	case <-runErr:
+		t.Fatal("Expected the daemon to wait for the in-flight call")
+	case <-time.After(200 * time.Millisecond):
+	}
+	close(release)
+
+	select {
+	case resp := <-callResp:
+		if !resp.OK {
+			t.Errorf("Expected the in-flight call to complete, got %+v", resp.Error)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a response to the in-flight call")
+	}
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the daemon to stop after draining")
+	}
+}
+
+func TestMCPDaemon_RejectsConnectionsOverLimit(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+	// Unix socket paths are length-limited, so avoid the long t.TempDir path
+	sockDir, err := os.MkdirTemp("", "mcpx")
+	if err != nil {
+		t.Fatalf("MkdirTemp failed: %v", err)
+	}
+	defer os.RemoveAll(sockDir)
+	origSocket, origPID, origState := SocketPath, PIDFile, LocalState
+	SocketPath = filepath.Join(sockDir, "d.sock")
+	PIDFile = filepath.Join(sockDir, "d.pid")
+	LocalState = filepath.Join(sockDir, "local.json")
+	defer func() { SocketPath, PIDFile, LocalState = origSocket, origPID, origState }()
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+	daemon.maxConns = 2
+	runErr := make(chan error, 1)
+	go func() { runErr <- daemon.Run() }()
+	defer func() {
+		daemon.shutdown()
+		<-runErr
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for !IsDaemonRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("Daemon did not start")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// Connections that never send a command hold their slots; connections
+	// are accepted in order, so the ping below comes after them
+	var idle []net.Conn
+	for i := 0; i < daemon.maxConns; i++ {
+		conn, err := net.Dial("unix", SocketPath)
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		idle = append(idle, conn)
+	}
+
+	resp, err := DaemonSend(DaemonCommand{Action: "ping"})
+	if err != nil {
+		t.Fatalf("DaemonSend failed: %v", err)
+	}
+	if resp.OK || resp.Error.Code != ErrDaemonBusy {
+		t.Errorf("Expected DAEMON_BUSY over the limit, got %+v", resp)
+	}
+	if resp.RequestID == "" {
+		t.Error("Expected a request ID on the DAEMON_BUSY reply")
+	}
+
+	for _, conn := range idle {
+		conn.Close()
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for !IsDaemonRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected connections to be accepted once slots are free")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestMCPDaemon_RequestIDInResponseAndLogs(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	var logs bytes.Buffer
+	origLogger := logger
+	logger = NewLogger(&logs, LevelInfo)
+	defer func() { logger = origLogger }()
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+
+	clientConn, daemonConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		daemon.handleConnection(daemonConn)
+		close(done)
+	}()
+	json.NewEncoder(clientConn).Encode(DaemonCommand{Action: "servers"})
+	var resp Response
+	if err := json.NewDecoder(clientConn).Decode(&resp); err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	clientConn.Close()
+	<-done
+
+	if resp.RequestID == "" {
+		t.Fatal("Expected a request ID in the response")
+	}
+	if !strings.Contains(logs.String(), "request_id="+resp.RequestID+" action=servers") {
+		t.Errorf("Expected request ID %s in the request log line, got %q", resp.RequestID, logs.String())
+	}
+}
+
+func TestMCPDaemon_CallForwardsRequestMeta(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	received := make(chan map[string]any, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ID == "" {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		result := map[string]any{"protocolVersion": ProtocolVersion, "capabilities": map[string]any{"tools": map[string]any{}}}
+		if req.Method == "tools/call" {
+			params, _ := req.Params.(map[string]any)
+			received <- params
+			result = map[string]any{"content": []any{}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}))
+	defer server.Close()
+	SaveConfig(&Config{Servers: map[string]ServerConfig{"traced": {URL: server.URL}}})
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+
+	// The command travels as JSON over the socket, so round-trip it
+	var cmd DaemonCommand
+	data, _ := json.Marshal(DaemonCommand{
+		Action: "call", Server: "traced", Tool: "search", NoValidate: true,
+		Meta: map[string]any{"progressToken": "job-7", "traceId": "abc123"},
+	})
+	json.Unmarshal(data, &cmd)
+
+	if resp := daemon.handleCommand(cmd); !resp.OK {
+		t.Fatalf("Expected call to succeed, got %+v", resp.Error)
+	}
+	params := <-received
+	meta, ok := params["_meta"].(map[string]any)
+	if !ok || meta["progressToken"] != "job-7" || meta["traceId"] != "abc123" {
+		t.Errorf("Expected _meta with progressToken and traceId, got %v", params["_meta"])
+	}
+	if params["name"] != "search" {
+		t.Errorf("Expected tool name to be unchanged, got %v", params["name"])
+	}
+}
+
+func TestMCPDaemon_CallTruncatesLargeResult(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ID == "" {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		result := map[string]any{"protocolVersion": ProtocolVersion, "capabilities": map[string]any{"tools": map[string]any{}}}
+		if req.Method == "tools/call" {
+			result = map[string]any{"content": []any{
+				map[string]any{"type": "text", "text": strings.Repeat("x", 100000)},
+			}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}))
+	defer server.Close()
+	SaveConfig(&Config{Servers: map[string]ServerConfig{"big": {URL: server.URL, MaxResponseBytes: 4096}}})
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		maxBytes int
+		want     int
+	}{{0, 4096}, {512, 512}} {
+		resp := daemon.handleCommand(DaemonCommand{Action: "call", Server: "big", Tool: "dump", NoValidate: true, MaxBytes: tt.maxBytes})
+		if !resp.OK {
+			t.Fatalf("Expected call to succeed, got %+v", resp.Error)
+		}
+		result, _ := resp.Data.(map[string]any)["result"].(map[string]any)
+		if result["truncated"] != true {
+			t.Errorf("max_bytes %d: expected truncated marker, got %v", tt.maxBytes, result["truncated"])
+		}
+		if size := jsonSize(result); size > tt.want || size < tt.want/2 {
+			t.Errorf("max_bytes %d: expected a result close to %d bytes, got %d", tt.maxBytes, tt.want, size)
+		}
+	}
+}
+
+// newSlowToolsServer serves tools/list and tools/call after a delay, so
+// concurrent requests overlap, and counts the requests of each method
+func newSlowToolsServer(t *testing.T, tools []any, counts map[string]*atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ID == "" {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if n, ok := counts[req.Method]; ok {
+			n.Add(1)
+		}
+		result := map[string]any{"protocolVersion": ProtocolVersion, "capabilities": map[string]any{"tools": map[string]any{}}}
+		switch req.Method {
+		case "tools/list":
+			time.Sleep(200 * time.Millisecond)
+			result = map[string]any{"tools": tools}
+		case "tools/call":
+			time.Sleep(200 * time.Millisecond)
+			result = map[string]any{"content": []any{map[string]any{"type": "text", "text": "ok"}}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestMCPDaemon_GetToolsCoalescesConcurrentRequests(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	lists := &atomic.Int32{}
+	server := newSlowToolsServer(t, []any{map[string]any{"name": "search"}}, map[string]*atomic.Int32{"tools/list": lists})
+	SaveConfig(&Config{Servers: map[string]ServerConfig{"slow": {URL: server.URL}}})
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+	// Connect first so both requests find the client and race on tools/list
+	if _, err := daemon.getClient("slow"); err != nil {
+		t.Fatalf("getClient failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tools, err := daemon.getTools(context.Background(), "slow")
+			if err == nil && len(tools) != 1 {
+				err = fmt.Errorf("expected 1 tool, got %d", len(tools))
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("getTools failed: %v", err)
+		}
+	}
+
+	if n := lists.Load(); n != 1 {
+		t.Errorf("Expected 1 upstream tools/list request, got %d", n)
+	}
+}
+
+func TestMCPDaemon_CoalescedRequestSurvivesFirstCallerCancel(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	lists := &atomic.Int32{}
+	server := newSlowToolsServer(t, []any{map[string]any{"name": "search"}}, map[string]*atomic.Int32{"tools/list": lists})
+	SaveConfig(&Config{Servers: map[string]ServerConfig{"slow": {URL: server.URL}}})
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+	if _, err := daemon.getClient("slow"); err != nil {
+		t.Fatalf("getClient failed: %v", err)
+	}
+
+	// The first caller starts tools/list and then hangs up
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := daemon.getTools(ctx, "slow")
+		firstErr <- err
+	}()
+	for lists.Load() == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	second := make(chan error, 1)
+	go func() {
+		tools, err := daemon.getTools(context.Background(), "slow")
+		if err == nil && len(tools) != 1 {
+			err = fmt.Errorf("expected 1 tool, got %d", len(tools))
+		}
+		second <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the first caller to see its cancellation, got %v", err)
+	}
+	if err := <-second; err != nil {
+		t.Errorf("Expected the second caller to get the shared result, got %v", err)
+	}
+	if n := lists.Load(); n != 1 {
+		t.Errorf("Expected 1 upstream tools/list request, got %d", n)
+	}
+}
+
+func TestMCPDaemon_CallCoalescesOnlyIdempotentTools(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	calls := &atomic.Int32{}
+	tools := []any{
+		map[string]any{"name": "lookup", "annotations": map[string]any{"readOnlyHint": true}},
+		map[string]any{"name": "append"},
+	}
+	server := newSlowToolsServer(t, tools, map[string]*atomic.Int32{"tools/call": calls})
+	// Session-based clients initialize once, so concurrent calls don't re-handshake
+	SaveConfig(&Config{Servers: map[string]ServerConfig{"slow": {URL: server.URL, SessionBased: true, CoalesceCalls: true}}})
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+	if _, err := daemon.getTools(context.Background(), "slow"); err != nil {
+		t.Fatalf("getTools failed: %v", err)
+	}
+
+	callTwice := func(tool string) {
+		var wg sync.WaitGroup
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, _, err := daemon.callTool(context.Background(), "slow", tool, map[string]any{"id": 1}, nil); err != nil {
+					t.Errorf("%s failed: %v", tool, err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	callTwice("lookup")
+	if n := calls.Swap(0); n != 1 {
+		t.Errorf("Expected identical read-only calls to share 1 request, got %d", n)
+	}
+
+	callTwice("append")
+	if n := calls.Load(); n != 2 {
+		t.Errorf("Expected a tool without idempotent hints to be called twice, got %d", n)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DebugEnv enables protocol tracing like --debug
+const DebugEnv = "MCPX_DEBUG"
+
+// debugEnabled writes every JSON-RPC request and raw response to debug.log
+// (--debug or MCPX_DEBUG)
+var debugEnabled = os.Getenv(DebugEnv) != ""
+
+// debugMu serializes writes so concurrent daemon requests don't interleave
+var debugMu sync.Mutex
+
+// debugLogPath returns the protocol trace file for the active profile
+func debugLogPath() string {
+	return filepath.Join(LogsDir, "debug.log")
+}
+
+// debugTrace appends one request or response to debug.log: a timestamped
+// summary line with the server name, the headers (credentials always masked)
+// and the raw body. Best effort; failures to write are ignored.
+func debugTrace(serverName, summary string, header http.Header, body []byte) {
+	if !debugEnabled {
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s %s\n", time.Now().UTC().Format("2006-01-02T15:04:05.000Z"), serverName, summary)
+
+	headers := make(map[string]string, len(header))
+	for k, v := range header {
+		headers[k] = strings.Join(v, ", ")
+	}
+	headers = redactHeaders(headers)
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		fmt.Fprintf(&b, "%s: %s\n", k, headers[k])
+	}
+
+	b.WriteString("\n")
+	b.Write(body)
+	b.WriteString("\n\n")
+
+	debugMu.Lock()
+	defer debugMu.Unlock()
+	if err := os.MkdirAll(LogsDir, 0755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(debugLogPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.WriteString(b.String())
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDebugTrace_RecordsRequestAndResponse(t *testing.T) {
+	tmpDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	origLogsDir, origEnabled := LogsDir, debugEnabled
+	LogsDir = filepath.Join(tmpDir, "logs")
+	debugEnabled = true
+	defer func() { LogsDir, debugEnabled = origLogsDir, origEnabled }()
+
+	server := newMetaToolServer(t)
+	client, err := NewMCPClient("traced", ServerConfig{
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer sk-live-secret", "X-Team": "core"},
+	})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+
+	if _, err := client.CallTool("summarize", map[string]any{"text": "hi"}); err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(LogsDir, "debug.log"))
+	if err != nil {
+		t.Fatalf("Expected debug.log: %v", err)
+	}
+	trace := string(data)
+
+	for _, want := range []string{
+		"traced -> POST " + server.URL,
+		`"method":"tools/call"`,
+		`"text":"hi"`,
+		"traced <- HTTP 200",
+		`"input_tokens":12`,
+		"X-Team: core",
+		"Authorization: REDACTED",
+	} {
+		if !strings.Contains(trace, want) {
+			t.Errorf("Expected debug.log to contain %q:\n%s", want, trace)
+		}
+	}
+	if strings.Contains(trace, "sk-live-secret") {
+		t.Errorf("Expected Authorization to be masked:\n%s", trace)
+	}
+}
+
+func TestDebugTrace_DisabledWritesNothing(t *testing.T) {
+	tmpDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	origLogsDir, origEnabled := LogsDir, debugEnabled
+	LogsDir = filepath.Join(tmpDir, "logs")
+	debugEnabled = false
+	defer func() { LogsDir, debugEnabled = origLogsDir, origEnabled }()
+
+	debugTrace("quiet", "-> POST http://example.com", nil, []byte("{}"))
+
+	if _, err := os.Stat(filepath.Join(LogsDir, "debug.log")); !os.IsNotExist(err) {
+		t.Errorf("Expected no debug.log when disabled, got %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// detachProcess starts cmd in its own session so the daemon outlives the
+// terminal that launched it
+func detachProcess(cmd *exec.Cmd) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	return nil
+}
+
+// openReadyFile returns the pipe inherited from --daemon, if any. It is
+// marked close-on-exec so local servers don't hold it open.
+func openReadyFile() *os.File {
+	fd, err := strconv.Atoi(os.Getenv(ReadyFDEnv))
+	if err != nil || fd < 3 {
+		return nil
+	}
+	os.Unsetenv(ReadyFDEnv)
+	syscall.CloseOnExec(fd)
+	return os.NewFile(uintptr(fd), "ready")
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// detachProcess reports that the background daemon isn't available: it
+// relies on Unix sockets, sessions and signals
+func detachProcess(cmd *exec.Cmd) error {
+	return fmt.Errorf("the background daemon is not supported on Windows; use --call instead of --query")
+}
+
+// openReadyFile returns nil: without the background daemon there is no
+// --daemon process waiting on a ready pipe
+func openReadyFile() *os.File {
+	return nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Health check defaults for --doctor
+const (
+	doctorConcurrency = 8
+	doctorTimeout     = 10 * time.Second
+)
+
+// Defaults for --servers --probe, kept short so the listing stays snappy
+const (
+	probeConcurrency = 8
+	probeTimeout     = 5 * time.Second
+)
+
+// Health states reported by --doctor
+const (
+	HealthOK           = "ok"
+	HealthAuthRequired = "auth_required"
+	HealthUnreachable  = "unreachable"
+	HealthTimeout      = "timeout"
+	HealthError        = "error"
+)
+
+// HealthCheck is one server's --doctor result
+type HealthCheck struct {
+	Server          string  `json:"server"`
+	Status          string  `json:"status"`
+	ElapsedMs       float64 `json:"elapsed_ms"`
+	ProtocolVersion string  `json:"protocol_version,omitempty"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// HealthReport summarizes --doctor results
+type HealthReport struct {
+	Checks  []HealthCheck  `json:"checks"`
+	Summary map[string]int `json:"summary"`
+	Healthy bool           `json:"healthy"`
+}
+
+// checkServers runs an initialize handshake against every server, at most
+// concurrency at a time, each bounded by timeout
+func checkServers(config *Config, concurrency int, timeout time.Duration) HealthReport {
+	names := make([]string, 0, len(config.Servers))
+	for name := range config.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	checks := make([]HealthCheck, len(names))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			checks[i] = checkServer(name, config.Servers[name], timeout)
+		}(i, name)
+	}
+	wg.Wait()
+
+	report := HealthReport{Checks: checks, Summary: make(map[string]int), Healthy: true}
+	for _, check := range checks {
+		report.Summary[check.Status]++
+		if check.Status != HealthOK {
+			report.Healthy = false
+		}
+	}
+	return report
+}
+
+// checkServer pings one server and classifies the outcome
+func checkServer(name string, serverConfig ServerConfig, timeout time.Duration) HealthCheck {
+	check := HealthCheck{Server: name}
+	start := time.Now()
+	defer func() {
+		check.ElapsedMs = float64(time.Since(start).Microseconds()) / 1000
+	}()
+
+	client, err := NewMCPClient(name, serverConfig)
+	if err != nil {
+		check.Status, check.Error = HealthError, err.Error()
+		return check
+	}
+	defer client.Close()
+	client.SetTimeout(timeout)
+
+	token, _ := GetTokenForServer(name, serverConfig)
+	if token != "" {
+		client.SetOAuthToken(token)
+	}
+
+	if _, err := client.Ping(); err != nil {
+		check.Status, check.Error = healthStatusFor(err), err.Error()
+		return check
+	}
+
+	check.Status = HealthOK
+	check.ProtocolVersion = client.ProtocolVersion()
+	return check
+}
+
+// probeServers fills in each server's reachability, protocol version and
+// tool count (--servers --probe), at most concurrency at a time, each
+// bounded by timeout
+func probeServers(config *Config, servers []ServerInfo, concurrency int, timeout time.Duration) {
+	listings, err := loadToolListings()
+	if err != nil {
+		listings = nil // Unreadable cache: fetch every tool list
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range servers {
+		wg.Add(1)
+		go func(info *ServerInfo) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			probeServer(info, config.Servers[info.Name], listings[info.Name], timeout)
+		}(&servers[i])
+	}
+	wg.Wait()
+}
+
+// probeServer handshakes with one server and counts its tools, taking the
+// count from the last --tools listing while it is fresh. A failed tool fetch
+// leaves the count unset without marking the server unreachable.
+func probeServer(info *ServerInfo, serverConfig ServerConfig, listing ToolListing, timeout time.Duration) {
+	reachable := false
+	info.Reachable = &reachable
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client, err := NewMCPClient(info.Name, serverConfig)
+	if err != nil {
+		info.Status, info.Error = HealthError, err.Error()
+		return
+	}
+	defer client.Close()
+	client.SetTimeout(timeout)
+
+	token, _ := GetTokenForServer(info.Name, serverConfig)
+	if token != "" {
+		client.SetOAuthToken(token)
+	}
+
+	if _, err := client.PingContext(ctx); err != nil {
+		info.Status, info.Error = healthStatusFor(err), err.Error()
+		return
+	}
+	reachable = true
+	info.Status = HealthOK
+	info.ProtocolVersion = client.ProtocolVersion()
+
+	if listing.ListedAt != 0 && time.Since(time.Unix(listing.ListedAt, 0)) <= toolListingTTL {
+		count := len(listing.Tools)
+		info.ToolCount = &count
+		return
+	}
+	if tools, err := client.ListToolsContext(ctx); err == nil {
+		count := len(tools)
+		info.ToolCount = &count
+	}
+}
+
+// healthStatusFor maps a ping error to a health state
+func healthStatusFor(err error) string {
+	if errorCodeOf(err, "") == ErrAuthExpired {
+		return HealthAuthRequired
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return HealthTimeout
+		}
+		return HealthUnreachable
+	}
+	return HealthError
+}
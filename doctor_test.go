@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckServers(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	healthy := newInitializeServer(t, ProtocolVersion)
+	defer healthy.Close()
+
+	unauthorized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer unauthorized.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	unreachable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	unreachable.Close()
+
+	config := &Config{Servers: map[string]ServerConfig{
+		"healthy":      {URL: healthy.URL},
+		"unauthorized": {URL: unauthorized.URL},
+		"slow":         {URL: slow.URL},
+		"unreachable":  {URL: unreachable.URL},
+	}}
+
+	report := checkServers(config, 2, 100*time.Millisecond)
+
+	want := map[string]string{
+		"healthy":      HealthOK,
+		"unauthorized": HealthAuthRequired,
+		"slow":         HealthTimeout,
+		"unreachable":  HealthUnreachable,
+	}
+	if len(report.Checks) != len(want) {
+		t.Fatalf("Expected %d checks, got %d", len(want), len(report.Checks))
+	}
+	for _, check := range report.Checks {
+		if check.Status != want[check.Server] {
+			t.Errorf("%s: expected %s, got %s (%s)", check.Server, want[check.Server], check.Status, check.Error)
+		}
+	}
+
+	if report.Healthy {
+		t.Error("Expected report to be unhealthy")
+	}
+	if report.Summary[HealthOK] != 1 || report.Summary[HealthAuthRequired] != 1 {
+		t.Errorf("Unexpected summary: %v", report.Summary)
+	}
+	if report.Checks[0].Server != "healthy" || report.Checks[0].ProtocolVersion != ProtocolVersion {
+		t.Errorf("Expected sorted checks with negotiated version, got %+v", report.Checks[0])
+	}
+}
+
+func TestProbeServers(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	withTools := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		result := map[string]any{"protocolVersion": ProtocolVersion}
+		if req.Method == "tools/list" {
+			result = map[string]any{"tools": []any{
+				map[string]any{"name": "a"}, map[string]any{"name": "b"}, map[string]any{"name": "c"},
+			}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}))
+	defer withTools.Close()
+
+	// Can't list tools, but a fresh --tools listing is cached
+	cached := newInitializeServer(t, ProtocolVersion)
+	defer cached.Close()
+	SaveToolListing("cached", []Tool{{Name: "x"}, {Name: "y"}})
+
+	unreachable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	unreachable.Close()
+
+	config := &Config{Servers: map[string]ServerConfig{
+		"tools":       {URL: withTools.URL},
+		"cached":      {URL: cached.URL},
+		"unreachable": {URL: unreachable.URL},
+	}}
+	servers := []ServerInfo{{Name: "tools"}, {Name: "cached"}, {Name: "unreachable"}}
+
+	probeServers(config, servers, 2, time.Second)
+
+	wantTools := map[string]int{"tools": 3, "cached": 2}
+	for _, info := range servers[:2] {
+		if info.Reachable == nil || !*info.Reachable || info.Status != HealthOK {
+			t.Errorf("%s: expected reachable, got %+v", info.Name, info)
+			continue
+		}
+		if info.ProtocolVersion != ProtocolVersion {
+			t.Errorf("%s: expected protocol version %s, got %q", info.Name, ProtocolVersion, info.ProtocolVersion)
+		}
+		if info.ToolCount == nil || *info.ToolCount != wantTools[info.Name] {
+			t.Errorf("%s: expected %d tools, got %v", info.Name, wantTools[info.Name], info.ToolCount)
+		}
+	}
+
+	down := servers[2]
+	if down.Reachable == nil || *down.Reachable || down.Status != HealthUnreachable || down.Error == "" {
+		t.Errorf("Expected unreachable server with an error, got %+v", down)
+	}
+	if down.ToolCount != nil {
+		t.Errorf("Expected no tool count for an unreachable server, got %d", *down.ToolCount)
+	}
+}
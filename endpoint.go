@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// unixSocketURL splits a Unix-socket server URL into the socket path and the
+// http:// URL whose path and query are sent over it. ok is false for other
+// schemes. Two forms are accepted:
+//
+//	unix:///run/mcp.sock               -> /run/mcp.sock, http://localhost/
+//	http+unix://%2Frun%2Fmcp.sock/mcp  -> /run/mcp.sock, http://localhost/mcp
+func unixSocketURL(rawURL string) (socket, httpURL string, ok bool, err error) {
+	var rest string
+	switch {
+	case strings.HasPrefix(rawURL, "unix://"):
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return "", "", false, fmt.Errorf("invalid server URL: %w", err)
+		}
+		socket, rest = u.Path, "/"
+	case strings.HasPrefix(rawURL, "http+unix://"):
+		// The socket path is the percent-encoded host, which net/url rejects
+		host, path, _ := strings.Cut(strings.TrimPrefix(rawURL, "http+unix://"), "/")
+		socket, err = url.PathUnescape(host)
+		if err != nil {
+			return "", "", false, fmt.Errorf("invalid socket path in %s: %w", rawURL, err)
+		}
+		rest = "/" + path
+	default:
+		return "", "", false, nil
+	}
+	if socket == "" {
+		return "", "", false, fmt.Errorf("no socket path in %s", rawURL)
+	}
+	return socket, "http://localhost" + rest, true, nil
+}
+
+// serverDialAddr returns the network and address to dial to reach a server
+// URL: the socket for Unix-socket URLs, otherwise host:port, with the
+// scheme's default port and IPv6 literals ("http://[::1]:3000") handled.
+func serverDialAddr(rawURL string) (network, addr string, err error) {
+	socket, _, ok, err := unixSocketURL(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	if ok {
+		return "unix", socket, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid server URL: %w", err)
+	}
+	if u.Hostname() == "" {
+		return "", "", fmt.Errorf("no host in server URL %s", rawURL)
+	}
+	port := u.Port()
+	if port == "" {
+		switch strings.ToLower(u.Scheme) {
+		case "https", "wss":
+			port = "443"
+		default:
+			port = "80"
+		}
+	}
+	return "tcp", net.JoinHostPort(u.Hostname(), port), nil
+}
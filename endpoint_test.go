@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServerDialAddr(t *testing.T) {
+	tests := []struct {
+		url     string
+		network string
+		addr    string
+	}{
+		{"http://localhost:8931/mcp", "tcp", "localhost:8931"},
+		{"http://[::1]:3000/mcp", "tcp", "[::1]:3000"},
+		{"http://[fe80::1]/mcp", "tcp", "[fe80::1]:80"},
+		{"https://example.com/mcp", "tcp", "example.com:443"},
+		{"unix:///run/mcp.sock", "unix", "/run/mcp.sock"},
+		{"http+unix://%2Frun%2Fmcp.sock/mcp", "unix", "/run/mcp.sock"},
+	}
+	for _, tt := range tests {
+		network, addr, err := serverDialAddr(tt.url)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.url, err)
+			continue
+		}
+		if network != tt.network || addr != tt.addr {
+			t.Errorf("%s: expected %s %s, got %s %s", tt.url, tt.network, tt.addr, network, addr)
+		}
+	}
+
+	if _, _, err := serverDialAddr("http+unix:///mcp"); err == nil {
+		t.Error("Expected error for a Unix-socket URL without a socket path")
+	}
+}
+
+func TestUnixSocketServer(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	// Unix socket paths are length-limited, so avoid the long t.TempDir path
+	sockDir, err := os.MkdirTemp("", "mcpx")
+	if err != nil {
+		t.Fatalf("MkdirTemp failed: %v", err)
+	}
+	defer os.RemoveAll(sockDir)
+	socket := filepath.Join(sockDir, "mcp.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	var paths []string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]any{}})
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	// A local server listening on the socket is ready once it accepts
+	proc := &LocalProcess{Name: "sock", ServerURL: "unix://" + socket, done: make(chan struct{})}
+	if err := proc.waitForReady(); err != nil {
+		t.Fatalf("waitForReady failed: %v", err)
+	}
+
+	client, err := NewMCPClient("sock", ServerConfig{URL: "http+unix://" + url.PathEscape(socket) + "/mcp"})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+	if _, _, err := client.Request("ping", nil); err != nil {
+		t.Fatalf("Request over the socket failed: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != "/mcp" {
+		t.Errorf("Expected a request to /mcp, got %v", paths)
+	}
+}
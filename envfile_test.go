@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	t.Setenv("MCPX_TEST_HOME", "/home/test")
+
+	data := []byte(`# API credentials
+API_KEY=sk-123
+export REGION = eu-west-1
+QUOTED="a value with spaces"
+LITERAL='${NOT_EXPANDED}'
+DATA_DIR=${MCPX_TEST_HOME}/data
+URL="https://$REGION.example.com"
+
+`)
+	env, err := parseEnvFile(data)
+	if err != nil {
+		t.Fatalf("parseEnvFile failed: %v", err)
+	}
+
+	want := []string{
+		"API_KEY=sk-123",
+		"REGION=eu-west-1",
+		"QUOTED=a value with spaces",
+		"LITERAL=${NOT_EXPANDED}",
+		"DATA_DIR=/home/test/data",
+		"URL=https://eu-west-1.example.com",
+	}
+	if strings.Join(env, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected %q, got %q", want, env)
+	}
+
+	if _, err := parseEnvFile([]byte("API_KEY=x\nnot a pair\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected a line 2 error, got %v", err)
+	}
+}
+
+func TestLocalProcess_EnvFile(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, "playwright.env")
+	if err := os.WriteFile(envFile, []byte("API_KEY=from-file\nSHARED=from-file\n"), 0600); err != nil {
+		t.Fatalf("Failed to write env file: %v", err)
+	}
+	t.Setenv("MCPX_TEST_SECRETS", dir)
+
+	proc := &LocalProcess{
+		Name: "browser",
+		Config: LocalConfig{
+			Command: "sh",
+			Args:    []string{"-c", `echo "$API_KEY $SHARED"`},
+			Env:     []string{"SHARED=from-config"},
+			EnvFile: "$MCPX_TEST_SECRETS/playwright.env",
+		},
+	}
+
+	run := func() string {
+		t.Helper()
+		cmd, err := proc.command("/bin/sh")
+		if err != nil {
+			t.Fatalf("command failed: %v", err)
+		}
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("child failed: %v", err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	if got := run(); got != "from-file from-config" {
+		t.Errorf("Expected env file loaded with inline env winning, got %q", got)
+	}
+
+	proc.Config.EnvFileOverride = true
+	if got := run(); got != "from-file from-file" {
+		t.Errorf("Expected env file to win with env_file_override, got %q", got)
+	}
+
+	proc.Config.EnvFile = filepath.Join(dir, "missing.env")
+	if _, err := proc.command("/bin/sh"); err == nil {
+		t.Error("Expected error for a missing env file")
+	}
+}
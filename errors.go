@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 )
@@ -23,49 +24,99 @@ const (
 	ErrInvalidJSON      = "INVALID_JSON"
 	ErrDaemonError      = "DAEMON_ERROR"
 	ErrUnknownAction    = "UNKNOWN_ACTION"
+	ErrReadOnly         = "READ_ONLY"
+	ErrRateLimited      = "RATE_LIMITED"
+	ErrDaemonBusy       = "DAEMON_BUSY"
 )
 
 // ErrorResponse represents a structured error
 type ErrorResponse struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code       string  `json:"code"`
+	Message    string  `json:"message"`
+	RetryAfter float64 `json:"retryAfter,omitempty"` // Seconds to wait before retrying (RATE_LIMITED)
 }
 
+// ResponseVersion is the envelope schema version; see "Output" in the README
+const ResponseVersion = 1
+
 // Response is the standard response format
 type Response struct {
-	OK    bool           `json:"ok"`
-	Data  any            `json:"data,omitempty"`
-	Error *ErrorResponse `json:"error,omitempty"`
+	OK        bool           `json:"ok"`
+	Version   int            `json:"version"`
+	Data      any            `json:"data,omitempty"`
+	Error     *ErrorResponse `json:"error,omitempty"`
+	NeedsAuth bool           `json:"needsAuth,omitempty"` // Caller must run --auth for the server
+	RequestID string         `json:"requestId,omitempty"` // Daemon request ID, as logged in daemon.log
+}
+
+// CodedError is an error that carries a structured error code
+type CodedError struct {
+	Code    string
+	Message string
+}
+
+func (e *CodedError) Error() string {
+	return e.Message
+}
+
+// ErrorCode returns the structured error code
+func (e *CodedError) ErrorCode() string {
+	return e.Code
+}
+
+// codedErrorf creates a CodedError with a formatted message
+func codedErrorf(code, format string, args ...any) error {
+	return &CodedError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// errorCodeOf returns the code of the first coded error in err's chain, or fallback
+func errorCodeOf(err error, fallback string) string {
+	var coded interface{ ErrorCode() string }
+	if errors.As(err, &coded) {
+		return coded.ErrorCode()
+	}
+	return fallback
 }
 
 // ok prints a success response and exits
 func ok(data any) {
-	resp := Response{OK: true, Data: data}
+	resp := okResponse(data)
 	out, _ := json.MarshalIndent(resp, "", "  ")
-	fmt.Println(string(out))
+	emit(string(out))
 	os.Exit(0)
 }
 
 // errExit prints an error response and exits
 func errExit(code, message string) {
-	resp := Response{
-		OK:    false,
-		Error: &ErrorResponse{Code: code, Message: message},
-	}
+	resp := errResponse(code, message)
 	out, _ := json.MarshalIndent(resp, "", "  ")
-	fmt.Println(string(out))
+	emit(string(out))
 	os.Exit(1)
 }
 
 // errResponse returns an error response (for daemon use, no exit)
 func errResponse(code, message string) Response {
 	return Response{
-		OK:    false,
-		Error: &ErrorResponse{Code: code, Message: message},
+		OK:      false,
+		Version: ResponseVersion,
+		Error:   &ErrorResponse{Code: code, Message: redactText(message)},
+	}
+}
+
+// errResponseFor converts an error into a daemon error response, preserving
+// its structured code and flagging auth failures so agents can react
+func errResponseFor(err error) Response {
+	resp := errResponse(errorCodeOf(err, ErrMCPError), err.Error())
+	resp.NeedsAuth = resp.Error.Code == ErrAuthExpired
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+		resp.Error.RetryAfter = httpErr.RetryAfter.Seconds()
 	}
+	return resp
 }
 
 // okResponse returns a success response (for daemon use, no exit)
 func okResponse(data any) Response {
-	return Response{OK: true, Data: data}
+	return Response{OK: true, Version: ResponseVersion, Data: data}
 }
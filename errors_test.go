@@ -2,6 +2,8 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -23,6 +25,8 @@ func TestErrorCodes(t *testing.T) {
 		ErrInvalidJSON,
 		ErrDaemonError,
 		ErrUnknownAction,
+		ErrReadOnly,
+		ErrRateLimited,
 	}
 
 	seen := make(map[string]bool)
@@ -195,3 +199,53 @@ func TestErrorResponseStructure(t *testing.T) {
 		t.Errorf("Expected message 'Connection failed', got %v", errObj["message"])
 	}
 }
+
+func TestErrorCodeOf(t *testing.T) {
+	err := codedErrorf(ErrUnknownTool, "tool '%s' is not available", "drop")
+	if err.Error() != "tool 'drop' is not available" {
+		t.Errorf("Unexpected message: %s", err.Error())
+	}
+
+	if code := errorCodeOf(err, ErrMCPError); code != ErrUnknownTool {
+		t.Errorf("Expected %s, got %s", ErrUnknownTool, code)
+	}
+
+	wrapped := fmt.Errorf("call failed: %w", err)
+	if code := errorCodeOf(wrapped, ErrMCPError); code != ErrUnknownTool {
+		t.Errorf("Expected wrapped code %s, got %s", ErrUnknownTool, code)
+	}
+
+	if code := errorCodeOf(errors.New("plain"), ErrMCPError); code != ErrMCPError {
+		t.Errorf("Expected fallback %s, got %s", ErrMCPError, code)
+	}
+}
+
+func TestResponsesCarryVersion(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+
+	responses := map[string]Response{
+		"okResponse":     okResponse(map[string]any{"message": "hi"}),
+		"errResponse":    errResponse(ErrNotFound, "missing"),
+		"errResponseFor": errResponseFor(codedErrorf(ErrAuthExpired, "expired")),
+		"daemon status":  daemon.handleCommand(DaemonCommand{Action: "status"}),
+		"daemon error":   daemon.handleCommand(DaemonCommand{Action: "bogus"}),
+		"daemon unknown": daemon.handleCommand(DaemonCommand{Action: "tools", Server: "missing"}),
+	}
+
+	for name, resp := range responses {
+		data, _ := json.Marshal(resp)
+		var raw map[string]any
+		if err := json.Unmarshal(data, &raw); err != nil {
+			t.Fatalf("%s: invalid JSON: %v", name, err)
+		}
+		if raw["version"] != float64(ResponseVersion) {
+			t.Errorf("%s: expected version %d, got %s", name, ResponseVersion, data)
+		}
+	}
+}
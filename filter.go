@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// filterStep is one step of a --filter path: an object key, an array index
+// or [] (every element of an array)
+type filterStep struct {
+	key   string
+	index int
+	kind  int
+}
+
+const (
+	stepKey = iota
+	stepIndex
+	stepEach
+)
+
+func (s filterStep) String() string {
+	switch s.kind {
+	case stepIndex:
+		return fmt.Sprintf("[%d]", s.index)
+	case stepEach:
+		return "[]"
+	}
+	if isFilterIdent(s.key) {
+		return "." + s.key
+	}
+	return "." + strconv.Quote(s.key)
+}
+
+// applyFilterExpr evaluates a --filter expression against a tool result.
+// The expression is a jq-style path: ".content[0].text", ".data.rows[-1]",
+// ."key with spaces", .["key"] and .items[].name (which collects a field
+// from every element). A leading "$" (JSONPath style) is accepted and "."
+// alone is the whole result.
+func applyFilterExpr(expr string, value any) (any, error) {
+	steps, err := parseFilter(expr)
+	if err != nil {
+		return nil, codedErrorf(ErrInvalidArgs, "invalid --filter %q: %v", expr, err)
+	}
+	result, err := applyFilter(value, steps, "")
+	if err != nil {
+		return nil, codedErrorf(ErrNotFound, "--filter %s: %v", expr, err)
+	}
+	return result, nil
+}
+
+// parseFilter splits a filter expression into steps
+func parseFilter(expr string) ([]filterStep, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(expr), "$")
+	if s == "" || s == "." {
+		return nil, nil
+	}
+	if s[0] != '.' && s[0] != '[' {
+		return nil, fmt.Errorf("must start with '.'")
+	}
+
+	var steps []filterStep
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '.':
+			i++
+			switch {
+			case i < len(s) && s[i] == '[':
+				// ".[0]" is the same as "[0]"
+			case i < len(s) && s[i] == '"':
+				key, n, err := readQuoted(s[i:])
+				if err != nil {
+					return nil, err
+				}
+				steps = append(steps, filterStep{kind: stepKey, key: key})
+				i += n
+			default:
+				start := i
+				for i < len(s) && isFilterIdentChar(s[i]) {
+					i++
+				}
+				if start == i {
+					return nil, fmt.Errorf("expected a key after '.' at position %d", start)
+				}
+				steps = append(steps, filterStep{kind: stepKey, key: s[start:i]})
+			}
+
+		case '[':
+			end := strings.IndexByte(s[i:], ']')
+			if strings.HasPrefix(s[i+1:], `"`) {
+				// The key may itself contain ']'
+				key, n, err := readQuoted(s[i+1:])
+				if err != nil {
+					return nil, err
+				}
+				if i+1+n >= len(s) || s[i+1+n] != ']' {
+					return nil, fmt.Errorf("missing ']' at position %d", i+1+n)
+				}
+				steps = append(steps, filterStep{kind: stepKey, key: key})
+				i += n + 2
+				continue
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("missing ']' at position %d", i)
+			}
+			inner := strings.TrimSpace(s[i+1 : i+end])
+			if inner == "" {
+				steps = append(steps, filterStep{kind: stepEach})
+			} else {
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index %q at position %d", inner, i+1)
+				}
+				steps = append(steps, filterStep{kind: stepIndex, index: index})
+			}
+			i += end + 1
+
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d", s[i], i)
+		}
+	}
+	return steps, nil
+}
+
+// readQuoted reads a double-quoted string at the start of s and returns it
+// with the number of bytes consumed
+func readQuoted(s string) (string, int, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			key, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return "", 0, fmt.Errorf("invalid quoted key %s", s[:i+1])
+			}
+			return key, i + 1, nil
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quoted key")
+}
+
+func isFilterIdentChar(c byte) bool {
+	return c == '_' || c == '-' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func isFilterIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isFilterIdentChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyFilter walks value along steps. at is the path walked so far, for
+// error messages.
+func applyFilter(value any, steps []filterStep, at string) (any, error) {
+	if len(steps) == 0 {
+		return value, nil
+	}
+	step, rest := steps[0], steps[1:]
+	where := at
+	if where == "" {
+		where = "."
+	}
+
+	switch step.kind {
+	case stepKey:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s is %s, not an object", where, jsonTypeName(value))
+		}
+		child, ok := obj[step.key]
+		if !ok {
+			return nil, fmt.Errorf("no key %q at %s", step.key, where)
+		}
+		return applyFilter(child, rest, at+step.String())
+
+	case stepIndex:
+		arr, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%s is %s, not an array", where, jsonTypeName(value))
+		}
+		index := step.index
+		if index < 0 {
+			index += len(arr)
+		}
+		if index < 0 || index >= len(arr) {
+			return nil, fmt.Errorf("index %d out of range at %s (%d elements)", step.index, where, len(arr))
+		}
+		return applyFilter(arr[index], rest, at+step.String())
+
+	default:
+		arr, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%s is %s, not an array", where, jsonTypeName(value))
+		}
+		results := make([]any, len(arr))
+		for i, item := range arr {
+			result, err := applyFilter(item, rest, fmt.Sprintf("%s[%d]", at, i))
+			if err != nil {
+				return nil, err
+			}
+			results[i] = result
+		}
+		return results, nil
+	}
+}
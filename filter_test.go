@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// filterResult is a tools/call result decoded the way the client decodes it
+func filterResult(t *testing.T) any {
+	t.Helper()
+	var result any
+	raw := `{
+		"content": [
+			{"type": "text", "text": "first"},
+			{"type": "text", "text": "second"}
+		],
+		"structuredContent": {"rows": [{"id": 1}, {"id": 2}], "row count": 2},
+		"isError": false
+	}`
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		t.Fatalf("Failed to decode result: %v", err)
+	}
+	return result
+}
+
+func TestApplyFilterExpr_KeyAccess(t *testing.T) {
+	result := filterResult(t)
+
+	tests := []struct {
+		expr string
+		want any
+	}{
+		{".isError", false},
+		{"$.structuredContent.rows[1].id", float64(2)},
+		{`."structuredContent"."row count"`, float64(2)},
+		{`.structuredContent["row count"]`, float64(2)},
+	}
+	for _, tt := range tests {
+		got, err := applyFilterExpr(tt.expr, result)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.expr, tt.want, got)
+		}
+	}
+
+	whole, err := applyFilterExpr(".", result)
+	if err != nil || !reflect.DeepEqual(whole, result) {
+		t.Errorf("Expected '.' to return the whole result, got %v (%v)", whole, err)
+	}
+}
+
+func TestApplyFilterExpr_ArrayIndexing(t *testing.T) {
+	result := filterResult(t)
+
+	tests := []struct {
+		expr string
+		want any
+	}{
+		{".content[0].text", "first"},
+		{".content[-1].text", "second"},
+		{".content.[1].text", "second"},
+		{".content[].text", []any{"first", "second"}},
+		{".structuredContent.rows[].id", []any{float64(1), float64(2)}},
+	}
+	for _, tt := range tests {
+		got, err := applyFilterExpr(tt.expr, result)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.expr, tt.want, got)
+		}
+	}
+}
+
+func TestApplyFilterExpr_MissingPath(t *testing.T) {
+	result := filterResult(t)
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{".content[0].missing", `no key "missing" at .content[0]`},
+		{".content[5].text", "index 5 out of range at .content (2 elements)"},
+		{".content[0].text.value", ".content[0].text is string, not an object"},
+		{".isError[0]", ".isError is boolean, not an array"},
+	}
+	for _, tt := range tests {
+		_, err := applyFilterExpr(tt.expr, result)
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.expr)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %q", tt.expr, tt.want, err)
+		}
+		if code := errorCodeOf(err, ""); code != ErrNotFound {
+			t.Errorf("%s: expected code %s, got %s", tt.expr, ErrNotFound, code)
+		}
+	}
+}
+
+func TestParseFilter_Invalid(t *testing.T) {
+	for _, expr := range []string{"content", ".content[0", ".content[x]", `.["key]`, ".."} {
+		if _, err := parseFilter(expr); err == nil {
+			t.Errorf("%q: expected a parse error", expr)
+		}
+	}
+}
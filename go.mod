@@ -2,4 +2,9 @@ module github.com/lakshminp/mcpx
 
 go 1.22.2
 
-require github.com/google/uuid v1.6.0
+require (
+	github.com/andybalholm/brotli v1.2.5
+	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.3
+	golang.org/x/crypto v0.33.0
+)
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// preCallTimeout bounds how long a pre_call_command may run
+var preCallTimeout = 30 * time.Second
+
+// runPreCallHook pipes a tool call's arguments as JSON through the server's
+// pre_call_command and returns the JSON object it prints, which replaces
+// them. MCPX_SERVER and MCPX_TOOL tell the hook which call it is handling.
+func runPreCallHook(ctx context.Context, command, serverName, toolName string, arguments map[string]any) (map[string]any, error) {
+	if arguments == nil {
+		arguments = map[string]any{}
+	}
+	input, err := json.Marshal(arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, preCallTimeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, command)
+	cmd.Env = append(os.Environ(), "MCPX_SERVER="+serverName, "MCPX_TOOL="+toolName)
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("pre_call_command for '%s' failed: %w: %s", serverName, err, msg)
+		}
+		return nil, fmt.Errorf("pre_call_command for '%s' failed: %w", serverName, err)
+	}
+
+	var transformed map[string]any
+	if err := json.Unmarshal(out, &transformed); err != nil || transformed == nil {
+		return nil, fmt.Errorf("pre_call_command for '%s' must print a JSON object: got %q", serverName, truncateOutput(out))
+	}
+	return transformed, nil
+}
+
+// truncateOutput shortens command output quoted in an error
+func truncateOutput(out []byte) string {
+	const max = 200
+	s := strings.TrimSpace(string(out))
+	if len(s) > max {
+		return cutUTF8(s, max) + "..."
+	}
+	return s
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMCPClient_CallTool_PreCallCommand(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	received := make(chan map[string]any, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ID == "" {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		result := map[string]any{"protocolVersion": ProtocolVersion, "capabilities": map[string]any{"tools": map[string]any{}}}
+		if req.Method == "tools/call" {
+			params, _ := req.Params.(map[string]any)
+			args, _ := params["arguments"].(map[string]any)
+			received <- args
+			result = map[string]any{"content": []any{}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}))
+	defer server.Close()
+
+	// The hook sees default_args too, and learns which tool is being called
+	client, err := NewMCPClient("signed", ServerConfig{
+		URL:            server.URL,
+		DefaultArgs:    map[string]any{"region": "eu"},
+		PreCallCommand: `sed "s/^{/{\"signed_for\":\"$MCPX_TOOL\",/"`,
+	})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.CallTool("upload", map[string]any{"file": "a.txt"}); err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	args := <-received
+	if args["signed_for"] != "upload" || args["file"] != "a.txt" || args["region"] != "eu" {
+		t.Errorf("Expected the hook's field added to the arguments, got %v", args)
+	}
+}
+
+func TestMCPClient_CallTool_PreCallCommandFails(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Method == "tools/call" {
+			t.Error("Expected no tool call when the hook fails")
+		}
+		if req.ID == "" {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]any{"protocolVersion": ProtocolVersion}})
+	}))
+	defer server.Close()
+
+	for command, want := range map[string]string{
+		"echo 'no signing key' >&2; exit 1": "no signing key",
+		"echo not-json":                     "must print a JSON object",
+	} {
+		client, _ := NewMCPClient("signed", ServerConfig{URL: server.URL, PreCallCommand: command})
+		_, err := client.CallTool("upload", nil)
+		client.Close()
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: expected error containing %q, got %v", command, want, err)
+		}
+	}
+}
+
+func TestTruncateOutput_RuneBoundary(t *testing.T) {
+	got := truncateOutput([]byte(strings.Repeat("é", 150)))
+	if !utf8.ValidString(got) {
+		t.Errorf("Expected valid UTF-8, got %q", got)
+	}
+	if !strings.HasSuffix(got, "...") || len(got) > 203 {
+		t.Errorf("Expected output cut to 200 bytes plus an ellipsis, got %d bytes", len(got))
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// promptArguments asks for each property in a tool's inputSchema and
+// assembles the arguments map. Required properties are asked first; an
+// empty answer takes the schema default, or skips an optional property.
+func promptArguments(schema map[string]any, in io.Reader, out io.Writer) (map[string]any, error) {
+	properties, _ := schema["properties"].(map[string]any)
+	required := requiredSet(schema)
+
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if required[names[i]] != required[names[j]] {
+			return required[names[i]]
+		}
+		return names[i] < names[j]
+	})
+
+	reader := bufio.NewReader(in)
+	args := make(map[string]any)
+	for _, name := range names {
+		prop, _ := properties[name].(map[string]any)
+		value, set, err := promptProperty(reader, out, name, prop, required[name])
+		if err != nil {
+			return nil, err
+		}
+		if set {
+			args[name] = value
+		}
+	}
+	return args, nil
+}
+
+// promptProperty asks for one property until a valid value is entered
+func promptProperty(reader *bufio.Reader, out io.Writer, name string, prop map[string]any, required bool) (any, bool, error) {
+	propType, _ := prop["type"].(string)
+	def, hasDefault := prop["default"]
+	enum, _ := prop["enum"].([]any)
+
+	for {
+		fmt.Fprint(out, promptLabel(name, prop, required))
+
+		line, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return nil, false, fmt.Errorf("no input for '%s'", name)
+		}
+		line = strings.TrimSpace(line)
+
+		if line == "" {
+			if hasDefault {
+				return def, true, nil
+			}
+			if !required {
+				return nil, false, nil
+			}
+			fmt.Fprintf(out, "  '%s' is required\n", name)
+			continue
+		}
+
+		value, err := parseArgumentValue(line, propType)
+		if err != nil {
+			fmt.Fprintf(out, "  %v\n", err)
+			continue
+		}
+		if len(enum) > 0 && !enumContains(enum, value) {
+			fmt.Fprintf(out, "  must be one of %s\n", formatEnum(enum))
+			continue
+		}
+		return value, true, nil
+	}
+}
+
+// promptLabel renders e.g. "query (string, required) - SQL to run [default: x]: "
+func promptLabel(name string, prop map[string]any, required bool) string {
+	var b strings.Builder
+	b.WriteString(name)
+
+	var tags []string
+	if t, ok := prop["type"].(string); ok {
+		tags = append(tags, t)
+	}
+	if required {
+		tags = append(tags, "required")
+	}
+	if len(tags) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(tags, ", "))
+	}
+	if desc, ok := prop["description"].(string); ok && desc != "" {
+		fmt.Fprintf(&b, " - %s", desc)
+	}
+	if enum, ok := prop["enum"].([]any); ok && len(enum) > 0 {
+		fmt.Fprintf(&b, " {%s}", formatEnum(enum))
+	}
+	if def, ok := prop["default"]; ok {
+		fmt.Fprintf(&b, " [default: %v]", def)
+	}
+	b.WriteString(": ")
+	return b.String()
+}
+
+// parseArgumentValue converts typed input to the JSON type the schema expects
+func parseArgumentValue(input, propType string) (any, error) {
+	switch propType {
+	case "integer":
+		n, err := strconv.ParseInt(input, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected an integer")
+		}
+		return n, nil
+	case "number":
+		f, err := strconv.ParseFloat(input, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected a number")
+		}
+		return f, nil
+	case "boolean":
+		switch strings.ToLower(input) {
+		case "y", "yes", "true", "1":
+			return true, nil
+		case "n", "no", "false", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("expected yes or no")
+	case "array", "object":
+		var v any
+		if err := json.Unmarshal([]byte(input), &v); err != nil {
+			return nil, fmt.Errorf("expected JSON %s", propType)
+		}
+		return v, nil
+	default:
+		return input, nil
+	}
+}
+
+// enumContains compares by JSON value so 3 (int64) matches 3.0 from the schema
+func enumContains(enum []any, value any) bool {
+	got, _ := json.Marshal(value)
+	for _, e := range enum {
+		want, _ := json.Marshal(e)
+		if string(got) == string(want) {
+			return true
+		}
+		if f, ok := e.(float64); ok {
+			if n, ok := value.(int64); ok && float64(n) == f {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func formatEnum(enum []any) string {
+	parts := make([]string, len(enum))
+	for i, e := range enum {
+		parts[i] = fmt.Sprint(e)
+	}
+	return strings.Join(parts, "|")
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func sampleToolSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query":  map[string]any{"type": "string", "description": "SQL to run"},
+			"limit":  map[string]any{"type": "integer", "default": float64(10)},
+			"format": map[string]any{"type": "string", "enum": []any{"json", "csv"}},
+			"dryRun": map[string]any{"type": "boolean"},
+			"tags":   map[string]any{"type": "array"},
+		},
+		"required": []any{"query"},
+	}
+}
+
+func TestPromptArguments(t *testing.T) {
+	// Prompt order: query (required), then dryRun, format, limit, tags
+	input := strings.Join([]string{
+		"SELECT 1",
+		"yes",
+		"csv",
+		"",
+		`["a","b"]`,
+	}, "\n") + "\n"
+
+	var out bytes.Buffer
+	args, err := promptArguments(sampleToolSchema(), strings.NewReader(input), &out)
+	if err != nil {
+		t.Fatalf("promptArguments failed: %v", err)
+	}
+
+	want := map[string]any{
+		"query":  "SELECT 1",
+		"dryRun": true,
+		"format": "csv",
+		"limit":  float64(10), // schema default
+		"tags":   []any{"a", "b"},
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("Expected %v, got %v", want, args)
+	}
+
+	prompts := out.String()
+	if !strings.Contains(prompts, "query (string, required) - SQL to run: ") {
+		t.Errorf("Expected required marker and description in prompt, got %q", prompts)
+	}
+	if !strings.Contains(prompts, "{json|csv}") || !strings.Contains(prompts, "[default: 10]") {
+		t.Errorf("Expected enum and default in prompts, got %q", prompts)
+	}
+}
+
+func TestPromptArguments_Reprompts(t *testing.T) {
+	// Empty required value, bad integer, bad enum, then valid answers; optional skipped
+	input := strings.Join([]string{
+		"",
+		"SELECT 1",
+		"",
+		"xml",
+		"json",
+		"ten",
+		"5",
+		"",
+	}, "\n") + "\n"
+
+	var out bytes.Buffer
+	args, err := promptArguments(sampleToolSchema(), strings.NewReader(input), &out)
+	if err != nil {
+		t.Fatalf("promptArguments failed: %v", err)
+	}
+
+	want := map[string]any{"query": "SELECT 1", "format": "json", "limit": int64(5)}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("Expected %v, got %v", want, args)
+	}
+	for _, msg := range []string{"'query' is required", "must be one of json|csv", "expected an integer"} {
+		if !strings.Contains(out.String(), msg) {
+			t.Errorf("Expected %q in output", msg)
+		}
+	}
+}
+
+func TestPromptArguments_EOF(t *testing.T) {
+	var out bytes.Buffer
+	if _, err := promptArguments(sampleToolSchema(), strings.NewReader(""), &out); err == nil {
+		t.Error("Expected error when input ends before required fields")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"strings"
+)
+
+// argFlags collects repeatable -a key=value / key:=json tool arguments
+type argFlags []string
+
+func (a *argFlags) String() string {
+	return strings.Join(*a, ", ")
+}
+
+func (a *argFlags) Set(value string) error {
+	*a = append(*a, value)
+	return nil
+}
+
+// applyArgAssignments sets tool arguments from httpie-style assignments:
+// key=value sets a string, key:=value parses value as JSON (numbers, bools,
+// arrays, objects, null). Dotted keys (a.b=c) create nested objects.
+func applyArgAssignments(arguments map[string]any, assignments []string) error {
+	for _, assignment := range assignments {
+		eq := strings.Index(assignment, "=")
+		if eq <= 0 {
+			return codedErrorf(ErrInvalidArgs, "invalid argument %q (use key=value or key:=json)", assignment)
+		}
+
+		key, raw := assignment[:eq], assignment[eq+1:]
+		var value any = raw
+		if strings.HasSuffix(key, ":") {
+			key = strings.TrimSuffix(key, ":")
+			if err := json.Unmarshal([]byte(raw), &value); err != nil {
+				return codedErrorf(ErrInvalidJSON, "invalid JSON for argument %q: %v", key, err)
+			}
+		}
+		if err := setArgPath(arguments, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setArgPath stores value at a dotted key path, creating objects on the way
+func setArgPath(arguments map[string]any, key string, value any) error {
+	parts := strings.Split(key, ".")
+	current := arguments
+	for i, part := range parts {
+		if part == "" {
+			return codedErrorf(ErrInvalidArgs, "invalid argument key %q", key)
+		}
+		if i == len(parts)-1 {
+			current[part] = value
+			return nil
+		}
+
+		next, exists := current[part]
+		if !exists {
+			child := make(map[string]any)
+			current[part] = child
+			current = child
+			continue
+		}
+		child, ok := next.(map[string]any)
+		if !ok {
+			return codedErrorf(ErrInvalidArgs, "argument %q conflicts with %q, which is not an object", key, strings.Join(parts[:i+1], "."))
+		}
+		current = child
+	}
+	return nil
+}
+
+// parseInterspersed parses fs from args, also accepting flags after
+// positional arguments (mcpx --call srv tool -a q=x --format text), and
+// returns the positionals in order. Everything after "--" is positional.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
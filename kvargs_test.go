@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestApplyArgAssignments(t *testing.T) {
+	arguments := map[string]any{"q": "from json", "keep": true}
+	err := applyArgAssignments(arguments, []string{
+		"q=x",
+		"limit:=10",
+		"verbose:=true",
+		"tags:=[\"a\",\"b\"]",
+		"ratio=0.5",
+		"filter.status=open",
+		"filter.owner.id:=42",
+		"expr=a=b",
+	})
+	if err != nil {
+		t.Fatalf("applyArgAssignments failed: %v", err)
+	}
+
+	want := map[string]any{
+		"q":       "x",
+		"keep":    true,
+		"limit":   float64(10),
+		"verbose": true,
+		"tags":    []any{"a", "b"},
+		"ratio":   "0.5", // Plain = always means a string
+		"filter": map[string]any{
+			"status": "open",
+			"owner":  map[string]any{"id": float64(42)},
+		},
+		"expr": "a=b",
+	}
+	if !reflect.DeepEqual(arguments, want) {
+		t.Errorf("Got %#v\nwant %#v", arguments, want)
+	}
+}
+
+func TestApplyArgAssignments_Errors(t *testing.T) {
+	tests := []struct {
+		assignments []string
+		code        string
+	}{
+		{[]string{"novalue"}, ErrInvalidArgs},
+		{[]string{"=x"}, ErrInvalidArgs},
+		{[]string{"limit:=ten"}, ErrInvalidJSON},
+		{[]string{"a..b=c"}, ErrInvalidArgs},
+		{[]string{"a=1", "a.b=2"}, ErrInvalidArgs},
+	}
+
+	for _, tt := range tests {
+		err := applyArgAssignments(make(map[string]any), tt.assignments)
+		if errorCodeOf(err, "") != tt.code {
+			t.Errorf("%v: expected %s error, got %v", tt.assignments, tt.code, err)
+		}
+	}
+}
+
+func TestParseInterspersed(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	call := fs.Bool("call", false, "")
+	format := fs.String("format", "json", "")
+	var args argFlags
+	fs.Var(&args, "a", "")
+
+	positional, err := parseInterspersed(fs, []string{"--call", "srv", "tool", "-a", "q=x", "--format", "text", "-a", "n:=1", "--", "--not-a-flag"})
+	if err != nil {
+		t.Fatalf("parseInterspersed failed: %v", err)
+	}
+
+	if !*call || *format != "text" {
+		t.Errorf("Expected flags after positionals to be parsed, got call=%v format=%s", *call, *format)
+	}
+	if !reflect.DeepEqual([]string(args), []string{"q=x", "n:=1"}) {
+		t.Errorf("Expected both -a values, got %v", args)
+	}
+	if !reflect.DeepEqual(positional, []string{"srv", "tool", "--not-a-flag"}) {
+		t.Errorf("Unexpected positionals %v", positional)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+)
+
+// launchWrapper applies the limits passed as $1 (data segment, KB) and $2
+// (CPU seconds) and the umask in $3 in a shell, then execs the real command
+// in its place so the server inherits them. "-" leaves a setting alone.
+const launchWrapper = `[ "$1" = - ] || ulimit -d "$1" || exit 126
+[ "$2" = - ] || ulimit -t "$2" || exit 126
+[ "$3" = - ] || umask "$3" || exit 126
+shift 3
+exec "$@"`
+
+// hasResourceLimits reports whether any resource limit is configured
+func (c LocalConfig) hasResourceLimits() bool {
+	return c.MemoryLimitMB > 0 || c.CPULimit > 0
+}
+
+// parseUmask parses an octal umask such as "077"
+func parseUmask(s string) (uint32, error) {
+	mask, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mask > 0777 {
+		return 0, fmt.Errorf("invalid umask %q (use octal, e.g. 077)", s)
+	}
+	return uint32(mask), nil
+}
+
+// limitedCommand returns the program and arguments that run cmdPath with
+// args under the configured rlimits and umask: memory_limit_mb caps the data
+// segment (RLIMIT_DATA, so allocations beyond it fail) and cpu_limit caps CPU
+// time (RLIMIT_CPU, after which the kernel sends SIGXCPU). With nothing to
+// apply, or on platforms without setrlimit, the command is returned unchanged.
+func limitedCommand(name, cmdPath string, args []string, cfg LocalConfig) (string, []string) {
+	if !cfg.hasResourceLimits() && cfg.Umask == "" {
+		return cmdPath, args
+	}
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		logger.Warn("resource limits and umask are not supported on this platform; ignoring", "server", name, "os", runtime.GOOS)
+		return cmdPath, args
+	}
+
+	memory, cpu, umask := "-", "-", "-"
+	if cfg.MemoryLimitMB > 0 {
+		memory = fmt.Sprint(cfg.MemoryLimitMB * 1024)
+	}
+	if cfg.CPULimit > 0 {
+		cpu = fmt.Sprint(cfg.CPULimit)
+	}
+	if cfg.Umask != "" {
+		umask = cfg.Umask
+	}
+	wrapped := append([]string{"-c", launchWrapper, "sh", memory, cpu, umask, cmdPath}, args...)
+	return "/bin/sh", wrapped
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestLimitedCommand_AppliesRlimits(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("rlimit wrapper is tested on Linux")
+	}
+
+	cfg := LocalConfig{MemoryLimitMB: 2048, CPULimit: 7}
+	program, args := limitedCommand("test", "/bin/sh", []string{"-c", "ulimit -d; ulimit -t; echo $0"}, cfg)
+	out, err := exec.Command(program, args...).Output()
+	if err != nil {
+		t.Fatalf("limited command failed: %v", err)
+	}
+	got := strings.Fields(string(out))
+	if len(got) != 3 || got[0] != "2097152" || got[1] != "7" {
+		t.Errorf("Expected data limit 2097152 KB and CPU limit 7s, got %q", got)
+	}
+	if got[2] != "/bin/sh" {
+		t.Errorf("Expected the wrapper to exec the real command, got $0=%q", got[2])
+	}
+
+	// Only the configured limit is set; the data limit stays inherited
+	inherited, _ := exec.Command("/bin/sh", "-c", "ulimit -d").Output()
+	program, args = limitedCommand("test", "/bin/sh", []string{"-c", "ulimit -d; ulimit -t"}, LocalConfig{CPULimit: 3})
+	out, _ = exec.Command(program, args...).Output()
+	if got := strings.Fields(string(out)); len(got) != 2 || got[0] != strings.TrimSpace(string(inherited)) || got[1] != "3" {
+		t.Errorf("Expected only the CPU limit set, got %q", got)
+	}
+}
+
+func TestLimitedCommand_NoLimits(t *testing.T) {
+	program, args := limitedCommand("test", "/usr/bin/node", []string{"server.js"}, LocalConfig{})
+	if program != "/usr/bin/node" || len(args) != 1 || args[0] != "server.js" {
+		t.Errorf("Expected command unchanged without limits, got %s %v", program, args)
+	}
+}
+
+func TestLimitExceeded(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("signal exits are tested on Linux")
+	}
+
+	cmd := exec.Command("/bin/sh", "-c", "kill -XCPU $$")
+	cmd.Run()
+	reason := limitExceeded(cmd.ProcessState, LocalConfig{CPULimit: 1})
+	if !strings.Contains(reason, "cpu_limit") {
+		t.Errorf("Expected SIGXCPU reported as exceeding cpu_limit, got %q", reason)
+	}
+	if reason := limitExceeded(cmd.ProcessState, LocalConfig{}); reason != "" {
+		t.Errorf("Expected no report without limits, got %q", reason)
+	}
+
+	cmd = exec.Command("/bin/sh", "-c", "exit 1")
+	cmd.Run()
+	if reason := limitExceeded(cmd.ProcessState, LocalConfig{MemoryLimitMB: 64}); reason != "" {
+		t.Errorf("Expected a plain exit not blamed on limits, got %q", reason)
+	}
+}
+
+func TestLocalProcess_ExitLimitReason_IgnoresStopKill(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("signal exits are tested on Linux")
+	}
+
+	cmd := exec.Command("/bin/sh", "-c", "kill -KILL $$")
+	cmd.Run()
+	proc := &LocalProcess{Name: "test", Cmd: cmd, Config: LocalConfig{MemoryLimitMB: 64}}
+	if reason := proc.exitLimitReason(); !strings.Contains(reason, "memory_limit_mb") {
+		t.Errorf("Expected an unexplained SIGKILL reported against memory_limit_mb, got %q", reason)
+	}
+
+	// Stop's own SIGKILL after the grace period is not a limit violation
+	proc.killed = true
+	if reason := proc.exitLimitReason(); reason != "" {
+		t.Errorf("Expected no report for a SIGKILL sent by Stop, got %q", reason)
+	}
+}
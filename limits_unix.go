@@ -0,0 +1,33 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// limitExceeded explains an exit that looks like the process hit one of its
+// resource limits, or returns "" if it doesn't
+func limitExceeded(state *os.ProcessState, cfg LocalConfig) string {
+	if state == nil || !cfg.hasResourceLimits() {
+		return ""
+	}
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok {
+		return ""
+	}
+
+	if status.Signaled() && status.Signal() == syscall.SIGXCPU {
+		return fmt.Sprintf("killed for exceeding cpu_limit (%d CPU seconds)", cfg.CPULimit)
+	}
+	// Failed allocations usually end in an abort or a crash, not a clean exit
+	if cfg.MemoryLimitMB > 0 && status.Signaled() {
+		switch status.Signal() {
+		case syscall.SIGKILL, syscall.SIGABRT, syscall.SIGSEGV, syscall.SIGBUS:
+			return fmt.Sprintf("killed by %s, likely for exceeding memory_limit_mb (%d MB)", status.Signal(), cfg.MemoryLimitMB)
+		}
+	}
+	return ""
+}
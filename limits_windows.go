@@ -0,0 +1,11 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// limitExceeded returns "": limits aren't applied on Windows (limitedCommand
+// warns and runs the server unchanged), so no exit is blamed on them
+func limitExceeded(state *os.ProcessState, cfg LocalConfig) string {
+	return ""
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// toolListingTTL is how long a --tools listing can be referred to by index
+const toolListingTTL = 24 * time.Hour
+
+// ToolListing is the tool names of a server's last --tools listing, in the
+// order they were printed
+type ToolListing struct {
+	Tools    []string `json:"tools"`
+	ListedAt int64    `json:"listed_at"` // Unix seconds
+}
+
+// loadToolListings reads every server's last listing (empty if none saved)
+func loadToolListings() (map[string]ToolListing, error) {
+	data, err := os.ReadFile(ListingFile)
+	if os.IsNotExist(err) {
+		return make(map[string]ToolListing), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var listings map[string]ToolListing
+	if err := json.Unmarshal(data, &listings); err != nil {
+		return nil, err
+	}
+	return listings, nil
+}
+
+// SaveToolListing records the order of a server's tools as just listed
+func SaveToolListing(serverName string, tools []Tool) error {
+	listings, err := loadToolListings()
+	if err != nil {
+		listings = make(map[string]ToolListing) // Corrupt file: start over
+	}
+
+	names := make([]string, len(tools))
+	for i, tool := range tools {
+		names[i] = tool.Name
+	}
+	listings[serverName] = ToolListing{Tools: names, ListedAt: time.Now().Unix()}
+
+	if err := os.MkdirAll(ConfigDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(listings)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(ListingFile, data, 0644)
+}
+
+// resolveToolRef turns "#N" into the Nth (1-based) tool of the server's last
+// listing; any other name is returned unchanged
+func resolveToolRef(serverName, ref string) (string, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return ref, nil
+	}
+	n, err := strconv.Atoi(ref[1:])
+	if err != nil || n < 1 {
+		return "", codedErrorf(ErrInvalidArgs, "invalid tool index %q (use #1, #2, ...)", ref)
+	}
+
+	listings, err := loadToolListings()
+	if err != nil {
+		return "", fmt.Errorf("failed to read tool listings: %w", err)
+	}
+	listing, ok := listings[serverName]
+	if !ok || time.Since(time.Unix(listing.ListedAt, 0)) > toolListingTTL {
+		return "", codedErrorf(ErrNotFound, "no recent tool listing for '%s'; run: mcpx --tools %s", serverName, serverName)
+	}
+	if n > len(listing.Tools) {
+		return "", codedErrorf(ErrInvalidArgs, "tool %s is out of range: the last listing of '%s' has %d tools", ref, serverName, len(listing.Tools))
+	}
+	return listing.Tools[n-1], nil
+}
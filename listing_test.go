@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestResolveToolRef(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	tools := []Tool{{Name: "list_tables"}, {Name: "execute_sql"}, {Name: "get_logs"}}
+	if err := SaveToolListing("supabase", tools); err != nil {
+		t.Fatalf("SaveToolListing failed: %v", err)
+	}
+
+	tests := []struct {
+		server, ref string
+		want        string
+		wantCode    string
+	}{
+		{"supabase", "#1", "list_tables", ""},
+		{"supabase", "#3", "get_logs", ""},
+		{"supabase", "execute_sql", "execute_sql", ""},
+		{"supabase", "#4", "", ErrInvalidArgs},
+		{"supabase", "#0", "", ErrInvalidArgs},
+		{"supabase", "#two", "", ErrInvalidArgs},
+		{"github", "#1", "", ErrNotFound},
+		{"github", "create_issue", "create_issue", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := resolveToolRef(tt.server, tt.ref)
+		if tt.wantCode != "" {
+			if errorCodeOf(err, "") != tt.wantCode {
+				t.Errorf("resolveToolRef(%s, %s): expected %s error, got %v", tt.server, tt.ref, tt.wantCode, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("resolveToolRef(%s, %s) = %q, %v; want %q", tt.server, tt.ref, got, err, tt.want)
+		}
+	}
+}
+
+func TestResolveToolRef_StaleListing(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	stale := map[string]ToolListing{
+		"supabase": {Tools: []string{"list_tables"}, ListedAt: time.Now().Add(-2 * toolListingTTL).Unix()},
+	}
+	data, _ := json.Marshal(stale)
+	if err := os.WriteFile(ListingFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := resolveToolRef("supabase", "#1"); errorCodeOf(err, "") != ErrNotFound {
+		t.Errorf("Expected a stale listing to be ignored, got %v", err)
+	}
+
+	// A fresh listing for one server leaves the others in place
+	if err := SaveToolListing("github", []Tool{{Name: "create_issue"}}); err != nil {
+		t.Fatalf("SaveToolListing failed: %v", err)
+	}
+	listings, _ := loadToolListings()
+	if _, ok := listings["supabase"]; !ok {
+		t.Error("Expected other servers' listings to be kept")
+	}
+}
@@ -2,12 +2,16 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -23,7 +27,14 @@ type LocalProcess struct {
 	Restarts   int
 	mu         sync.Mutex
 	stopping   bool
+	killed     bool         // Stop force-killed the process after the grace period
+	cgroup     *localCgroup // Holds the process tree under memory_limit_mb, where cgroups are available
 	done       chan struct{}
+
+	// Stdio transport (Config.Stdio): JSON-RPC messages in and out
+	stdin    io.WriteCloser
+	stdinMu  sync.Mutex
+	messages chan []byte
 }
 
 // ProcessInfo holds status information for a local process
@@ -37,6 +48,14 @@ type ProcessInfo struct {
 	LogFile  string `json:"log_file"`
 }
 
+// localProcessRecord is the persisted state of a running local server, used
+// to find processes orphaned by a daemon that was killed without cleanup
+type localProcessRecord struct {
+	PID     int    `json:"pid"`
+	Command string `json:"command"`
+	URL     string `json:"url"`
+}
+
 // LocalManager manages all locally-spawned MCP server processes
 type LocalManager struct {
 	processes map[string]*LocalProcess
@@ -76,6 +95,7 @@ func (m *LocalManager) StartServer(name string, serverConfig ServerConfig) error
 
 	m.mu.Lock()
 	m.processes[name] = proc
+	m.saveState()
 	m.mu.Unlock()
 
 	// Start monitor goroutine for automatic restart
@@ -93,6 +113,7 @@ func (m *LocalManager) StopServer(name string) error {
 		return fmt.Errorf("server '%s' not running", name)
 	}
 	delete(m.processes, name)
+	m.saveState()
 	m.mu.Unlock()
 
 	return proc.Stop()
@@ -106,6 +127,7 @@ func (m *LocalManager) StopAll() {
 		procs = append(procs, proc)
 	}
 	m.processes = make(map[string]*LocalProcess)
+	m.saveState()
 	m.mu.Unlock()
 
 	for _, proc := range procs {
@@ -113,6 +135,86 @@ func (m *LocalManager) StopAll() {
 	}
 }
 
+// saveState records running process PIDs to LocalState. Caller must hold m.mu.
+func (m *LocalManager) saveState() {
+	if len(m.processes) == 0 {
+		os.Remove(LocalState)
+		return
+	}
+
+	records := make(map[string]localProcessRecord, len(m.processes))
+	for name, proc := range m.processes {
+		if proc.Cmd == nil || proc.Cmd.Process == nil {
+			continue
+		}
+		records[name] = localProcessRecord{
+			PID:     proc.Cmd.Process.Pid,
+			Command: proc.Config.Command,
+			URL:     proc.ServerURL,
+		}
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return
+	}
+	os.MkdirAll(filepath.Dir(LocalState), 0755)
+	writeFileAtomic(LocalState, data, 0644)
+}
+
+// ReapOrphans kills local servers left running by a previous daemon (e.g.
+// one killed with SIGKILL) so their ports are free for a fresh start.
+// Returns the names of servers whose processes were reaped.
+func (m *LocalManager) ReapOrphans() []string {
+	data, err := os.ReadFile(LocalState)
+	if err != nil {
+		return nil
+	}
+	defer os.Remove(LocalState)
+
+	var records map[string]localProcessRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil
+	}
+
+	var reaped []string
+	for name, rec := range records {
+		if !isOrphan(rec) {
+			continue
+		}
+		killOrphan(rec.PID)
+		reaped = append(reaped, name)
+	}
+	return reaped
+}
+
+// isOrphan reports whether rec still refers to a live process running the
+// recorded command (guarding against the PID having been reused)
+func isOrphan(rec localProcessRecord) bool {
+	if rec.PID <= 0 || !processAlive(rec.PID) {
+		return false
+	}
+
+	out, err := exec.Command("ps", "-o", "command=", "-p", strconv.Itoa(rec.PID)).Output()
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(out), filepath.Base(rec.Command))
+}
+
+// killOrphan terminates pid, escalating to SIGKILL if it doesn't exit
+func killOrphan(pid int) {
+	terminateProcess(pid)
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if !processAlive(pid) {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	killProcess(pid)
+}
+
 // GetStatus returns status information for all processes
 func (m *LocalManager) GetStatus() []ProcessInfo {
 	m.mu.RLock()
@@ -133,6 +235,21 @@ func (m *LocalManager) GetProcess(name string) (*LocalProcess, bool) {
 	return proc, exists
 }
 
+// exitLimitReason reports whether the exited process was stopped by one of
+// its limits. A SIGKILL sent by Stop is not blamed on memory_limit_mb.
+func (p *LocalProcess) exitLimitReason() string {
+	p.mu.Lock()
+	killed := p.killed
+	p.mu.Unlock()
+	if killed {
+		return ""
+	}
+	if p.cgroup != nil && p.cgroup.oomKilled() {
+		return fmt.Sprintf("killed for exceeding memory_limit_mb (%d MB)", p.Config.MemoryLimitMB)
+	}
+	return limitExceeded(p.Cmd.ProcessState, p.Config)
+}
+
 // IsRunning checks if a server is running
 func (m *LocalManager) IsRunning(name string) bool {
 	m.mu.RLock()
@@ -166,8 +283,7 @@ func (m *LocalManager) monitorProcess(name string, serverConfig ServerConfig) {
 		proc.mu.Unlock()
 
 		// Process crashed, attempt restart
-		fmt.Fprintf(os.Stderr, "[%s] Server '%s' crashed, restarting...\n",
-			time.Now().Format("15:04:05"), name)
+		logger.Warn("local server crashed, restarting", "server", name)
 
 		// Brief delay before restart
 		time.Sleep(1 * time.Second)
@@ -190,14 +306,15 @@ func (m *LocalManager) monitorProcess(name string, serverConfig ServerConfig) {
 		}
 
 		if err := newProc.Start(); err != nil {
-			fmt.Fprintf(os.Stderr, "[%s] Failed to restart '%s': %v\n",
-				time.Now().Format("15:04:05"), name, err)
+			logger.Error("failed to restart local server", "server", name, "error", err)
 			delete(m.processes, name)
+			m.saveState()
 			m.mu.Unlock()
 			return
 		}
 
 		m.processes[name] = newProc
+		m.saveState()
 		m.mu.Unlock()
 	}
 }
@@ -228,10 +345,11 @@ func (p *LocalProcess) Start() error {
 	}
 
 	// Create command
-	p.Cmd = exec.Command(cmdPath, p.Config.Args...)
-
-	// Set environment
-	p.Cmd.Env = append(os.Environ(), p.Config.Env...)
+	p.Cmd, err = p.command(cmdPath)
+	if err != nil {
+		logFile.Close()
+		return err
+	}
 
 	// Capture stdout and stderr
 	stdout, err := p.Cmd.StdoutPipe()
@@ -240,27 +358,67 @@ func (p *LocalProcess) Start() error {
 		return fmt.Errorf("failed to get stdout pipe: %w", err)
 	}
 
+	// Stdio servers read requests from stdin
+	if p.Config.Stdio {
+		p.stdin, err = p.Cmd.StdinPipe()
+		if err != nil {
+			logFile.Close()
+			return fmt.Errorf("failed to get stdin pipe: %w", err)
+		}
+		p.messages = make(chan []byte, 64)
+	}
+
 	stderr, err := p.Cmd.StderrPipe()
 	if err != nil {
 		logFile.Close()
 		return fmt.Errorf("failed to get stderr pipe: %w", err)
 	}
 
+	cgroup, err := newLocalCgroup(p.Name, p.Config)
+	if err != nil {
+		logger.Warn("cannot create a cgroup; memory_limit_mb applies to each process separately", "server", p.Name, "error", err)
+	}
+
 	// Start the process
 	if err := p.Cmd.Start(); err != nil {
+		if cgroup != nil {
+			cgroup.remove()
+		}
 		logFile.Close()
 		return fmt.Errorf("failed to start process: %w", err)
 	}
 
 	p.Started = time.Now()
 
-	// Start log capture goroutines
-	go p.captureOutput("stdout", stdout)
+	if cgroup != nil {
+		if err := cgroup.add(p.Cmd.Process.Pid); err != nil {
+			logger.Warn("cannot move local server into its cgroup; memory_limit_mb applies to each process separately", "server", p.Name, "error", err)
+			cgroup.remove()
+		} else {
+			p.cgroup = cgroup
+		}
+	}
+
+	// Start log capture goroutines (stdout carries protocol messages for stdio servers)
+	if p.Config.Stdio {
+		go p.readMessages(stdout)
+	} else {
+		go p.captureOutput("stdout", stdout)
+	}
 	go p.captureOutput("stderr", stderr)
 
 	// Start wait goroutine
 	go func() {
 		p.Cmd.Wait()
+		if reason := p.exitLimitReason(); reason != "" {
+			logger.Warn("local server "+reason, "server", p.Name, "pid", p.Cmd.Process.Pid)
+			p.mu.Lock()
+			fmt.Fprintf(p.LogFile, "=== %s %s ===\n", p.Name, reason)
+			p.mu.Unlock()
+		}
+		if p.cgroup != nil {
+			p.cgroup.remove()
+		}
 		p.LogFile.Close()
 		close(p.done)
 	}()
@@ -271,12 +429,44 @@ func (p *LocalProcess) Start() error {
 		return err
 	}
 
-	fmt.Fprintf(os.Stderr, "[%s] Started '%s' (pid %d)\n",
-		time.Now().Format("15:04:05"), p.Name, p.Cmd.Process.Pid)
+	logger.Info("started local server", "server", p.Name, "pid", p.Cmd.Process.Pid)
 
 	return nil
 }
 
+// command builds the server's exec.Cmd: its arguments, environment and
+// working directory, under the configured rlimits and umask
+func (p *LocalProcess) command(cmdPath string) (*exec.Cmd, error) {
+	program, args := limitedCommand(p.Name, cmdPath, p.Config.Args, p.Config)
+	cmd := exec.Command(program, args...)
+
+	// Later entries win: env_file goes before or after env depending on
+	// env_file_override
+	cmd.Env = os.Environ()
+	var fileEnv []string
+	if p.Config.EnvFile != "" {
+		var err error
+		fileEnv, err = loadEnvFile(os.ExpandEnv(p.Config.EnvFile))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.Config.EnvFileOverride {
+		cmd.Env = append(append(cmd.Env, p.Config.Env...), fileEnv...)
+	} else {
+		cmd.Env = append(append(cmd.Env, fileEnv...), p.Config.Env...)
+	}
+
+	if p.Config.WorkingDir != "" {
+		dir := os.ExpandEnv(p.Config.WorkingDir)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("working directory %s does not exist", dir)
+		}
+		cmd.Dir = dir
+	}
+	return cmd, nil
+}
+
 // captureOutput captures output from a pipe and writes to log file
 func (p *LocalProcess) captureOutput(name string, pipe io.Reader) {
 	scanner := bufio.NewScanner(pipe)
@@ -290,26 +480,82 @@ func (p *LocalProcess) captureOutput(name string, pipe io.Reader) {
 	}
 }
 
+// Write sends one JSON-RPC message to a stdio server. Per the MCP stdio
+// transport, messages are newline-delimited and must not contain newlines,
+// so the JSON is compacted before writing.
+func (p *LocalProcess) Write(msg []byte) error {
+	if p.stdin == nil {
+		return fmt.Errorf("server '%s' is not a stdio server", p.Name)
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, msg); err != nil {
+		return fmt.Errorf("invalid JSON-RPC message: %w", err)
+	}
+	buf.WriteByte('\n')
+
+	p.stdinMu.Lock()
+	defer p.stdinMu.Unlock()
+	_, err := p.stdin.Write(buf.Bytes())
+	return err
+}
+
+// Messages returns the channel of JSON-RPC messages read from a stdio
+// server's stdout. It is closed when the process's stdout closes. Messages
+// that arrive while the channel is full are logged and dropped.
+func (p *LocalProcess) Messages() <-chan []byte {
+	return p.messages
+}
+
+// readMessages splits a stdio server's stdout into messages. Lines that
+// aren't JSON are treated as stray logging and written to the log file.
+func (p *LocalProcess) readMessages(pipe io.Reader) {
+	defer close(p.messages)
+
+	scanner := bufio.NewScanner(pipe)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			p.mu.Lock()
+			if p.LogFile != nil {
+				fmt.Fprintf(p.LogFile, "[%s] %s\n", time.Now().Format("15:04:05"), line)
+			}
+			p.mu.Unlock()
+			continue
+		}
+		// Never block on a full channel: the server would stall writing stdout
+		select {
+		case p.messages <- append([]byte(nil), line...):
+		default:
+			p.mu.Lock()
+			if p.LogFile != nil {
+				fmt.Fprintf(p.LogFile, "[%s] dropped unread message: %s\n", time.Now().Format("15:04:05"), line)
+			}
+			p.mu.Unlock()
+		}
+	}
+}
+
 // waitForReady waits for the server to accept connections
 func (p *LocalProcess) waitForReady() error {
-	// Extract host:port from URL
-	// Simple parsing - assume http://host:port/path format
-	url := p.ServerURL
-	if len(url) > 7 && url[:7] == "http://" {
-		url = url[7:]
-	}
-	// Find end of host:port (before path)
-	for i, c := range url {
-		if c == '/' {
-			url = url[:i]
-			break
-		}
+	// Stdio servers are ready as soon as they're running
+	if p.Config.Stdio {
+		return nil
+	}
+
+	network, addr, err := serverDialAddr(p.ServerURL)
+	if err != nil {
+		return err
 	}
 
 	// Try connecting for up to 30 seconds
 	deadline := time.Now().Add(30 * time.Second)
 	for time.Now().Before(deadline) {
-		conn, err := net.DialTimeout("tcp", url, 1*time.Second)
+		conn, err := net.DialTimeout(network, addr, 1*time.Second)
 		if err == nil {
 			conn.Close()
 			return nil
@@ -339,6 +585,9 @@ func (p *LocalProcess) Stop() error {
 	}
 
 	// Try graceful shutdown first
+	if p.stdin != nil {
+		p.stdin.Close() // stdio servers exit on EOF
+	}
 	p.Cmd.Process.Signal(os.Interrupt)
 
 	// Wait up to 5 seconds for graceful shutdown
@@ -349,10 +598,13 @@ func (p *LocalProcess) Stop() error {
 	}
 
 	// Force kill
+	p.mu.Lock()
+	p.killed = true
+	p.mu.Unlock()
 	p.Cmd.Process.Kill()
 	<-p.done
 
-	fmt.Fprintf(os.Stderr, "[%s] Stopped '%s'\n", time.Now().Format("15:04:05"), p.Name)
+	logger.Info("stopped local server", "server", p.Name)
 	return nil
 }
 
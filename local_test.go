@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startDummyProcess(t *testing.T) (*exec.Cmd, chan struct{}) {
+	t.Helper()
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start dummy process: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(done)
+	}()
+	t.Cleanup(func() { cmd.Process.Kill() })
+	return cmd, done
+}
+
+func writeLocalState(t *testing.T, records map[string]localProcessRecord) {
+	t.Helper()
+	data, _ := json.Marshal(records)
+	if err := os.WriteFile(LocalState, data, 0644); err != nil {
+		t.Fatalf("Failed to write local state: %v", err)
+	}
+}
+
+func TestIsOrphan(t *testing.T) {
+	cmd, _ := startDummyProcess(t)
+
+	if !isOrphan(localProcessRecord{PID: cmd.Process.Pid, Command: "sleep"}) {
+		t.Error("Expected live process running recorded command to be an orphan")
+	}
+	if isOrphan(localProcessRecord{PID: cmd.Process.Pid, Command: "some-other-server"}) {
+		t.Error("Expected reused PID with different command not to be an orphan")
+	}
+	if isOrphan(localProcessRecord{PID: 0, Command: "sleep"}) {
+		t.Error("Expected invalid PID not to be an orphan")
+	}
+}
+
+func TestLocalManager_ReapOrphans(t *testing.T) {
+	tmpDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+	origState := LocalState
+	LocalState = filepath.Join(tmpDir, "local.json")
+	defer func() { LocalState = origState }()
+
+	orphan, orphanDone := startDummyProcess(t)
+	bystander, bystanderDone := startDummyProcess(t)
+
+	writeLocalState(t, map[string]localProcessRecord{
+		"stale": {PID: orphan.Process.Pid, Command: "sleep", URL: "http://localhost:8931"},
+		// PID reused by an unrelated process: must not be killed
+		"reused": {PID: bystander.Process.Pid, Command: "npx"},
+	})
+
+	reaped := NewLocalManager().ReapOrphans()
+	if len(reaped) != 1 || reaped[0] != "stale" {
+		t.Errorf("Expected [stale] reaped, got %v", reaped)
+	}
+
+	select {
+	case <-orphanDone:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected orphaned process to be killed")
+	}
+	select {
+	case <-bystanderDone:
+		t.Error("Expected unrelated process to survive")
+	default:
+	}
+
+	if _, err := os.Stat(LocalState); !os.IsNotExist(err) {
+		t.Error("Expected state file removed after reaping")
+	}
+}
+
+func TestLocalProcess_StdioRoundTrip(t *testing.T) {
+	tmpDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+	origLogsDir := LogsDir
+	LogsDir = filepath.Join(tmpDir, "logs")
+	defer func() { LogsDir = origLogsDir }()
+
+	// cat is a fake stdio server that echoes each request back
+	proc := &LocalProcess{
+		Name:   "echo",
+		Config: LocalConfig{Command: "cat", Stdio: true},
+		done:   make(chan struct{}),
+	}
+	if err := proc.Start(); err != nil {
+		t.Skipf("cannot start cat: %v", err)
+	}
+	defer proc.Stop()
+
+	request := []byte("{\n  \"jsonrpc\": \"2.0\",\n  \"id\": \"1\",\n  \"method\": \"tools/list\"\n}")
+	if err := proc.Write(request); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	select {
+	case msg := <-proc.Messages():
+		var resp MCPRequest
+		if err := json.Unmarshal(msg, &resp); err != nil {
+			t.Fatalf("Invalid message %q: %v", msg, err)
+		}
+		if resp.Method != "tools/list" || resp.ID != "1" {
+			t.Errorf("Unexpected message: %+v", resp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for response on stdout")
+	}
+}
+
+func TestLocalProcess_StdioUnreadMessagesDontBlock(t *testing.T) {
+	tmpDir, cleanup := setupTestConfig(t)
+	defer cleanup()
+	origLogsDir := LogsDir
+	LogsDir = filepath.Join(tmpDir, "logs")
+	defer func() { LogsDir = origLogsDir }()
+
+	proc := &LocalProcess{
+		Name:   "echo",
+		Config: LocalConfig{Command: "cat", Stdio: true},
+		done:   make(chan struct{}),
+	}
+	if err := proc.Start(); err != nil {
+		t.Skipf("cannot start cat: %v", err)
+	}
+	defer proc.Stop()
+
+	// Overfill the message buffer without reading it
+	for i := 0; i < cap(proc.messages)+10; i++ {
+		if err := proc.Write([]byte(fmt.Sprintf(`{"jsonrpc": "2.0", "id": "%d", "method": "ping"}`, i))); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	proc.stdin.Write([]byte("still reading\n"))
+
+	logPath := filepath.Join(LogsDir, "echo.log")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(logPath)
+		if strings.Contains(string(data), "still reading") {
+			if !strings.Contains(string(data), "dropped unread message") {
+				t.Error("Expected the overflow to be logged as dropped")
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected stdout to keep being read with a full message buffer")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestLocalProcess_WriteRequiresStdio(t *testing.T) {
+	proc := &LocalProcess{Name: "http-server"}
+	if err := proc.Write([]byte(`{}`)); err == nil {
+		t.Error("Expected error writing to non-stdio server")
+	}
+}
+
+func TestLocalProcess_WorkingDirAndUmask(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("MCPX_TEST_DATA", dir)
+
+	proc := &LocalProcess{
+		Name:   "data-server",
+		Config: LocalConfig{Command: "sh", Args: []string{"-c", "pwd; umask"}, WorkingDir: "$MCPX_TEST_DATA", Umask: "027"},
+	}
+	cmd, err := proc.command("/bin/sh")
+	if err != nil {
+		t.Fatalf("command failed: %v", err)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("child failed: %v", err)
+	}
+
+	lines := strings.Fields(string(out))
+	want, _ := filepath.EvalSymlinks(dir)
+	if len(lines) != 2 {
+		t.Fatalf("Expected cwd and umask, got %q", out)
+	}
+	if got, _ := filepath.EvalSymlinks(lines[0]); got != want {
+		t.Errorf("Expected child cwd %s, got %s", want, lines[0])
+	}
+	if lines[1] != "0027" {
+		t.Errorf("Expected umask 0027, got %s", lines[1])
+	}
+
+	proc.Config.WorkingDir = filepath.Join(dir, "missing")
+	if _, err := proc.command("/bin/sh"); err == nil {
+		t.Error("Expected error for a missing working directory")
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockHandle blocks until it holds an exclusive advisory lock on f
+func lockHandle(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
+
+// unlockHandle releases the lock taken by lockHandle
+func unlockHandle(f *os.File) {
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogFormatEnv selects the daemon log format ("json" for one object per line)
+const LogFormatEnv = "MCPX_LOG_FORMAT"
+
+// LogLevel is the severity of a log line
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[LogLevel]string{
+	LevelDebug: "DEBUG",
+	LevelInfo:  "INFO",
+	LevelWarn:  "WARN",
+	LevelError: "ERROR",
+}
+
+func (l LogLevel) String() string {
+	return levelNames[l]
+}
+
+// ParseLogLevel parses a level name (case-insensitive)
+func ParseLogLevel(s string) (LogLevel, error) {
+	for level, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return level, nil
+		}
+	}
+	if strings.EqualFold(s, "warning") {
+		return LevelWarn, nil
+	}
+	return LevelInfo, fmt.Errorf("invalid log level %q (use debug, info, warn or error)", s)
+}
+
+// Logger writes leveled log lines with key/value fields, as text
+// ("[15:04:05] INFO msg key=value") or JSON
+type Logger struct {
+	mu    sync.Mutex
+	out   io.Writer
+	level LogLevel
+	json  bool
+}
+
+// NewLogger creates a logger writing to out, honoring MCPX_LOG_FORMAT
+func NewLogger(out io.Writer, level LogLevel) *Logger {
+	return &Logger{
+		out:   out,
+		level: level,
+		json:  strings.EqualFold(os.Getenv(LogFormatEnv), "json"),
+	}
+}
+
+// logger is the daemon's process-wide logger
+var logger = NewLogger(os.Stderr, LevelInfo)
+
+// SetLevel changes the minimum level that is written
+func (l *Logger) SetLevel(level LogLevel) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+}
+
+// SetOutput changes where log lines are written
+func (l *Logger) SetOutput(out io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out = out
+}
+
+func (l *Logger) Debug(msg string, fields ...any) { l.log(LevelDebug, msg, fields) }
+func (l *Logger) Info(msg string, fields ...any)  { l.log(LevelInfo, msg, fields) }
+func (l *Logger) Warn(msg string, fields ...any)  { l.log(LevelWarn, msg, fields) }
+func (l *Logger) Error(msg string, fields ...any) { l.log(LevelError, msg, fields) }
+
+// log writes one line. fields are alternating keys and values; durations
+// are rendered as strings and errors by their message.
+func (l *Logger) log(level LogLevel, msg string, fields []any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if level < l.level {
+		return
+	}
+	now := time.Now()
+
+	if l.json {
+		entry := map[string]any{
+			"time":  now.Format(time.RFC3339),
+			"level": strings.ToLower(level.String()),
+			"msg":   msg,
+		}
+		for i := 0; i+1 < len(fields); i += 2 {
+			entry[fmt.Sprint(fields[i])] = logValue(fields[i+1])
+		}
+		data, _ := json.Marshal(entry)
+		fmt.Fprintf(l.out, "%s\n", data)
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s %s", now.Format("15:04:05"), level, msg)
+	for i := 0; i+1 < len(fields); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", fields[i], logValue(fields[i+1]))
+	}
+	fmt.Fprintln(l.out, b.String())
+}
+
+func logValue(v any) any {
+	switch v := v.(type) {
+	case time.Duration:
+		return v.String()
+	case error:
+		return v.Error()
+	}
+	return v
+}
+
+type requestIDKey struct{}
+
+// withRequestID tags ctx with the ID of the daemon request it serves
+func withRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// requestIDFrom returns the request ID carried by ctx, or ""
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestFields prepends the request ID carried by ctx, if any, to log
+// fields so lines logged while serving a request can be correlated
+func requestFields(ctx context.Context, fields ...any) []any {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return append([]any{"request_id", id}, fields...)
+	}
+	return fields
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger_JSON(t *testing.T) {
+	t.Setenv(LogFormatEnv, "json")
+	var buf bytes.Buffer
+	l := NewLogger(&buf, LevelInfo)
+
+	l.Info("request", "server", "supabase", "elapsed", 150*time.Millisecond)
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected JSON line, got %q: %v", buf.String(), err)
+	}
+	if entry["level"] != "info" {
+		t.Errorf("Expected level info, got %v", entry["level"])
+	}
+	if entry["msg"] != "request" {
+		t.Errorf("Expected msg request, got %v", entry["msg"])
+	}
+	if entry["server"] != "supabase" {
+		t.Errorf("Expected server supabase, got %v", entry["server"])
+	}
+	if entry["elapsed"] != "150ms" {
+		t.Errorf("Expected elapsed 150ms, got %v", entry["elapsed"])
+	}
+	if _, ok := entry["time"]; !ok {
+		t.Error("Expected time field")
+	}
+}
+
+func TestLogger_TextAndLevels(t *testing.T) {
+	t.Setenv(LogFormatEnv, "")
+	var buf bytes.Buffer
+	l := NewLogger(&buf, LevelWarn)
+
+	l.Info("hidden")
+	l.Debug("hidden")
+	l.Warn("request failed", "server", "db")
+
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Errorf("Expected lines below WARN to be dropped, got %q", out)
+	}
+	if !strings.Contains(out, "WARN request failed server=db") {
+		t.Errorf("Unexpected text output: %q", out)
+	}
+
+	l.SetLevel(LevelDebug)
+	l.Debug("now visible")
+	if !strings.Contains(buf.String(), "DEBUG now visible") {
+		t.Error("Expected debug line after SetLevel")
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	tests := map[string]LogLevel{"debug": LevelDebug, "INFO": LevelInfo, "warn": LevelWarn, "warning": LevelWarn, "error": LevelError}
+	for in, want := range tests {
+		got, err := ParseLogLevel(in)
+		if err != nil || got != want {
+			t.Errorf("ParseLogLevel(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseLogLevel("loud"); err == nil {
+		t.Error("Expected error for invalid level")
+	}
+}
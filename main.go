@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
-	"os/exec"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 )
 
 // headerFlags allows multiple --header flags
@@ -21,37 +24,136 @@ func (h *headerFlags) Set(value string) error {
 	return nil
 }
 
+// optionalServerFlag is a flag whose server name may be left out to use the
+// default server: --tools, --tools <server> or --tools=<server>. Parsed as a
+// boolean, so a separate name arrives as the first positional argument.
+type optionalServerFlag struct {
+	set  bool
+	name string
+}
+
+func (f *optionalServerFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.name
+}
+
+func (f *optionalServerFlag) Set(value string) error {
+	f.set = true
+	if value != "true" {
+		f.name = value
+	}
+	return nil
+}
+
+func (f *optionalServerFlag) IsBoolFlag() bool { return true }
+
+// server returns the named server, the first positional argument, or the
+// configured default server
+func (f *optionalServerFlag) server(args []string) string {
+	if f.name != "" {
+		return f.name
+	}
+	if len(args) > 0 {
+		return args[0]
+	}
+	config, err := LoadConfig()
+	if err != nil {
+		errExit(ErrMCPError, fmt.Sprintf("Failed to load config: %v", err))
+	}
+	name, err := config.defaultServer()
+	if err != nil {
+		errExit(errorCodeOf(err, ErrNotFound), err.Error())
+	}
+	return name
+}
+
 var (
 	// Basic commands
 	flagServers       = flag.Bool("servers", false, "List configured servers")
-	flagTools         = flag.String("tools", "", "List tools on a server")
+	flagProbe         = flag.Bool("probe", false, "With --servers, check each server's reachability and count its tools")
+	flagTools         optionalServerFlag
+	flagArgs          argFlags
 	flagCall          = flag.Bool("call", false, "Call a tool: --call <server> <tool> '<json>'")
+	flagDoctor        = flag.Bool("doctor", false, "Check reachability and auth of every configured server")
+	flagPing          = flag.String("ping", "", "Check a server is reachable and auth works (initialize only)")
+	flagFind          = flag.String("find", "", "Search tool names and descriptions across all servers")
+	flagDescribeTool  = flag.Bool("describe-tool", false, "Show a tool's parameters and example arguments: --describe-tool <server> <tool>")
+	flagInteractive   = flag.Bool("interactive", false, "Prompt for tool arguments: --interactive <server> <tool>")
+	flagComplete      = flag.Bool("complete", false, "Suggest argument values: --complete <server> <prompt|uri-template> <arg> [partial]")
 	flagInit          = flag.Bool("init", false, "Initialize config file")
 	flagInitSkill     = flag.Bool("init-skill", false, "Install Claude Code skill to ~/.claude/skills/")
 	flagClearSessions = flag.Bool("clear-sessions", false, "Clear cached sessions")
 	flagClearTokens   = flag.Bool("clear-tokens", false, "Clear stored OAuth tokens")
+	flagSetDefault    = flag.String("set-default-server", "", "Use <name> when --call, --query or --tools omit the server")
+	flagClearDefault  = flag.Bool("clear-default-server", false, "Stop using a default server")
+	flagTrustProject  = flag.Bool("trust-project", false, "Trust the nearest .mcpx.json as it is now: let it replace global servers and run commands")
 	flagAuth          = flag.String("auth", "", "OAuth login for a server")
+	flagLogout        = flag.String("logout", "", "Revoke a server's OAuth token at the provider and delete it locally")
+	flagAuthRedirect  = flag.String("auth-redirect", "", "With --auth, redirect URI to use instead of localhost, or \"manual\" to paste the code into the terminal")
+	flagAuthPort      = flag.Int("auth-port", -1, "With --auth, local port for the OAuth callback (0 picks a free port; default 8085 or oauth.callback_port)")
+	flagProfile       = flag.String("profile", "", "Config profile to use (default: $MCPX_PROFILE or \"default\")")
+	flagVersion       = flag.Bool("version", false, "Print version and build info")
+	flagShowSecrets   = flag.Bool("show-secrets", false, "Print header values, tokens and secrets unmasked")
+	flagDebug         = flag.Bool("debug", false, "Trace JSON-RPC requests and raw responses to ~/.mcpx/logs/debug.log (or set MCPX_DEBUG)")
+	flagFormat        = flag.String("format", FormatJSON, "Result format for --call and --query: json or text")
+	flagSaveBlobs     = flag.String("save-blobs", "", "Write image/blob content from --call and --query results to files in <dir>")
+	flagOutput        = flag.String("output", "", "Write the result to <path> (replaced on every call) instead of stdout; - is stdout")
+	flagFilter        = flag.String("filter", "", "Print only part of a --call or --query result, e.g. '.content[0].text'")
 
 	// Server management
-	flagAdd    = flag.Bool("add", false, "Add a server: --add <name> <url>")
-	flagHeader headerFlags
-	flagRemove = flag.String("remove", "", "Remove a server: --remove <name>")
+	flagAdd          = flag.Bool("add", false, "Add a server: --add <name> <url>")
+	flagHeader       headerFlags
+	flagRemove       = flag.String("remove", "", "Remove a server: --remove <name>")
+	flagRename       = flag.Bool("rename", false, "Rename a server, keeping its tokens: --rename <old> <new>")
+	flagClone        = flag.Bool("clone", false, "Copy a server's config under a new name: --clone <src> <dest>")
+	flagEdit         = flag.String("edit", "", "Edit a server in place: --edit <name> [--url <url>] [--header ...] [--remove-header <name>]")
+	flagURL          = flag.String("url", "", "With --edit, the server's new URL")
+	flagRemoveHeader headerFlags
+	flagCallHeader   headerFlags
+
+	// Export / import
+	flagExport         = flag.String("export", "", "Export servers and registrations to a bundle file: --export <path>")
+	flagImport         = flag.String("import", "", "Import a bundle into the current config: --import <path>")
+	flagIncludeSecrets = flag.Bool("include-secrets", false, "With --export, keep secrets and include OAuth tokens")
+	flagForce          = flag.Bool("force", false, "With --import, overwrite servers that already exist")
 
 	// Daemon mode
 	flagDaemon           = flag.Bool("daemon", false, "Start daemon in background")
-	flagDaemonForeground = flag.Bool("daemon-foreground", false, "Run daemon in foreground (internal)")
+	flagDaemonForeground = flag.Bool("daemon-foreground", false, "Run daemon in foreground (for systemd or a process supervisor)")
+	flagStartupTimeout   = flag.Int("startup-timeout", int(defaultStartupTimeout/time.Second), "With --daemon, seconds to wait for local servers to start")
 	flagDaemonStop       = flag.Bool("daemon-stop", false, "Stop the daemon")
 	flagDaemonStatus     = flag.Bool("daemon-status", false, "Check daemon status")
+	flagDaemonReload     = flag.Bool("daemon-reload", false, "Reload daemon config from servers.json")
 	flagDaemonTools      = flag.String("daemon-tools", "", "List tools via daemon")
 	flagQuery            = flag.Bool("query", false, "Fast query via daemon: --query <server> <tool> '<json>'")
+	flagSubscribe        = flag.Bool("subscribe", false, "Stream resource updates via daemon: --subscribe <server> <uri>")
+	flagNoValidate       = flag.Bool("no-validate", false, "Skip checking tool arguments against the tool's inputSchema")
+	flagMaxBytes         = flag.Int("max-bytes", 0, "Truncate --call/--query results larger than <n> bytes of JSON (overrides max_response_bytes)")
+	flagMeta             = flag.String("meta", "", "JSON object sent as the tool call's _meta for --call/--query, e.g. '{\"progressToken\": \"job-1\"}'")
+	flagReadOnly         = flag.Bool("read-only", false, "Block tool calls (listing still works); applies to --call, --query and --daemon")
+	flagMaxConnections   = flag.Int("max-connections", 0, fmt.Sprintf("Connections the daemon handles at once; more get DAEMON_BUSY (default %d)", DefaultMaxConnections))
+	flagLogLevel         = flag.String("log-level", "", "Daemon log level: debug, info, warn, error (default info)")
+	flagLogStdout        = flag.Bool("log-stdout", false, "With --daemon-foreground, write daemon logs to stdout instead of stderr")
+	flagLogLevelServer   = flag.Bool("log-level-server", false, "Set a server's own log level via logging/setLevel: --log-level-server <server> <level>")
 
 	// Process management
-	flagStatus = flag.Bool("status", false, "Show running processes")
-	flagLogs   = flag.String("logs", "", "Tail logs for a managed server: --logs <server>")
+	flagStatus     = flag.Bool("status", false, "Show daemon status: processes, per-server health, last error and token state")
+	flagLogs       = flag.String("logs", "", "Tail logs for a managed server: --logs <server>")
+	flagLogsAll    = flag.Bool("logs-all", false, "Tail the logs of every local server as one stream, prefixed with the server name")
+	flagServerLogs = flag.String("server-logs", "", "Show recent log messages a server sent the daemon: --server-logs <server>")
 )
 
+// positionalArgs are the non-flag arguments, wherever they appeared
+var positionalArgs []string
+
 func init() {
-	flag.Var(&flagHeader, "header", "Header for --add: --header 'Authorization: Bearer TOKEN'")
+	flag.Var(&flagTools, "tools", "List tools on a server: --tools <server> (or the default server)")
+	flag.Var(&flagArgs, "a", "Tool argument for --call/--query: key=value (string) or key:=json (number, bool, array...); repeatable, a.b=c nests")
+	flag.Var(&flagHeader, "header", "Header for --add or --edit: --header 'Authorization: Bearer TOKEN'")
+	flag.Var(&flagRemoveHeader, "remove-header", "Header name to remove with --edit (repeatable)")
+	flag.Var(&flagCallHeader, "call-header", "Header for this --call or --tools only, not saved: --call-header 'X-Debug: 1' (repeatable)")
 }
 
 func main() {
@@ -60,28 +162,64 @@ func main() {
 
 Usage:
   mcpx --servers                          # List configured servers
+  mcpx --servers --probe                  # ...with reachability, protocol version and tool count
   mcpx --tools <server>                   # List tools on a server
   mcpx --call <server> <tool> '<json>'    # Call a tool
+  mcpx --call <server> '#3' '<json>'      # Call the 3rd tool of the last --tools listing
+  mcpx --call <server> <tool> -a q=x -a limit:=10  # Arguments without JSON (:= for numbers, bools, arrays)
+  mcpx --call ... --format text           # Print content blocks instead of JSON
+  mcpx --call ... --output result.json    # Write the result to a file instead of stdout
+  mcpx --call ... --call-header 'X-Debug: 1'  # Add or override a header for this call only
+  mcpx --call ... --save-blobs <dir>      # Write image/blob content to files
+  mcpx --interactive <server> <tool>      # Call a tool, prompting for arguments
+  mcpx --describe-tool <server> <tool>    # Show a tool's parameters and example
+  mcpx --find '<keyword>'                 # Search tools across all servers
+  mcpx --complete <server> <prompt> <arg> <partial>  # Suggest argument values
+  mcpx --ping <server>                    # Check connectivity and auth
+  mcpx --doctor                           # Health check all servers
   mcpx --auth <server>                    # OAuth login for a server
+  mcpx --auth <server> --auth-port 0      # Use a free port for the OAuth callback
+  mcpx --auth <server> --auth-redirect manual  # Paste the code (browser on another machine)
+  mcpx --logout <server>                  # Revoke a server's OAuth token and delete it locally
   mcpx --init                             # Create config file
   mcpx --init-skill                       # Install Claude Code skill
+  mcpx --trust-project                    # Trust this repo's .mcpx.json (commands, global overrides)
+  mcpx --version                          # Print version
+  mcpx --debug ...                        # Trace protocol traffic to ~/.mcpx/logs/debug.log
 
 Server management:
   mcpx --add <name> <url>                 # Add a server
   mcpx --add --header 'Authorization: Bearer TOKEN' <name> <url>
   mcpx --remove <name>                    # Remove a server
+  mcpx --rename <old> <new>               # Rename a server (keeps tokens)
+  mcpx --clone <src> <dest>               # Copy a server's config (re-auth the copy)
+  mcpx --edit <name> --url <url>          # Change a server's URL
+  mcpx --edit <name> --header 'X-Key: v' --remove-header Authorization
+
+Export / import:
+  mcpx --export <path>                    # Export config (secrets redacted)
+  mcpx --export <path> --include-secrets  # Export including secrets and tokens
+  mcpx --import <path> [--force]          # Merge a bundle (--force overwrites conflicts)
 
 Daemon mode (fast queries):
   mcpx --daemon                           # Start daemon + local servers
   mcpx --query <server> <tool> '<json>'   # Fast query via daemon
   mcpx --daemon-tools <server>            # List tools via daemon
+  mcpx --subscribe <server> <uri>         # Stream resource updates (one JSON line each)
   mcpx --daemon-stop                      # Stop daemon + local servers
+  mcpx --daemon-reload                    # Reload daemon config
+  mcpx --daemon --read-only               # Start daemon that rejects tool calls
+  mcpx --daemon --log-level debug         # Verbose daemon logs (MCPX_LOG_FORMAT=json for JSON)
+  mcpx --daemon-foreground --log-stdout   # Run under systemd or a supervisor, logging to stdout
+  mcpx --log-level-server <server> <level>  # Set server verbosity (debug, info, warning, error)
 
 Process management:
-  mcpx --status                           # Show running processes
+  mcpx --status                           # Daemon dashboard: processes, server health, tokens
   mcpx --logs <server>                    # Tail logs for a managed server
+  mcpx --logs-all                         # Tail every local server's log as one stream
+  mcpx --server-logs <server>             # Log messages the server sent (via daemon)
 
-Config: ~/.mcpx/servers.json
+Config: ~/.mcpx/servers.json (profiles: ~/.mcpx/profiles/<name>/, select with --profile or MCPX_PROFILE)
 Logs: ~/.mcpx/logs/<server>.log
 
 Flags:
@@ -89,10 +227,38 @@ Flags:
 		flag.PrintDefaults()
 	}
 
-	flag.Parse()
+	// Like flag.Parse, but flags may also follow positional arguments
+	var err error
+	positionalArgs, err = parseInterspersed(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		os.Exit(2)
+	}
+	showSecrets = *flagShowSecrets
+	debugEnabled = debugEnabled || *flagDebug
+	outputPath = *flagOutput
+	if *flagFormat != FormatJSON && *flagFormat != FormatText {
+		errExit(ErrInvalidArgs, fmt.Sprintf("invalid --format %q (use json or text)", *flagFormat))
+	}
+	if *flagMaxBytes < 0 {
+		errExit(ErrInvalidArgs, "--max-bytes must not be negative")
+	}
+	if _, err := parseFilter(*flagFilter); err != nil {
+		errExit(ErrInvalidArgs, fmt.Sprintf("invalid --filter %q: %v", *flagFilter, err))
+	}
+
+	profile := *flagProfile
+	if profile == "" {
+		profile = os.Getenv(ProfileEnv)
+	}
+	if err := UseProfile(profile); err != nil {
+		errExit(ErrInvalidArgs, err.Error())
+	}
 
 	// Handle commands
 	switch {
+	case *flagVersion:
+		fmt.Println(versionString())
+
 	case *flagInit:
 		if err := InitConfig(); err != nil {
 			errExit(ErrMCPError, fmt.Sprintf("Failed to init config: %v", err))
@@ -123,11 +289,23 @@ Flags:
 		}
 		fmt.Println("OAuth tokens cleared.")
 
+	case *flagLogout != "":
+		logout(*flagLogout)
+
+	case *flagSetDefault != "":
+		setDefaultServer(*flagSetDefault)
+
+	case *flagClearDefault:
+		setDefaultServer("")
+
+	case *flagTrustProject:
+		trustProject()
+
 	case *flagServers:
 		listServers()
 
 	case *flagAdd:
-		args := flag.Args()
+		args := positionalArgs
 		if len(args) < 2 {
 			errExit(ErrInvalidArgs, "Usage: --add <name> <url>")
 		}
@@ -136,8 +314,48 @@ Flags:
 	case *flagRemove != "":
 		removeServer(*flagRemove)
 
-	case *flagTools != "":
-		listTools(*flagTools)
+	case *flagRename:
+		args := positionalArgs
+		if len(args) < 2 {
+			errExit(ErrInvalidArgs, "Usage: --rename <old> <new>")
+		}
+		renameServer(args[0], args[1])
+
+	case *flagClone:
+		args := positionalArgs
+		if len(args) < 2 {
+			errExit(ErrInvalidArgs, "Usage: --clone <src> <dest>")
+		}
+		cloneServer(args[0], args[1])
+
+	case *flagEdit != "":
+		editServer(*flagEdit, ServerEdit{
+			URL:           *flagURL,
+			SetHeaders:    flagHeader,
+			RemoveHeaders: flagRemoveHeader,
+		})
+
+	case *flagExport != "":
+		bundle, err := exportConfig(*flagExport, *flagIncludeSecrets)
+		if err != nil {
+			errExit(errorCodeOf(err, ErrMCPError), err.Error())
+		}
+		ok(map[string]any{
+			"path":     *flagExport,
+			"servers":  len(bundle.Servers),
+			"redacted": !*flagIncludeSecrets,
+		})
+
+	case *flagImport != "":
+		result, err := importConfig(*flagImport, *flagForce)
+		if err != nil {
+			errExit(errorCodeOf(err, ErrMCPError), err.Error())
+		}
+		notifyDaemonReload()
+		ok(result)
+
+	case flagTools.set:
+		listTools(flagTools.server(positionalArgs))
 
 	case *flagAuth != "":
 		doAuth(*flagAuth)
@@ -154,22 +372,65 @@ Flags:
 	case *flagDaemonStatus:
 		daemonStatus()
 
+	case *flagDaemonReload:
+		daemonReload()
+
 	case *flagDaemonTools != "":
 		daemonTools(*flagDaemonTools)
 
 	case *flagCall:
-		args := flag.Args()
+		callTool(callArgs(positionalArgs, "Usage: --call <server> <tool> '<json>' (or -a key=value ...)"))
+
+	case *flagDoctor:
+		doctor()
+
+	case *flagPing != "":
+		pingServer(*flagPing)
+
+	case *flagFind != "":
+		findTools(*flagFind)
+
+	case *flagDescribeTool:
+		args := positionalArgs
+		if len(args) < 2 {
+			errExit(ErrInvalidArgs, "Usage: --describe-tool <server> <tool>")
+		}
+		describeToolCmd(args[0], args[1])
+
+	case *flagInteractive:
+		args := positionalArgs
+		if len(args) < 2 {
+			errExit(ErrInvalidArgs, "Usage: --interactive <server> <tool>")
+		}
+		interactiveCall(args[0], args[1])
+
+	case *flagComplete:
+		args := positionalArgs
 		if len(args) < 3 {
-			errExit(ErrInvalidArgs, "Usage: --call <server> <tool> '<json>'")
+			errExit(ErrInvalidArgs, "Usage: --complete <server> <prompt|uri-template> <arg> [partial]")
+		}
+		partial := ""
+		if len(args) > 3 {
+			partial = args[3]
 		}
-		callTool(args[0], args[1], args[2])
+		completeArgument(args[0], args[1], args[2], partial)
 
 	case *flagQuery:
-		args := flag.Args()
-		if len(args) < 3 {
-			errExit(ErrInvalidArgs, "Usage: --query <server> <tool> '<json>'")
+		daemonQuery(callArgs(positionalArgs, "Usage: --query <server> <tool> '<json>' (or -a key=value ...)"))
+
+	case *flagSubscribe:
+		args := positionalArgs
+		if len(args) < 2 {
+			errExit(ErrInvalidArgs, "Usage: --subscribe <server> <uri>")
+		}
+		daemonSubscribe(args[0], args[1])
+
+	case *flagLogLevelServer:
+		args := positionalArgs
+		if len(args) < 2 {
+			errExit(ErrInvalidArgs, "Usage: --log-level-server <server> <level>")
 		}
-		daemonQuery(args[0], args[1], args[2])
+		setServerLogLevel(args[0], args[1])
 
 	case *flagStatus:
 		showStatus()
@@ -177,139 +438,330 @@ Flags:
 	case *flagLogs != "":
 		tailLogs(*flagLogs)
 
+	case *flagLogsAll:
+		tailAllLogs()
+
+	case *flagServerLogs != "":
+		serverLogs(*flagServerLogs)
+
 	default:
 		flag.Usage()
 	}
 }
 
-// listServers lists all configured servers
-func listServers() {
-	config, err := LoadConfig()
-	if err != nil {
-		errExit(ErrMCPError, fmt.Sprintf("Failed to load config: %v", err))
-	}
-
+// serverInfos describes the configured servers for --servers, secrets redacted
+func serverInfos(config *Config) []ServerInfo {
+	defaultServer, _ := config.defaultServer()
 	servers := make([]ServerInfo, 0, len(config.Servers))
 	for name, cfg := range config.Servers {
 		servers = append(servers, ServerInfo{
 			Name:    name,
 			URL:     cfg.URL,
-			HasAuth: len(cfg.Headers) > 0,
+			Headers: cfg.Headers,
+			HasAuth: len(cfg.Headers) > 0 || cfg.TokenCommand != "",
 			IsLocal: cfg.Local != nil,
-		})
+			Aliases: cfg.Aliases,
+			Default: name == defaultServer,
+		}.Redact())
 	}
-
-	ok(map[string]any{"servers": servers})
+	return servers
 }
 
-// addServer adds a server to the configuration
-func addServer(name, url string, headers headerFlags) {
+// listServers lists all configured servers
+func listServers() {
 	config, err := LoadConfig()
 	if err != nil {
 		errExit(ErrMCPError, fmt.Sprintf("Failed to load config: %v", err))
 	}
 
-	if _, exists := config.Servers[name]; exists {
-		errExit(ErrExists, fmt.Sprintf("Server '%s' already exists. Remove it first with --remove.", name))
+	servers := serverInfos(config)
+	if *flagProbe {
+		probeServers(config, servers, probeConcurrency, probeTimeout)
+	}
+
+	result := map[string]any{"servers": servers}
+	if config.DefaultServer != "" {
+		result["default_server"] = config.DefaultServer
 	}
+	ok(result)
+}
 
+// addServer adds a server to the configuration
+func addServer(name, url string, headers headerFlags) {
 	serverConfig := ServerConfig{URL: url}
 	if len(headers) > 0 {
 		serverConfig.Headers = make(map[string]string)
 		for _, h := range headers {
-			if !strings.Contains(h, ":") {
-				errExit(ErrInvalidArgs, fmt.Sprintf("Invalid header format: '%s'. Use 'Name: Value'", h))
+			key, value, err := parseHeaderFlag(h)
+			if err != nil {
+				errExit(ErrInvalidArgs, err.Error())
 			}
-			parts := strings.SplitN(h, ":", 2)
-			serverConfig.Headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+			serverConfig.Headers[key] = value
 		}
 	}
 
-	config.Servers[name] = serverConfig
-	if err := SaveConfig(config); err != nil {
-		errExit(ErrMCPError, fmt.Sprintf("Failed to save config: %v", err))
+	err := UpdateConfig(func(config *Config) error {
+		if _, _, exists := config.Lookup(name); exists {
+			return codedErrorf(ErrExists, "Server '%s' already exists. Remove it first with --remove.", name)
+		}
+		config.Servers[name] = serverConfig
+		return nil
+	})
+	if err != nil {
+		errExit(errorCodeOf(err, ErrMCPError), err.Error())
 	}
+	notifyDaemonReload()
 
 	ok(map[string]any{
 		"message": fmt.Sprintf("Server '%s' added", name),
 		"server": ServerInfo{
 			Name:    name,
 			URL:     url,
+			Headers: serverConfig.Headers,
 			HasAuth: len(serverConfig.Headers) > 0,
-		},
+		}.Redact(),
 	})
 }
 
-// removeServer removes a server from the configuration
-func removeServer(name string) {
-	config, err := LoadConfig()
-	if err != nil {
-		errExit(ErrMCPError, fmt.Sprintf("Failed to load config: %v", err))
+// parseHeaderFlag splits a 'Name: Value' header flag
+func parseHeaderFlag(h string) (string, string, error) {
+	key, value, found := strings.Cut(h, ":")
+	if !found {
+		return "", "", fmt.Errorf("Invalid header format: '%s'. Use 'Name: Value'", h)
 	}
+	return strings.TrimSpace(key), strings.TrimSpace(value), nil
+}
 
-	if _, exists := config.Servers[name]; !exists {
-		errExit(ErrNotFound, fmt.Sprintf("Server '%s' not found.", name))
+// withCallHeaders returns cfg with --call-header values merged over its
+// headers for one invocation; the stored config is never modified. Names
+// match case-insensitively. A stored OAuth token still overrides any
+// Authorization header, as it does for configured headers.
+func withCallHeaders(cfg ServerConfig, headers []string) (ServerConfig, error) {
+	if len(headers) == 0 {
+		return cfg, nil
+	}
+	overrides := make(map[string]string, len(headers))
+	for _, h := range headers {
+		key, value, err := parseHeaderFlag(h)
+		if err != nil {
+			return cfg, codedErrorf(ErrInvalidArgs, "%v", err)
+		}
+		overrides[key] = value
 	}
+	cfg.Headers = mergeHeaders(cfg.Headers, overrides)
+	return cfg, nil
+}
+
+// ServerEdit describes in-place changes to a server made by --edit
+type ServerEdit struct {
+	URL           string   // New URL; empty keeps the current one
+	SetHeaders    []string // 'Name: Value' headers, replacing any with the same name
+	RemoveHeaders []string // Header names to delete
+}
 
-	delete(config.Servers, name)
-	if err := SaveConfig(config); err != nil {
-		errExit(ErrMCPError, fmt.Sprintf("Failed to save config: %v", err))
+// applyServerEdit returns cfg with the edit applied. Header names match
+// case-insensitively; OAuth, Local and other settings are left untouched.
+func applyServerEdit(cfg ServerConfig, edit ServerEdit) (ServerConfig, error) {
+	if edit.URL != "" {
+		cfg.URL = edit.URL
 	}
 
-	ok(map[string]any{
-		"message": fmt.Sprintf("Server '%s' removed", name),
+	headers := make(map[string]string, len(cfg.Headers))
+	for k, v := range cfg.Headers {
+		headers[k] = v
+	}
+	deleteHeader := func(name string) bool {
+		for k := range headers {
+			if strings.EqualFold(k, name) {
+				delete(headers, k)
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, name := range edit.RemoveHeaders {
+		if !deleteHeader(strings.TrimSpace(name)) {
+			return cfg, codedErrorf(ErrNotFound, "Header '%s' is not set on this server", name)
+		}
+	}
+	for _, h := range edit.SetHeaders {
+		key, value, err := parseHeaderFlag(h)
+		if err != nil {
+			return cfg, codedErrorf(ErrInvalidArgs, "%s", err.Error())
+		}
+		deleteHeader(key)
+		headers[key] = value
+	}
+
+	cfg.Headers = headers
+	if len(headers) == 0 {
+		cfg.Headers = nil
+	}
+	return cfg, nil
+}
+
+// editServerConfig applies an edit to a configured server (name or alias)
+// and saves it, returning the canonical name and updated config
+func editServerConfig(name string, edit ServerEdit) (string, ServerConfig, error) {
+	var canonical string
+	var updated ServerConfig
+	err := UpdateConfig(func(config *Config) error {
+		var cfg ServerConfig
+		var exists bool
+		canonical, cfg, exists = config.Lookup(name)
+		if !exists {
+			return codedErrorf(ErrNotFound, "Server '%s' not found.", name)
+		}
+
+		var err error
+		updated, err = applyServerEdit(cfg, edit)
+		if err != nil {
+			return err
+		}
+		config.Servers[canonical] = updated
+		return config.Validate()
 	})
+	return canonical, updated, err
 }
 
-// Placeholder implementations - will be filled in subsequent phases
+// editServer modifies an existing server in place
+func editServer(name string, edit ServerEdit) {
+	if edit.URL == "" && len(edit.SetHeaders) == 0 && len(edit.RemoveHeaders) == 0 {
+		errExit(ErrInvalidArgs, "Usage: --edit <name> [--url <url>] [--header 'Name: Value'] [--remove-header <name>]")
+	}
 
-func listTools(serverName string) {
-	config, err := LoadConfig()
+	canonical, cfg, err := editServerConfig(name, edit)
 	if err != nil {
-		errExit(ErrMCPError, fmt.Sprintf("Failed to load config: %v", err))
+		errExit(errorCodeOf(err, ErrInvalidArgs), err.Error())
 	}
+	notifyDaemonReload()
 
-	serverConfig, exists := config.Servers[serverName]
-	if !exists {
-		errExit(ErrNotFound, fmt.Sprintf("Server '%s' not configured. Run --servers to list.", serverName))
+	ok(map[string]any{
+		"message": fmt.Sprintf("Server '%s' updated", canonical),
+		"server": ServerInfo{
+			Name:    canonical,
+			URL:     cfg.URL,
+			Headers: cfg.Headers,
+			HasAuth: len(cfg.Headers) > 0 || cfg.TokenCommand != "",
+			IsLocal: cfg.Local != nil,
+			Aliases: cfg.Aliases,
+		}.Redact(),
+	})
+}
+
+// renameServer renames a server along with its stored credentials
+func renameServer(oldName, newName string) {
+	canonical, err := RenameServer(oldName, newName)
+	if canonical != "" {
+		notifyDaemonReload()
+	}
+	if err != nil {
+		errExit(errorCodeOf(err, ErrMCPError), err.Error())
 	}
 
-	client := NewMCPClient(serverName, serverConfig)
+	ok(map[string]any{
+		"message": fmt.Sprintf("Server '%s' renamed to '%s'", canonical, newName),
+	})
+}
 
-	// Get OAuth token if available
-	token, _ := GetTokenForServer(serverName, serverConfig)
-	if token != "" {
-		client.SetOAuthToken(token)
+// cloneServer copies a server's config under a new name
+func cloneServer(srcName, destName string) {
+	canonical, err := CloneServer(srcName, destName)
+	if err != nil {
+		errExit(errorCodeOf(err, ErrMCPError), err.Error())
 	}
+	notifyDaemonReload()
 
-	tools, err := client.ListTools()
+	ok(map[string]any{
+		"message": fmt.Sprintf("Server '%s' cloned to '%s'", canonical, destName),
+	})
+}
+
+// setDefaultServer sets (or with "" clears) the default server
+func setDefaultServer(name string) {
+	canonical, err := SetDefaultServer(name)
+	if err != nil {
+		errExit(errorCodeOf(err, ErrMCPError), err.Error())
+	}
+
+	if canonical == "" {
+		ok(map[string]any{"message": "Default server cleared"})
+	}
+	ok(map[string]any{
+		"message": fmt.Sprintf("Default server set to '%s'", canonical),
+	})
+}
+
+// trustProject trusts the project .mcpx.json that applies in the current
+// directory, as it is now
+func trustProject() {
+	cwd, err := os.Getwd()
 	if err != nil {
 		errExit(ErrMCPError, err.Error())
 	}
+	path := findProjectConfig(cwd)
+	if path == "" {
+		errExit(ErrNotFound, fmt.Sprintf("No %s in this directory or its parents", ProjectConfigName))
+	}
+	if err := TrustProjectConfig(path); err != nil {
+		errExit(ErrMCPError, fmt.Sprintf("Failed to trust %s: %v", path, err))
+	}
+	ok(map[string]any{"message": fmt.Sprintf("Trusted %s", path), "path": path})
+}
+
+// removeServer removes a server from the configuration
+func removeServer(name string) {
+	err := UpdateConfig(func(config *Config) error {
+		if _, exists := config.Servers[name]; !exists {
+			return codedErrorf(ErrNotFound, "Server '%s' not found.", name)
+		}
+		delete(config.Servers, name)
+		if config.DefaultServer == name {
+			config.DefaultServer = ""
+		}
+		return nil
+	})
+	if err != nil {
+		errExit(errorCodeOf(err, ErrMCPError), err.Error())
+	}
+	notifyDaemonReload()
 
 	ok(map[string]any{
-		"server": serverName,
-		"tools":  tools,
+		"message": fmt.Sprintf("Server '%s' removed", name),
 	})
 }
 
-func callTool(serverName, toolName, argsJSON string) {
+// notifyDaemonReload asks a running daemon to pick up config changes.
+// Best effort: the daemon also reloads on demand.
+func notifyDaemonReload() {
+	if IsDaemonRunning() {
+		DaemonSend(DaemonCommand{Action: "reload"})
+	}
+}
+
+// Placeholder implementations - will be filled in subsequent phases
+
+func listTools(serverName string) {
 	config, err := LoadConfig()
 	if err != nil {
 		errExit(ErrMCPError, fmt.Sprintf("Failed to load config: %v", err))
 	}
 
-	serverConfig, exists := config.Servers[serverName]
+	canonical, serverConfig, exists := config.Lookup(serverName)
 	if !exists {
 		errExit(ErrNotFound, fmt.Sprintf("Server '%s' not configured. Run --servers to list.", serverName))
 	}
-
-	var arguments map[string]any
-	if err := json.Unmarshal([]byte(argsJSON), &arguments); err != nil {
-		errExit(ErrInvalidJSON, fmt.Sprintf("Invalid JSON arguments: %v", err))
+	serverName = canonical
+	serverConfig, err = withCallHeaders(serverConfig, flagCallHeader)
+	if err != nil {
+		errExit(ErrInvalidArgs, err.Error())
 	}
 
-	client := NewMCPClient(serverName, serverConfig)
+	client, err := NewMCPClient(serverName, serverConfig)
+	if err != nil {
+		errExit(ErrConnectionFailed, err.Error())
+	}
 
 	// Get OAuth token if available
 	token, _ := GetTokenForServer(serverName, serverConfig)
@@ -317,99 +769,789 @@ func callTool(serverName, toolName, argsJSON string) {
 		client.SetOAuthToken(token)
 	}
 
-	result, err := client.CallTool(toolName, arguments)
+	tools, err := client.ListToolsOnce(context.Background())
 	if err != nil {
-		errExit(ErrMCPError, err.Error())
+		printAuthHint(serverName, err)
+		errExit(errorCodeOf(err, ErrMCPError), err.Error())
 	}
+	SaveToolListing(serverName, tools) // Best effort: only needed for --call <server> #N
 
 	ok(map[string]any{
 		"server": serverName,
-		"tool":   toolName,
-		"result": result,
+		"tools":  tools,
 	})
 }
 
-func doAuth(serverName string) {
-	config, err := LoadConfig()
-	if err != nil {
-		errExit(ErrMCPError, fmt.Sprintf("Failed to load config: %v", err))
+// callMeta parses --meta, the _meta object sent with a tool call
+func callMeta() map[string]any {
+	if *flagMeta == "" {
+		return nil
 	}
+	var meta map[string]any
+	if err := json.Unmarshal([]byte(*flagMeta), &meta); err != nil {
+		errExit(ErrInvalidJSON, fmt.Sprintf("Invalid --meta JSON: %v", err))
+	}
+	return meta
+}
 
-	serverConfig, exists := config.Servers[serverName]
-	if !exists {
-		errExit(ErrNotFound, fmt.Sprintf("Server '%s' not configured", serverName))
+// callArgs splits --call/--query positionals into server, tool and JSON
+// arguments. -a assignments are applied over the JSON, which may then be
+// omitted.
+func callArgs(args []string, usage string) (string, string, string) {
+	if config, err := LoadConfig(); err == nil {
+		args, err = withDefaultServer(config, args)
+		if err != nil {
+			errExit(errorCodeOf(err, ErrNotFound), err.Error())
+		}
+	}
+	if len(args) < 2 || (len(args) < 3 && len(flagArgs) == 0) {
+		errExit(ErrInvalidArgs, usage)
+	}
+	if len(flagArgs) == 0 {
+		return args[0], args[1], args[2]
 	}
 
-	if err := DoOAuthFlow(serverName, serverConfig); err != nil {
-		errExit(ErrAuthExpired, err.Error())
+	var arguments map[string]any
+	if len(args) > 2 {
+		if err := json.Unmarshal([]byte(args[2]), &arguments); err != nil {
+			errExit(ErrInvalidJSON, fmt.Sprintf("Invalid JSON arguments: %v", err))
+		}
 	}
+	if arguments == nil {
+		arguments = make(map[string]any)
+	}
+	if err := applyArgAssignments(arguments, flagArgs); err != nil {
+		errExit(errorCodeOf(err, ErrInvalidArgs), err.Error())
+	}
+	data, _ := json.Marshal(arguments)
+	return args[0], args[1], string(data)
 }
 
-func startDaemon() {
-	if err := StartDaemonBackground(); err != nil {
-		errExit(ErrDaemonError, err.Error())
+// withDefaultServer puts the default server in front of --call/--query
+// positionals that leave the server out: <tool> or <tool> '<json>', whose
+// first argument is not a configured server or alias. Any other pair is
+// <server> <tool> with -a arguments, so a mistyped server name is reported
+// as not configured rather than parsed as a tool.
+func withDefaultServer(config *Config, args []string) ([]string, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return args, nil
+	}
+	if _, _, ok := config.Lookup(args[0]); ok {
+		return args, nil
+	}
+	if len(args) == 2 && !strings.HasPrefix(strings.TrimSpace(args[1]), "{") {
+		return args, nil
+	}
+	if config.DefaultServer == "" {
+		return nil, codedErrorf(ErrNotFound, "Server '%s' not configured and no default server set. Use --set-default-server <name> or name the server first.", args[0])
+	}
+	server, err := config.defaultServer()
+	if err != nil {
+		return nil, err
 	}
+	return append([]string{server}, args...), nil
 }
 
-func runDaemonForeground() {
-	daemon, err := NewMCPDaemon()
+// canonicalServerName resolves an alias for commands that otherwise leave
+// that to the daemon; unknown names are returned unchanged
+func canonicalServerName(serverName string) string {
+	config, err := LoadConfig()
 	if err != nil {
-		errExit(ErrMCPError, err.Error())
+		return serverName
 	}
-	if err := daemon.Run(); err != nil {
-		errExit(ErrMCPError, err.Error())
+	if canonical, _, ok := config.Lookup(serverName); ok {
+		return canonical
 	}
+	return serverName
 }
 
-func stopDaemon() {
-	if err := StopDaemon(); err != nil {
-		errExit(ErrDaemonError, err.Error())
+// resolveToolRefOrExit resolves a #N tool reference against the server's
+// last --tools listing, exiting with a structured error if it can't
+func resolveToolRefOrExit(serverName, toolName string) string {
+	toolName, err := resolveToolRef(serverName, toolName)
+	if err != nil {
+		errExit(errorCodeOf(err, ErrMCPError), err.Error())
 	}
+	return toolName
 }
 
-func daemonStatus() {
-	GetDaemonStatus()
-}
-
-func daemonTools(serverName string) {
-	resp, err := DaemonSend(DaemonCommand{
-		Action: "tools",
-		Server: serverName,
-	})
+// doctor health-checks every server and exits non-zero if any is unhealthy
+func doctor() {
+	config, err := LoadConfig()
 	if err != nil {
-		errExit(ErrDaemonError, err.Error())
+		errExit(ErrMCPError, fmt.Sprintf("Failed to load config: %v", err))
+	}
+
+	report := checkServers(config, doctorConcurrency, doctorTimeout)
+	resp := Response{OK: report.Healthy, Version: ResponseVersion, Data: report}
+	if !report.Healthy {
+		resp.Error = &ErrorResponse{
+			Code:    ErrConnectionFailed,
+			Message: fmt.Sprintf("%d of %d servers unhealthy", len(report.Checks)-report.Summary[HealthOK], len(report.Checks)),
+		}
 	}
 
 	out, _ := json.MarshalIndent(resp, "", "  ")
-	fmt.Println(string(out))
-	if !resp.OK {
+	emit(string(out))
+	if !report.Healthy {
 		os.Exit(1)
 	}
 }
 
-func daemonQuery(serverName, toolName, argsJSON string) {
-	var arguments map[string]any
-	if err := json.Unmarshal([]byte(argsJSON), &arguments); err != nil {
-		errExit(ErrInvalidJSON, fmt.Sprintf("Invalid JSON arguments: %v", err))
+// pingServer runs an initialize handshake and reports the round-trip time
+func pingServer(serverName string) {
+	config, err := LoadConfig()
+	if err != nil {
+		errExit(ErrMCPError, fmt.Sprintf("Failed to load config: %v", err))
 	}
 
-	resp, err := DaemonSend(DaemonCommand{
-		Action:    "call",
-		Server:    serverName,
-		Tool:      toolName,
-		Arguments: arguments,
-	})
-	if err != nil {
-		errExit(ErrDaemonError, err.Error())
+	canonical, serverConfig, exists := config.Lookup(serverName)
+	if !exists {
+		errExit(ErrNotFound, fmt.Sprintf("Server '%s' not configured. Run --servers to list.", serverName))
+	}
+
+	client, err := NewMCPClient(canonical, serverConfig)
+	if err != nil {
+		errExit(ErrConnectionFailed, err.Error())
+	}
+
+	token, _ := GetTokenForServer(canonical, serverConfig)
+	if token != "" {
+		client.SetOAuthToken(token)
+	}
+
+	rtt, err := client.Ping()
+	if err != nil {
+		printAuthHint(canonical, err)
+		errExit(errorCodeOf(err, ErrConnectionFailed), err.Error())
+	}
+
+	ok(newPingResult(canonical, client, rtt))
+}
+
+// findTools searches tools across all servers, via the daemon's cache when running
+func findTools(keyword string) {
+	if IsDaemonRunning() {
+		resp, err := DaemonSend(DaemonCommand{Action: "find", Query: keyword})
+		if err != nil {
+			errExit(ErrDaemonError, err.Error())
+		}
+		out, _ := json.MarshalIndent(resp, "", "  ")
+		emit(string(out))
+		if !resp.OK {
+			os.Exit(1)
+		}
+		return
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		errExit(ErrMCPError, fmt.Sprintf("Failed to load config: %v", err))
+	}
+
+	names := make([]string, 0, len(config.Servers))
+	for name := range config.Servers {
+		names = append(names, name)
+	}
+	toolsByServer, warnings := fetchAllTools(names, findConcurrency, func(name string) ([]Tool, error) {
+		return fetchTools(name, config.Servers[name])
+	})
+
+	ok(map[string]any{
+		"query":    keyword,
+		"matches":  searchTools(keyword, toolsByServer),
+		"warnings": warnings,
+	})
+}
+
+// fetchTools lists a server's tools over a one-shot connection
+func fetchTools(serverName string, serverConfig ServerConfig) ([]Tool, error) {
+	client, err := NewMCPClient(serverName, serverConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	token, _ := GetTokenForServer(serverName, serverConfig)
+	if token != "" {
+		client.SetOAuthToken(token)
+	}
+	return client.ListTools()
+}
+
+// describeToolCmd prints a tool's parameters and an example arguments
+// skeleton, using the daemon's cached tool list when the daemon is running
+func describeToolCmd(serverName, toolName string) {
+	if IsDaemonRunning() {
+		resp, err := DaemonSend(DaemonCommand{Action: "describe", Server: serverName, Tool: toolName})
+		if err != nil {
+			errExit(ErrDaemonError, err.Error())
+		}
+		out, _ := json.MarshalIndent(resp, "", "  ")
+		emit(string(out))
+		if !resp.OK {
+			os.Exit(1)
+		}
+		return
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		errExit(ErrMCPError, fmt.Sprintf("Failed to load config: %v", err))
+	}
+
+	canonical, serverConfig, exists := config.Lookup(serverName)
+	if !exists {
+		errExit(ErrNotFound, fmt.Sprintf("Server '%s' not configured. Run --servers to list.", serverName))
+	}
+
+	client, err := NewMCPClient(canonical, serverConfig)
+	if err != nil {
+		errExit(ErrConnectionFailed, err.Error())
+	}
+
+	token, _ := GetTokenForServer(canonical, serverConfig)
+	if token != "" {
+		client.SetOAuthToken(token)
+	}
+
+	tools, err := client.ListTools()
+	if err != nil {
+		printAuthHint(canonical, err)
+		errExit(errorCodeOf(err, ErrMCPError), err.Error())
+	}
+
+	tool := findTool(tools, toolName)
+	if tool == nil {
+		errExit(ErrUnknownTool, fmt.Sprintf("Tool '%s' not found on '%s'. Run --tools %s to list.", toolName, canonical, canonical))
+	}
+
+	ok(describeTool(canonical, *tool))
+}
+
+// interactiveCall prompts for a tool's arguments from its inputSchema, then calls it
+func interactiveCall(serverName, toolName string) {
+	config, err := LoadConfig()
+	if err != nil {
+		errExit(ErrMCPError, fmt.Sprintf("Failed to load config: %v", err))
+	}
+
+	canonical, serverConfig, exists := config.Lookup(serverName)
+	if !exists {
+		errExit(ErrNotFound, fmt.Sprintf("Server '%s' not configured. Run --servers to list.", serverName))
+	}
+
+	client, err := NewMCPClient(canonical, serverConfig)
+	if err != nil {
+		errExit(ErrConnectionFailed, err.Error())
+	}
+
+	token, _ := GetTokenForServer(canonical, serverConfig)
+	if token != "" {
+		client.SetOAuthToken(token)
+	}
+
+	tools, err := client.ListTools()
+	if err != nil {
+		printAuthHint(canonical, err)
+		errExit(errorCodeOf(err, ErrMCPError), err.Error())
+	}
+
+	tool := findTool(tools, toolName)
+	if tool == nil {
+		errExit(ErrUnknownTool, fmt.Sprintf("Tool '%s' not found on '%s'. Run --tools %s to list.", toolName, canonical, canonical))
+	}
+
+	// Prompts go to stderr so stdout stays pure JSON
+	if tool.Description != "" {
+		fmt.Fprintf(os.Stderr, "%s: %s\n\n", tool.Name, tool.Description)
+	}
+	arguments, err := promptArguments(tool.Parameters, os.Stdin, os.Stderr)
+	if err != nil {
+		errExit(ErrInvalidArgs, err.Error())
+	}
+
+	argsJSON, _ := json.Marshal(arguments)
+	callTool(canonical, toolName, string(argsJSON))
+}
+
+// completeArgument prints the server's suggested values for a prompt or
+// resource template argument
+func completeArgument(serverName, refName, argName, partial string) {
+	config, err := LoadConfig()
+	if err != nil {
+		errExit(ErrMCPError, fmt.Sprintf("Failed to load config: %v", err))
+	}
+
+	canonical, serverConfig, exists := config.Lookup(serverName)
+	if !exists {
+		errExit(ErrNotFound, fmt.Sprintf("Server '%s' not configured. Run --servers to list.", serverName))
+	}
+
+	client, err := NewMCPClient(canonical, serverConfig)
+	if err != nil {
+		errExit(ErrConnectionFailed, err.Error())
+	}
+
+	token, _ := GetTokenForServer(canonical, serverConfig)
+	if token != "" {
+		client.SetOAuthToken(token)
+	}
+
+	ref := parseCompletionRef(refName)
+	values, err := client.Complete(ref, argName, partial)
+	if err != nil {
+		printAuthHint(canonical, err)
+		errExit(errorCodeOf(err, ErrMCPError), err.Error())
+	}
+
+	ok(map[string]any{
+		"server":   canonical,
+		"ref":      ref,
+		"argument": argName,
+		"values":   values,
+	})
+}
+
+// validateToolCall checks prepared arguments (default_args merged in and
+// pre_call_command applied) against the tool's inputSchema. If tools can't
+// be listed, validation is skipped and the call itself reports the problem.
+func validateToolCall(client *MCPClient, toolName string, arguments map[string]any) error {
+	tools, err := client.ListTools()
+	if err != nil {
+		return nil
+	}
+	tool := findTool(tools, toolName)
+	if tool == nil {
+		return codedErrorf(ErrUnknownTool, "Tool '%s' not found. Run --tools to list.", toolName)
+	}
+	return validateArguments(tool.Parameters, arguments)
+}
+
+func callTool(serverName, toolName, argsJSON string) {
+	config, err := LoadConfig()
+	if err != nil {
+		errExit(ErrMCPError, fmt.Sprintf("Failed to load config: %v", err))
+	}
+
+	canonical, serverConfig, exists := config.Lookup(serverName)
+	if !exists {
+		errExit(ErrNotFound, fmt.Sprintf("Server '%s' not configured. Run --servers to list.", serverName))
+	}
+	serverName = canonical
+	toolName = resolveToolRefOrExit(serverName, toolName)
+
+	var arguments map[string]any
+	if err := json.Unmarshal([]byte(argsJSON), &arguments); err != nil {
+		errExit(ErrInvalidJSON, fmt.Sprintf("Invalid JSON arguments: %v", err))
+	}
+
+	if *flagReadOnly || serverConfig.ReadOnly {
+		errExit(ErrReadOnly, fmt.Sprintf("read-only mode: tool calls to '%s' are disabled", serverName))
+	}
+
+	serverConfig, err = withCallHeaders(serverConfig, flagCallHeader)
+	if err != nil {
+		errExit(ErrInvalidArgs, err.Error())
+	}
+
+	client, err := NewMCPClient(serverName, serverConfig)
+	if err != nil {
+		errExit(ErrConnectionFailed, err.Error())
+	}
+
+	// Get OAuth token if available
+	token, _ := GetTokenForServer(serverName, serverConfig)
+	if token != "" {
+		client.SetOAuthToken(token)
+	}
+
+	arguments, err = client.PrepareArguments(context.Background(), toolName, arguments)
+	if err != nil {
+		errExit(errorCodeOf(err, ErrMCPError), err.Error())
+	}
+	if !*flagNoValidate {
+		if err := validateToolCall(client, toolName, arguments); err != nil {
+			errExit(errorCodeOf(err, ErrSchemaError), err.Error())
+		}
+	}
+
+	result, _, err := client.CallPreparedTool(context.Background(), toolName, arguments, callMeta())
+	if err != nil {
+		printAuthHint(serverName, err)
+		errExit(errorCodeOf(err, ErrMCPError), err.Error())
+	}
+	result = truncateResult(result, serverConfig.responseLimit(*flagMaxBytes))
+
+	printCallResult(okResponse(map[string]any{
+		"server": serverName,
+		"tool":   toolName,
+		"result": result,
+	}), toolName)
+}
+
+// printCallResult prints a tool call response, saving binary content blocks
+// with --save-blobs, narrowing the result with --filter and rendering
+// content as text with --format text.
+// Errors are always printed as JSON. Exits non-zero if resp is not OK.
+func printCallResult(resp Response, toolName string) {
+	data, _ := resp.Data.(map[string]any)
+	result, _ := data["result"].(map[string]any)
+
+	var saved []SavedBlob
+	if resp.OK && *flagSaveBlobs != "" && result != nil {
+		var err error
+		saved, err = saveBlobs(result, *flagSaveBlobs, toolName)
+		if err != nil {
+			errExit(ErrMCPError, fmt.Sprintf("Failed to save blobs: %v", err))
+		}
+		data["blobs"] = saved
+	}
+
+	if resp.OK && *flagFilter != "" {
+		filtered, err := applyFilterExpr(*flagFilter, data["result"])
+		if err != nil {
+			errExit(errorCodeOf(err, ErrInvalidArgs), err.Error())
+		}
+		if *flagFormat == FormatText {
+			if text, ok := filtered.(string); ok {
+				emit(text)
+			} else {
+				out, _ := json.MarshalIndent(filtered, "", "  ")
+				emit(string(out))
+			}
+			os.Exit(0)
+		}
+		data["result"] = filtered
+		out, _ := json.MarshalIndent(resp, "", "  ")
+		emit(string(out))
+		os.Exit(0)
+	}
+
+	if resp.OK && *flagFormat == FormatText && result != nil {
+		emit(formatResultText(result, saved))
+		os.Exit(0)
+	}
+
+	out, _ := json.MarshalIndent(resp, "", "  ")
+	emit(string(out))
+	if !resp.OK {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// authHint returns a re-auth instruction when err indicates missing or expired credentials
+func authHint(serverName string, err error) string {
+	if errorCodeOf(err, "") != ErrAuthExpired {
+		return ""
+	}
+	return fmt.Sprintf("Authentication required for '%s'. Run: mcpx --auth %s", serverName, serverName)
+}
+
+// printAuthHint prints the re-auth hint to stderr, keeping stdout valid JSON
+func printAuthHint(serverName string, err error) {
+	if hint := authHint(serverName, err); hint != "" {
+		fmt.Fprintln(os.Stderr, hint)
+	}
+}
+
+func doAuth(serverName string) {
+	config, err := LoadConfig()
+	if err != nil {
+		errExit(ErrMCPError, fmt.Sprintf("Failed to load config: %v", err))
+	}
+
+	canonical, serverConfig, exists := config.Lookup(serverName)
+	if !exists {
+		errExit(ErrNotFound, fmt.Sprintf("Server '%s' not configured", serverName))
+	}
+	serverName = canonical
+
+	// Work on a copy so the flags only affect this login, not the saved config
+	oauth := OAuthConfig{}
+	if serverConfig.OAuth != nil {
+		oauth = *serverConfig.OAuth
+	}
+	if *flagAuthPort >= 0 {
+		if *flagAuthPort > 65535 {
+			errExit(ErrInvalidArgs, fmt.Sprintf("Invalid --auth-port %d", *flagAuthPort))
+		}
+		oauth.CallbackPort = flagAuthPort
+	}
+	if *flagAuthRedirect != "" {
+		oauth.RedirectURI = *flagAuthRedirect
+	}
+	if err := oauth.validateRedirect(); err != nil {
+		errExit(ErrInvalidArgs, err.Error())
+	}
+	serverConfig.OAuth = &oauth
+
+	if err := DoOAuthFlow(serverName, serverConfig); err != nil {
+		errExit(ErrAuthExpired, err.Error())
+	}
+}
+
+func logout(serverName string) {
+	config, err := LoadConfig()
+	if err != nil {
+		errExit(ErrMCPError, fmt.Sprintf("Failed to load config: %v", err))
+	}
+
+	canonical, serverConfig, exists := config.Lookup(serverName)
+	if !exists {
+		errExit(ErrNotFound, fmt.Sprintf("Server '%s' not configured", serverName))
+	}
+
+	revoked, warning, err := LogoutServer(canonical, serverConfig)
+	if err != nil {
+		errExit(errorCodeOf(err, ErrMCPError), err.Error())
+	}
+	notifyDaemonReload()
+
+	data := map[string]any{
+		"message": fmt.Sprintf("Logged out of '%s'", canonical),
+		"revoked": revoked,
+	}
+	if warning != "" {
+		data["warning"] = warning
+	}
+	ok(data)
+}
+
+func startDaemon() {
+	// Validate before forking so a bad level is reported to the user
+	if *flagLogLevel != "" {
+		if _, err := ParseLogLevel(*flagLogLevel); err != nil {
+			errExit(ErrInvalidArgs, err.Error())
+		}
+	}
+	if *flagStartupTimeout <= 0 {
+		errExit(ErrInvalidArgs, "--startup-timeout must be positive")
+	}
+	timeout := time.Duration(*flagStartupTimeout) * time.Second
+	if err := StartDaemonBackground(daemonArgs(), timeout); err != nil {
+		errExit(ErrDaemonError, err.Error())
+	}
+}
+
+// daemonArgs returns the CLI flags forwarded to the background daemon process
+func daemonArgs() []string {
+	var args []string
+	if ActiveProfile != DefaultProfile {
+		args = append(args, "--profile", ActiveProfile)
+	}
+	if *flagReadOnly {
+		args = append(args, "--read-only")
+	}
+	if *flagLogLevel != "" {
+		args = append(args, "--log-level", *flagLogLevel)
+	}
+	if *flagMaxConnections > 0 {
+		args = append(args, "--max-connections", fmt.Sprint(*flagMaxConnections))
+	}
+	if *flagDebug {
+		args = append(args, "--debug")
+	}
+	return args
+}
+
+func runDaemonForeground() {
+	if *flagLogLevel != "" {
+		level, err := ParseLogLevel(*flagLogLevel)
+		if err != nil {
+			errExit(ErrInvalidArgs, err.Error())
+		}
+		logger.SetLevel(level)
+	}
+	if *flagLogStdout {
+		logger.SetOutput(os.Stdout)
+	}
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		errExit(ErrMCPError, err.Error())
+	}
+	daemon.readOnly = *flagReadOnly
+	if *flagMaxConnections > 0 {
+		daemon.maxConns = *flagMaxConnections
+	}
+	if err := daemon.Run(); err != nil {
+		errExit(ErrMCPError, err.Error())
+	}
+}
+
+// setServerLogLevel sends logging/setLevel to a server (through the daemon's
+// live connection when it is running) and saves the level to the server's
+// config so it is re-applied after every initialize
+func setServerLogLevel(serverName, level string) {
+	level, err := parseServerLogLevel(level)
+	if err != nil {
+		errExit(ErrInvalidArgs, err.Error())
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		errExit(ErrMCPError, fmt.Sprintf("Failed to load config: %v", err))
+	}
+	canonical, serverConfig, exists := config.Lookup(serverName)
+	if !exists {
+		errExit(ErrNotFound, fmt.Sprintf("Server '%s' not configured. Run --servers to list.", serverName))
+	}
+
+	if IsDaemonRunning() {
+		resp, err := DaemonSend(DaemonCommand{Action: "set-log-level", Server: canonical, Level: level})
+		if err != nil {
+			errExit(ErrDaemonError, err.Error())
+		}
+		if !resp.OK {
+			errExit(resp.Error.Code, resp.Error.Message)
+		}
+	} else {
+		client, err := NewMCPClient(canonical, serverConfig)
+		if err != nil {
+			errExit(ErrConnectionFailed, err.Error())
+		}
+		token, _ := GetTokenForServer(canonical, serverConfig)
+		if token != "" {
+			client.SetOAuthToken(token)
+		}
+		if err := client.SetLogLevel(context.Background(), level); err != nil {
+			printAuthHint(canonical, err)
+			errExit(errorCodeOf(err, ErrMCPError), err.Error())
+		}
+	}
+
+	err = UpdateConfig(func(config *Config) error {
+		cfg, exists := config.Servers[canonical]
+		if !exists {
+			return codedErrorf(ErrNotFound, "Server '%s' is not in %s; level applied but not saved.", canonical, ConfigFile)
+		}
+		cfg.LogLevel = level
+		config.Servers[canonical] = cfg
+		return nil
+	})
+	if err != nil {
+		errExit(errorCodeOf(err, ErrMCPError), err.Error())
+	}
+
+	ok(map[string]any{
+		"server": canonical,
+		"level":  level,
+	})
+}
+
+func stopDaemon() {
+	if err := StopDaemon(); err != nil {
+		errExit(ErrDaemonError, err.Error())
+	}
+}
+
+func daemonStatus() {
+	GetDaemonStatus()
+}
+
+func daemonTools(serverName string) {
+	resp, err := DaemonSend(DaemonCommand{
+		Action: "tools",
+		Server: serverName,
+	})
+	if err != nil {
+		errExit(ErrDaemonError, err.Error())
+	}
+
+	if resp.OK {
+		var listing struct {
+			Tools []Tool `json:"tools"`
+		}
+		if data, err := json.Marshal(resp.Data); err == nil && json.Unmarshal(data, &listing) == nil {
+			SaveToolListing(canonicalServerName(serverName), listing.Tools)
+		}
 	}
 
 	out, _ := json.MarshalIndent(resp, "", "  ")
-	fmt.Println(string(out))
+	emit(string(out))
 	if !resp.OK {
 		os.Exit(1)
 	}
 }
 
+func daemonReload() {
+	resp, err := sendDaemonReload()
+	if err != nil {
+		errExit(ErrDaemonError, err.Error())
+	}
+
+	out, _ := json.MarshalIndent(resp, "", "  ")
+	emit(string(out))
+	if !resp.OK {
+		os.Exit(1)
+	}
+}
+
+// daemonSubscribe prints resource update notifications from the daemon, one
+// JSON line per update, until interrupted
+func daemonSubscribe(serverName, uri string) {
+	stop := make(chan struct{})
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		close(stop)
+	}()
+
+	failed := false
+	err := DaemonStream(DaemonCommand{Action: "subscribe", Server: serverName, URI: uri}, stop, func(resp Response) bool {
+		out, _ := json.Marshal(resp)
+		fmt.Println(string(out))
+		failed = !resp.OK
+		return resp.OK
+	})
+	if err != nil {
+		errExit(ErrDaemonError, err.Error())
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// sendDaemonReload asks the running daemon to reload servers.json
+func sendDaemonReload() (Response, error) {
+	return DaemonSend(DaemonCommand{Action: "reload"})
+}
+
+func daemonQuery(serverName, toolName, argsJSON string) {
+	// The daemon enforces its own and the servers' read-only settings; this
+	// one belongs to the command line
+	if *flagReadOnly {
+		errExit(ErrReadOnly, fmt.Sprintf("read-only mode: tool calls to '%s' are disabled", serverName))
+	}
+
+	if strings.HasPrefix(toolName, "#") {
+		toolName = resolveToolRefOrExit(canonicalServerName(serverName), toolName)
+	}
+
+	var arguments map[string]any
+	if err := json.Unmarshal([]byte(argsJSON), &arguments); err != nil {
+		errExit(ErrInvalidJSON, fmt.Sprintf("Invalid JSON arguments: %v", err))
+	}
+
+	resp, err := DaemonSend(DaemonCommand{
+		Action:     "call",
+		Server:     serverName,
+		Tool:       toolName,
+		Arguments:  arguments,
+		NoValidate: *flagNoValidate,
+		Meta:       callMeta(),
+		MaxBytes:   *flagMaxBytes,
+	})
+	if err != nil {
+		errExit(ErrDaemonError, err.Error())
+	}
+
+	printCallResult(resp, toolName)
+}
+
 func showStatus() {
 	resp, err := DaemonSend(DaemonCommand{
 		Action: "status",
@@ -419,7 +1561,22 @@ func showStatus() {
 	}
 
 	out, _ := json.MarshalIndent(resp, "", "  ")
-	fmt.Println(string(out))
+	emit(string(out))
+	if !resp.OK {
+		os.Exit(1)
+	}
+}
+
+// serverLogs prints the notifications/message entries the daemon has
+// captured from a server
+func serverLogs(serverName string) {
+	resp, err := DaemonSend(DaemonCommand{Action: "logs", Server: serverName})
+	if err != nil {
+		errExit(ErrDaemonError, err.Error())
+	}
+
+	out, _ := json.MarshalIndent(resp, "", "  ")
+	emit(string(out))
 	if !resp.OK {
 		os.Exit(1)
 	}
@@ -433,19 +1590,48 @@ func tailLogs(serverName string) {
 		errExit(ErrNotFound, fmt.Sprintf("No logs found for server '%s'. Log path: %s", serverName, logPath))
 	}
 
-	// Use tail -f to follow the log file
-	cmd := exec.Command("tail", "-f", "-n", "100", logPath)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
 	fmt.Printf("Tailing logs for '%s' (Ctrl+C to stop)\n", serverName)
 	fmt.Printf("Log file: %s\n\n", logPath)
 
-	if err := cmd.Run(); err != nil {
-		// Ignore interrupt errors from Ctrl+C
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			os.Exit(exitErr.ExitCode())
-		}
+	// Stop following cleanly on Ctrl+C
+	stop := make(chan struct{})
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		close(stop)
+	}()
+
+	if err := followLog(logPath, os.Stdout, 100, stop, tailPollInterval); err != nil {
 		errExit(ErrMCPError, err.Error())
 	}
 }
+
+func tailAllLogs() {
+	config, err := LoadConfig()
+	if err != nil {
+		errExit(ErrMCPError, fmt.Sprintf("Failed to load config: %v", err))
+	}
+
+	logs := make(map[string]string)
+	for name, serverConfig := range config.Servers {
+		if serverConfig.Local != nil {
+			logs[name] = GetLogPath(name)
+		}
+	}
+	if len(logs) == 0 {
+		errExit(ErrNotFound, "No local servers configured")
+	}
+
+	fmt.Printf("Tailing logs for %d local servers (Ctrl+C to stop)\n\n", len(logs))
+
+	stop := make(chan struct{})
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		close(stop)
+	}()
+
+	followLogs(logs, os.Stdout, 100, stop, tailPollInterval, stdoutIsTerminal())
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The daemon isn't available on Windows, but the CLI must still build there
+func TestBuild_Windows(t *testing.T) {
+	if testing.Short() {
+		t.Skip("cross-compiling is skipped in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+
+	cmd := exec.Command(goTool, "build", "-o", os.DevNull, ".")
+	cmd.Env = append(os.Environ(), "GOOS=windows", "GOARCH=amd64")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("GOOS=windows go build failed: %v\n%s", err, out)
+	}
+}
+
+func TestAuthHint(t *testing.T) {
+	hint := authHint("supabase", &HTTPError{StatusCode: http.StatusUnauthorized})
+	if !strings.Contains(hint, "mcpx --auth supabase") {
+		t.Errorf("Expected auth hint for 401, got %q", hint)
+	}
+
+	if hint := authHint("supabase", &HTTPError{StatusCode: http.StatusInternalServerError}); hint != "" {
+		t.Errorf("Expected no hint for 500, got %q", hint)
+	}
+
+	if hint := authHint("supabase", errors.New("connection refused")); hint != "" {
+		t.Errorf("Expected no hint for plain error, got %q", hint)
+	}
+}
+
+func TestSendDaemonReload(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	// Unix socket paths are length-limited, so avoid the long t.TempDir path
+	sockDir, err := os.MkdirTemp("", "mcpx")
+	if err != nil {
+		t.Fatalf("MkdirTemp failed: %v", err)
+	}
+	defer os.RemoveAll(sockDir)
+	origSocket := SocketPath
+	SocketPath = filepath.Join(sockDir, "d.sock")
+	defer func() { SocketPath = origSocket }()
+
+	listener, err := net.Listen("unix", SocketPath)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan DaemonCommand, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var cmd DaemonCommand
+		json.NewDecoder(conn).Decode(&cmd)
+		received <- cmd
+		json.NewEncoder(conn).Encode(okResponse("config reloaded"))
+	}()
+
+	resp, err := sendDaemonReload()
+	if err != nil {
+		t.Fatalf("sendDaemonReload failed: %v", err)
+	}
+	if !resp.OK {
+		t.Errorf("Expected OK response, got %+v", resp)
+	}
+	if cmd := <-received; cmd.Action != "reload" {
+		t.Errorf("Expected reload action, got %q", cmd.Action)
+	}
+}
+
+func TestEditServerConfig_ChangeURL(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	original := ServerConfig{
+		URL:     "https://old.example.com/mcp",
+		Headers: map[string]string{"Authorization": "Bearer abc"},
+		OAuth:   &OAuthConfig{ClientID: "client-1"},
+		Aliases: []string{"db"},
+	}
+	if err := SaveConfig(&Config{Servers: map[string]ServerConfig{"database": original}}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	canonical, _, err := editServerConfig("db", ServerEdit{URL: "https://new.example.com/mcp"})
+	if err != nil {
+		t.Fatalf("editServerConfig failed: %v", err)
+	}
+	if canonical != "database" {
+		t.Errorf("Expected alias to resolve to 'database', got %s", canonical)
+	}
+
+	config, _ := LoadConfig()
+	cfg := config.Servers["database"]
+	if cfg.URL != "https://new.example.com/mcp" {
+		t.Errorf("Expected new URL, got %s", cfg.URL)
+	}
+	if cfg.Headers["Authorization"] != "Bearer abc" {
+		t.Errorf("Expected headers preserved, got %v", cfg.Headers)
+	}
+	if cfg.OAuth == nil || cfg.OAuth.ClientID != "client-1" {
+		t.Errorf("Expected OAuth block preserved, got %+v", cfg.OAuth)
+	}
+}
+
+func TestEditServerConfig_Headers(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	original := ServerConfig{
+		URL:     "https://example.com/mcp",
+		Headers: map[string]string{"Authorization": "Bearer abc", "X-Team": "ops"},
+	}
+	if err := SaveConfig(&Config{Servers: map[string]ServerConfig{"api": original}}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	_, _, err := editServerConfig("api", ServerEdit{
+		SetHeaders:    []string{"x-team: platform", "X-Region: eu"},
+		RemoveHeaders: []string{"authorization"},
+	})
+	if err != nil {
+		t.Fatalf("editServerConfig failed: %v", err)
+	}
+
+	config, _ := LoadConfig()
+	headers := config.Servers["api"].Headers
+	want := map[string]string{"x-team": "platform", "X-Region": "eu"}
+	if len(headers) != len(want) {
+		t.Fatalf("Expected headers %v, got %v", want, headers)
+	}
+	for k, v := range want {
+		if headers[k] != v {
+			t.Errorf("Expected %s=%s, got %v", k, v, headers)
+		}
+	}
+}
+
+func TestWithCallHeaders(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]any{}})
+	}))
+	defer server.Close()
+
+	stored := map[string]string{"X-Team": "ops", "X-Region": "us"}
+	if err := SaveConfig(&Config{Servers: map[string]ServerConfig{"api": {URL: server.URL, Headers: stored}}}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	config, _ := LoadConfig()
+	_, serverConfig, _ := config.Lookup("api")
+	serverConfig, err := withCallHeaders(serverConfig, []string{"x-team: debug", "X-Trace: 1", "Authorization: Bearer static"})
+	if err != nil {
+		t.Fatalf("withCallHeaders failed: %v", err)
+	}
+
+	client, err := NewMCPClient("api", serverConfig)
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+	client.SetOAuthToken("oauth-token")
+
+	if _, _, err := client.Request("ping", nil); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if v := got.Values("X-Team"); len(v) != 1 || v[0] != "debug" {
+		t.Errorf("Expected call-time X-Team to override the stored one, got %q", v)
+	}
+	if got.Get("X-Trace") != "1" || got.Get("X-Region") != "us" {
+		t.Errorf("Expected call-time and stored headers sent, got %v", got)
+	}
+	if got.Get("Authorization") != "Bearer oauth-token" {
+		t.Errorf("Expected OAuth token to take precedence, got %q", got.Get("Authorization"))
+	}
+
+	config, _ = LoadConfig()
+	headers := config.Servers["api"].Headers
+	if len(headers) != 2 || headers["X-Team"] != "ops" || headers["X-Region"] != "us" {
+		t.Errorf("Expected stored headers untouched, got %v", headers)
+	}
+
+	if _, err := withCallHeaders(serverConfig, []string{"no-colon"}); errorCodeOf(err, "") != ErrInvalidArgs {
+		t.Errorf("Expected INVALID_ARGS for a malformed header, got %v", err)
+	}
+}
+
+func TestEditServerConfig_Errors(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	if err := SaveConfig(&Config{Servers: map[string]ServerConfig{"api": {URL: "https://example.com"}}}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		server string
+		edit   ServerEdit
+		code   string
+	}{
+		{"unknown server", "missing", ServerEdit{URL: "https://x"}, ErrNotFound},
+		{"missing header", "api", ServerEdit{RemoveHeaders: []string{"X-Nope"}}, ErrNotFound},
+		{"bad header", "api", ServerEdit{SetHeaders: []string{"no-colon"}}, ErrInvalidArgs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := editServerConfig(tt.server, tt.edit)
+			if code := errorCodeOf(err, ""); code != tt.code {
+				t.Errorf("Expected %s, got %s (%v)", tt.code, code, err)
+			}
+		})
+	}
+}
+
+func TestWithDefaultServer_FallsBackWhenServerOmitted(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	SaveConfig(&Config{Servers: map[string]ServerConfig{
+		"database": {URL: "https://db.example.com/mcp", Aliases: []string{"db"}},
+		"search":   {URL: "https://search.example.com/mcp"},
+	}})
+	if _, err := SetDefaultServer("db"); err != nil {
+		t.Fatalf("SetDefaultServer failed: %v", err)
+	}
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if config.DefaultServer != "database" {
+		t.Fatalf("Expected the alias to be stored as 'database', got %q", config.DefaultServer)
+	}
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"execute_sql", `{"query": "SELECT 1"}`}, []string{"database", "execute_sql", `{"query": "SELECT 1"}`}},
+		{[]string{"execute_sql"}, []string{"database", "execute_sql"}},
+		// A configured server (or alias) first is used as given
+		{[]string{"search", "find", "{}"}, []string{"search", "find", "{}"}},
+		{[]string{"db", "execute_sql"}, []string{"db", "execute_sql"}},
+		// Two names that aren't a tool and its JSON are a server and tool
+		// (with -a arguments), even when the server is mistyped
+		{[]string{"typo", "execute_sql"}, []string{"typo", "execute_sql"}},
+	}
+	for _, tt := range tests {
+		got, err := withDefaultServer(config, tt.args)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tt.args, err)
+			continue
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%v: expected %v, got %v", tt.args, tt.want, got)
+		}
+	}
+}
+
+func TestWithDefaultServer_NoDefault(t *testing.T) {
+	config := &Config{Servers: map[string]ServerConfig{"search": {URL: "https://search.example.com/mcp"}}}
+
+	_, err := withDefaultServer(config, []string{"execute_sql", "{}"})
+	if err == nil {
+		t.Fatal("Expected an error when the server is omitted and no default is set")
+	}
+	if errorCodeOf(err, "") != ErrNotFound || !strings.Contains(err.Error(), "--set-default-server") {
+		t.Errorf("Expected a NOT_FOUND error pointing at --set-default-server, got %v", err)
+	}
+
+	config.DefaultServer = "gone"
+	if _, err := withDefaultServer(config, []string{"execute_sql", "{}"}); err == nil || !strings.Contains(err.Error(), "'gone' is not configured") {
+		t.Errorf("Expected an error naming the missing default server, got %v", err)
+	}
+}
+
+func TestDefaultServer_FollowsRenameAndRemove(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	SaveConfig(&Config{Servers: map[string]ServerConfig{"database": {URL: "https://db.example.com/mcp"}}})
+	if _, err := SetDefaultServer("database"); err != nil {
+		t.Fatalf("SetDefaultServer failed: %v", err)
+	}
+	if _, err := SetDefaultServer("missing"); errorCodeOf(err, "") != ErrNotFound {
+		t.Errorf("Expected NOT_FOUND for an unknown server, got %v", err)
+	}
+
+	if _, err := RenameServer("database", "postgres"); err != nil {
+		t.Fatalf("RenameServer failed: %v", err)
+	}
+	config, _ := LoadConfig()
+	if config.DefaultServer != "postgres" {
+		t.Errorf("Expected the default to follow the rename, got %q", config.DefaultServer)
+	}
+
+	if _, err := SetDefaultServer(""); err != nil {
+		t.Fatalf("Clearing the default failed: %v", err)
+	}
+	config, _ = LoadConfig()
+	if config.DefaultServer != "" {
+		t.Errorf("Expected the default to be cleared, got %q", config.DefaultServer)
+	}
+}
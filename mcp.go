@@ -2,21 +2,136 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
 
+// ProtocolVersion is the latest MCP protocol version mcpx requests during initialize
+const ProtocolVersion = "2025-06-18"
+
+// supportedProtocolVersions lists every version mcpx can speak, newest first
+var supportedProtocolVersions = []string{
+	ProtocolVersion,
+	"2025-03-26",
+	"2024-11-05",
+}
+
+// errSessionExpired is returned when the server no longer recognizes our session ID
+var errSessionExpired = errors.New("session expired")
+
+// rpcCodeSessionNotFound is the JSON-RPC error code servers use for unknown sessions
+const rpcCodeSessionNotFound = -32001
+
+// maxErrorBodySnippet caps how much of a non-2xx response body is shown in errors
+const maxErrorBodySnippet = 200
+
+// Rate limit handling: a 429 whose Retry-After is at most rateLimitMaxWait
+// is retried after sleeping, up to rateLimitRetries times; longer waits are
+// returned to the caller as RATE_LIMITED with the retry-after duration.
+var (
+	rateLimitRetries      = 2
+	rateLimitMaxWait      = 5 * time.Second
+	rateLimitDefaultDelay = 1 * time.Second // When 429 has no Retry-After
+)
+
+// cancelNotifyTimeout bounds sending notifications/cancelled for an abandoned request
+const cancelNotifyTimeout = 5 * time.Second
+
+// terminateTimeout bounds the session DELETE sent when a client is closed
+const terminateTimeout = 5 * time.Second
+
+// HTTPError is returned when the server responds with a non-2xx status
+type HTTPError struct {
+	StatusCode int
+	Body       string        // Truncated response body
+	RetryAfter time.Duration // From Retry-After, if the server sent one
+}
+
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("server returned HTTP %d", e.StatusCode)
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf(" (retry after %s)", e.RetryAfter)
+	}
+	return msg
+}
+
+// ErrorCode maps the HTTP status to a structured error code
+func (e *HTTPError) ErrorCode() string {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrAuthExpired
+	default:
+		return ErrMCPError
+	}
+}
+
+// newHTTPError builds an HTTPError with a whitespace-collapsed, truncated body snippet
+func newHTTPError(statusCode int, body []byte) *HTTPError {
+	snippet := strings.Join(strings.Fields(string(body)), " ")
+	if len(snippet) > maxErrorBodySnippet {
+		snippet = cutUTF8(snippet, maxErrorBodySnippet) + "..."
+	}
+	return &HTTPError{StatusCode: statusCode, Body: snippet}
+}
+
+// cutUTF8 returns the longest prefix of s that is at most max bytes and ends
+// on a rune boundary, so quoting it keeps the text valid UTF-8
+func cutUTF8(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+// parseRetryAfter parses a Retry-After value in delay-seconds or HTTP-date
+// form. Returns 0 when absent or unparseable.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait.Round(time.Second)
+		}
+	}
+	return 0
+}
+
 var defaultHeaders = map[string]string{
-	"Content-Type": "application/json",
-	"Accept":       "application/json, text/event-stream",
+	"Content-Type":    "application/json",
+	"Accept":          "application/json, text/event-stream",
+	"Accept-Encoding": acceptEncoding,
 }
 
 // HTTPClient wraps http.Client with MCP-specific functionality
@@ -29,30 +144,42 @@ type HTTPClient struct {
 }
 
 // NewHTTPClient creates a new HTTP client
-func NewHTTPClient(timeout time.Duration) *HTTPClient {
-	return &HTTPClient{
-		client:  &http.Client{Timeout: timeout},
-		timeout: timeout,
+func NewHTTPClient(timeout time.Duration, config ServerConfig) (*HTTPClient, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if err := configureTransport(transport, config); err != nil {
+		return nil, err
 	}
+
+	// Negotiate HTTP/2 via ALPN even with a custom TLS config, unless disabled
+	transport.ForceAttemptHTTP2 = !config.DisableHTTP2
+	if config.DisableHTTP2 {
+		transport.TLSNextProto = disabledHTTP2()
+	}
+
+	return &HTTPClient{
+		client:    &http.Client{Transport: transport, Timeout: timeout},
+		transport: transport,
+		timeout:   timeout,
+	}, nil
 }
 
 // NewPersistentHTTPClient creates an HTTP client that maintains persistent connections
 // for session-based MCP servers (like Playwright MCP using Streamable HTTP).
-func NewPersistentHTTPClient(timeout time.Duration) *HTTPClient {
+func NewPersistentHTTPClient(timeout time.Duration, config ServerConfig) (*HTTPClient, error) {
 	// Create a transport that keeps connections alive
 	transport := &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
 		MaxIdleConns:          1,
 		MaxIdleConnsPerHost:   1,
 		MaxConnsPerHost:       1, // Force single connection for session affinity
 		IdleConnTimeout:       0, // Never timeout idle connections
 		DisableKeepAlives:     false,
 		ForceAttemptHTTP2:     false, // Use HTTP/1.1 for simpler connection management
+		TLSNextProto:          disabledHTTP2(),
 		ResponseHeaderTimeout: timeout,
 	}
+	if err := configureTransport(transport, config); err != nil {
+		return nil, err
+	}
 
 	return &HTTPClient{
 		client: &http.Client{
@@ -62,7 +189,140 @@ func NewPersistentHTTPClient(timeout time.Duration) *HTTPClient {
 		transport:  transport,
 		timeout:    timeout,
 		persistent: true,
+	}, nil
+}
+
+// ConnectTimeoutError is returned when a server can't be reached within its
+// connect timeout, as opposed to accepting the connection and then not
+// responding
+type ConnectTimeoutError struct {
+	Addr    string
+	Timeout time.Duration
+}
+
+func (e *ConnectTimeoutError) Error() string {
+	return fmt.Sprintf("connecting to %s timed out after %s (connect_timeout)", e.Addr, e.Timeout)
+}
+
+// ErrorCode reports connect timeouts as TIMEOUT
+func (e *ConnectTimeoutError) ErrorCode() string {
+	return ErrTimeout
+}
+
+// connectDialer returns a DialContext that gives up after timeout and
+// reports that as a ConnectTimeoutError
+func connectDialer(timeout time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		var netErr net.Error
+		if err != nil && ctx.Err() == nil && errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, &ConnectTimeoutError{Addr: addr, Timeout: timeout}
+		}
+		return conn, err
+	}
+}
+
+// disabledHTTP2 returns a non-nil, empty TLSNextProto map, which keeps a
+// transport on HTTP/1.1. Session-based servers need this: the session is tied
+// to one TCP connection and HTTP/2 multiplexing would hide that.
+func disabledHTTP2() map[string]func(string, *tls.Conn) http.RoundTripper {
+	return map[string]func(string, *tls.Conn) http.RoundTripper{}
+}
+
+// configureTransport applies per-server network settings to a transport
+func configureTransport(transport *http.Transport, config ServerConfig) error {
+	transport.DialContext = connectDialer(config.connectTimeout())
+	if config.ConnectTimeoutSeconds > 0 {
+		transport.TLSHandshakeTimeout = config.connectTimeout()
+	}
+
+	// Unix-socket servers: every request goes to the socket, never a proxy
+	socket, _, ok, err := unixSocketURL(config.URL)
+	if err != nil {
+		return err
+	}
+	if ok {
+		dial := transport.DialContext
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dial(ctx, "unix", socket)
+		}
+		return nil
+	}
+
+	proxy, err := proxyForServer(config)
+	if err != nil {
+		return err
+	}
+	transport.Proxy = proxy
+
+	tlsConfig, err := tlsConfigForServer(config)
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return nil
+}
+
+// tlsConfigForServer builds a TLS config from the server's CA, verification and
+// client certificate settings. Returns nil when the server uses the system defaults.
+func tlsConfigForServer(config ServerConfig) (*tls.Config, error) {
+	if config.CACertFile == "" && !config.InsecureSkipVerify &&
+		config.ClientCertFile == "" && config.ClientKeyFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+
+	if config.CACertFile != "" {
+		pem, err := os.ReadFile(config.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca_cert_file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificates in ca_cert_file: %s", config.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.ClientCertFile != "" || config.ClientKeyFile != "" {
+		if config.ClientCertFile == "" || config.ClientKeyFile == "" {
+			return nil, fmt.Errorf("client_cert_file and client_key_file must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if config.InsecureSkipVerify {
+		fmt.Fprintf(os.Stderr, "Warning: TLS certificate verification disabled for %s\n", config.URL)
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	return tlsConfig, nil
+}
+
+// proxyForServer returns the proxy selector for a server. An explicit
+// ProxyURL wins; otherwise HTTPS_PROXY/HTTP_PROXY from the environment apply.
+func proxyForServer(config ServerConfig) (func(*http.Request) (*url.URL, error), error) {
+	if config.ProxyURL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxyURL, err := url.Parse(config.ProxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy_url: %w", err)
 	}
+	return http.ProxyURL(proxyURL), nil
 }
 
 // Close closes idle connections (for persistent clients)
@@ -76,11 +336,23 @@ func (h *HTTPClient) Close() {
 
 // parseSSEResponse extracts JSON data from an SSE response
 func parseSSEResponse(text string) (*MCPResponse, error) {
+	return parseSSEResponseNotify(text, nil)
+}
+
+// parseSSEResponseNotify extracts the JSON-RPC response from an SSE response,
+// passing any notifications sent ahead of it (e.g. log messages) to onNotify
+func parseSSEResponseNotify(text string, onNotify func(MCPNotification)) (*MCPResponse, error) {
 	lines := strings.Split(strings.TrimSpace(text), "\n")
 	for _, line := range lines {
 		if strings.HasPrefix(line, "data:") {
 			dataStr := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
 			if dataStr != "" {
+				if n, ok := parseNotification([]byte(dataStr)); ok {
+					if onNotify != nil {
+						onNotify(n)
+					}
+					continue
+				}
 				var resp MCPResponse
 				if err := json.Unmarshal([]byte(dataStr), &resp); err == nil {
 					return &resp, nil
@@ -100,50 +372,159 @@ func parseSSEResponse(text string) (*MCPResponse, error) {
 // MCPClient handles MCP protocol communication
 type MCPClient struct {
 	httpClient  *HTTPClient
+	ws          *WebSocketTransport // Set for websocket servers; replaces HTTP POSTs
 	config      ServerConfig
+	endpoint    string // URL requests go to; an http://localhost URL for Unix-socket servers
 	serverName  string
 	sessionID   string
 	oauthToken  string
 	persistent  bool
 	initialized bool
-	mu          sync.Mutex
+	protocol    string // Protocol version negotiated during initialize
+	logLevel    string // logging/setLevel level re-applied after each initialize
+	// Capabilities advertised by the server; nil when unknown (e.g. cached session)
+	capabilities map[string]any
+	mu           sync.Mutex
+
+	// Server notification listeners (see Events)
+	listenersMu sync.Mutex
+	listeners   map[chan MCPNotification]struct{}
+	streaming   bool               // Streamable HTTP GET stream is open
+	stopStream  context.CancelFunc // Closes the GET stream
+	serverLogs  logRing            // Recent notifications/message from the server
+	cmdToken    commandToken       // Cached token_command output
 }
 
 // NewMCPClient creates a new MCP client for a server
-func NewMCPClient(serverName string, config ServerConfig) *MCPClient {
+func NewMCPClient(serverName string, config ServerConfig) (*MCPClient, error) {
+	config.Headers = mergeHeaders(config.defaultHeaders, config.Headers)
+
 	var httpClient *HTTPClient
+	var err error
 	if config.SessionBased {
-		httpClient = NewPersistentHTTPClient(30 * time.Second)
+		httpClient, err = NewPersistentHTTPClient(30*time.Second, config)
 	} else {
-		httpClient = NewHTTPClient(30 * time.Second)
+		httpClient, err = NewHTTPClient(30*time.Second, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := config.URL
+	if _, httpURL, ok, _ := unixSocketURL(config.URL); ok {
+		endpoint = httpURL
 	}
 
-	return &MCPClient{
+	client := &MCPClient{
 		httpClient: httpClient,
 		config:     config,
+		endpoint:   endpoint,
 		serverName: serverName,
 		persistent: config.SessionBased,
+		logLevel:   config.LogLevel,
+	}
+
+	if config.Transport == TransportWebSocket {
+		client.ws, err = NewWebSocketTransport(30*time.Second, config)
+		if err != nil {
+			return nil, err
+		}
+		client.ws.onNotify = client.dispatch
+		// The session lives and dies with the connection
+		client.persistent = true
 	}
+
+	return client, nil
 }
 
-// Close closes the underlying HTTP client connections
+// Close ends a session-based client's session on the server, then closes
+// the underlying HTTP client connections
 func (c *MCPClient) Close() {
+	if c.persistent {
+		// The notification stream holds the only connection; free it first
+		c.stopNotificationStream()
+		ctx, cancel := context.WithTimeout(context.Background(), terminateTimeout)
+		c.TerminateContext(ctx) // Best effort: servers also expire idle sessions
+		cancel()
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.httpClient != nil {
 		c.httpClient.Close()
 	}
+	if c.ws != nil {
+		c.ws.Close()
+	}
+	c.stopNotificationStream()
 	c.initialized = false
 	c.sessionID = ""
 }
 
+// Terminate ends the server-side session with an HTTP DELETE carrying the
+// session ID, as the Streamable HTTP spec asks of clients that are done with
+// a session. Servers that don't let clients end sessions answer 405, which
+// is not an error. WebSocket sessions end when the connection closes.
+func (c *MCPClient) Terminate() error {
+	return c.TerminateContext(context.Background())
+}
+
+// TerminateContext is Terminate, giving up when ctx is done
+func (c *MCPClient) TerminateContext(ctx context.Context) error {
+	if c.ws != nil || c.httpClient == nil || c.sessionID == "" {
+		return nil
+	}
+
+	req, err := c.newRequest(ctx, "DELETE", nil)
+	if err != nil {
+		return err
+	}
+	debugTrace(c.serverName, "-> DELETE "+redactURL(c.config.URL), req.Header, nil)
+	resp, err := c.httpClient.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("session termination failed: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	debugTrace(c.serverName, fmt.Sprintf("<- HTTP %d", resp.StatusCode), resp.Header, nil)
+
+	// The session is gone either way; 404 means the server already dropped it
+	c.resetSession()
+	switch {
+	case resp.StatusCode == http.StatusMethodNotAllowed, resp.StatusCode == http.StatusNotFound:
+		return nil
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("session termination failed: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
 // IsPersistent returns whether this client uses persistent connections
 func (c *MCPClient) IsPersistent() bool {
 	return c.persistent
 }
 
-// SetOAuthToken sets the OAuth token for requests
+// SetTimeout changes the per-request HTTP timeout
+func (c *MCPClient) SetTimeout(timeout time.Duration) {
+	if c.ws != nil {
+		c.ws.SetTimeout(timeout)
+	}
+	c.httpClient.mu.Lock()
+	defer c.httpClient.mu.Unlock()
+	c.httpClient.timeout = timeout
+	if c.httpClient.persistent {
+		// Persistent clients bound the wait for headers, not the whole stream
+		c.httpClient.transport.ResponseHeaderTimeout = timeout
+		return
+	}
+	c.httpClient.client.Timeout = timeout
+}
+
+// SetOAuthToken sets the OAuth token for requests. Safe to call while
+// requests are in flight (the daemon swaps tokens on refresh).
 func (c *MCPClient) SetOAuthToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.oauthToken = token
 }
 
@@ -152,8 +533,31 @@ func (c *MCPClient) SetSessionID(id string) {
 	c.sessionID = id
 }
 
+// ProtocolVersion returns the protocol version negotiated with the server
+func (c *MCPClient) ProtocolVersion() string {
+	return c.protocol
+}
+
+// requireCapability returns an error if the server's initialize result did not
+// advertise the named capability. Unknown capabilities are not enforced.
+func (c *MCPClient) requireCapability(name string) error {
+	if c.capabilities == nil {
+		return nil
+	}
+	if _, ok := c.capabilities[name]; !ok {
+		return fmt.Errorf("server does not advertise %s capability", name)
+	}
+	return nil
+}
+
 // Request makes an MCP JSON-RPC request
 func (c *MCPClient) Request(method string, params any) (*MCPResponse, string, error) {
+	return c.RequestContext(context.Background(), method, params)
+}
+
+// RequestContext makes an MCP JSON-RPC request that is abandoned when ctx is
+// done. The server is sent notifications/cancelled so it can stop the work.
+func (c *MCPClient) RequestContext(ctx context.Context, method string, params any) (*MCPResponse, string, error) {
 	payload := MCPRequest{
 		JSONRPC: "2.0",
 		Method:  method,
@@ -166,52 +570,147 @@ func (c *MCPClient) Request(method string, params any) (*MCPResponse, string, er
 		return nil, "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", c.config.URL, bytes.NewReader(body))
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	mcpResp, sessionID, err := c.send(ctx, payload.ID, body)
+	// A token can be revoked or expire mid-session: renew it and retry once
+	if isAuthRejected(err) && c.renewBearerToken(ctx) {
+		mcpResp, sessionID, err = c.send(ctx, payload.ID, body)
 	}
+	if mcpResp != nil {
+		mcpResp.requestBytes = len(body)
+	}
+	if err != nil && ctx.Err() != nil {
+		// Initialize must not be cancelled per the spec; the rest can be
+		if method != "initialize" {
+			c.notifyCancelled(payload.ID, ctx.Err())
+		}
+		return nil, sessionID, fmt.Errorf("%s cancelled: %w", method, ctx.Err())
+	}
+	return mcpResp, sessionID, err
+}
 
-	// Set default headers
-	for k, v := range defaultHeaders {
-		req.Header.Set(k, v)
+// isAuthRejected reports whether err is an HTTP 401 or 403 from the server
+func isAuthRejected(err error) bool {
+	var httpErr *HTTPError
+	return errors.As(err, &httpErr) &&
+		(httpErr.StatusCode == http.StatusUnauthorized || httpErr.StatusCode == http.StatusForbidden)
+}
+
+// renewBearerToken replaces a bearer token the server rejected, reporting
+// whether there is a new one worth retrying with. An OAuth token is replaced
+// by the stored one if another process already refreshed it, else refreshed
+// with the stored refresh token; a token_command token is minted again.
+func (c *MCPClient) renewBearerToken(ctx context.Context) bool {
+	c.mu.Lock()
+	oauthToken := c.oauthToken
+	c.mu.Unlock()
+
+	if oauthToken == "" {
+		if c.config.TokenCommand == "" {
+			return false
+		}
+		c.cmdToken.mu.Lock()
+		c.cmdToken.token = ""
+		c.cmdToken.mu.Unlock()
+		return true
 	}
 
-	// Set server-specific headers
-	for k, v := range c.config.Headers {
-		req.Header.Set(k, v)
+	tokens, err := LoadTokens()
+	if err != nil {
+		return false
+	}
+	tokenData, ok := tokens[c.serverName]
+	if !ok {
+		return false
+	}
+	if tokenData.AccessToken != oauthToken && !tokenExpiring(tokenData.ExpiresAt) {
+		c.SetOAuthToken(tokenData.AccessToken)
+		return true
+	}
+	if tokenData.RefreshToken == "" {
+		return false
 	}
 
-	// Set OAuth token if available (overrides static headers)
-	if c.oauthToken != "" {
-		req.Header.Set("Authorization", "Bearer "+c.oauthToken)
+	newToken, err := RefreshOAuthToken(c.serverName, c.config, tokenData)
+	if err != nil || newToken == "" {
+		logger.Warn("token refresh after auth failure failed", requestFields(ctx, "server", c.serverName, "error", err)...)
+		return false
 	}
+	c.SetOAuthToken(newToken)
+	return true
+}
 
-	// Set session ID if available
-	if c.sessionID != "" {
-		req.Header.Set("Mcp-Session-Id", c.sessionID)
+// notifyCancelled tells the server to stop working on a request we abandoned
+func (c *MCPClient) notifyCancelled(requestID string, reason error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelNotifyTimeout)
+	defer cancel()
+	c.notify(ctx, "notifications/cancelled", map[string]any{
+		"requestId": requestID,
+		"reason":    reason.Error(),
+	})
+}
+
+// send delivers an encoded request over the client's transport and parses the reply
+func (c *MCPClient) send(ctx context.Context, id string, body []byte) (*MCPResponse, string, error) {
+	if c.ws != nil {
+		header, err := c.wsHeaders(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		debugTrace(c.serverName, "-> WS "+redactURL(c.config.URL), header, body)
+		resp, err := c.ws.Request(ctx, id, body, header)
+		if resp != nil && debugEnabled {
+			raw, _ := json.Marshal(resp)
+			debugTrace(c.serverName, "<- WS", nil, raw)
+		}
+		return resp, "", err
 	}
 
-	resp, err := c.httpClient.client.Do(req)
+	resp, err := c.postRetryingRateLimits(ctx, body)
 	if err != nil {
-		return nil, "", fmt.Errorf("request failed: %w", err)
+		return nil, "", err
 	}
 	defer resp.Body.Close()
 
 	// Extract session ID from response headers
-	newSessionID := resp.Header.Get("Mcp-Session-Id")
+	newSessionID := resp.Header.Get(c.config.sessionHeader())
 
-	// Read response body
-	respBody, err := io.ReadAll(resp.Body)
+	// Per the Streamable HTTP spec, 404 for a request carrying a session ID
+	// means the session was terminated and the client must re-initialize
+	if resp.StatusCode == http.StatusNotFound && c.sessionID != "" {
+		return nil, newSessionID, errSessionExpired
+	}
+
+	// Read response body, decompressing if the server encoded it
+	reader, err := decodeBody(resp)
+	if err != nil {
+		return nil, newSessionID, err
+	}
+	respBody, err := io.ReadAll(reader)
 	if err != nil {
 		return nil, newSessionID, fmt.Errorf("failed to read response: %w", err)
 	}
+	debugTrace(c.serverName, fmt.Sprintf("<- HTTP %d", resp.StatusCode), resp.Header, respBody)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		httpErr := newHTTPError(resp.StatusCode, respBody)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			httpErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+		return nil, newSessionID, httpErr
+	}
+
+	// 202 Accepted (or any empty body) carries no JSON-RPC message; return a
+	// response with a nil result rather than failing to parse nothing
+	if resp.StatusCode == http.StatusAccepted || len(bytes.TrimSpace(respBody)) == 0 {
+		return &MCPResponse{JSONRPC: "2.0", ID: id}, newSessionID, nil
+	}
 
 	// Parse response (might be SSE or JSON)
 	contentType := resp.Header.Get("Content-Type")
 	var mcpResp *MCPResponse
 
 	if strings.Contains(contentType, "text/event-stream") {
-		mcpResp, err = parseSSEResponse(string(respBody))
+		mcpResp, err = parseSSEResponseNotify(string(respBody), c.dispatch)
 	} else {
 		err = json.Unmarshal(respBody, &mcpResp)
 	}
@@ -219,15 +718,216 @@ func (c *MCPClient) Request(method string, params any) (*MCPResponse, string, er
 	if err != nil {
 		return nil, newSessionID, fmt.Errorf("failed to parse response: %w", err)
 	}
+	mcpResp.responseBytes = len(respBody)
 
 	return mcpResp, newSessionID, nil
 }
 
+// requestWithReinit sends a request and, if the server reports our session as
+// expired, clears the stored session, re-initializes once and retries.
+func (c *MCPClient) requestWithReinit(ctx context.Context, method string, params any) (*MCPResponse, error) {
+	resp, _, err := c.RequestContext(ctx, method, params)
+	if !isSessionExpired(resp, err) {
+		return resp, err
+	}
+
+	c.resetSession()
+	if err := c.InitializeContext(ctx); err != nil {
+		return nil, fmt.Errorf("re-initialize after expired session failed: %w", err)
+	}
+
+	resp, _, err = c.RequestContext(ctx, method, params)
+	return resp, err
+}
+
+// isSessionExpired reports whether a response indicates an invalid session
+func isSessionExpired(resp *MCPResponse, err error) bool {
+	if errors.Is(err, errSessionExpired) {
+		return true
+	}
+	return err == nil && resp != nil && resp.Error != nil && resp.Error.Code == rpcCodeSessionNotFound
+}
+
+// resetSession forgets the current session, including the cached copy on disk
+func (c *MCPClient) resetSession() {
+	c.sessionID = ""
+	c.initialized = false
+	if c.persistent {
+		return
+	}
+
+	sessions, err := LoadSessions()
+	if err != nil {
+		return
+	}
+	if _, ok := sessions[c.serverName]; ok {
+		delete(sessions, c.serverName)
+		SaveSessions(sessions)
+	}
+}
+
+// postRetryingRateLimits posts body, sleeping and retrying on 429 when the
+// server asks for a short enough wait. The final response is returned as-is.
+func (c *MCPClient) postRetryingRateLimits(ctx context.Context, body []byte) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.post(ctx, body)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt >= rateLimitRetries {
+			return resp, err
+		}
+
+		wait := rateLimitDefaultDelay
+		if header := resp.Header.Get("Retry-After"); header != "" {
+			wait = parseRetryAfter(header, time.Now())
+		}
+		if wait > rateLimitMaxWait {
+			return resp, nil
+		}
+
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Notify sends a JSON-RPC notification. Notifications have no reply, so the
+// response body is discarded.
+func (c *MCPClient) Notify(method string, params any) error {
+	return c.notify(context.Background(), method, params)
+}
+
+// notify sends a notification, giving up when ctx is done
+func (c *MCPClient) notify(ctx context.Context, method string, params any) error {
+	body, err := json.Marshal(MCPNotification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	if c.ws != nil {
+		header, err := c.wsHeaders(ctx)
+		if err != nil {
+			return err
+		}
+		debugTrace(c.serverName, "-> WS "+redactURL(c.config.URL), header, body)
+		return c.ws.Notify(body, header)
+	}
+
+	resp, err := c.post(ctx, body)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	return nil
+}
+
+// setSession attaches the session ID to req: in the session_param query
+// parameter if one is configured, otherwise in the session header
+func (c *MCPClient) setSession(req *http.Request) {
+	if c.sessionID == "" {
+		return
+	}
+	if c.config.SessionParam != "" {
+		query := req.URL.Query()
+		query.Set(c.config.SessionParam, c.sessionID)
+		req.URL.RawQuery = query.Encode()
+		return
+	}
+	req.Header.Set(c.config.sessionHeader(), c.sessionID)
+}
+
+// post sends a JSON-RPC message body with the default, server, auth and session headers
+func (c *MCPClient) post(ctx context.Context, body []byte) (*http.Response, error) {
+	req, err := c.newRequest(ctx, "POST", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	debugTrace(c.serverName, "-> POST "+redactURL(c.config.URL), req.Header, body)
+
+	resp, err := c.httpClient.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// newRequest builds a request to the server URL with the default, server,
+// auth and session headers
+func (c *MCPClient) newRequest(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set default headers
+	for k, v := range defaultHeaders {
+		req.Header.Set(k, v)
+	}
+
+	// Set server-specific headers
+	for k, v := range c.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	// Set OAuth or token_command token if available (overrides static headers)
+	oauthToken, err := c.bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if oauthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+oauthToken)
+	}
+
+	// Set session ID if available
+	c.setSession(req)
+
+	// Announce the negotiated protocol version on subsequent requests
+	if c.protocol != "" {
+		req.Header.Set("Mcp-Protocol-Version", c.protocol)
+	}
+	return req, nil
+}
+
+// wsHeaders returns the server and auth headers sent with the WebSocket upgrade
+func (c *MCPClient) wsHeaders(ctx context.Context) (http.Header, error) {
+	header := http.Header{}
+	for k, v := range c.config.Headers {
+		header.Set(k, v)
+	}
+
+	oauthToken, err := c.bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if oauthToken != "" {
+		header.Set("Authorization", "Bearer "+oauthToken)
+	}
+	return header, nil
+}
+
 // Initialize establishes an MCP session
 func (c *MCPClient) Initialize() error {
-	// For session-based servers (Streamable HTTP), skip session cache lookup.
-	// The session is tied to the TCP connection, so cached session IDs are invalid.
-	if !c.config.SessionBased {
+	return c.InitializeContext(context.Background())
+}
+
+// InitializeContext establishes an MCP session, giving up when ctx is done
+func (c *MCPClient) InitializeContext(ctx context.Context) error {
+	// For session-based servers (Streamable HTTP, WebSocket), skip session cache lookup.
+	// The session is tied to the connection, so cached session IDs are invalid.
+	if c.persistent && c.initialized {
+		// Keep the live session; a new one would drop its subscriptions
+		return nil
+	}
+	if !c.persistent {
 		// Check if we have a cached session
 		sessions, err := LoadSessions()
 		if err == nil {
@@ -238,13 +938,37 @@ func (c *MCPClient) Initialize() error {
 		}
 	}
 
-	// Initialize new session
-	resp, sessionID, err := c.Request("initialize", map[string]any{
-		"protocolVersion": "2024-11-05",
+	return c.handshake(ctx)
+}
+
+// Ping performs a fresh initialize handshake, bypassing any cached session,
+// and returns the round-trip time. It checks reachability and auth without
+// listing tools.
+func (c *MCPClient) Ping() (time.Duration, error) {
+	return c.PingContext(context.Background())
+}
+
+// PingContext is Ping, giving up when ctx is done
+func (c *MCPClient) PingContext(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	if err := c.handshake(ctx); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// handshake runs initialize + notifications/initialized and caches the session
+func (c *MCPClient) handshake(ctx context.Context) error {
+	if c.ws != nil {
+		c.ws.resetDropped()
+	}
+
+	resp, sessionID, err := c.RequestContext(ctx, "initialize", map[string]any{
+		"protocolVersion": ProtocolVersion,
 		"capabilities":    map[string]any{},
 		"clientInfo": map[string]any{
 			"name":    "mcpx",
-			"version": "0.1.0",
+			"version": Version,
 		},
 	})
 
@@ -256,29 +980,81 @@ func (c *MCPClient) Initialize() error {
 		return fmt.Errorf("initialize failed: %s", resp.Error.Message)
 	}
 
-	// Save session ID if we got one (skip for session-based servers)
+	protocol, err := negotiateProtocolVersion(resp.Result)
+	if err != nil {
+		return err
+	}
+	c.protocol = protocol
+	c.capabilities, _ = resp.Result["capabilities"].(map[string]any)
+
 	if sessionID != "" {
 		c.sessionID = sessionID
-		if !c.config.SessionBased {
-			sessions, _ := LoadSessions()
-			if sessions == nil {
-				sessions = make(map[string]string)
-			}
-			sessions[c.serverName] = sessionID
-			SaveSessions(sessions)
+	}
+
+	// Complete the handshake; the server may reject requests until it sees this
+	if err := c.notify(ctx, "notifications/initialized", nil); err != nil {
+		return fmt.Errorf("initialized notification failed: %w", err)
+	}
+	c.initialized = c.persistent
+	c.applyLogLevel(ctx)
+
+	// Save session ID if we got one (skip for session-based servers)
+	if sessionID != "" && !c.persistent {
+		sessions, _ := LoadSessions()
+		if sessions == nil {
+			sessions = make(map[string]string)
 		}
+		sessions[c.serverName] = sessionID
+		SaveSessions(sessions)
 	}
 
 	return nil
 }
 
+// negotiateProtocolVersion checks the version the server chose in its initialize
+// result. Servers that omit it are assumed to accept the requested version.
+func negotiateProtocolVersion(result map[string]any) (string, error) {
+	version, _ := result["protocolVersion"].(string)
+	if version == "" {
+		return ProtocolVersion, nil
+	}
+
+	for _, supported := range supportedProtocolVersions {
+		if version == supported {
+			return version, nil
+		}
+	}
+	return "", fmt.Errorf("server requires unsupported MCP protocol version %s (mcpx supports %s)",
+		version, strings.Join(supportedProtocolVersions, ", "))
+}
+
 // ListTools retrieves available tools from the server
 func (c *MCPClient) ListTools() ([]Tool, error) {
-	if err := c.Initialize(); err != nil {
+	return c.ListToolsContext(context.Background())
+}
+
+// ListToolsOnce lists tools for a caller that won't reuse the client. A
+// session-based client is closed afterwards, since nothing could resume its
+// session and each invocation would otherwise leave one behind.
+func (c *MCPClient) ListToolsOnce(ctx context.Context) ([]Tool, error) {
+	tools, err := c.ListToolsContext(ctx)
+	if c.persistent {
+		c.Close()
+	}
+	return tools, err
+}
+
+// ListToolsContext retrieves available tools, giving up when ctx is done
+func (c *MCPClient) ListToolsContext(ctx context.Context) ([]Tool, error) {
+	if err := c.InitializeContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := c.requireCapability("tools"); err != nil {
 		return nil, err
 	}
 
-	resp, _, err := c.Request("tools/list", nil)
+	resp, err := c.requestWithReinit(ctx, "tools/list", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -297,116 +1073,274 @@ func (c *MCPClient) ListTools() ([]Tool, error) {
 		return nil, fmt.Errorf("no tools in response")
 	}
 
-	// Convert to []Tool
-	toolsJSON, err := json.Marshal(toolsRaw)
-	if err != nil {
-		return nil, err
-	}
-
-	var rawTools []struct {
-		Name        string         `json:"name"`
-		Description string         `json:"description"`
-		InputSchema map[string]any `json:"inputSchema"`
-	}
-	if err := json.Unmarshal(toolsJSON, &rawTools); err != nil {
-		return nil, err
+	items, ok := toolsRaw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("unexpected tools format in response")
 	}
 
-	tools := make([]Tool, len(rawTools))
-	for i, t := range rawTools {
-		tools[i] = Tool{
-			Name:        t.Name,
-			Description: t.Description,
-			Parameters:  t.InputSchema,
+	// Decode entries one at a time so a malformed tool doesn't hide the rest
+	tools := make([]Tool, 0, len(items))
+	skipped := 0
+	for _, item := range items {
+		t, ok := decodeTool(item)
+		if !ok {
+			skipped++
+			continue
 		}
+		if !c.config.ToolAllowed(t.Name) {
+			continue
+		}
+		tools = append(tools, t)
+	}
+	if skipped > 0 {
+		logger.Warn("skipped malformed tools in tools/list", requestFields(ctx, "server", c.serverName, "skipped", skipped, "returned", len(tools))...)
 	}
 
 	return tools, nil
 }
 
+// decodeTool converts one tools/list entry, reporting false if it isn't a
+// tool object with a name
+func decodeTool(item any) (Tool, bool) {
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return Tool{}, false
+	}
+	var t struct {
+		Name        string           `json:"name"`
+		Description string           `json:"description"`
+		InputSchema map[string]any   `json:"inputSchema"`
+		SnakeSchema map[string]any   `json:"input_schema"` // Older SDKs
+		Annotations *ToolAnnotations `json:"annotations"`
+	}
+	if err := json.Unmarshal(raw, &t); err != nil || t.Name == "" {
+		return Tool{}, false
+	}
+	if t.InputSchema == nil {
+		t.InputSchema = t.SnakeSchema
+	}
+	return Tool{
+		Name:        t.Name,
+		Description: t.Description,
+		Parameters:  t.InputSchema,
+		Annotations: t.Annotations,
+	}, true
+}
+
 // CallTool invokes a tool on the server
 func (c *MCPClient) CallTool(toolName string, arguments map[string]any) (map[string]any, error) {
-	if err := c.Initialize(); err != nil {
-		return nil, err
+	return c.CallToolContext(context.Background(), toolName, arguments)
+}
+
+// CallToolContext invokes a tool, cancelling it on the server if ctx is done
+// before the result arrives
+func (c *MCPClient) CallToolContext(ctx context.Context, toolName string, arguments map[string]any) (map[string]any, error) {
+	result, _, err := c.CallToolWithMetadata(ctx, toolName, arguments, nil)
+	return result, err
+}
+
+// CallMetadata describes a tool call: how long it took, how much was sent
+// and received, and any _meta the server attached to the result
+type CallMetadata struct {
+	ElapsedMs     float64        `json:"elapsed_ms"`
+	RequestBytes  int            `json:"request_bytes"`
+	ResponseBytes int            `json:"response_bytes"`
+	Meta          map[string]any `json:"_meta,omitempty"` // e.g. token usage reported by the server
+}
+
+// CallToolWithMetadata invokes a tool like CallToolContext and also reports
+// CallMetadata. requestMeta, if any, is sent as the request's _meta (e.g. a
+// progressToken or trace ID). The result is returned unchanged, _meta included.
+func (c *MCPClient) CallToolWithMetadata(ctx context.Context, toolName string, arguments, requestMeta map[string]any) (map[string]any, CallMetadata, error) {
+	arguments, err := c.PrepareArguments(ctx, toolName, arguments)
+	if err != nil {
+		return nil, CallMetadata{}, err
 	}
+	return c.CallPreparedTool(ctx, toolName, arguments, requestMeta)
+}
+
+// PrepareArguments returns the arguments a call to toolName sends: the
+// server's default_args merged under arguments, then rewritten by its
+// pre_call_command, if any. Arguments are validated in this final form.
+func (c *MCPClient) PrepareArguments(ctx context.Context, toolName string, arguments map[string]any) (map[string]any, error) {
+	if !c.config.ToolAllowed(toolName) {
+		return nil, codedErrorf(ErrUnknownTool, "tool '%s' is not available on server '%s'", toolName, c.serverName)
+	}
+	arguments = mergeDefaultArgs(c.config.DefaultArgs, arguments)
+	if c.config.PreCallCommand == "" {
+		return arguments, nil
+	}
+	return runPreCallHook(ctx, c.config.PreCallCommand, c.serverName, toolName, arguments)
+}
 
-	resp, _, err := c.Request("tools/call", map[string]any{
+// CallPreparedTool is CallToolWithMetadata for arguments that have already
+// been through PrepareArguments; they are sent as they are
+func (c *MCPClient) CallPreparedTool(ctx context.Context, toolName string, arguments, requestMeta map[string]any) (map[string]any, CallMetadata, error) {
+	var meta CallMetadata
+	if !c.config.ToolAllowed(toolName) {
+		return nil, meta, codedErrorf(ErrUnknownTool, "tool '%s' is not available on server '%s'", toolName, c.serverName)
+	}
+
+	if err := c.InitializeContext(ctx); err != nil {
+		return nil, meta, err
+	}
+
+	if err := c.requireCapability("tools"); err != nil {
+		return nil, meta, err
+	}
+
+	params := map[string]any{
 		"name":      toolName,
 		"arguments": arguments,
-	})
+	}
+	if len(requestMeta) > 0 {
+		params["_meta"] = requestMeta
+	}
+
+	start := time.Now()
+	resp, err := c.requestWithReinit(ctx, "tools/call", params)
+	meta.ElapsedMs = float64(time.Since(start).Microseconds()) / 1000
 
 	if err != nil {
-		return nil, err
+		return nil, meta, err
 	}
+	meta.RequestBytes = resp.requestBytes
+	meta.ResponseBytes = resp.responseBytes
 
 	if resp.Error != nil {
-		return nil, fmt.Errorf("tool call failed: %s", resp.Error.Message)
+		return nil, meta, fmt.Errorf("tool call failed: %s", resp.Error.Message)
+	}
+
+	meta.Meta, _ = resp.Result["_meta"].(map[string]any)
+	return resp.Result, meta, nil
+}
+
+// mergeDefaultArgs shallow-merges per-server default arguments under the
+// caller-supplied arguments. Explicit arguments take precedence.
+func mergeDefaultArgs(defaults, arguments map[string]any) map[string]any {
+	if len(defaults) == 0 {
+		return arguments
+	}
+
+	merged := make(map[string]any, len(defaults)+len(arguments))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range arguments {
+		merged[k] = v
 	}
+	return merged
+}
+
+// tokenExpiryBuffer is how many seconds before expiry a token is treated as expired
+const tokenExpiryBuffer = 60
 
-	return resp.Result, nil
+// tokenExpiring reports whether a token expiring at expiresAt is within the expiry buffer
+func tokenExpiring(expiresAt float64) bool {
+	return expiresAt > 0 && float64(time.Now().Unix()) > expiresAt-tokenExpiryBuffer
 }
 
 // GetTokenForServer retrieves the OAuth token for a server, refreshing if needed
 func GetTokenForServer(serverName string, serverConfig ServerConfig) (string, error) {
+	tokenData, err := GetTokenDataForServer(serverName, serverConfig)
+	return tokenData.AccessToken, err
+}
+
+// GetTokenDataForServer retrieves the stored token data for a server, refreshing
+// it if it is about to expire. Returns empty TokenData when no usable token exists.
+func GetTokenDataForServer(serverName string, serverConfig ServerConfig) (TokenData, error) {
 	tokens, err := LoadTokens()
 	if err != nil {
-		return "", nil // No tokens, not an error
+		return TokenData{}, nil // No tokens, not an error
 	}
 
 	tokenData, ok := tokens[serverName]
 	if !ok {
-		return "", nil // No token for this server
+		return TokenData{}, nil // No token for this server
 	}
 
 	// Check if token is expired
-	if tokenData.ExpiresAt > 0 {
-		if float64(time.Now().Unix()) > tokenData.ExpiresAt-60 { // 60s buffer
-			// Try to refresh
-			if tokenData.RefreshToken != "" {
-				newToken, err := RefreshOAuthToken(serverName, serverConfig, tokenData)
-				if err != nil || newToken == "" {
-					return "", nil // Refresh failed, need re-auth
-				}
-				return newToken, nil
+	if tokenExpiring(tokenData.ExpiresAt) {
+		// Try to refresh
+		if tokenData.RefreshToken != "" {
+			newTokenData, err := refreshTokenData(serverName, serverConfig, tokenData)
+			if errorCodeOf(err, "") == ErrAuthExpired {
+				// Revoked refresh token: say why the request will need re-auth
+				logger.Warn(err.Error(), "server", serverName)
+			}
+			if err != nil || newTokenData.AccessToken == "" {
+				return TokenData{}, nil // Refresh failed, need re-auth
 			}
-			return "", nil // Token expired, no refresh token
+			return newTokenData, nil
 		}
+		return TokenData{}, nil // Token expired, no refresh token
 	}
 
-	return tokenData.AccessToken, nil
+	return tokenData, nil
 }
 
 // RefreshOAuthToken refreshes an expired OAuth token
 func RefreshOAuthToken(serverName string, serverConfig ServerConfig, tokenData TokenData) (string, error) {
+	newTokenData, err := refreshTokenData(serverName, serverConfig, tokenData)
+	return newTokenData.AccessToken, err
+}
+
+// refreshTokenData exchanges a refresh token for new token data and persists it
+func refreshTokenData(serverName string, serverConfig ServerConfig, tokenData TokenData) (TokenData, error) {
 	if serverConfig.OAuth == nil || serverConfig.OAuth.TokenURL == "" {
-		return "", fmt.Errorf("no token URL configured")
+		return TokenData{}, fmt.Errorf("no token URL configured")
 	}
 
 	client := &http.Client{Timeout: 30 * time.Second}
 
-	data := fmt.Sprintf("grant_type=refresh_token&refresh_token=%s&client_id=%s",
-		tokenData.RefreshToken,
-		getClientID(serverConfig))
+	data := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {tokenData.RefreshToken},
+		"client_id":     {getClientID(serverConfig)},
+	}
+	mergeParams(data, serverConfig.OAuth.ExtraTokenParams)
 
-	req, err := http.NewRequest("POST", serverConfig.OAuth.TokenURL, strings.NewReader(data))
+	req, err := http.NewRequest("POST", serverConfig.OAuth.TokenURL, strings.NewReader(data.Encode()))
 	if err != nil {
-		return "", err
+		return TokenData{}, err
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return TokenData{}, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("token refresh failed: %d", resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		var oauthErr struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Error == "invalid_grant" {
+			// The refresh token was revoked, expired or rotated away: drop it
+			// so nothing keeps retrying it, and ask for a fresh login. If
+			// another process (CLI or daemon) has meanwhile stored a newer
+			// token, this one lost a rotation race: keep theirs.
+			UpdateTokens(func(tokens map[string]TokenData) error {
+				if stored, ok := tokens[serverName]; ok && stored.RefreshToken == tokenData.RefreshToken {
+					delete(tokens, serverName)
+				}
+				return nil
+			})
+			detail := ""
+			if oauthErr.Description != "" {
+				detail = ": " + oauthErr.Description
+			}
+			return TokenData{}, codedErrorf(ErrAuthExpired, "refresh token for '%s' was rejected (invalid_grant%s); run: mcpx --auth %s", serverName, detail, serverName)
+		}
+		return TokenData{}, fmt.Errorf("token refresh failed: %d", resp.StatusCode)
 	}
 
 	var newTokenData TokenData
 	if err := json.NewDecoder(resp.Body).Decode(&newTokenData); err != nil {
-		return "", err
+		return TokenData{}, err
 	}
 
 	// Calculate expiry time
@@ -414,20 +1348,21 @@ func RefreshOAuthToken(serverName string, serverConfig ServerConfig, tokenData T
 		newTokenData.ExpiresAt = float64(time.Now().Unix()) + float64(newTokenData.ExpiresIn)
 	}
 
-	// Preserve refresh token if not returned
+	newTokenData.RevocationURL = tokenData.RevocationURL
+
+	// A returned refresh token replaces the old one (servers that rotate
+	// invalidate it); keep the old one only if none was returned
 	if newTokenData.RefreshToken == "" {
 		newTokenData.RefreshToken = tokenData.RefreshToken
 	}
 
 	// Save updated token
-	tokens, _ := LoadTokens()
-	if tokens == nil {
-		tokens = make(map[string]TokenData)
-	}
-	tokens[serverName] = newTokenData
-	SaveTokens(tokens)
+	UpdateTokens(func(tokens map[string]TokenData) error {
+		tokens[serverName] = newTokenData
+		return nil
+	})
 
-	return newTokenData.AccessToken, nil
+	return newTokenData, nil
 }
 
 func getClientID(config ServerConfig) string {
@@ -1,12 +1,30 @@
 package main
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"io"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
+	"unicode/utf8"
 )
 
 func TestParseSSEResponse_SingleData(t *testing.T) {
@@ -89,7 +107,10 @@ func TestParseSSEResponse_Invalid(t *testing.T) {
 }
 
 func TestNewHTTPClient(t *testing.T) {
-	client := NewHTTPClient(30 * time.Second)
+	client, err := NewHTTPClient(30*time.Second, ServerConfig{})
+	if err != nil {
+		t.Fatalf("NewHTTPClient failed: %v", err)
+	}
 
 	if client == nil {
 		t.Fatal("Expected client to be created")
@@ -105,7 +126,10 @@ func TestNewHTTPClient(t *testing.T) {
 }
 
 func TestNewPersistentHTTPClient(t *testing.T) {
-	client := NewPersistentHTTPClient(30 * time.Second)
+	client, err := NewPersistentHTTPClient(30*time.Second, ServerConfig{})
+	if err != nil {
+		t.Fatalf("NewPersistentHTTPClient failed: %v", err)
+	}
 
 	if client == nil {
 		t.Fatal("Expected client to be created")
@@ -124,13 +148,226 @@ func TestNewPersistentHTTPClient(t *testing.T) {
 }
 
 func TestHTTPClientClose(t *testing.T) {
-	client := NewPersistentHTTPClient(30 * time.Second)
+	client, err := NewPersistentHTTPClient(30*time.Second, ServerConfig{})
+	if err != nil {
+		t.Fatalf("NewPersistentHTTPClient failed: %v", err)
+	}
 
 	// Should not panic
 	client.Close()
 	client.Close() // Double close should be safe
 }
 
+func TestMCPClient_Request_ViaProxy(t *testing.T) {
+	var proxiedURL string
+
+	// A forward proxy receives the absolute target URL in the request line
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedURL = r.URL.String()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{
+			JSONRPC: "2.0",
+			ID:      "1",
+			Result:  map[string]any{},
+		})
+	}))
+	defer proxy.Close()
+
+	for _, sessionBased := range []bool{false, true} {
+		proxiedURL = ""
+		config := ServerConfig{
+			URL:          "http://mcp.example.invalid/mcp",
+			ProxyURL:     proxy.URL,
+			SessionBased: sessionBased,
+		}
+		client, err := NewMCPClient("test", config)
+		if err != nil {
+			t.Fatalf("NewMCPClient failed: %v", err)
+		}
+
+		if _, _, err := client.Request("test", nil); err != nil {
+			t.Fatalf("Request failed (session_based=%v): %v", sessionBased, err)
+		}
+		client.Close()
+
+		if proxiedURL != "http://mcp.example.invalid/mcp" {
+			t.Errorf("Expected request to be routed through proxy (session_based=%v), got %q", sessionBased, proxiedURL)
+		}
+	}
+}
+
+func TestProxyForServer_InvalidURL(t *testing.T) {
+	_, err := proxyForServer(ServerConfig{ProxyURL: "://bad"})
+	if err == nil {
+		t.Error("Expected error for invalid proxy URL")
+	}
+}
+
+// newTLSTestServer starts a TLS server that answers every request with an empty MCP result
+func newTLSTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{
+			JSONRPC: "2.0",
+			ID:      "1",
+			Result:  map[string]any{},
+		})
+	}))
+}
+
+// writeCertPEM writes a certificate to a PEM file in dir and returns its path
+func writeCertPEM(t *testing.T, dir, name string, cert *x509.Certificate) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write cert: %v", err)
+	}
+	return path
+}
+
+func TestMCPClient_Request_CustomCA(t *testing.T) {
+	server := newTLSTestServer(t)
+	defer server.Close()
+
+	// Without the CA, verification must fail
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	if _, _, err := client.Request("test", nil); err == nil {
+		t.Error("Expected certificate verification error without custom CA")
+	}
+	client.Close()
+
+	caFile := writeCertPEM(t, t.TempDir(), "ca.pem", server.Certificate())
+	client, err = NewMCPClient("test", ServerConfig{URL: server.URL, CACertFile: caFile})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, _, err := client.Request("test", nil); err != nil {
+		t.Fatalf("Request with custom CA failed: %v", err)
+	}
+}
+
+func TestMCPClient_Request_InsecureSkipVerify(t *testing.T) {
+	server := newTLSTestServer(t)
+	defer server.Close()
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL, InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, _, err := client.Request("test", nil); err != nil {
+		t.Fatalf("Request with insecure_skip_verify failed: %v", err)
+	}
+}
+
+func TestTLSConfigForServer_InvalidCAFile(t *testing.T) {
+	badFile := filepath.Join(t.TempDir(), "bad.pem")
+	os.WriteFile(badFile, []byte("not a cert"), 0644)
+
+	if _, err := tlsConfigForServer(ServerConfig{CACertFile: badFile}); err == nil {
+		t.Error("Expected error for CA file without certificates")
+	}
+
+	if _, err := tlsConfigForServer(ServerConfig{CACertFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("Expected error for missing CA file")
+	}
+}
+
+// writeClientCert generates a self-signed client certificate and key in dir
+func writeClientCert(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "mcpx-test-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	keyFile := filepath.Join(dir, "client-key.pem")
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+
+	return cert, writeCertPEM(t, dir, "client.pem", cert), keyFile
+}
+
+func TestMCPClient_Request_ClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, certFile, keyFile := writeClientCert(t, dir)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: "1", Result: map[string]any{}})
+	}))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := writeCertPEM(t, dir, "ca.pem", server.Certificate())
+
+	// Without a client certificate the handshake is rejected
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL, CACertFile: caFile})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	if _, _, err := client.Request("test", nil); err == nil {
+		t.Error("Expected request without client certificate to be rejected")
+	}
+	client.Close()
+
+	client, err = NewMCPClient("test", ServerConfig{
+		URL:            server.URL,
+		CACertFile:     caFile,
+		ClientCertFile: certFile,
+		ClientKeyFile:  keyFile,
+	})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, _, err := client.Request("test", nil); err != nil {
+		t.Fatalf("Request with client certificate failed: %v", err)
+	}
+}
+
+func TestTLSConfigForServer_ClientCertRequiresKey(t *testing.T) {
+	_, err := tlsConfigForServer(ServerConfig{ClientCertFile: "client.pem"})
+	if err == nil {
+		t.Error("Expected error when client_key_file is missing")
+	}
+}
+
 func TestNewMCPClient(t *testing.T) {
 	config := ServerConfig{
 		URL: "https://example.com/mcp",
@@ -139,7 +376,10 @@ func TestNewMCPClient(t *testing.T) {
 		},
 	}
 
-	client := NewMCPClient("test-server", config)
+	client, err := NewMCPClient("test-server", config)
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
 
 	if client == nil {
 		t.Fatal("Expected client to be created")
@@ -166,7 +406,10 @@ func TestNewMCPClient_SessionBased(t *testing.T) {
 		SessionBased: true,
 	}
 
-	client := NewMCPClient("session-server", config)
+	client, err := NewMCPClient("session-server", config)
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
 
 	if !client.IsPersistent() {
 		t.Error("Expected persistent client for session-based config")
@@ -177,7 +420,10 @@ func TestNewMCPClient_SessionBased(t *testing.T) {
 
 func TestMCPClient_SetOAuthToken(t *testing.T) {
 	config := ServerConfig{URL: "https://example.com/mcp"}
-	client := NewMCPClient("test", config)
+	client, err := NewMCPClient("test", config)
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
 	defer client.Close()
 
 	client.SetOAuthToken("test-token-123")
@@ -189,7 +435,10 @@ func TestMCPClient_SetOAuthToken(t *testing.T) {
 
 func TestMCPClient_SetSessionID(t *testing.T) {
 	config := ServerConfig{URL: "https://example.com/mcp"}
-	client := NewMCPClient("test", config)
+	client, err := NewMCPClient("test", config)
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
 	defer client.Close()
 
 	client.SetSessionID("session-456")
@@ -199,6 +448,52 @@ func TestMCPClient_SetSessionID(t *testing.T) {
 	}
 }
 
+func TestMCPClient_DefaultHeaders(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]any{}})
+	}))
+	defer server.Close()
+
+	SaveConfig(&Config{
+		DefaultHeaders: map[string]string{
+			"X-Org-Id":      "acme",
+			"Authorization": "Bearer default",
+		},
+		Servers: map[string]ServerConfig{
+			"api": {URL: server.URL, Headers: map[string]string{"authorization": "Bearer server"}},
+		},
+	})
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	_, serverConfig, _ := config.Lookup("api")
+	client, err := NewMCPClient("api", serverConfig)
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, _, err := client.Request("ping", nil); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if got.Get("X-Org-Id") != "acme" {
+		t.Errorf("Expected default header sent, got %q", got.Get("X-Org-Id"))
+	}
+	if got.Get("Authorization") != "Bearer server" {
+		t.Errorf("Expected server header to win, got %q", got.Values("Authorization"))
+	}
+}
+
 func TestMCPClient_Request(t *testing.T) {
 	// Create test server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -247,7 +542,10 @@ func TestMCPClient_Request(t *testing.T) {
 		},
 	}
 
-	client := NewMCPClient("test", config)
+	client, err := NewMCPClient("test", config)
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
 	defer client.Close()
 
 	resp, sessionID, err := client.Request("initialize", map[string]any{
@@ -282,7 +580,10 @@ data: {"jsonrpc": "2.0", "id": "1", "result": {"tools": []}}
 	defer server.Close()
 
 	config := ServerConfig{URL: server.URL}
-	client := NewMCPClient("test", config)
+	client, err := NewMCPClient("test", config)
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
 	defer client.Close()
 
 	resp, _, err := client.Request("tools/list", nil)
@@ -311,12 +612,15 @@ func TestMCPClient_Request_WithSessionID(t *testing.T) {
 	defer server.Close()
 
 	config := ServerConfig{URL: server.URL}
-	client := NewMCPClient("test", config)
+	client, err := NewMCPClient("test", config)
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
 	defer client.Close()
 
 	client.SetSessionID("existing-session")
 
-	_, _, err := client.Request("test", nil)
+	_, _, err = client.Request("test", nil)
 	if err != nil {
 		t.Fatalf("Request failed: %v", err)
 	}
@@ -326,6 +630,65 @@ func TestMCPClient_Request_WithSessionID(t *testing.T) {
 	}
 }
 
+func TestMCPClient_Request_CustomSessionHeader(t *testing.T) {
+	var received, standard string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("X-Session")
+		standard = r.Header.Get("Mcp-Session-Id")
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Session", "issued-session")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: "1", Result: map[string]any{}})
+	}))
+	defer server.Close()
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL, SessionHeader: "X-Session"})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+	client.SetSessionID("existing-session")
+
+	_, sessionID, err := client.Request("test", nil)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if received != "existing-session" || standard != "" {
+		t.Errorf("Expected session ID only in X-Session, got X-Session=%q Mcp-Session-Id=%q", received, standard)
+	}
+	if sessionID != "issued-session" {
+		t.Errorf("Expected session ID read from X-Session, got %q", sessionID)
+	}
+}
+
+func TestMCPClient_Request_SessionParam(t *testing.T) {
+	var query url.Values
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		header = r.Header.Get("Mcp-Session-Id")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: "1", Result: map[string]any{}})
+	}))
+	defer server.Close()
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL + "/mcp?project=x", SessionParam: "sessionId"})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+	client.SetSessionID("existing-session")
+
+	if _, _, err := client.Request("test", nil); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if query.Get("sessionId") != "existing-session" || query.Get("project") != "x" {
+		t.Errorf("Expected session ID added to the query, got %v", query)
+	}
+	if header != "" {
+		t.Errorf("Expected no session header, got %q", header)
+	}
+}
+
 func TestMCPClient_Request_WithOAuthToken(t *testing.T) {
 	var receivedAuth string
 
@@ -346,13 +709,16 @@ func TestMCPClient_Request_WithOAuthToken(t *testing.T) {
 			"Authorization": "Bearer static-token",
 		},
 	}
-	client := NewMCPClient("test", config)
+	client, err := NewMCPClient("test", config)
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
 	defer client.Close()
 
 	// OAuth token should override static header
 	client.SetOAuthToken("dynamic-token")
 
-	_, _, err := client.Request("test", nil)
+	_, _, err = client.Request("test", nil)
 	if err != nil {
 		t.Fatalf("Request failed: %v", err)
 	}
@@ -362,6 +728,90 @@ func TestMCPClient_Request_WithOAuthToken(t *testing.T) {
 	}
 }
 
+func TestMCPClient_Request_RefreshesOn401(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	refreshes := 0
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refreshes++
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "new-access", "expires_in": 3600})
+	}))
+	defer tokenServer.Close()
+
+	var auths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auths = append(auths, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer new-access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]any{}})
+	}))
+	defer server.Close()
+
+	// The stored token looks valid but the server has revoked it
+	SaveTokens(map[string]TokenData{"api": {
+		AccessToken:  "revoked-access",
+		RefreshToken: "refresh-1",
+		ExpiresAt:    float64(time.Now().Add(time.Hour).Unix()),
+	}})
+
+	client, err := NewMCPClient("api", ServerConfig{URL: server.URL, OAuth: &OAuthConfig{TokenURL: tokenServer.URL}})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+	client.SetOAuthToken("revoked-access")
+
+	if _, _, err := client.Request("ping", nil); err != nil {
+		t.Fatalf("Expected request to succeed after refresh, got %v", err)
+	}
+	if refreshes != 1 {
+		t.Errorf("Expected one refresh, got %d", refreshes)
+	}
+	if len(auths) != 2 || auths[0] != "Bearer revoked-access" || auths[1] != "Bearer new-access" {
+		t.Errorf("Expected a retry with the refreshed token, got %q", auths)
+	}
+}
+
+func TestMCPClient_Request_RetriesAuthOnce(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "still-rejected", "expires_in": 3600})
+	}))
+	defer tokenServer.Close()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	SaveTokens(map[string]TokenData{"api": {AccessToken: "old", RefreshToken: "refresh-1", ExpiresAt: float64(time.Now().Add(time.Hour).Unix())}})
+
+	client, err := NewMCPClient("api", ServerConfig{URL: server.URL, OAuth: &OAuthConfig{TokenURL: tokenServer.URL}})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+	client.SetOAuthToken("old")
+
+	_, _, err = client.Request("ping", nil)
+	if errorCodeOf(err, "") != ErrAuthExpired {
+		t.Errorf("Expected AUTH_EXPIRED, got %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected exactly one retry, got %d requests", requests)
+	}
+}
+
 func TestMCPClient_ListTools(t *testing.T) {
 	_, cleanup := setupTestConfig(t)
 	defer cleanup()
@@ -404,7 +854,10 @@ func TestMCPClient_ListTools(t *testing.T) {
 	defer server.Close()
 
 	config := ServerConfig{URL: server.URL}
-	client := NewMCPClient("test", config)
+	client, err := NewMCPClient("test", config)
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
 	defer client.Close()
 
 	tools, err := client.ListTools()
@@ -425,53 +878,145 @@ func TestMCPClient_ListTools(t *testing.T) {
 	}
 }
 
-func TestMCPClient_CallTool(t *testing.T) {
+func TestMCPClient_ListTools_SkipsMalformed(t *testing.T) {
 	_, cleanup := setupTestConfig(t)
 	defer cleanup()
 
-	var receivedToolName string
-	var receivedArgs map[string]any
-
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		body, _ := io.ReadAll(r.Body)
 		var req MCPRequest
-		json.Unmarshal(body, &req)
-
+		json.NewDecoder(r.Body).Decode(&req)
 		w.Header().Set("Content-Type", "application/json")
 
-		if req.Method == "initialize" {
-			json.NewEncoder(w).Encode(MCPResponse{
-				JSONRPC: "2.0",
-				ID:      req.ID,
-				Result:  map[string]any{"protocolVersion": "2024-11-05"},
-			})
-		} else if req.Method == "tools/call" {
-			params := req.Params.(map[string]any)
-			receivedToolName = params["name"].(string)
-			receivedArgs = params["arguments"].(map[string]any)
-
-			json.NewEncoder(w).Encode(MCPResponse{
-				JSONRPC: "2.0",
-				ID:      req.ID,
-				Result: map[string]any{
-					"content": []map[string]any{
-						{"type": "text", "text": "Result from tool"},
-					},
+		result := map[string]any{"protocolVersion": "2024-11-05"}
+		if req.Method == "tools/list" {
+			result = map[string]any{
+				"tools": []any{
+					map[string]any{"name": "good", "description": "Valid tool"},
+					map[string]any{"description": "Missing name"},
+					map[string]any{"name": "bad", "inputSchema": "not an object"},
+					"not a tool",
 				},
-			})
+			}
 		}
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
 	}))
 	defer server.Close()
 
-	config := ServerConfig{URL: server.URL}
-	client := NewMCPClient("test", config)
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
 	defer client.Close()
 
-	result, err := client.CallTool("my-tool", map[string]any{"arg1": "value1"})
+	tools, err := client.ListTools()
 	if err != nil {
-		t.Fatalf("CallTool failed: %v", err)
+		t.Fatalf("ListTools failed: %v", err)
 	}
-
+	if len(tools) != 1 || tools[0].Name != "good" || tools[0].Description != "Valid tool" {
+		t.Errorf("Expected only the valid tool, got %+v", tools)
+	}
+}
+
+func TestMCPClient_ListTools_SnakeCaseSchema(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+
+		result := map[string]any{"protocolVersion": "2024-11-05"}
+		if req.Method == "tools/list" {
+			result = map[string]any{
+				"tools": []any{
+					map[string]any{
+						"name":         "search",
+						"input_schema": map[string]any{"type": "object", "required": []any{"q"}},
+					},
+					map[string]any{
+						"name":         "both",
+						"inputSchema":  map[string]any{"type": "object"},
+						"input_schema": map[string]any{"type": "string"},
+					},
+				},
+			}
+		}
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}))
+	defer server.Close()
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	tools, err := client.ListTools()
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+	if len(tools) != 2 {
+		t.Fatalf("Expected 2 tools, got %+v", tools)
+	}
+	if tools[0].Parameters == nil || tools[0].Parameters["type"] != "object" {
+		t.Errorf("Expected Parameters from input_schema, got %v", tools[0].Parameters)
+	}
+	if tools[1].Parameters["type"] != "object" {
+		t.Errorf("Expected inputSchema to win over input_schema, got %v", tools[1].Parameters)
+	}
+}
+
+func TestMCPClient_CallTool(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	var receivedToolName string
+	var receivedArgs map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req MCPRequest
+		json.Unmarshal(body, &req)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if req.Method == "initialize" {
+			json.NewEncoder(w).Encode(MCPResponse{
+				JSONRPC: "2.0",
+				ID:      req.ID,
+				Result:  map[string]any{"protocolVersion": "2024-11-05"},
+			})
+		} else if req.Method == "tools/call" {
+			params := req.Params.(map[string]any)
+			receivedToolName = params["name"].(string)
+			receivedArgs = params["arguments"].(map[string]any)
+
+			json.NewEncoder(w).Encode(MCPResponse{
+				JSONRPC: "2.0",
+				ID:      req.ID,
+				Result: map[string]any{
+					"content": []map[string]any{
+						{"type": "text", "text": "Result from tool"},
+					},
+				},
+			})
+		}
+	}))
+	defer server.Close()
+
+	config := ServerConfig{URL: server.URL}
+	client, err := NewMCPClient("test", config)
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	result, err := client.CallTool("my-tool", map[string]any{"arg1": "value1"})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+
 	if receivedToolName != "my-tool" {
 		t.Errorf("Expected tool name 'my-tool', got '%s'", receivedToolName)
 	}
@@ -520,10 +1065,13 @@ func TestMCPClient_Request_Error(t *testing.T) {
 	defer server.Close()
 
 	config := ServerConfig{URL: server.URL}
-	client := NewMCPClient("test", config)
+	client, err := NewMCPClient("test", config)
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
 	defer client.Close()
 
-	_, err := client.CallTool("nonexistent", nil)
+	_, err = client.CallTool("nonexistent", nil)
 	if err == nil {
 		t.Error("Expected error for failed tool call")
 	}
@@ -661,3 +1209,1208 @@ func TestGetClientID(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeDefaultArgs(t *testing.T) {
+	defaults := map[string]any{"project_id": "default-project", "limit": 10}
+
+	merged := mergeDefaultArgs(defaults, map[string]any{"limit": 5, "query": "SELECT 1"})
+	if merged["project_id"] != "default-project" {
+		t.Errorf("Expected default project_id, got %v", merged["project_id"])
+	}
+	if merged["limit"] != 5 {
+		t.Errorf("Expected explicit limit to win, got %v", merged["limit"])
+	}
+	if merged["query"] != "SELECT 1" {
+		t.Errorf("Expected query to be preserved, got %v", merged["query"])
+	}
+
+	merged = mergeDefaultArgs(defaults, nil)
+	if merged["project_id"] != "default-project" || merged["limit"] != 10 {
+		t.Errorf("Expected defaults for nil arguments, got %v", merged)
+	}
+
+	// Defaults map must not be mutated by the merge
+	if defaults["limit"] != 10 {
+		t.Errorf("Expected defaults to be untouched, got %v", defaults["limit"])
+	}
+}
+
+func TestMCPClient_CallTool_DefaultArgs(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	var receivedArgs map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req MCPRequest
+		json.Unmarshal(body, &req)
+
+		if req.Method == "tools/call" {
+			params := req.Params.(map[string]any)
+			receivedArgs, _ = params["arguments"].(map[string]any)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]any{}})
+	}))
+	defer server.Close()
+
+	config := ServerConfig{
+		URL:         server.URL,
+		DefaultArgs: map[string]any{"database": "analytics"},
+	}
+	client, err := NewMCPClient("test", config)
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.CallTool("query", nil); err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+
+	if receivedArgs["database"] != "analytics" {
+		t.Errorf("Expected default database argument, got %v", receivedArgs)
+	}
+}
+
+func TestMCPClient_ToolFilters(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	var calledTools []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req MCPRequest
+		json.Unmarshal(body, &req)
+
+		w.Header().Set("Content-Type", "application/json")
+		switch req.Method {
+		case "tools/list":
+			json.NewEncoder(w).Encode(MCPResponse{
+				JSONRPC: "2.0",
+				ID:      req.ID,
+				Result: map[string]any{
+					"tools": []map[string]any{
+						{"name": "query"},
+						{"name": "list_tables"},
+						{"name": "delete_table"},
+					},
+				},
+			})
+		case "tools/call":
+			params := req.Params.(map[string]any)
+			calledTools = append(calledTools, params["name"].(string))
+			json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]any{}})
+		default:
+			json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]any{}})
+		}
+	}))
+	defer server.Close()
+
+	config := ServerConfig{
+		URL:        server.URL,
+		AllowTools: []string{"query", "*_table*"},
+		DenyTools:  []string{"delete_*"},
+	}
+	client, err := NewMCPClient("test", config)
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	tools, err := client.ListTools()
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+	if len(tools) != 2 || tools[0].Name != "query" || tools[1].Name != "list_tables" {
+		t.Errorf("Expected query and list_tables, got %v", tools)
+	}
+
+	_, err = client.CallTool("delete_table", nil)
+	if err == nil {
+		t.Fatal("Expected denied tool call to fail")
+	}
+	if code := errorCodeOf(err, ""); code != ErrUnknownTool {
+		t.Errorf("Expected %s, got %q", ErrUnknownTool, code)
+	}
+	if len(calledTools) != 0 {
+		t.Errorf("Denied call should not reach the server, got %v", calledTools)
+	}
+
+	if _, err := client.CallTool("query", nil); err != nil {
+		t.Errorf("Allowed call failed: %v", err)
+	}
+}
+
+func TestMCPClient_ListTools_Annotations(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req MCPRequest
+		json.Unmarshal(body, &req)
+
+		w.Header().Set("Content-Type", "application/json")
+		result := map[string]any{}
+		if req.Method == "tools/list" {
+			result["tools"] = []map[string]any{
+				{
+					"name": "drop_table",
+					"annotations": map[string]any{
+						"title":           "Drop Table",
+						"readOnlyHint":    false,
+						"destructiveHint": true,
+						"idempotentHint":  true,
+					},
+				},
+				{"name": "query"},
+			}
+		}
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}))
+	defer server.Close()
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	tools, err := client.ListTools()
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+
+	ann := tools[0].Annotations
+	if ann == nil {
+		t.Fatal("Expected annotations on drop_table")
+	}
+	if ann.Title != "Drop Table" {
+		t.Errorf("Expected title 'Drop Table', got %q", ann.Title)
+	}
+	if ann.ReadOnlyHint == nil || *ann.ReadOnlyHint {
+		t.Error("Expected readOnlyHint=false")
+	}
+	if ann.DestructiveHint == nil || !*ann.DestructiveHint {
+		t.Error("Expected destructiveHint=true")
+	}
+	if ann.IdempotentHint == nil || !*ann.IdempotentHint {
+		t.Error("Expected idempotentHint=true")
+	}
+
+	if tools[1].Annotations != nil {
+		t.Error("Expected no annotations on query")
+	}
+
+	// Annotations are part of the --tools output
+	data, _ := json.Marshal(tools[0])
+	var raw map[string]any
+	json.Unmarshal(data, &raw)
+	annRaw, ok := raw["annotations"].(map[string]any)
+	if !ok || annRaw["destructiveHint"] != true || annRaw["readOnlyHint"] != false {
+		t.Errorf("Expected annotations in marshaled tool, got %s", data)
+	}
+}
+
+// newInitializeServer returns a server whose initialize result reports the given protocol version
+func newInitializeServer(t *testing.T, version string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req MCPRequest
+		json.Unmarshal(body, &req)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Result:  map[string]any{"protocolVersion": version},
+		})
+	}))
+}
+
+func TestMCPClient_Initialize_VersionDowngrade(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server := newInitializeServer(t, "2024-11-05")
+	defer server.Close()
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	if client.ProtocolVersion() != "2024-11-05" {
+		t.Errorf("Expected negotiated version 2024-11-05, got %s", client.ProtocolVersion())
+	}
+}
+
+func TestMCPClient_Initialize_UnsupportedVersion(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server := newInitializeServer(t, "1999-01-01")
+	defer server.Close()
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	err = client.Initialize()
+	if err == nil {
+		t.Fatal("Expected error for unsupported protocol version")
+	}
+	if !strings.Contains(err.Error(), "1999-01-01") {
+		t.Errorf("Expected error to name the server version, got: %v", err)
+	}
+}
+
+func TestMCPClient_Initialize_RequestsLatestVersion(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req MCPRequest
+		json.Unmarshal(body, &req)
+		if params, ok := req.Params.(map[string]any); ok {
+			requested, _ = params["protocolVersion"].(string)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]any{"protocolVersion": ProtocolVersion}})
+	}))
+	defer server.Close()
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if requested != ProtocolVersion {
+		t.Errorf("Expected initialize to request %s, got %s", ProtocolVersion, requested)
+	}
+}
+
+func TestMCPClient_Initialize_SendsInitializedNotification(t *testing.T) {
+	for _, sessionBased := range []bool{false, true} {
+		t.Run(fmt.Sprintf("session_based=%v", sessionBased), func(t *testing.T) {
+			_, cleanup := setupTestConfig(t)
+			defer cleanup()
+
+			var methods []string
+			var notificationHadID bool
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				var raw map[string]any
+				json.Unmarshal(body, &raw)
+				method, _ := raw["method"].(string)
+				methods = append(methods, method)
+
+				if method == "notifications/initialized" {
+					_, notificationHadID = raw["id"]
+					w.WriteHeader(http.StatusAccepted)
+					return
+				}
+
+				id, _ := raw["id"].(string)
+				result := map[string]any{}
+				if method == "tools/list" {
+					result["tools"] = []map[string]any{}
+				}
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: id, Result: result})
+			}))
+			defer server.Close()
+
+			client, err := NewMCPClient("test", ServerConfig{URL: server.URL, SessionBased: sessionBased})
+			if err != nil {
+				t.Fatalf("NewMCPClient failed: %v", err)
+			}
+			defer client.Close()
+
+			if _, err := client.ListTools(); err != nil {
+				t.Fatalf("ListTools failed: %v", err)
+			}
+
+			expected := []string{"initialize", "notifications/initialized", "tools/list"}
+			if strings.Join(methods, ",") != strings.Join(expected, ",") {
+				t.Errorf("Expected methods %v, got %v", expected, methods)
+			}
+			if notificationHadID {
+				t.Error("Notification must not carry an id")
+			}
+		})
+	}
+}
+
+func TestMCPClient_CapabilityGating(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	var toolsRequested bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req MCPRequest
+		json.Unmarshal(body, &req)
+
+		if strings.HasPrefix(req.Method, "tools/") {
+			toolsRequested = true
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Result: map[string]any{
+				"protocolVersion": "2024-11-05",
+				"capabilities":    map[string]any{"resources": map[string]any{}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	client, err := NewMCPClient("resources-only", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	_, err = client.ListTools()
+	if err == nil || err.Error() != "server does not advertise tools capability" {
+		t.Errorf("Expected missing tools capability error, got %v", err)
+	}
+
+	_, err = client.CallTool("anything", nil)
+	if err == nil {
+		t.Error("Expected CallTool to fail without tools capability")
+	}
+
+	if toolsRequested {
+		t.Error("No tools/* request should reach a server without the tools capability")
+	}
+
+	if err := client.requireCapability("resources"); err != nil {
+		t.Errorf("Expected resources capability, got %v", err)
+	}
+}
+
+func TestMCPClient_ReinitializeOnExpiredSession(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	// A stale session left over from an earlier run
+	if err := SaveSessions(map[string]string{"test": "stale-session"}); err != nil {
+		t.Fatalf("SaveSessions failed: %v", err)
+	}
+
+	var initializeCount int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req MCPRequest
+		json.Unmarshal(body, &req)
+
+		if req.Method == "initialize" {
+			initializeCount++
+			w.Header().Set("Mcp-Session-Id", "fresh-session")
+		} else if r.Header.Get("Mcp-Session-Id") != "fresh-session" {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return
+		}
+
+		result := map[string]any{}
+		if req.Method == "tools/list" {
+			result["tools"] = []map[string]any{{"name": "query"}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}))
+	defer server.Close()
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	tools, err := client.ListTools()
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+	if len(tools) != 1 {
+		t.Errorf("Expected 1 tool, got %d", len(tools))
+	}
+
+	if initializeCount != 1 {
+		t.Errorf("Expected exactly one re-initialize, got %d", initializeCount)
+	}
+
+	sessions, _ := LoadSessions()
+	if sessions["test"] != "fresh-session" {
+		t.Errorf("Expected cached session to be replaced, got %q", sessions["test"])
+	}
+}
+
+func TestMCPClient_ReinitializeOnlyOnce(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	var initializeCount int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req MCPRequest
+		json.Unmarshal(body, &req)
+
+		if req.Method == "initialize" {
+			initializeCount++
+			w.Header().Set("Mcp-Session-Id", "always-rejected")
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]any{}})
+			return
+		}
+		if req.Method == "notifications/initialized" {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		http.Error(w, "session not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.ListTools(); err == nil {
+		t.Fatal("Expected ListTools to fail when the session is always rejected")
+	}
+
+	// Initial initialize plus a single retry
+	if initializeCount != 2 {
+		t.Errorf("Expected 2 initialize calls, got %d", initializeCount)
+	}
+}
+
+func TestMCPClient_Request_AcceptedNoBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	resp, _, err := client.Request("notifications/initialized", nil)
+	if err != nil {
+		t.Fatalf("Expected 202 with no body to succeed, got %v", err)
+	}
+	if resp == nil {
+		t.Fatal("Expected a response")
+	}
+	if resp.Result != nil || resp.Error != nil {
+		t.Errorf("Expected empty response, got %+v", resp)
+	}
+}
+
+func TestMCPClient_Request_EmptyBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+	}))
+	defer server.Close()
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, _, err := client.Request("test", nil); err != nil {
+		t.Errorf("Expected empty body to succeed, got %v", err)
+	}
+}
+
+func TestMCPClient_Request_HTTPErrorStatus(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		expectedCode string
+	}{
+		{
+			name:         "500 with HTML",
+			status:       http.StatusInternalServerError,
+			body:         "<html><body><h1>Internal Server Error</h1></body></html>",
+			expectedCode: ErrMCPError,
+		},
+		{
+			name:         "401 unauthorized",
+			status:       http.StatusUnauthorized,
+			body:         `{"error": "invalid_token"}`,
+			expectedCode: ErrAuthExpired,
+		},
+		{
+			name:         "403 forbidden",
+			status:       http.StatusForbidden,
+			body:         "",
+			expectedCode: ErrAuthExpired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client, err := NewMCPClient("test", ServerConfig{URL: server.URL})
+			if err != nil {
+				t.Fatalf("NewMCPClient failed: %v", err)
+			}
+			defer client.Close()
+
+			_, _, err = client.Request("test", nil)
+			if err == nil {
+				t.Fatal("Expected error for non-2xx status")
+			}
+
+			var httpErr *HTTPError
+			if !errors.As(err, &httpErr) {
+				t.Fatalf("Expected *HTTPError, got %T: %v", err, err)
+			}
+			if httpErr.StatusCode != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, httpErr.StatusCode)
+			}
+			if !strings.Contains(err.Error(), fmt.Sprintf("HTTP %d", tt.status)) {
+				t.Errorf("Expected status in message, got %q", err.Error())
+			}
+			if tt.body != "" && !strings.Contains(err.Error(), strings.Fields(tt.body)[0]) {
+				t.Errorf("Expected body snippet in message, got %q", err.Error())
+			}
+			if code := errorCodeOf(err, ""); code != tt.expectedCode {
+				t.Errorf("Expected code %s, got %s", tt.expectedCode, code)
+			}
+		})
+	}
+}
+
+func TestNewHTTPError_TruncatesBody(t *testing.T) {
+	err := newHTTPError(http.StatusBadGateway, []byte(strings.Repeat("x", 1000)))
+	if len(err.Body) != maxErrorBodySnippet+len("...") {
+		t.Errorf("Expected truncated body, got %d bytes", len(err.Body))
+	}
+}
+
+func TestNewHTTPError_TruncatesOnRuneBoundary(t *testing.T) {
+	// "é" is two bytes, so byte maxErrorBodySnippet falls inside a rune
+	body := "x" + strings.Repeat("é", maxErrorBodySnippet)
+	err := newHTTPError(http.StatusBadGateway, []byte(body))
+	if !utf8.ValidString(err.Body) {
+		t.Errorf("Expected valid UTF-8, got %q", err.Body)
+	}
+	if len(err.Body) > maxErrorBodySnippet+len("...") {
+		t.Errorf("Expected at most %d bytes, got %d", maxErrorBodySnippet+len("..."), len(err.Body))
+	}
+}
+
+func TestMCPClient_Initialize_ClientVersion(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	var clientInfo map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     string         `json:"id"`
+			Method string         `json:"method"`
+			Params map[string]any `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Method == "initialize" {
+			clientInfo, _ = req.Params["clientInfo"].(map[string]any)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]any{}})
+	}))
+	defer server.Close()
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	if clientInfo["version"] != Version {
+		t.Errorf("Expected clientInfo version %q, got %v", Version, clientInfo["version"])
+	}
+}
+
+func TestMCPClient_Ping(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server := newInitializeServer(t, "2025-03-26")
+	defer server.Close()
+
+	// A cached session must not short-circuit the handshake
+	SaveSessions(map[string]string{"test": "stale-session"})
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	rtt, err := client.Ping()
+	if err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	if rtt <= 0 {
+		t.Errorf("Expected positive round-trip time, got %v", rtt)
+	}
+
+	result := newPingResult("test", client, rtt)
+	if result.ProtocolVersion != "2025-03-26" {
+		t.Errorf("Expected negotiated version 2025-03-26, got %s", result.ProtocolVersion)
+	}
+}
+
+func TestMCPClient_RateLimited(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	origMaxWait := rateLimitMaxWait
+	rateLimitMaxWait = time.Second
+	defer func() { rateLimitMaxWait = origMaxWait }()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	_, _, err = client.Request("tools/list", nil)
+
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("Expected HTTPError, got %v", err)
+	}
+	if httpErr.RetryAfter != 2*time.Second {
+		t.Errorf("Expected RetryAfter 2s, got %v", httpErr.RetryAfter)
+	}
+	if errorCodeOf(err, "") != ErrRateLimited {
+		t.Errorf("Expected RATE_LIMITED, got %s", errorCodeOf(err, ""))
+	}
+	if requests != 1 {
+		t.Errorf("Expected no retry when Retry-After exceeds the max wait, got %d requests", requests)
+	}
+
+	resp := errResponseFor(err)
+	if resp.Error.RetryAfter != 2 {
+		t.Errorf("Expected retryAfter 2 in response, got %v", resp.Error.RetryAfter)
+	}
+}
+
+func TestMCPClient_RateLimitRetries(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"jsonrpc": "2.0", "id": "1", "result": {}}`))
+	}))
+	defer server.Close()
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, _, err := client.Request("tools/list", nil); err != nil {
+		t.Fatalf("Expected retry to succeed, got %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", requests)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              0,
+		"2":                             2 * time.Second,
+		"-5":                            0,
+		"soon":                          0,
+		"Wed, 01 Jan 2025 12:00:30 GMT": 30 * time.Second,
+		"Wed, 01 Jan 2025 11:00:00 GMT": 0,
+	}
+	for value, want := range tests {
+		if got := parseRetryAfter(value, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+// newSlowToolServer serves an MCP server whose tools/call never answers on its
+// own. It reports each call's request id and each notifications/cancelled params.
+func newSlowToolServer(t *testing.T) (*httptest.Server, <-chan string, <-chan map[string]any) {
+	t.Helper()
+
+	calls := make(chan string, 1)
+	cancelled := make(chan map[string]any, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		switch req.Method {
+		case "tools/call":
+			calls <- req.ID
+			<-r.Context().Done()
+			return
+		case "notifications/cancelled":
+			params, _ := req.Params.(map[string]any)
+			cancelled <- params
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		if req.ID == "" {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Result:  map[string]any{"protocolVersion": ProtocolVersion, "capabilities": map[string]any{"tools": map[string]any{}}},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	return server, calls, cancelled
+}
+
+func TestMCPClient_CallToolContext_Cancel(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server, calls, cancelled := newSlowToolServer(t)
+
+	client, err := NewMCPClient("slow", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-calls
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err = client.CallToolContext(ctx, "long_job", nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected prompt return, took %s", elapsed)
+	}
+
+	select {
+	case params := <-cancelled:
+		if params["requestId"] == nil || params["requestId"] == "" {
+			t.Errorf("Expected requestId in cancellation, got %v", params)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected notifications/cancelled to be sent")
+	}
+}
+
+func TestMCPClient_RequestContext_Deadline(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client, err := NewMCPClient("slow", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err = client.RequestContext(ctx, "initialize", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected request to abort at the deadline, took %s", elapsed)
+	}
+}
+
+func TestMCPClient_ListToolsContext_Cancelled(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := client.ListToolsContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("Expected no requests with a cancelled context, got %d", requests)
+	}
+}
+
+// newMetaToolServer serves tools/call results carrying _meta usage data
+func newMetaToolServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ID == "" {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		result := map[string]any{"protocolVersion": ProtocolVersion}
+		if req.Method == "tools/call" {
+			time.Sleep(5 * time.Millisecond)
+			result = map[string]any{
+				"content": []any{map[string]any{"type": "text", "text": "done"}},
+				"_meta":   map[string]any{"usage": map[string]any{"input_tokens": float64(12)}},
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestMCPClient_CallToolWithMetadata(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	client, err := NewMCPClient("meta", ServerConfig{URL: newMetaToolServer(t).URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	result, meta, err := client.CallToolWithMetadata(context.Background(), "summarize", map[string]any{"text": "hi"}, nil)
+	if err != nil {
+		t.Fatalf("CallToolWithMetadata failed: %v", err)
+	}
+
+	if meta.ElapsedMs <= 0 {
+		t.Errorf("Expected elapsed time, got %v", meta.ElapsedMs)
+	}
+	if meta.RequestBytes == 0 || meta.ResponseBytes == 0 {
+		t.Errorf("Expected byte sizes, got %+v", meta)
+	}
+	usage, _ := meta.Meta["usage"].(map[string]any)
+	if usage["input_tokens"] != float64(12) {
+		t.Errorf("Expected _meta passed through, got %v", meta.Meta)
+	}
+
+	// The result itself is unchanged
+	if _, ok := result["_meta"]; !ok {
+		t.Error("Expected result to keep _meta")
+	}
+	if _, ok := result["content"]; !ok {
+		t.Error("Expected result content")
+	}
+}
+
+func TestNewHTTPClient_HTTP2Settings(t *testing.T) {
+	plain, err := NewHTTPClient(time.Second, ServerConfig{URL: "https://example.com"})
+	if err != nil {
+		t.Fatalf("NewHTTPClient failed: %v", err)
+	}
+	if !plain.transport.ForceAttemptHTTP2 || plain.transport.TLSNextProto != nil {
+		t.Error("Expected HTTP/2 to be enabled for non-session servers")
+	}
+
+	disabled, err := NewHTTPClient(time.Second, ServerConfig{URL: "https://example.com", DisableHTTP2: true})
+	if err != nil {
+		t.Fatalf("NewHTTPClient failed: %v", err)
+	}
+	if disabled.transport.ForceAttemptHTTP2 || disabled.transport.TLSNextProto == nil {
+		t.Error("Expected disable_http2 to keep the transport on HTTP/1.1")
+	}
+
+	persistent, err := NewPersistentHTTPClient(time.Second, ServerConfig{URL: "https://example.com"})
+	if err != nil {
+		t.Fatalf("NewPersistentHTTPClient failed: %v", err)
+	}
+	if persistent.transport.ForceAttemptHTTP2 || persistent.transport.TLSNextProto == nil {
+		t.Error("Expected session-based servers to stay on HTTP/1.1")
+	}
+}
+
+func TestMCPClient_NegotiatesHTTP2(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	protos := make(chan string, 4)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		protos <- r.Proto
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"jsonrpc":"2.0","id":"1","result":{}}`))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		config ServerConfig
+		want   string
+	}{
+		{"non-session", ServerConfig{URL: server.URL, InsecureSkipVerify: true}, "HTTP/2.0"},
+		{"session-based", ServerConfig{URL: server.URL, InsecureSkipVerify: true, SessionBased: true}, "HTTP/1.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewMCPClient("h2", tt.config)
+			if err != nil {
+				t.Fatalf("NewMCPClient failed: %v", err)
+			}
+			defer client.Close()
+
+			if _, _, err := client.Request("test", nil); err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			if got := <-protos; got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMCPClient_ListToolsOnce_EndsSession(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	closed := make(chan struct{}, 1)
+	deletes := make(chan string, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deletes <- r.Header.Get("Mcp-Session-Id")
+			return
+		}
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ID == "" {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		result := map[string]any{"tools": []map[string]any{{"name": "search"}}}
+		if req.Method == "initialize" {
+			w.Header().Set("Mcp-Session-Id", "sess-1")
+			result = map[string]any{"protocolVersion": ProtocolVersion, "capabilities": map[string]any{"tools": map[string]any{}}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			select {
+			case closed <- struct{}{}:
+			default:
+			}
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL, SessionBased: true})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+
+	tools, err := client.ListToolsOnce(context.Background())
+	if err != nil {
+		t.Fatalf("ListToolsOnce failed: %v", err)
+	}
+	if len(tools) != 1 {
+		t.Errorf("Expected 1 tool, got %d", len(tools))
+	}
+	if client.sessionID != "" {
+		t.Errorf("Expected the session to be dropped, got %q", client.sessionID)
+	}
+	select {
+	case id := <-deletes:
+		if id != "sess-1" {
+			t.Errorf("Expected DELETE to carry session sess-1, got %q", id)
+		}
+	default:
+		t.Error("Expected a session DELETE")
+	}
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the session's connection to be closed")
+	}
+}
+func TestMCPClient_Close_TerminatesSession(t *testing.T) {
+	deletes := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deletes <- r.Header.Get("Mcp-Session-Id")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ID == "" {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Mcp-Session-Id", "sess-42")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]any{"protocolVersion": ProtocolVersion}})
+	}))
+	defer server.Close()
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL, SessionBased: true})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	if err := client.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	// A 405 from a server that doesn't support termination is not a problem
+	client.Close()
+	select {
+	case id := <-deletes:
+		if id != "sess-42" {
+			t.Errorf("Expected DELETE to carry session sess-42, got %q", id)
+		}
+	default:
+		t.Fatal("Expected Close to send a session DELETE")
+	}
+
+	// Nothing to end once the session is gone
+	client.Close()
+	if len(deletes) != 0 {
+		t.Error("Expected no DELETE without a session")
+	}
+}
+
+// newUnresponsiveAddr returns a loopback address whose connects hang: the
+// listener has a minimal backlog, is never accepted from, and its queue is
+// filled first, so further SYNs are dropped
+func newUnresponsiveAddr(t *testing.T) string {
+	t.Helper()
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatalf("Socket failed: %v", err)
+	}
+	t.Cleanup(func() { syscall.Close(fd) })
+	if err := syscall.Bind(fd, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+	if err := syscall.Listen(fd, 0); err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	sa, _ := syscall.Getsockname(fd)
+	addr := fmt.Sprintf("127.0.0.1:%d", sa.(*syscall.SockaddrInet4).Port)
+
+	filler, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { filler.Close() })
+	return addr
+}
+
+func TestMCPClient_ConnectTimeout(t *testing.T) {
+	addr := newUnresponsiveAddr(t)
+
+	for _, sessionBased := range []bool{false, true} {
+		client, err := NewMCPClient("test", ServerConfig{URL: "http://" + addr + "/mcp", SessionBased: sessionBased, ConnectTimeoutSeconds: 1})
+		if err != nil {
+			t.Fatalf("NewMCPClient failed: %v", err)
+		}
+
+		start := time.Now()
+		_, _, err = client.Request("ping", nil)
+		elapsed := time.Since(start)
+		client.Close()
+
+		var connectErr *ConnectTimeoutError
+		if !errors.As(err, &connectErr) || errorCodeOf(err, "") != ErrTimeout {
+			t.Errorf("session_based=%v: expected a connect timeout, got %v", sessionBased, err)
+		}
+		if elapsed > 5*time.Second {
+			t.Errorf("session_based=%v: expected the connect timeout to fire after ~1s, took %s", sessionBased, elapsed)
+		}
+	}
+}
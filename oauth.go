@@ -1,34 +1,71 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"os/exec"
 	"regexp"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// Default OAuth callback, used unless oauth.callback_port or --auth-port
+// says otherwise. Registrations saved without a redirect URI used this one.
 const (
 	callbackPort = 8085
 	redirectURI  = "http://localhost:8085/callback"
 )
 
+// ManualRedirect as oauth.redirect_uri (or --auth-redirect) skips the callback
+// server: the user authorizes in any browser and pastes the redirect back
+const ManualRedirect = "manual"
+
+// validateRedirect checks RedirectURI is "manual" or an absolute http(s) URL
+func (o *OAuthConfig) validateRedirect() error {
+	if o.RedirectURI == "" || o.RedirectURI == ManualRedirect {
+		return nil
+	}
+	u, err := url.Parse(o.RedirectURI)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid oauth redirect_uri %q (use an http(s) URL or %q)", o.RedirectURI, ManualRedirect)
+	}
+	return nil
+}
+
+// callbackRedirectURI is the redirect URI for a callback server on port
+func callbackRedirectURI(port int) string {
+	return fmt.Sprintf("http://localhost:%d/callback", port)
+}
+
 // OAuthDiscovery holds discovered OAuth endpoints
 type OAuthDiscovery struct {
 	AuthURL         string   `json:"auth_url"`
 	TokenURL        string   `json:"token_url"`
 	RegistrationURL string   `json:"registration_url"`
+	RevocationURL   string   `json:"revocation_url,omitempty"`
 	Scopes          []string `json:"scopes"`
 	Resource        string   `json:"resource"`
+	// Issuer is the auth server's issuer identifier, checked against the iss
+	// callback parameter (RFC 9207); IssRequired is set when the server
+	// promises to always send it
+	Issuer      string `json:"issuer,omitempty"`
+	IssRequired bool   `json:"iss_required,omitempty"`
+	// CodeChallengeMethods is the auth server's code_challenge_methods_supported
+	CodeChallengeMethods []string `json:"code_challenge_methods,omitempty"`
 }
 
 // generatePKCE creates a code verifier and challenge
@@ -57,6 +94,77 @@ func generateState() (string, error) {
 	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
+// Well-known discovery timeouts: each candidate URL gets wellKnownTimeout
+// and a whole round of concurrent lookups gives up after discoveryDeadline
+var (
+	wellKnownTimeout  = 5 * time.Second
+	discoveryDeadline = 10 * time.Second
+)
+
+// fetchFirstJSON GETs every URL concurrently and returns the first URL, in
+// list order, whose response is a 200 that decodes as a JSON object, along
+// with that document. A URL is only chosen once every URL before it has
+// failed, so a faster fallback never wins over a preferred location. The
+// remaining requests are cancelled once the choice is made.
+func fetchFirstJSON(urls []string) (map[string]any, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryDeadline)
+	defer cancel()
+	client := &http.Client{Timeout: wellKnownTimeout}
+
+	type result struct {
+		doc   map[string]any
+		index int
+	}
+	results := make(chan result, len(urls))
+	for i, u := range urls {
+		go func(i int, u string) {
+			results <- result{doc: fetchJSON(ctx, client, u), index: i}
+		}(i, u)
+	}
+
+	docs := make([]map[string]any, len(urls))
+	finished := make([]bool, len(urls))
+	next := 0
+	for range urls {
+		select {
+		case r := <-results:
+			docs[r.index], finished[r.index] = r.doc, true
+			for next < len(urls) && finished[next] {
+				if docs[next] != nil {
+					return docs[next], urls[next]
+				}
+				next++
+			}
+		case <-ctx.Done():
+			return nil, ""
+		}
+	}
+	return nil, ""
+}
+
+// fetchJSON GETs a JSON document, returning nil on any failure
+func fetchJSON(ctx context.Context, client *http.Client, u string) map[string]any {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	var doc map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil
+	}
+	return doc
+}
+
 // discoverOAuthEndpoints discovers OAuth endpoints from an MCP server (RFC 9728)
 func discoverOAuthEndpoints(serverURL string) (*OAuthDiscovery, error) {
 	parsed, err := url.Parse(serverURL)
@@ -65,7 +173,6 @@ func discoverOAuthEndpoints(serverURL string) (*OAuthDiscovery, error) {
 	}
 
 	baseURL := fmt.Sprintf("%s://%s", parsed.Scheme, parsed.Host)
-	client := &http.Client{Timeout: 30 * time.Second}
 
 	fmt.Printf("Discovering OAuth configuration for %s...\n", baseURL)
 
@@ -75,26 +182,14 @@ func discoverOAuthEndpoints(serverURL string) (*OAuthDiscovery, error) {
 		fmt.Sprintf("%s/.well-known/oauth-protected-resource", baseURL),
 	}
 
-	var resourceMetadata map[string]any
-	for _, wkURL := range wellKnownURLs {
-		req, _ := http.NewRequest("GET", wkURL, nil)
-		req.Header.Set("Accept", "application/json")
-		resp, err := client.Do(req)
-		if err != nil {
-			continue
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode == 200 {
-			if err := json.NewDecoder(resp.Body).Decode(&resourceMetadata); err == nil {
-				fmt.Printf("  Found resource metadata at %s\n", wkURL)
-				break
-			}
-		}
+	resourceMetadata, foundAt := fetchFirstJSON(wellKnownURLs)
+	if resourceMetadata != nil {
+		fmt.Printf("  Found resource metadata at %s\n", foundAt)
 	}
 
 	if resourceMetadata == nil {
 		// Try getting 401 to extract WWW-Authenticate
+		client := &http.Client{Timeout: wellKnownTimeout}
 		payload := `{"jsonrpc": "2.0", "method": "initialize", "id": "1"}`
 		req, _ := http.NewRequest("POST", serverURL, strings.NewReader(payload))
 		req.Header.Set("Content-Type", "application/json")
@@ -106,14 +201,7 @@ func discoverOAuthEndpoints(serverURL string) (*OAuthDiscovery, error) {
 				if strings.Contains(wwwAuth, "resource_metadata=") {
 					re := regexp.MustCompile(`resource_metadata="([^"]+)"`)
 					if matches := re.FindStringSubmatch(wwwAuth); len(matches) > 1 {
-						metaReq, _ := http.NewRequest("GET", matches[1], nil)
-						metaResp, err := client.Do(metaReq)
-						if err == nil {
-							defer metaResp.Body.Close()
-							if metaResp.StatusCode == 200 {
-								json.NewDecoder(metaResp.Body).Decode(&resourceMetadata)
-							}
-						}
+						resourceMetadata = fetchJSON(context.Background(), client, matches[1])
 					}
 				}
 			}
@@ -150,22 +238,9 @@ func discoverOAuthEndpoints(serverURL string) (*OAuthDiscovery, error) {
 		fmt.Sprintf("%s://%s/.well-known/openid-configuration", parsedIssuer.Scheme, parsedIssuer.Host),
 	}
 
-	var authMetadata map[string]any
-	for _, wkURL := range authWellKnownURLs {
-		req, _ := http.NewRequest("GET", wkURL, nil)
-		req.Header.Set("Accept", "application/json")
-		resp, err := client.Do(req)
-		if err != nil {
-			continue
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode == 200 {
-			if err := json.NewDecoder(resp.Body).Decode(&authMetadata); err == nil {
-				fmt.Println("  Found auth server metadata")
-				break
-			}
-		}
+	authMetadata, _ := fetchFirstJSON(authWellKnownURLs)
+	if authMetadata != nil {
+		fmt.Println("  Found auth server metadata")
 	}
 
 	if authMetadata == nil {
@@ -175,6 +250,7 @@ func discoverOAuthEndpoints(serverURL string) (*OAuthDiscovery, error) {
 
 	discovery := &OAuthDiscovery{
 		Resource: serverURL,
+		Issuer:   authServerIssuer,
 	}
 
 	if v, ok := authMetadata["authorization_endpoint"].(string); ok {
@@ -186,17 +262,43 @@ func discoverOAuthEndpoints(serverURL string) (*OAuthDiscovery, error) {
 	if v, ok := authMetadata["registration_endpoint"].(string); ok {
 		discovery.RegistrationURL = v
 	}
-	if v, ok := resourceMetadata["scopes_supported"].([]any); ok {
-		for _, s := range v {
-			if str, ok := s.(string); ok {
-				discovery.Scopes = append(discovery.Scopes, str)
-			}
-		}
+	if v, ok := authMetadata["revocation_endpoint"].(string); ok {
+		discovery.RevocationURL = v
+	}
+	if v, ok := authMetadata["issuer"].(string); ok && v != "" {
+		discovery.Issuer = v
+	}
+	discovery.IssRequired, _ = authMetadata["authorization_response_iss_parameter_supported"].(bool)
+	// Prefer the resource's scopes; the auth server's list covers every
+	// resource it protects
+	discovery.Scopes = metadataStrings(resourceMetadata, "scopes_supported")
+	if len(discovery.Scopes) == 0 {
+		discovery.Scopes = metadataStrings(authMetadata, "scopes_supported")
+	}
+
+	// mcpx always uses S256 PKCE. A server that doesn't advertise methods is
+	// given the benefit of the doubt; one that lists only plain is refused.
+	discovery.CodeChallengeMethods = metadataStrings(authMetadata, "code_challenge_methods_supported")
+	if len(discovery.CodeChallengeMethods) > 0 && !slices.Contains(discovery.CodeChallengeMethods, "S256") {
+		return nil, fmt.Errorf("auth server does not support S256 PKCE (code_challenge_methods_supported: %s)",
+			strings.Join(discovery.CodeChallengeMethods, ", "))
 	}
 
 	return discovery, nil
 }
 
+// metadataStrings reads a string array field from a metadata document
+func metadataStrings(metadata map[string]any, key string) []string {
+	raw, _ := metadata[key].([]any)
+	var values []string
+	for _, v := range raw {
+		if str, ok := v.(string); ok {
+			values = append(values, str)
+		}
+	}
+	return values
+}
+
 // doDynamicClientRegistration registers a client dynamically (RFC 7591)
 func doDynamicClientRegistration(registrationURL, redirectURI, scopes string) (*ClientRegistration, error) {
 	fmt.Println("Performing dynamic client registration...")
@@ -240,38 +342,71 @@ func doDynamicClientRegistration(registrationURL, redirectURI, scopes string) (*
 	return &ClientRegistration{
 		ClientID:     result.ClientID,
 		ClientSecret: result.ClientSecret,
+		RedirectURI:  redirectURI,
 	}, nil
 }
 
 // OAuthCallbackServer handles the OAuth callback
 type OAuthCallbackServer struct {
 	server   *http.Server
+	mux      *http.ServeMux
+	listener net.Listener
+	port     int // Port actually bound, which differs from the one asked for if that was 0 or busy
 	authCode string
 	state    string
 	err      string
 	done     chan struct{}
+
+	// Checked against the callback's iss (RFC 9207) before a code is accepted
+	discovery *OAuthDiscovery
 }
 
-func newOAuthCallbackServer() *OAuthCallbackServer {
+// newOAuthCallbackServer binds the callback listener on port. Port 0, or a
+// port already in use, gets an ephemeral port instead; the redirect URI must
+// then be built from s.port.
+func newOAuthCallbackServer(port int) (*OAuthCallbackServer, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil && port != 0 {
+		fmt.Printf("Callback port %d is unavailable (%v), using a free port\n", port, err)
+		listener, err = net.Listen("tcp", ":0")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to start OAuth callback server: %w", err)
+	}
+
 	s := &OAuthCallbackServer{
-		done: make(chan struct{}),
+		listener: listener,
+		port:     listener.Addr().(*net.TCPAddr).Port,
+		done:     make(chan struct{}),
 	}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/callback", s.handleCallback)
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("/callback", s.handleCallback)
 
 	s.server = &http.Server{
-		Addr:    fmt.Sprintf(":%d", callbackPort),
-		Handler: mux,
+		Handler: s.mux,
 	}
 
-	return s
+	return s, nil
+}
+
+// redirectURI is the redirect URI pointing at this callback server
+func (s *OAuthCallbackServer) redirectURI() string {
+	return callbackRedirectURI(s.port)
 }
 
 func (s *OAuthCallbackServer) handleCallback(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
+	code, errMsg := query.Get("code"), query.Get("error")
+	if code != "" && s.discovery != nil {
+		// Never accept a code from an authorization server that isn't the
+		// one we sent the user to
+		if err := checkIssuer(s.discovery, query.Get("iss")); err != nil {
+			code, errMsg = "", err.Error()
+		}
+	}
 
-	if code := query.Get("code"); code != "" {
+	if code != "" {
 		s.authCode = code
 		s.state = query.Get("state")
 		w.Header().Set("Content-Type", "text/html")
@@ -282,7 +417,7 @@ func (s *OAuthCallbackServer) handleCallback(w http.ResponseWriter, r *http.Requ
 			<p>You can close this window and return to your terminal.</p>
 			</body></html>
 		`))
-	} else if errMsg := query.Get("error"); errMsg != "" {
+	} else if errMsg != "" {
 		s.err = errMsg
 		w.Header().Set("Content-Type", "text/html")
 		w.WriteHeader(400)
@@ -291,7 +426,7 @@ func (s *OAuthCallbackServer) handleCallback(w http.ResponseWriter, r *http.Requ
 			<h1>Authorization Failed</h1>
 			<p>Error: %s</p>
 			</body></html>
-		`, errMsg)))
+		`, html.EscapeString(errMsg))))
 	} else {
 		w.WriteHeader(404)
 	}
@@ -304,7 +439,13 @@ func (s *OAuthCallbackServer) handleCallback(w http.ResponseWriter, r *http.Requ
 }
 
 func (s *OAuthCallbackServer) start() {
-	go s.server.ListenAndServe()
+	go s.server.Serve(s.listener)
+}
+
+// close releases the listener without waiting for a callback
+func (s *OAuthCallbackServer) close() {
+	s.server.Close()
+	s.listener.Close()
 }
 
 func (s *OAuthCallbackServer) waitForCallback(timeout time.Duration) {
@@ -319,6 +460,206 @@ func (s *OAuthCallbackServer) waitForCallback(timeout time.Duration) {
 	s.server.Shutdown(context.Background())
 }
 
+// authRequestParams builds the authorization URL query. Extra params from
+// oauth.extra_auth_params are added last and win over the built-in ones.
+func authRequestParams(clientID, redirect, state, codeChallenge, resource, scope string, extra map[string]string) url.Values {
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {redirect},
+		"state":                 {state},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+		"resource":              {resource},
+	}
+	if scope != "" {
+		params.Set("scope", scope)
+	}
+	mergeParams(params, extra)
+	return params
+}
+
+// tokenRequestParams builds the authorization_code token request body,
+// including oauth.extra_token_params
+func tokenRequestParams(code, redirect, clientID, clientSecret, codeVerifier string, extra map[string]string) url.Values {
+	params := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirect},
+		"client_id":     {clientID},
+		"code_verifier": {codeVerifier},
+	}
+	if clientSecret != "" {
+		params.Set("client_secret", clientSecret)
+	}
+	mergeParams(params, extra)
+	return params
+}
+
+// mergeParams sets each extra parameter, replacing any existing value
+func mergeParams(params url.Values, extra map[string]string) {
+	for k, v := range extra {
+		params.Set(k, v)
+	}
+}
+
+// refreshTokenHint explains how to get a refresh token when the server
+// didn't issue one, so the user doesn't have to re-run --auth on every expiry
+func refreshTokenHint(scope string, supported []string) string {
+	hint := "Note: no refresh token was issued; you will need to run --auth again when this token expires."
+	if slices.Contains(strings.Fields(scope), "offline_access") {
+		return hint
+	}
+	if slices.Contains(supported, "offline_access") {
+		return hint + ` The server supports the "offline_access" scope: add it to oauth.scope to get a refresh token.`
+	}
+	return hint + ` Many servers only issue one for the "offline_access" scope (oauth.scope) or a parameter such as access_type=offline (oauth.extra_auth_params).`
+}
+
+// readPastedCode prompts for the authorization result in manual mode. The
+// user may paste the whole URL the browser was redirected to (code, state and
+// iss are read from its query) or just the code.
+func readPastedCode(r io.Reader, w io.Writer) (code, state, iss string, err error) {
+	fmt.Fprint(w, "Paste the URL you were redirected to (or just the code): ")
+	line, err := bufio.NewReader(r).ReadString('\n')
+	line = strings.TrimSpace(line)
+	if line == "" {
+		if err == nil || err == io.EOF {
+			err = fmt.Errorf("no authorization code entered")
+		}
+		return "", "", "", err
+	}
+
+	if !strings.Contains(line, "?") && !strings.Contains(line, "code=") {
+		return line, "", "", nil
+	}
+
+	query := line
+	if i := strings.Index(line, "?"); i >= 0 {
+		query = line[i+1:]
+	}
+	values, err := url.ParseQuery(strings.SplitN(query, "#", 2)[0])
+	if err != nil {
+		return "", "", "", fmt.Errorf("could not parse pasted URL: %w", err)
+	}
+	if errMsg := values.Get("error"); errMsg != "" {
+		return "", "", "", fmt.Errorf("authorization error: %s", errMsg)
+	}
+	if values.Get("code") == "" {
+		return "", "", "", fmt.Errorf("no code in pasted URL")
+	}
+	return values.Get("code"), values.Get("state"), values.Get("iss"), nil
+}
+
+// checkIssuer validates the iss callback parameter against the discovered
+// issuer (RFC 9207), so a code minted by a different authorization server
+// (a mix-up attack) is never sent to this one's token endpoint
+func checkIssuer(discovery *OAuthDiscovery, iss string) error {
+	if discovery.Issuer == "" {
+		return nil
+	}
+	if iss == "" {
+		if discovery.IssRequired {
+			return fmt.Errorf("authorization response is missing iss, which %s always sends - possible mix-up attack", discovery.Issuer)
+		}
+		return nil
+	}
+	if iss != discovery.Issuer {
+		return fmt.Errorf("issuer mismatch: expected %s, got %s - possible mix-up attack", discovery.Issuer, iss)
+	}
+	return nil
+}
+
+// revokeToken revokes one token at the auth server (RFC 7009)
+func revokeToken(revocationURL, token, tokenTypeHint, clientID, clientSecret string) error {
+	data := url.Values{
+		"token":           {token},
+		"token_type_hint": {tokenTypeHint},
+	}
+	if clientID != "" {
+		data.Set("client_id", clientID)
+	}
+	if clientSecret != "" {
+		data.Set("client_secret", clientSecret)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	req, err := http.NewRequest("POST", revocationURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%d - %s", resp.StatusCode, strings.TrimSpace(string(bodyBytes)))
+	}
+	return nil
+}
+
+// LogoutServer revokes a server's tokens at its auth server and removes them
+// locally. Revocation is best effort: without a revocation endpoint, or if
+// the server refuses, the token is still deleted and the reason is returned
+// as a warning.
+func LogoutServer(serverName string, serverConfig ServerConfig) (revoked bool, warning string, err error) {
+	tokens, err := LoadTokens()
+	if err != nil {
+		return false, "", err
+	}
+	tokenData, ok := tokens[serverName]
+	if !ok {
+		return false, "", codedErrorf(ErrNotFound, "No OAuth token stored for '%s'", serverName)
+	}
+
+	revocationURL := tokenData.RevocationURL
+	var clientID, clientSecret string
+	if serverConfig.OAuth != nil {
+		if serverConfig.OAuth.RevocationURL != "" {
+			revocationURL = serverConfig.OAuth.RevocationURL
+		}
+		clientID = serverConfig.OAuth.ClientID
+		clientSecret = serverConfig.OAuth.ClientSecret
+	}
+	if clientID == "" {
+		regs, _ := LoadRegistrations()
+		if reg, ok := regs[serverName]; ok {
+			clientID = reg.ClientID
+			clientSecret = reg.ClientSecret
+		}
+	}
+
+	if revocationURL == "" {
+		warning = "server has no revocation endpoint; token deleted locally only"
+	} else {
+		// Refresh token first: revoking it usually kills its access tokens too
+		for _, t := range []struct{ token, hint string }{
+			{tokenData.RefreshToken, "refresh_token"},
+			{tokenData.AccessToken, "access_token"},
+		} {
+			if t.token == "" {
+				continue
+			}
+			if err := revokeToken(revocationURL, t.token, t.hint, clientID, clientSecret); err != nil {
+				warning = fmt.Sprintf("revoking the %s failed (%v); token deleted locally only", t.hint, err)
+				break
+			}
+		}
+		revoked = warning == ""
+	}
+
+	delete(tokens, serverName)
+	if err := SaveTokens(tokens); err != nil {
+		return revoked, warning, fmt.Errorf("failed to save tokens: %w", err)
+	}
+	return revoked, warning, nil
+}
+
 // openBrowser opens a URL in the default browser
 func openBrowser(url string) error {
 	var cmd *exec.Cmd
@@ -354,6 +695,7 @@ func DoOAuthFlow(serverName string, serverConfig ServerConfig) error {
 			AuthURL:         serverConfig.OAuth.AuthURL,
 			TokenURL:        serverConfig.OAuth.TokenURL,
 			RegistrationURL: serverConfig.OAuth.RegistrationURL,
+			RevocationURL:   serverConfig.OAuth.RevocationURL,
 			Scopes:          serverConfig.OAuth.Scopes,
 			Resource:        serverConfig.URL,
 		}
@@ -373,6 +715,61 @@ func DoOAuthFlow(serverName string, serverConfig ServerConfig) error {
 		scope = strings.Join(discovery.Scopes, " ")
 	}
 
+	// Bind the callback first: the redirect URI depends on the port we get.
+	// An override URI is served as-is (something must forward it here), and
+	// manual mode runs no server at all.
+	port := callbackPort
+	override := ""
+	if serverConfig.OAuth != nil {
+		if serverConfig.OAuth.CallbackPort != nil {
+			port = *serverConfig.OAuth.CallbackPort
+		}
+		override = serverConfig.OAuth.RedirectURI
+	}
+
+	var callbackServer *OAuthCallbackServer
+	var redirect string
+	if override == ManualRedirect {
+		if port == 0 {
+			port = callbackPort
+		}
+		redirect = callbackRedirectURI(port)
+	} else {
+		callbackPath := "/callback"
+		if override != "" {
+			u, err := url.Parse(override)
+			if err != nil {
+				return fmt.Errorf("invalid oauth redirect_uri: %w", err)
+			}
+			if p, err := strconv.Atoi(u.Port()); err == nil {
+				port = p
+			} else if port == 0 {
+				port = callbackPort
+			}
+			if u.Path != "" {
+				callbackPath = u.Path
+			}
+		}
+
+		callbackServer, err = newOAuthCallbackServer(port)
+		if err != nil {
+			return err
+		}
+		defer callbackServer.close()
+		callbackServer.discovery = discovery
+
+		redirect = callbackServer.redirectURI()
+		if override != "" {
+			if callbackServer.port != port {
+				return fmt.Errorf("redirect URI %s needs port %d, which is in use", override, port)
+			}
+			if callbackPath != "/callback" {
+				callbackServer.mux.HandleFunc(callbackPath, callbackServer.handleCallback)
+			}
+			redirect = override
+		}
+	}
+
 	// Get or create client credentials
 	var clientID, clientSecret string
 	if serverConfig.OAuth != nil {
@@ -381,17 +778,24 @@ func DoOAuthFlow(serverName string, serverConfig ServerConfig) error {
 	}
 
 	if clientID == "" {
-		// Check for saved registration
+		// Check for saved registration. One made for a different redirect
+		// URI is only reused if we can't register again.
 		regs, _ := LoadRegistrations()
 		if reg, ok := regs[serverName]; ok {
-			clientID = reg.ClientID
-			clientSecret = reg.ClientSecret
+			registered := reg.RedirectURI
+			if registered == "" {
+				registered = redirectURI
+			}
+			if registered == redirect || discovery.RegistrationURL == "" {
+				clientID = reg.ClientID
+				clientSecret = reg.ClientSecret
+			}
 		}
 	}
 
 	if clientID == "" && discovery.RegistrationURL != "" {
 		// Try dynamic registration
-		reg, err := doDynamicClientRegistration(discovery.RegistrationURL, redirectURI, scope)
+		reg, err := doDynamicClientRegistration(discovery.RegistrationURL, redirect, scope)
 		if err != nil {
 			fmt.Printf("Dynamic registration failed: %v\n", err)
 		} else {
@@ -417,59 +821,64 @@ func DoOAuthFlow(serverName string, serverConfig ServerConfig) error {
 		return err
 	}
 
-	// Build auth URL
-	authParams := url.Values{
-		"response_type":         {"code"},
-		"client_id":             {clientID},
-		"redirect_uri":          {redirectURI},
-		"state":                 {state},
-		"code_challenge":        {codeChallenge},
-		"code_challenge_method": {"S256"},
-		"resource":              {discovery.Resource},
-	}
-	if scope != "" {
-		authParams.Set("scope", scope)
+	var extraAuth, extraToken map[string]string
+	if serverConfig.OAuth != nil {
+		extraAuth = serverConfig.OAuth.ExtraAuthParams
+		extraToken = serverConfig.OAuth.ExtraTokenParams
 	}
 
+	// Build auth URL
+	authParams := authRequestParams(clientID, redirect, state, codeChallenge, discovery.Resource, scope, extraAuth)
 	fullAuthURL := discovery.AuthURL + "?" + authParams.Encode()
 
-	// Start callback server
-	callbackServer := newOAuthCallbackServer()
-	callbackServer.start()
+	var authCode string
+	if callbackServer == nil {
+		fmt.Println("Open this URL in a browser on any machine and authorize:")
+		fmt.Println(fullAuthURL)
+		code, returnedState, iss, err := readPastedCode(os.Stdin, os.Stdout)
+		if err != nil {
+			return err
+		}
+		// A bare code carries no state or issuer; a pasted URL must match
+		if returnedState != "" {
+			if returnedState != state {
+				return fmt.Errorf("state mismatch - possible CSRF attack")
+			}
+			if err := checkIssuer(discovery, iss); err != nil {
+				return err
+			}
+		}
+		authCode = code
+	} else {
+		// Start callback server
+		callbackServer.start()
 
-	// Open browser
-	fmt.Println("Opening browser for authorization...")
-	fmt.Printf("If browser doesn't open, visit: %s\n", fullAuthURL)
-	openBrowser(fullAuthURL)
+		// Open browser
+		fmt.Println("Opening browser for authorization...")
+		fmt.Printf("If browser doesn't open, visit: %s\n", fullAuthURL)
+		openBrowser(fullAuthURL)
 
-	// Wait for callback (2 minute timeout)
-	callbackServer.waitForCallback(2 * time.Minute)
+		// Wait for callback (2 minute timeout)
+		callbackServer.waitForCallback(2 * time.Minute)
 
-	if callbackServer.err != "" {
-		return fmt.Errorf("authorization error: %s", callbackServer.err)
-	}
+		if callbackServer.err != "" {
+			return fmt.Errorf("authorization error: %s", callbackServer.err)
+		}
 
-	if callbackServer.authCode == "" {
-		return fmt.Errorf("authorization timed out or was cancelled")
-	}
+		if callbackServer.authCode == "" {
+			return fmt.Errorf("authorization timed out or was cancelled")
+		}
 
-	if callbackServer.state != state {
-		return fmt.Errorf("state mismatch - possible CSRF attack")
+		if callbackServer.state != state {
+			return fmt.Errorf("state mismatch - possible CSRF attack")
+		}
+		authCode = callbackServer.authCode
 	}
 
 	// Exchange code for token
 	fmt.Println("Exchanging authorization code for token...")
 
-	tokenData := url.Values{
-		"grant_type":    {"authorization_code"},
-		"code":          {callbackServer.authCode},
-		"redirect_uri":  {redirectURI},
-		"client_id":     {clientID},
-		"code_verifier": {codeVerifier},
-	}
-	if clientSecret != "" {
-		tokenData.Set("client_secret", clientSecret)
-	}
+	tokenData := tokenRequestParams(authCode, redirect, clientID, clientSecret, codeVerifier, extraToken)
 
 	client := &http.Client{Timeout: 30 * time.Second}
 	req, _ := http.NewRequest("POST", discovery.TokenURL, strings.NewReader(tokenData.Encode()))
@@ -496,6 +905,11 @@ func DoOAuthFlow(serverName string, serverConfig ServerConfig) error {
 		tokenResp.ExpiresAt = float64(time.Now().Unix()) + float64(tokenResp.ExpiresIn)
 	}
 
+	if tokenResp.RefreshToken == "" {
+		fmt.Println(refreshTokenHint(scope, discovery.Scopes))
+	}
+	tokenResp.RevocationURL = discovery.RevocationURL
+
 	// Save token
 	tokens, _ := LoadTokens()
 	if tokens == nil {
@@ -3,9 +3,14 @@ package main
 import (
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGeneratePKCE(t *testing.T) {
@@ -133,7 +138,11 @@ func TestOpenBrowser(t *testing.T) {
 }
 
 func TestNewOAuthCallbackServer(t *testing.T) {
-	server := newOAuthCallbackServer()
+	server, err := newOAuthCallbackServer(0)
+	if err != nil {
+		t.Fatalf("newOAuthCallbackServer failed: %v", err)
+	}
+	defer server.close()
 
 	if server == nil {
 		t.Fatal("Expected server to be created")
@@ -148,8 +157,64 @@ func TestNewOAuthCallbackServer(t *testing.T) {
 	}
 }
 
+func TestNewOAuthCallbackServer_ChosenPort(t *testing.T) {
+	// Find a free port, release it, then ask for it explicitly
+	probe, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := probe.Addr().(*net.TCPAddr).Port
+	probe.Close()
+
+	server, err := newOAuthCallbackServer(port)
+	if err != nil {
+		t.Fatalf("newOAuthCallbackServer failed: %v", err)
+	}
+	defer server.close()
+
+	if server.port != port {
+		t.Errorf("Expected port %d, got %d", port, server.port)
+	}
+	if want := fmt.Sprintf("http://localhost:%d/callback", port); server.redirectURI() != want {
+		t.Errorf("Expected redirect URI %s, got %s", want, server.redirectURI())
+	}
+}
+
+func TestNewOAuthCallbackServer_BusyPort(t *testing.T) {
+	busy, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	port := busy.Addr().(*net.TCPAddr).Port
+
+	server, err := newOAuthCallbackServer(port)
+	if err != nil {
+		t.Fatalf("newOAuthCallbackServer failed: %v", err)
+	}
+	defer server.close()
+
+	if server.port == port || server.port == 0 {
+		t.Errorf("Expected a different free port than busy %d, got %d", port, server.port)
+	}
+
+	server.start()
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/callback?code=abc&state=xyz", server.port))
+	if err != nil {
+		t.Fatalf("Callback request failed: %v", err)
+	}
+	resp.Body.Close()
+	if server.authCode != "abc" {
+		t.Errorf("Expected auth code from callback, got %q", server.authCode)
+	}
+}
+
 func TestOAuthCallbackServer_HandleCallback_Success(t *testing.T) {
-	server := newOAuthCallbackServer()
+	server, err := newOAuthCallbackServer(0)
+	if err != nil {
+		t.Fatalf("newOAuthCallbackServer failed: %v", err)
+	}
+	defer server.close()
 
 	// Create test request
 	req := httptest.NewRequest("GET", "/callback?code=test-auth-code&state=test-state", nil)
@@ -176,7 +241,11 @@ func TestOAuthCallbackServer_HandleCallback_Success(t *testing.T) {
 }
 
 func TestOAuthCallbackServer_HandleCallback_Error(t *testing.T) {
-	server := newOAuthCallbackServer()
+	server, err := newOAuthCallbackServer(0)
+	if err != nil {
+		t.Fatalf("newOAuthCallbackServer failed: %v", err)
+	}
+	defer server.close()
 
 	req := httptest.NewRequest("GET", "/callback?error=access_denied&error_description=User+denied", nil)
 	w := httptest.NewRecorder()
@@ -198,7 +267,11 @@ func TestOAuthCallbackServer_HandleCallback_Error(t *testing.T) {
 }
 
 func TestOAuthCallbackServer_HandleCallback_NoParams(t *testing.T) {
-	server := newOAuthCallbackServer()
+	server, err := newOAuthCallbackServer(0)
+	if err != nil {
+		t.Fatalf("newOAuthCallbackServer failed: %v", err)
+	}
+	defer server.close()
 
 	req := httptest.NewRequest("GET", "/callback", nil)
 	w := httptest.NewRecorder()
@@ -211,6 +284,150 @@ func TestOAuthCallbackServer_HandleCallback_NoParams(t *testing.T) {
 	}
 }
 
+func TestAuthRequestParams_Extra(t *testing.T) {
+	params := authRequestParams("client", redirectURI, "state", "challenge", "https://mcp.example.com", "read",
+		map[string]string{"audience": "https://api.example.com", "prompt": "consent"})
+
+	authURL, err := url.Parse("https://auth.example.com/authorize?" + params.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := authURL.Query()
+	if query.Get("audience") != "https://api.example.com" || query.Get("prompt") != "consent" {
+		t.Errorf("Expected extra params in auth URL, got %s", authURL)
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("scope") != "read" {
+		t.Errorf("Expected built-in params to be kept, got %s", authURL)
+	}
+}
+
+func TestTokenRequestParams_Extra(t *testing.T) {
+	params := tokenRequestParams("code", redirectURI, "client", "", "verifier", map[string]string{"audience": "api"})
+
+	if params.Get("audience") != "api" {
+		t.Errorf("Expected audience in token params, got %v", params)
+	}
+	if params.Get("grant_type") != "authorization_code" || params.Get("code_verifier") != "verifier" {
+		t.Errorf("Expected authorization_code grant, got %v", params)
+	}
+	if params.Has("client_secret") {
+		t.Error("Expected no client_secret for a public client")
+	}
+}
+
+func TestRefreshTokenHint(t *testing.T) {
+	if hint := refreshTokenHint("read", []string{"read", "offline_access"}); !strings.Contains(hint, "add it to oauth.scope") {
+		t.Errorf("Expected offline_access suggestion, got %q", hint)
+	}
+	if hint := refreshTokenHint("read offline_access", []string{"offline_access"}); strings.Contains(hint, "oauth.scope") {
+		t.Errorf("Expected no scope suggestion when offline_access was requested, got %q", hint)
+	}
+	if hint := refreshTokenHint("", nil); !strings.Contains(hint, "extra_auth_params") {
+		t.Errorf("Expected generic guidance, got %q", hint)
+	}
+}
+
+func TestReadPastedCode(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantCode  string
+		wantState string
+		wantErr   bool
+	}{
+		{"full URL", "http://localhost:8085/callback?code=abc123&state=xyz&iss=https%3A%2F%2Fauth.example.com\n", "abc123", "xyz", false},
+		{"query only", "code=abc123&state=xyz\n", "abc123", "xyz", false},
+		{"bare code", "  abc123==  \n", "abc123==", "", false},
+		{"no trailing newline", "abc123", "abc123", "", false},
+		{"error redirect", "http://localhost:8085/callback?error=access_denied\n", "", "", true},
+		{"URL without code", "http://localhost:8085/callback?state=xyz\n", "", "", true},
+		{"empty", "\n", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prompt strings.Builder
+			code, state, _, err := readPastedCode(strings.NewReader(tt.input), &prompt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readPastedCode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if code != tt.wantCode || state != tt.wantState {
+				t.Errorf("Got code %q state %q, want %q %q", code, state, tt.wantCode, tt.wantState)
+			}
+			if !strings.Contains(prompt.String(), "Paste") {
+				t.Errorf("Expected a prompt, got %q", prompt.String())
+			}
+		})
+	}
+}
+
+func TestOAuthCallbackServer_HandleCallback_Issuer(t *testing.T) {
+	discovery := &OAuthDiscovery{Issuer: "https://auth.example.com"}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantCode   string
+	}{
+		{"matching iss", "code=c&state=s&iss=https%3A%2F%2Fauth.example.com", http.StatusOK, "c"},
+		{"mismatched iss", "code=c&state=s&iss=https%3A%2F%2Fevil.example.com", http.StatusBadRequest, ""},
+		{"no iss", "code=c&state=s", http.StatusOK, "c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := newOAuthCallbackServer(0)
+			if err != nil {
+				t.Fatalf("newOAuthCallbackServer failed: %v", err)
+			}
+			defer server.close()
+			server.discovery = discovery
+			server.start()
+
+			resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/callback?%s", server.port, tt.query))
+			if err != nil {
+				t.Fatalf("Callback request failed: %v", err)
+			}
+			resp.Body.Close()
+			server.waitForCallback(time.Second)
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if server.authCode != tt.wantCode {
+				t.Errorf("Expected auth code %q, got %q", tt.wantCode, server.authCode)
+			}
+			if tt.wantCode == "" && !strings.Contains(server.err, "issuer mismatch") {
+				t.Errorf("Expected an issuer mismatch error, got %q", server.err)
+			}
+		})
+	}
+}
+
+func TestCheckIssuer_Required(t *testing.T) {
+	discovery := &OAuthDiscovery{Issuer: "https://auth.example.com", IssRequired: true}
+	if err := checkIssuer(discovery, ""); err == nil {
+		t.Error("Expected missing iss to be rejected when the server always sends it")
+	}
+	if err := checkIssuer(&OAuthDiscovery{}, "https://anything.example.com"); err != nil {
+		t.Errorf("Expected no check without a known issuer, got %v", err)
+	}
+}
+
+func TestOAuthConfigValidateRedirect(t *testing.T) {
+	for _, uri := range []string{"", ManualRedirect, "https://dev.example.com:8443/oauth/callback"} {
+		if err := (&OAuthConfig{RedirectURI: uri}).validateRedirect(); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", uri, err)
+		}
+	}
+	for _, uri := range []string{"localhost:8085/callback", "ftp://example.com/cb", "/callback"} {
+		if err := (&OAuthConfig{RedirectURI: uri}).validateRedirect(); err == nil {
+			t.Errorf("Expected %q to be rejected", uri)
+		}
+	}
+}
+
 func TestDiscoverOAuthEndpoints_ResourceMetadata(t *testing.T) {
 	// Create mock server with well-known endpoints
 	mux := http.NewServeMux()
@@ -242,6 +459,154 @@ func TestDiscoverOAuthEndpoints_ResourceMetadata(t *testing.T) {
 	// The test above verifies the basic server infrastructure works
 }
 
+func TestDiscoverOAuthEndpoints_ConcurrentLookups(t *testing.T) {
+	// Every candidate but one hangs until the client gives up. The fallback
+	// is only used once the preferred candidates time out, but they time out
+	// together rather than one after another.
+	hang := func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}
+
+	authMux := http.NewServeMux()
+	authMux.HandleFunc("/.well-known/oauth-authorization-server/tenant", hang)
+	authMux.HandleFunc("/.well-known/oauth-authorization-server", hang)
+	authServer := httptest.NewServer(authMux)
+	defer authServer.Close()
+	authMux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"authorization_endpoint": authServer.URL + "/authorize",
+			"token_endpoint":         authServer.URL + "/token",
+		})
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/oauth-protected-resource/mcp", hang)
+	mux.HandleFunc("/.well-known/oauth-protected-resource", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"authorization_servers": []string{authServer.URL + "/tenant"},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	oldTimeout, oldDeadline := wellKnownTimeout, discoveryDeadline
+	wellKnownTimeout, discoveryDeadline = time.Second, 10*time.Second
+	defer func() { wellKnownTimeout, discoveryDeadline = oldTimeout, oldDeadline }()
+
+	start := time.Now()
+	discovery, err := discoverOAuthEndpoints(server.URL + "/mcp")
+	if err != nil {
+		t.Fatalf("discoverOAuthEndpoints failed: %v", err)
+	}
+	// One timeout per lookup stage; trying candidates in turn would take three
+	if elapsed := time.Since(start); elapsed > 2500*time.Millisecond {
+		t.Errorf("Discovery took %v, expected hanging endpoints to time out together", elapsed)
+	}
+	if discovery.TokenURL != authServer.URL+"/token" {
+		t.Errorf("Expected token URL from openid-configuration, got %s", discovery.TokenURL)
+	}
+}
+
+// newOpenIDDiscoveryServer serves resource metadata without scopes and an
+// openid-configuration document with the given PKCE methods
+func newOpenIDDiscoveryServer(t *testing.T, methods []string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("/.well-known/oauth-protected-resource", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"authorization_servers": []string{server.URL},
+		})
+	})
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                           server.URL,
+			"authorization_endpoint":           server.URL + "/authorize",
+			"token_endpoint":                   server.URL + "/token",
+			"registration_endpoint":            server.URL + "/register",
+			"scopes_supported":                 []string{"openid", "profile", "offline_access"},
+			"code_challenge_methods_supported": methods,
+		})
+	})
+	return server
+}
+
+func TestDiscoverOAuthEndpoints_OpenIDConfiguration(t *testing.T) {
+	server := newOpenIDDiscoveryServer(t, []string{"plain", "S256"})
+
+	discovery, err := discoverOAuthEndpoints(server.URL)
+	if err != nil {
+		t.Fatalf("discoverOAuthEndpoints failed: %v", err)
+	}
+	if discovery.AuthURL != server.URL+"/authorize" || discovery.RegistrationURL != server.URL+"/register" {
+		t.Errorf("Unexpected endpoints: %+v", discovery)
+	}
+	if strings.Join(discovery.Scopes, " ") != "openid profile offline_access" {
+		t.Errorf("Expected scopes from auth server metadata, got %v", discovery.Scopes)
+	}
+	if discovery.Issuer != server.URL {
+		t.Errorf("Expected issuer %s, got %s", server.URL, discovery.Issuer)
+	}
+	if len(discovery.CodeChallengeMethods) != 2 {
+		t.Errorf("Expected code challenge methods, got %v", discovery.CodeChallengeMethods)
+	}
+}
+
+func TestDiscoverOAuthEndpoints_PlainPKCEOnly(t *testing.T) {
+	server := newOpenIDDiscoveryServer(t, []string{"plain"})
+
+	_, err := discoverOAuthEndpoints(server.URL)
+	if err == nil || !strings.Contains(err.Error(), "S256") {
+		t.Errorf("Expected S256 error, got %v", err)
+	}
+}
+
+func TestFetchFirstJSON_Deadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	oldDeadline := discoveryDeadline
+	discoveryDeadline = 100 * time.Millisecond
+	defer func() { discoveryDeadline = oldDeadline }()
+
+	start := time.Now()
+	doc, _ := fetchFirstJSON([]string{server.URL + "/a", server.URL + "/b"})
+	if doc != nil {
+		t.Errorf("Expected no document, got %v", doc)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the overall deadline to stop discovery, took %v", elapsed)
+	}
+}
+
+func TestFetchFirstJSON_PrefersEarlierURL(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/preferred", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		json.NewEncoder(w).Encode(map[string]any{"from": "preferred"})
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	mux.HandleFunc("/fallback", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"from": "fallback"})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	doc, foundAt := fetchFirstJSON([]string{server.URL + "/preferred", server.URL + "/fallback"})
+	if doc["from"] != "preferred" || foundAt != server.URL+"/preferred" {
+		t.Errorf("Expected the slower preferred URL to win, got %v from %s", doc, foundAt)
+	}
+
+	doc, foundAt = fetchFirstJSON([]string{server.URL + "/missing", server.URL + "/fallback"})
+	if doc["from"] != "fallback" || foundAt != server.URL+"/fallback" {
+		t.Errorf("Expected the fallback once the preferred URL failed, got %v from %s", doc, foundAt)
+	}
+}
+
 func TestDoDynamicClientRegistration(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
@@ -273,6 +638,10 @@ func TestDoDynamicClientRegistration(t *testing.T) {
 	if reg.ClientSecret != "registered-client-secret" {
 		t.Errorf("Expected client_secret 'registered-client-secret', got '%s'", reg.ClientSecret)
 	}
+
+	if reg.RedirectURI != redirectURI {
+		t.Errorf("Expected registration to record redirect URI %s, got '%s'", redirectURI, reg.RedirectURI)
+	}
 }
 
 func TestDoDynamicClientRegistration_Error(t *testing.T) {
@@ -490,7 +859,42 @@ func TestRefreshOAuthToken_Success(t *testing.T) {
 	}
 }
 
+func TestRefreshOAuthToken_ExtraTokenParams(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "new-access-token"})
+	}))
+	defer server.Close()
+
+	config := ServerConfig{
+		URL: "https://example.com",
+		OAuth: &OAuthConfig{
+			TokenURL:         server.URL,
+			ClientID:         "test-client",
+			ExtraTokenParams: map[string]string{"audience": "https://api.example.com"},
+		},
+	}
+
+	if _, err := RefreshOAuthToken("test-server", config, TokenData{RefreshToken: "r+t/="}); err != nil {
+		t.Fatalf("RefreshOAuthToken failed: %v", err)
+	}
+	if form.Get("audience") != "https://api.example.com" {
+		t.Errorf("Expected audience in token POST body, got %v", form)
+	}
+	if form.Get("refresh_token") != "r+t/=" || form.Get("grant_type") != "refresh_token" {
+		t.Errorf("Expected escaped refresh token grant, got %v", form)
+	}
+}
+
 func TestRefreshOAuthToken_Failure(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(401)
 		w.Write([]byte(`{"error": "invalid_grant"}`))
@@ -511,6 +915,216 @@ func TestRefreshOAuthToken_Failure(t *testing.T) {
 	}
 }
 
+func TestRefreshOAuthToken_RotatesRefreshToken(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	// Each refresh token works once and is replaced by the next
+	valid := "refresh-1"
+	rotations := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("refresh_token") != valid {
+			w.WriteHeader(400)
+			w.Write([]byte(`{"error": "invalid_grant"}`))
+			return
+		}
+		rotations++
+		valid = fmt.Sprintf("refresh-%d", rotations+1)
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  fmt.Sprintf("access-%d", rotations),
+			"refresh_token": valid,
+			"expires_in":    3600,
+		})
+	}))
+	defer server.Close()
+
+	config := ServerConfig{
+		URL:   "https://example.com",
+		OAuth: &OAuthConfig{TokenURL: server.URL},
+	}
+
+	tokenData := TokenData{RefreshToken: "refresh-1"}
+	for i := 1; i <= 2; i++ {
+		newData, err := refreshTokenData("test-server", config, tokenData)
+		if err != nil {
+			t.Fatalf("Refresh %d failed: %v", i, err)
+		}
+		if newData.RefreshToken != fmt.Sprintf("refresh-%d", i+1) {
+			t.Fatalf("Expected rotated refresh token refresh-%d, got %s", i+1, newData.RefreshToken)
+		}
+		tokenData = newData
+	}
+
+	tokens, _ := LoadTokens()
+	if tokens["test-server"].RefreshToken != "refresh-3" {
+		t.Errorf("Expected the latest rotated refresh token to be saved, got %s", tokens["test-server"].RefreshToken)
+	}
+}
+
+func TestRefreshOAuthToken_InvalidGrantClearsToken(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error": "invalid_grant", "error_description": "Token has been revoked"}`))
+	}))
+	defer server.Close()
+
+	if err := SaveTokens(map[string]TokenData{
+		"test-server":  {AccessToken: "old", RefreshToken: "revoked"},
+		"other-server": {AccessToken: "keep"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	config := ServerConfig{
+		URL:   "https://example.com",
+		OAuth: &OAuthConfig{TokenURL: server.URL},
+	}
+	_, err := RefreshOAuthToken("test-server", config, TokenData{RefreshToken: "revoked"})
+	if errorCodeOf(err, "") != ErrAuthExpired {
+		t.Fatalf("Expected AUTH_EXPIRED error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "mcpx --auth test-server") || !strings.Contains(err.Error(), "revoked") {
+		t.Errorf("Expected a re-auth instruction with the server's reason, got %q", err)
+	}
+
+	tokens, _ := LoadTokens()
+	if _, ok := tokens["test-server"]; ok {
+		t.Error("Expected the rejected token to be removed")
+	}
+	if tokens["other-server"].AccessToken != "keep" {
+		t.Error("Expected other servers' tokens to be kept")
+	}
+}
+
+func TestRefreshOAuthToken_InvalidGrantKeepsRotatedToken(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"error": "invalid_grant"}`))
+	}))
+	defer server.Close()
+
+	// Another process refreshed first: refresh-1 was rotated to refresh-2
+	if err := SaveTokens(map[string]TokenData{
+		"test-server": {AccessToken: "fresh", RefreshToken: "refresh-2"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	config := ServerConfig{
+		URL:   "https://example.com",
+		OAuth: &OAuthConfig{TokenURL: server.URL},
+	}
+	if _, err := RefreshOAuthToken("test-server", config, TokenData{RefreshToken: "refresh-1"}); errorCodeOf(err, "") != ErrAuthExpired {
+		t.Fatalf("Expected AUTH_EXPIRED error, got %v", err)
+	}
+
+	tokens, _ := LoadTokens()
+	if tokens["test-server"].AccessToken != "fresh" {
+		t.Errorf("Expected the other process's fresh token to be kept, got %+v", tokens["test-server"])
+	}
+}
+
+func TestLogoutServer_Revokes(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	var revoked []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("client_id") != "test-client" {
+			t.Errorf("Expected client_id in revocation request, got %v", r.PostForm)
+		}
+		revoked = append(revoked, r.PostForm.Get("token_type_hint")+":"+r.PostForm.Get("token"))
+	}))
+	defer server.Close()
+
+	if err := SaveTokens(map[string]TokenData{
+		"test-server":  {AccessToken: "access", RefreshToken: "refresh", RevocationURL: server.URL},
+		"other-server": {AccessToken: "keep"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	config := ServerConfig{URL: "https://example.com", OAuth: &OAuthConfig{ClientID: "test-client"}}
+	ok, warning, err := LogoutServer("test-server", config)
+	if err != nil {
+		t.Fatalf("LogoutServer failed: %v", err)
+	}
+	if !ok || warning != "" {
+		t.Errorf("Expected revocation without warning, got revoked=%v warning=%q", ok, warning)
+	}
+	if strings.Join(revoked, ",") != "refresh_token:refresh,access_token:access" {
+		t.Errorf("Expected both tokens revoked, got %v", revoked)
+	}
+
+	tokens, _ := LoadTokens()
+	if _, exists := tokens["test-server"]; exists {
+		t.Error("Expected token to be deleted locally")
+	}
+	if _, exists := tokens["other-server"]; !exists {
+		t.Error("Expected other servers' tokens to be kept")
+	}
+}
+
+func TestLogoutServer_NoRevocationEndpoint(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	if err := SaveTokens(map[string]TokenData{"test-server": {AccessToken: "access"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, warning, err := LogoutServer("test-server", ServerConfig{URL: "https://example.com"})
+	if err != nil {
+		t.Fatalf("LogoutServer failed: %v", err)
+	}
+	if revoked || !strings.Contains(warning, "locally") {
+		t.Errorf("Expected local-only deletion warning, got revoked=%v warning=%q", revoked, warning)
+	}
+	tokens, _ := LoadTokens()
+	if _, exists := tokens["test-server"]; exists {
+		t.Error("Expected token to be deleted locally")
+	}
+
+	if _, _, err := LogoutServer("test-server", ServerConfig{}); errorCodeOf(err, "") != ErrNotFound {
+		t.Errorf("Expected NOT_FOUND when no token is stored, got %v", err)
+	}
+}
+
+func TestLogoutServer_RevocationRefused(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(503)
+	}))
+	defer server.Close()
+
+	if err := SaveTokens(map[string]TokenData{"test-server": {AccessToken: "access"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	config := ServerConfig{URL: "https://example.com", OAuth: &OAuthConfig{RevocationURL: server.URL}}
+	revoked, warning, err := LogoutServer("test-server", config)
+	if err != nil {
+		t.Fatalf("LogoutServer failed: %v", err)
+	}
+	if revoked || !strings.Contains(warning, "503") {
+		t.Errorf("Expected a warning with the server's status, got revoked=%v warning=%q", revoked, warning)
+	}
+	tokens, _ := LoadTokens()
+	if _, exists := tokens["test-server"]; exists {
+		t.Error("Expected token to be deleted locally even when revocation fails")
+	}
+}
+
 func TestRefreshOAuthToken_PreservesRefreshToken(t *testing.T) {
 	_, cleanup := setupTestConfig(t)
 	defer cleanup()
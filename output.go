@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// outputPath is where responses are written (--output); "" or "-" is stdout
+var outputPath string
+
+// emit writes one response (or --format text result) to stdout or the
+// --output target. If the target can't be written, the failure is reported
+// on stderr and mcpx exits non-zero so scripts don't read a stale file.
+func emit(s string) {
+	if err := writeOutput(outputPath, []byte(s+"\n")); err != nil {
+		fmt.Fprintf(os.Stderr, "mcpx: failed to write --output %s: %v\n", outputPath, err)
+		os.Exit(1)
+	}
+}
+
+// writeOutput writes data to path, replacing its contents. Regular files are
+// replaced atomically, so a reader never sees a partial or previous result
+// mixed in; named pipes and devices can't be renamed over and are written
+// directly. An empty path or "-" writes to stdout.
+func writeOutput(path string, data []byte) error {
+	if path == "" || path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+
+	if info, err := os.Stat(path); err == nil && !info.Mode().IsRegular() {
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory", path)
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+
+	return writeFileAtomic(path, data, 0644)
+}
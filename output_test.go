@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestWriteOutput_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "result.json")
+
+	first, _ := json.MarshalIndent(okResponse(map[string]any{"tool": "list_tables", "rows": []int{1, 2, 3}}), "", "  ")
+	if err := writeOutput(path, append(first, '\n')); err != nil {
+		t.Fatalf("writeOutput failed: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if string(got) != string(first)+"\n" {
+		t.Errorf("expected %q, got %q", string(first)+"\n", got)
+	}
+
+	// A shorter second result replaces the first entirely
+	second := []byte("{}\n")
+	if err := writeOutput(path, second); err != nil {
+		t.Fatalf("writeOutput failed: %v", err)
+	}
+	got, _ = os.ReadFile(path)
+	if string(got) != "{}\n" {
+		t.Errorf("expected output truncated to %q, got %q", second, got)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("expected only the output file, found %d entries (temp file left behind?)", len(entries))
+	}
+}
+
+func TestWriteOutput_NamedPipe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "result.fifo")
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		t.Skipf("mkfifo not supported: %v", err)
+	}
+
+	read := make(chan []byte)
+	go func() {
+		data, _ := os.ReadFile(path)
+		read <- data
+	}()
+
+	if err := writeOutput(path, []byte("{\"ok\": true}\n")); err != nil {
+		t.Fatalf("writeOutput failed: %v", err)
+	}
+	if got := <-read; string(got) != "{\"ok\": true}\n" {
+		t.Errorf("expected result on the pipe, got %q", got)
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeNamedPipe == 0 {
+		t.Errorf("expected %s to still be a named pipe", path)
+	}
+}
+
+func TestWriteOutput_Directory(t *testing.T) {
+	if err := writeOutput(t.TempDir(), []byte("{}\n")); err == nil {
+		t.Error("expected an error writing output to a directory")
+	}
+}
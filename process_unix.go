@@ -0,0 +1,22 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// processAlive reports whether a process with the given PID exists. EPERM
+// means it exists but belongs to another user.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// terminateProcess asks pid to exit (SIGTERM)
+func terminateProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
+
+// killProcess kills pid outright (SIGKILL)
+func killProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGKILL)
+}
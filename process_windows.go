@@ -0,0 +1,32 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// processAlive reports whether a process with the given PID exists, which on
+// Windows means it can still be opened
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
+
+// terminateProcess ends pid. Windows has no SIGTERM, so this is the same as
+// killProcess.
+func terminateProcess(pid int) error {
+	return killProcess(pid)
+}
+
+// killProcess kills pid outright
+func killProcess(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	defer p.Release()
+	return p.Kill()
+}
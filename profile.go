@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// ProfileEnv selects the config profile when --profile isn't given
+const ProfileEnv = "MCPX_PROFILE"
+
+// DefaultProfile uses the flat ~/.mcpx layout
+const DefaultProfile = "default"
+
+// ActiveProfile is the profile the current paths point at
+var ActiveProfile = DefaultProfile
+
+// profilesRoot is the base mcpx directory that holds profiles/<name>
+var profilesRoot = filepath.Join(os.Getenv("HOME"), ".mcpx")
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// UseProfile points all mcpx paths (config, sessions, tokens, registrations,
+// daemon socket, PID and logs) at the named profile. The default profile
+// keeps the flat ~/.mcpx layout; others live under ~/.mcpx/profiles/<name>/,
+// so each profile runs its own daemon.
+func UseProfile(name string) error {
+	if name == "" {
+		name = DefaultProfile
+	}
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q (use letters, digits, '-' and '_')", name)
+	}
+
+	dir := profilesRoot
+	if name != DefaultProfile {
+		dir = filepath.Join(profilesRoot, "profiles", name)
+	}
+	setConfigDir(dir)
+	ActiveProfile = name
+	return nil
+}
+
+// setConfigDir recomputes every path that lives under the config directory
+func setConfigDir(dir string) {
+	ConfigDir = dir
+	ConfigFile = filepath.Join(dir, "servers.json")
+	SessionFile = filepath.Join(dir, "sessions.json")
+	TokensFile = filepath.Join(dir, "tokens.json")
+	RegFile = filepath.Join(dir, "registrations.json")
+	SocketPath = filepath.Join(dir, "daemon.sock")
+	PIDFile = filepath.Join(dir, "daemon.pid")
+	LogFile = filepath.Join(dir, "daemon.log")
+	LogsDir = filepath.Join(dir, "logs")
+	LocalState = filepath.Join(dir, "local.json")
+	ListingFile = filepath.Join(dir, "listings.json")
+	TrustedProjectsFile = filepath.Join(dir, "trusted-projects.json")
+}
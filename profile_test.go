@@ -0,0 +1,87 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useTestProfilesRoot points profiles at a temp dir and restores all paths afterwards
+func useTestProfilesRoot(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+
+	origRoot, origProfile, origDir := profilesRoot, ActiveProfile, ConfigDir
+	origPaths := []string{ConfigFile, SessionFile, TokensFile, RegFile, SocketPath, PIDFile, LogFile, LogsDir, LocalState}
+	profilesRoot = root
+	t.Cleanup(func() {
+		profilesRoot, ActiveProfile = origRoot, origProfile
+		setConfigDir(origDir)
+		ConfigFile, SessionFile, TokensFile, RegFile, SocketPath = origPaths[0], origPaths[1], origPaths[2], origPaths[3], origPaths[4]
+		PIDFile, LogFile, LogsDir, LocalState = origPaths[5], origPaths[6], origPaths[7], origPaths[8]
+	})
+	return root
+}
+
+func TestUseProfile_Paths(t *testing.T) {
+	root := useTestProfilesRoot(t)
+
+	if err := UseProfile(""); err != nil {
+		t.Fatalf("UseProfile failed: %v", err)
+	}
+	if ConfigFile != filepath.Join(root, "servers.json") {
+		t.Errorf("Expected default profile to use flat layout, got %s", ConfigFile)
+	}
+
+	if err := UseProfile("work"); err != nil {
+		t.Fatalf("UseProfile failed: %v", err)
+	}
+	workDir := filepath.Join(root, "profiles", "work")
+	for _, path := range []string{ConfigFile, SessionFile, TokensFile, RegFile, SocketPath} {
+		if !strings.HasPrefix(path, workDir+string(filepath.Separator)) {
+			t.Errorf("Expected %s under %s", path, workDir)
+		}
+	}
+	if keychainAccount() != "tokens-work" {
+		t.Errorf("Expected per-profile keychain account, got %s", keychainAccount())
+	}
+
+	if err := UseProfile("../escape"); err == nil {
+		t.Error("Expected invalid profile name to be rejected")
+	}
+}
+
+func TestUseProfile_Isolation(t *testing.T) {
+	useTestProfilesRoot(t)
+
+	UseProfile("work")
+	if err := SaveConfig(&Config{Servers: map[string]ServerConfig{"jira": {URL: "https://jira.example.com"}}}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	if err := SaveTokens(map[string]TokenData{"jira": {AccessToken: "work-token"}}); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+
+	UseProfile("personal")
+	if err := SaveConfig(&Config{Servers: map[string]ServerConfig{"notes": {URL: "https://notes.example.com"}}}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	config, _ := LoadConfig()
+	tokens, _ := LoadTokens()
+	if _, ok := config.Servers["jira"]; ok {
+		t.Error("Expected work server not visible in personal profile")
+	}
+	if len(tokens) != 0 {
+		t.Errorf("Expected no tokens in personal profile, got %v", tokens)
+	}
+
+	UseProfile("work")
+	config, _ = LoadConfig()
+	tokens, _ = LoadTokens()
+	if _, ok := config.Servers["notes"]; ok || len(config.Servers) != 1 {
+		t.Errorf("Expected only work servers, got %v", config.Servers)
+	}
+	if tokens["jira"].AccessToken != "work-token" {
+		t.Error("Expected work token preserved")
+	}
+}
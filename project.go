@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// projectWarnings remembers warnings already printed, since one command may
+// load the config several times
+var projectWarnings sync.Map
+
+// loadTrustedProjects returns the project configs trusted with
+// --trust-project: absolute path to the SHA-256 of the trusted content
+func loadTrustedProjects() (map[string]string, error) {
+	trusted := make(map[string]string)
+	data, err := os.ReadFile(TrustedProjectsFile)
+	if os.IsNotExist(err) {
+		return trusted, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &trusted); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", TrustedProjectsFile, err)
+	}
+	return trusted, nil
+}
+
+// TrustProjectConfig trusts the project config at path as it is now. Any
+// later edit to the file needs trusting again.
+func TrustProjectConfig(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return err
+	}
+	if _, err := decodeConfig(abs, data); err != nil {
+		return err
+	}
+
+	trusted, err := loadTrustedProjects()
+	if err != nil {
+		return err
+	}
+	trusted[abs] = projectHash(data)
+	out, err := json.MarshalIndent(trusted, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(ConfigDir, 0700); err != nil {
+		return err
+	}
+	return writeFileAtomic(TrustedProjectsFile, out, 0600)
+}
+
+// projectTrusted reports whether the project config at path was trusted
+// with exactly this content
+func projectTrusted(path string, data []byte) bool {
+	trusted, err := loadTrustedProjects()
+	if err != nil {
+		return false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	return trusted[abs] == projectHash(data)
+}
+
+func projectHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// overlayProject merges a project config's servers, default headers and
+// default server into config, project entries winning. A project file is
+// checked into a repo that may not be the user's, so until it is trusted it
+// can only add servers: it can't replace a global server (and so send that
+// server's stored credentials elsewhere), its servers can't run commands
+// (local, token_command, pre_call_command), and it can't redirect calls that
+// leave out the server by changing default_server. What was ignored is
+// returned as warnings.
+func overlayProject(config, project *Config, trusted bool) []string {
+	var warnings []string
+	for name, serverConfig := range project.Servers {
+		if !trusted {
+			if _, exists := config.Servers[name]; exists {
+				warnings = append(warnings, fmt.Sprintf("not replacing global server %q", name))
+				continue
+			}
+			var ignored []string
+			if serverConfig.Local != nil {
+				ignored = append(ignored, "local")
+				serverConfig.Local = nil
+			}
+			if serverConfig.TokenCommand != "" {
+				ignored = append(ignored, "token_command")
+				serverConfig.TokenCommand = ""
+			}
+			if serverConfig.PreCallCommand != "" {
+				ignored = append(ignored, "pre_call_command")
+				serverConfig.PreCallCommand = ""
+			}
+			for _, field := range ignored {
+				warnings = append(warnings, fmt.Sprintf("ignoring %s of server %q", field, name))
+			}
+		}
+		config.Servers[name] = serverConfig
+	}
+	for k, v := range project.DefaultHeaders {
+		if config.DefaultHeaders == nil {
+			config.DefaultHeaders = make(map[string]string)
+		}
+		config.DefaultHeaders[k] = v
+	}
+	if project.DefaultServer != "" {
+		if trusted {
+			config.DefaultServer = project.DefaultServer
+		} else {
+			warnings = append(warnings, fmt.Sprintf("ignoring default_server %q", project.DefaultServer))
+		}
+	}
+	return warnings
+}
+
+// warnUntrustedProject prints each warning about an untrusted project config
+// once per process
+func warnUntrustedProject(path string, warnings []string) {
+	for _, w := range warnings {
+		msg := fmt.Sprintf("warning: untrusted project config %s: %s (run mcpx --trust-project to allow)", path, w)
+		if _, seen := projectWarnings.LoadOrStore(msg, true); !seen {
+			fmt.Fprintln(os.Stderr, msg)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// redactedValue replaces secrets in printed output and exported bundles. An
+// import recognizes it as a secret still to be filled in.
+const redactedValue = "REDACTED"
+
+// showSecrets disables masking in printed output (--show-secrets)
+var showSecrets = false
+
+// secretValuePattern matches credentials embedded in free text: bearer/basic
+// authorization values and sensitive query parameters in URLs
+var secretValuePattern = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+|([?&][a-z_]*(?:token|secret|key|password|auth)[a-z_]*=)[^&\s"']+`)
+
+// redactServerConfig returns a copy of cfg with header secrets, OAuth client
+// secrets, sensitive env values and URL credentials replaced. It backs both
+// --servers output and --export bundles.
+func redactServerConfig(cfg ServerConfig) ServerConfig {
+	cfg.URL = redactURL(cfg.URL)
+	cfg.Headers = redactHeaders(cfg.Headers)
+
+	if cfg.OAuth != nil && cfg.OAuth.ClientSecret != "" {
+		oauth := *cfg.OAuth
+		oauth.ClientSecret = redactedValue
+		cfg.OAuth = &oauth
+	}
+
+	if cfg.Local != nil && len(cfg.Local.Env) > 0 {
+		local := *cfg.Local
+		local.Env = make([]string, len(cfg.Local.Env))
+		for i, kv := range cfg.Local.Env {
+			if k, v, ok := strings.Cut(kv, "="); ok && (isSensitiveKey(k) || isSecretValue(v)) {
+				kv = k + "=" + redactedValue
+			}
+			local.Env[i] = kv
+		}
+		cfg.Local = &local
+	}
+	return cfg
+}
+
+// Redact returns a copy of the server info with secrets masked for display
+func (s ServerInfo) Redact() ServerInfo {
+	if showSecrets {
+		return s
+	}
+	cfg := redactServerConfig(ServerConfig{URL: s.URL, Headers: s.Headers})
+	s.URL, s.Headers = cfg.URL, cfg.Headers
+	return s
+}
+
+// redactHeaders masks values of sensitive headers and any bearer/basic credentials
+func redactHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return headers
+	}
+	masked := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if isSensitiveKey(k) || isSecretValue(v) {
+			v = redactedValue
+		}
+		masked[k] = v
+	}
+	return masked
+}
+
+// isSecretValue reports whether a value looks like a credential regardless of its name
+func isSecretValue(value string) bool {
+	lower := strings.ToLower(strings.TrimSpace(value))
+	return strings.HasPrefix(lower, "bearer ") || strings.HasPrefix(lower, "basic ")
+}
+
+// redactURL masks userinfo passwords and sensitive query parameters
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || (u.User == nil && u.RawQuery == "") {
+		return raw
+	}
+
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), redactedValue)
+	}
+
+	if u.RawQuery != "" {
+		query := u.Query()
+		changed := false
+		for k := range query {
+			if isSensitiveKey(k) {
+				query.Set(k, redactedValue)
+				changed = true
+			}
+		}
+		if changed {
+			u.RawQuery = query.Encode()
+		}
+	}
+
+	// Keep the mask readable rather than percent-encoded
+	return strings.ReplaceAll(u.String(), url.QueryEscape(redactedValue), redactedValue)
+}
+
+// redactText masks credentials that leak into messages such as wrapped
+// transport errors, which quote request URLs
+func redactText(text string) string {
+	if showSecrets {
+		return text
+	}
+	return secretValuePattern.ReplaceAllStringFunc(text, func(match string) string {
+		if i := strings.IndexAny(match, "=\t "); i >= 0 {
+			return match[:i+1] + redactedValue
+		}
+		return redactedValue
+	})
+}
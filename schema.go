@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// validateArguments checks tool-call arguments against the tool's inputSchema
+// before sending: required properties, basic JSON types, enum membership,
+// and unknown properties when additionalProperties is false. Nested schemas
+// are not checked; the server remains the final authority.
+func validateArguments(schema map[string]any, args map[string]any) error {
+	if schema == nil {
+		return nil
+	}
+	properties, _ := schema["properties"].(map[string]any)
+
+	var problems []string
+	required := requiredSet(schema)
+	requiredNames := make([]string, 0, len(required))
+	for name := range required {
+		requiredNames = append(requiredNames, name)
+	}
+	sort.Strings(requiredNames)
+	for _, name := range requiredNames {
+		if _, present := args[name]; !present {
+			problems = append(problems, fmt.Sprintf("missing required argument '%s'", name))
+		}
+	}
+
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := args[name]
+		prop, known := properties[name].(map[string]any)
+		if !known {
+			if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+				problems = append(problems, fmt.Sprintf("unknown argument '%s'", name))
+			}
+			continue
+		}
+
+		if types := schemaTypes(prop["type"]); len(types) > 0 && !matchesAnyType(value, types) {
+			problems = append(problems, fmt.Sprintf("argument '%s' must be %s, got %s",
+				name, strings.Join(types, " or "), jsonTypeName(value)))
+			continue
+		}
+		if enum, ok := prop["enum"].([]any); ok && len(enum) > 0 && !enumContains(enum, value) {
+			problems = append(problems, fmt.Sprintf("argument '%s' must be one of %s", name, formatEnum(enum)))
+		}
+	}
+
+	if len(problems) > 0 {
+		return codedErrorf(ErrSchemaError, "invalid arguments: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// findTool returns the named tool from a tool list, or nil
+func findTool(tools []Tool, name string) *Tool {
+	for i := range tools {
+		if tools[i].Name == name {
+			return &tools[i]
+		}
+	}
+	return nil
+}
+
+// schemaTypes normalizes a schema "type" (string or list of strings)
+func schemaTypes(t any) []string {
+	switch t := t.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func matchesAnyType(value any, types []string) bool {
+	for _, t := range types {
+		if matchesType(value, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesType reports whether a decoded JSON value has the given schema type
+func matchesType(value any, t string) bool {
+	switch t {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "number":
+		switch value.(type) {
+		case float64, int, int64:
+			return true
+		}
+		return false
+	case "integer":
+		switch v := value.(type) {
+		case int, int64:
+			return true
+		case float64:
+			return v == math.Trunc(v)
+		}
+		return false
+	}
+	return true // Unknown types are not checked
+}
+
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64, int, int64:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// ParameterInfo describes one property of a tool's inputSchema
+type ParameterInfo struct {
+	Name        string `json:"name"`
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+	Enum        []any  `json:"enum,omitempty"`
+	Default     any    `json:"default,omitempty"`
+}
+
+// ToolDescription is the --describe-tool view of a tool
+type ToolDescription struct {
+	Server      string           `json:"server"`
+	Tool        string           `json:"tool"`
+	Description string           `json:"description,omitempty"`
+	Annotations *ToolAnnotations `json:"annotations,omitempty"`
+	Parameters  []ParameterInfo  `json:"parameters"`
+	Example     map[string]any   `json:"example"`
+	InputSchema map[string]any   `json:"input_schema,omitempty"`
+}
+
+// describeTool builds a readable description of a tool's schema, with
+// required parameters listed first and an example arguments skeleton
+func describeTool(serverName string, tool Tool) ToolDescription {
+	properties, _ := tool.Parameters["properties"].(map[string]any)
+	required := requiredSet(tool.Parameters)
+
+	params := make([]ParameterInfo, 0, len(properties))
+	for name, p := range properties {
+		prop, _ := p.(map[string]any)
+		info := ParameterInfo{
+			Name:     name,
+			Type:     strings.Join(schemaTypes(prop["type"]), "|"),
+			Required: required[name],
+			Default:  prop["default"],
+		}
+		info.Description, _ = prop["description"].(string)
+		info.Enum, _ = prop["enum"].([]any)
+		params = append(params, info)
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i].Required != params[j].Required {
+			return params[i].Required
+		}
+		return params[i].Name < params[j].Name
+	})
+
+	return ToolDescription{
+		Server:      serverName,
+		Tool:        tool.Name,
+		Description: tool.Description,
+		Annotations: tool.Annotations,
+		Parameters:  params,
+		Example:     exampleArguments(tool.Parameters),
+		InputSchema: tool.Parameters,
+	}
+}
+
+// exampleArguments generates an arguments skeleton with a placeholder for
+// every property: the default or first enum value when the schema has one,
+// otherwise a zero value of the right type
+func exampleArguments(schema map[string]any) map[string]any {
+	properties, _ := schema["properties"].(map[string]any)
+	example := make(map[string]any, len(properties))
+	for name, p := range properties {
+		prop, _ := p.(map[string]any)
+		example[name] = exampleValue(name, prop)
+	}
+	return example
+}
+
+func exampleValue(name string, prop map[string]any) any {
+	if def, ok := prop["default"]; ok {
+		return def
+	}
+	if enum, ok := prop["enum"].([]any); ok && len(enum) > 0 {
+		return enum[0]
+	}
+
+	var propType string
+	if types := schemaTypes(prop["type"]); len(types) > 0 {
+		propType = types[0]
+	}
+	switch propType {
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	case "array":
+		return []any{}
+	case "object":
+		return exampleArguments(prop)
+	case "null":
+		return nil
+	default:
+		return "<" + name + ">"
+	}
+}
+
+// requiredSet returns the schema's required property names
+func requiredSet(schema map[string]any) map[string]bool {
+	required := make(map[string]bool)
+	if list, ok := schema["required"].([]any); ok {
+		for _, r := range list {
+			if name, ok := r.(string); ok {
+				required[name] = true
+			}
+		}
+	}
+	return required
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func queryToolSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{"type": "string"},
+			"limit": map[string]any{"type": "integer"},
+			"mode":  map[string]any{"type": "string", "enum": []any{"read", "write"}},
+		},
+		"required": []any{"query"},
+	}
+}
+
+func TestValidateArguments(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    map[string]any
+		wantErr string
+	}{
+		{"valid", map[string]any{"query": "SELECT 1", "limit": float64(5)}, ""},
+		{"missing required", map[string]any{"limit": float64(5)}, "missing required argument 'query'"},
+		{"wrong type", map[string]any{"query": float64(42)}, "argument 'query' must be string, got number"},
+		{"non-integer", map[string]any{"query": "q", "limit": 1.5}, "argument 'limit' must be integer"},
+		{"enum", map[string]any{"query": "q", "mode": "delete"}, "argument 'mode' must be one of read|write"},
+		{"unknown allowed by default", map[string]any{"query": "q", "extra": true}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateArguments(queryToolSchema(), tt.args)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if errorCodeOf(err, "") != ErrSchemaError {
+				t.Errorf("Expected SCHEMA_ERROR code, got %s", errorCodeOf(err, ""))
+			}
+		})
+	}
+}
+
+func TestValidateArguments_AdditionalPropertiesFalse(t *testing.T) {
+	schema := queryToolSchema()
+	schema["additionalProperties"] = false
+
+	err := validateArguments(schema, map[string]any{"query": "q", "qeury": "typo"})
+	if err == nil || !strings.Contains(err.Error(), "unknown argument 'qeury'") {
+		t.Errorf("Expected unknown argument error, got %v", err)
+	}
+}
+
+func TestValidateArguments_NoSchema(t *testing.T) {
+	if err := validateArguments(nil, map[string]any{"anything": 1}); err != nil {
+		t.Errorf("Expected no validation without schema, got %v", err)
+	}
+}
+
+func TestDescribeTool(t *testing.T) {
+	schema := queryToolSchema()
+	schema["required"] = []any{"query", "mode"}
+	tool := Tool{Name: "run", Description: "Run a query", Parameters: schema}
+
+	desc := describeTool("db", tool)
+
+	for _, name := range []string{"query", "mode"} {
+		if _, ok := desc.Example[name]; !ok {
+			t.Errorf("Expected example skeleton to include required property %q, got %v", name, desc.Example)
+		}
+	}
+	if desc.Example["mode"] != "read" {
+		t.Errorf("Expected enum property to use first enum value, got %v", desc.Example["mode"])
+	}
+	if desc.Example["query"] != "<query>" {
+		t.Errorf("Expected string placeholder, got %v", desc.Example["query"])
+	}
+
+	if len(desc.Parameters) != 3 {
+		t.Fatalf("Expected 3 parameters, got %d", len(desc.Parameters))
+	}
+	if !desc.Parameters[0].Required || !desc.Parameters[1].Required || desc.Parameters[2].Required {
+		t.Errorf("Expected required parameters first, got %+v", desc.Parameters)
+	}
+	if desc.Parameters[2].Name != "limit" || desc.Parameters[2].Type != "integer" {
+		t.Errorf("Unexpected optional parameter: %+v", desc.Parameters[2])
+	}
+
+	// The skeleton must itself pass validation
+	if err := validateArguments(schema, desc.Example); err != nil {
+		t.Errorf("Expected example to validate, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// findConcurrency bounds how many servers --find lists tools from at once
+const findConcurrency = 8
+
+// ToolMatch is a --find result
+type ToolMatch struct {
+	Server      string `json:"server"`
+	Tool        string `json:"tool"`
+	Description string `json:"description,omitempty"`
+	score       int
+}
+
+// ServerWarning notes a server skipped during a cross-server operation
+type ServerWarning struct {
+	Server string `json:"server"`
+	Error  string `json:"error"`
+}
+
+// Match ranks, best first
+const (
+	scoreExactName    = 100
+	scoreNamePrefix   = 75
+	scoreNameContains = 50
+	scoreDescription  = 25
+)
+
+// searchTools finds tools whose name or description contains keyword
+// (case-insensitive) across servers. Exact name matches rank first, then
+// name prefixes, name substrings, and description matches.
+func searchTools(keyword string, toolsByServer map[string][]Tool) []ToolMatch {
+	keyword = strings.ToLower(strings.TrimSpace(keyword))
+	matches := []ToolMatch{}
+	if keyword == "" {
+		return matches
+	}
+
+	for server, tools := range toolsByServer {
+		for _, tool := range tools {
+			score := matchScore(keyword, tool)
+			if score == 0 {
+				continue
+			}
+			matches = append(matches, ToolMatch{
+				Server:      server,
+				Tool:        tool.Name,
+				Description: tool.Description,
+				score:       score,
+			})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		if matches[i].Server != matches[j].Server {
+			return matches[i].Server < matches[j].Server
+		}
+		return matches[i].Tool < matches[j].Tool
+	})
+	return matches
+}
+
+func matchScore(keyword string, tool Tool) int {
+	name := strings.ToLower(tool.Name)
+	switch {
+	case name == keyword:
+		return scoreExactName
+	case strings.HasPrefix(name, keyword):
+		return scoreNamePrefix
+	case strings.Contains(name, keyword):
+		return scoreNameContains
+	case strings.Contains(strings.ToLower(tool.Description), keyword):
+		return scoreDescription
+	}
+	return 0
+}
+
+// fetchAllTools lists tools from every named server, at most concurrency at
+// a time, so one slow server doesn't hold up the rest. Servers that fail are
+// returned as warnings, sorted by name.
+func fetchAllTools(names []string, concurrency int, fetch func(name string) ([]Tool, error)) (map[string][]Tool, []ServerWarning) {
+	toolsByServer := make(map[string][]Tool)
+	var warnings []ServerWarning
+	var mu sync.Mutex
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			tools, err := fetch(name)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				warnings = append(warnings, ServerWarning{Server: name, Error: err.Error()})
+				return
+			}
+			toolsByServer[name] = tools
+		}(name)
+	}
+	wg.Wait()
+
+	sort.Slice(warnings, func(i, j int) bool { return warnings[i].Server < warnings[j].Server })
+	return toolsByServer, warnings
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSearchTools_Ranking(t *testing.T) {
+	toolsByServer := map[string][]Tool{
+		"supabase": {
+			{Name: "execute_sql", Description: "Run a SQL query"},
+			{Name: "list_tables", Description: "List tables"},
+		},
+		"warehouse": {
+			{Name: "query", Description: "Query the warehouse"},
+			{Name: "query_history", Description: "Past runs"},
+		},
+	}
+
+	matches := searchTools("QUERY", toolsByServer)
+
+	want := []struct{ server, tool string }{
+		{"warehouse", "query"},         // exact name
+		{"warehouse", "query_history"}, // name prefix
+		{"supabase", "execute_sql"},    // description
+	}
+	if len(matches) != len(want) {
+		t.Fatalf("Expected %d matches, got %+v", len(want), matches)
+	}
+	for i, w := range want {
+		if matches[i].Server != w.server || matches[i].Tool != w.tool {
+			t.Errorf("Match %d: expected %s/%s, got %s/%s", i, w.server, w.tool, matches[i].Server, matches[i].Tool)
+		}
+	}
+
+	if len(searchTools("  ", toolsByServer)) != 0 {
+		t.Error("Expected no matches for empty keyword")
+	}
+}
+
+func TestSearchTools_NoMatchesIsEmptyArray(t *testing.T) {
+	data, _ := json.Marshal(searchTools("nothing", map[string][]Tool{"s": {{Name: "query"}}}))
+	if string(data) != "[]" {
+		t.Errorf("Expected no matches to encode as [], got %s", data)
+	}
+}
+
+func TestFetchAllTools_Concurrent(t *testing.T) {
+	names := []string{"a", "b", "c", "d", "down"}
+	start := time.Now()
+	toolsByServer, warnings := fetchAllTools(names, 8, func(name string) ([]Tool, error) {
+		time.Sleep(100 * time.Millisecond)
+		if name == "down" {
+			return nil, fmt.Errorf("connection refused")
+		}
+		return []Tool{{Name: name + "_tool"}}, nil
+	})
+
+	// One at a time would take 500ms
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("Expected servers fetched concurrently, took %v", elapsed)
+	}
+	if len(toolsByServer) != 4 || toolsByServer["c"][0].Name != "c_tool" {
+		t.Errorf("Unexpected tools: %+v", toolsByServer)
+	}
+	if len(warnings) != 1 || warnings[0].Server != "down" {
+		t.Errorf("Expected one warning for down, got %+v", warnings)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serverLogBuffer is how many notifications/message entries a client keeps
+const serverLogBuffer = 200
+
+// serverLogLevels are the RFC 5424 severities accepted by logging/setLevel,
+// least severe first
+var serverLogLevels = []string{"debug", "info", "notice", "warning", "error", "critical", "alert", "emergency"}
+
+// parseServerLogLevel normalizes a logging/setLevel level name
+func parseServerLogLevel(level string) (string, error) {
+	level = strings.ToLower(strings.TrimSpace(level))
+	if level == "warn" {
+		level = "warning"
+	}
+	for _, l := range serverLogLevels {
+		if level == l {
+			return level, nil
+		}
+	}
+	return "", codedErrorf(ErrInvalidArgs, "invalid server log level %q (use %s)", level, strings.Join(serverLogLevels, ", "))
+}
+
+// SetLogLevel asks the server to send log messages at level and above. The
+// level is remembered and re-applied after every initialize, so it survives
+// reconnects and expired sessions. Requires the logging capability.
+func (c *MCPClient) SetLogLevel(ctx context.Context, level string) error {
+	level, err := parseServerLogLevel(level)
+	if err != nil {
+		return err
+	}
+
+	if err := c.InitializeContext(ctx); err != nil {
+		return err
+	}
+
+	if err := c.requireCapability("logging"); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.logLevel = level
+	c.mu.Unlock()
+
+	resp, err := c.requestWithReinit(ctx, "logging/setLevel", map[string]any{"level": level})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("logging/setLevel failed: %s", resp.Error.Message)
+	}
+	return nil
+}
+
+// applyLogLevel re-sends the remembered log level after a handshake. Failures
+// are logged rather than returned: a server ignoring the level shouldn't
+// break the session.
+func (c *MCPClient) applyLogLevel(ctx context.Context) {
+	c.mu.Lock()
+	level := c.logLevel
+	c.mu.Unlock()
+	if level == "" || c.requireCapability("logging") != nil {
+		return
+	}
+
+	resp, _, err := c.RequestContext(ctx, "logging/setLevel", map[string]any{"level": level})
+	if err == nil && resp.Error != nil {
+		err = fmt.Errorf("%s", resp.Error.Message)
+	}
+	if err != nil {
+		logger.Warn("failed to re-apply server log level", "server", c.serverName, "level", level, "error", err)
+	}
+}
+
+// ServerLogEntry is one notifications/message a server sent
+type ServerLogEntry struct {
+	Time   string `json:"time"`
+	Level  string `json:"level"`
+	Logger string `json:"logger,omitempty"`
+	Data   any    `json:"data"`
+}
+
+// newServerLogEntry extracts a log entry from a notifications/message
+func newServerLogEntry(n MCPNotification) ServerLogEntry {
+	params, _ := n.Params.(map[string]any)
+	level, _ := params["level"].(string)
+	name, _ := params["logger"].(string)
+	return ServerLogEntry{
+		Time:   time.Now().UTC().Format(time.RFC3339),
+		Level:  level,
+		Logger: name,
+		Data:   params["data"],
+	}
+}
+
+// logRing keeps the most recent serverLogBuffer log entries
+type logRing struct {
+	mu      sync.Mutex
+	entries []ServerLogEntry
+}
+
+// add appends an entry, dropping the oldest when full
+func (r *logRing) add(entry ServerLogEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.entries) >= serverLogBuffer {
+		copy(r.entries, r.entries[1:])
+		r.entries = r.entries[:len(r.entries)-1]
+	}
+	r.entries = append(r.entries, entry)
+}
+
+// snapshot returns a copy of the buffered entries, oldest first
+func (r *logRing) snapshot() []ServerLogEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ServerLogEntry{}, r.entries...)
+}
+
+// ServerLogs returns the most recent log messages the server sent over any
+// transport (WebSocket, the notification stream or SSE responses)
+func (c *MCPClient) ServerLogs() []ServerLogEntry {
+	return c.serverLogs.snapshot()
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newLoggingServer advertises the logging capability and records every
+// logging/setLevel request it receives
+func newLoggingServer(t *testing.T) (*httptest.Server, func() []MCPRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var setLevels []MCPRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ID == "" {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		result := map[string]any{}
+		switch req.Method {
+		case "initialize":
+			result = map[string]any{
+				"protocolVersion": ProtocolVersion,
+				"capabilities":    map[string]any{"logging": map[string]any{}, "tools": map[string]any{}},
+			}
+		case "logging/setLevel":
+			mu.Lock()
+			setLevels = append(setLevels, req)
+			mu.Unlock()
+		case "tools/list":
+			result = map[string]any{"tools": []any{}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []MCPRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]MCPRequest(nil), setLevels...)
+	}
+}
+
+func TestMCPClient_SetLogLevel(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server, setLevels := newLoggingServer(t)
+	client, err := NewMCPClient("api", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+
+	if err := client.SetLogLevel(context.Background(), "WARN"); err != nil {
+		t.Fatalf("SetLogLevel failed: %v", err)
+	}
+
+	reqs := setLevels()
+	if len(reqs) != 1 {
+		t.Fatalf("Expected 1 logging/setLevel request, got %d", len(reqs))
+	}
+	if reqs[0].JSONRPC != "2.0" || reqs[0].ID == "" {
+		t.Errorf("Expected a JSON-RPC request with an id, got %+v", reqs[0])
+	}
+	params, _ := reqs[0].Params.(map[string]any)
+	if params["level"] != "warning" {
+		t.Errorf("Expected level 'warning', got %v", params["level"])
+	}
+}
+
+func TestMCPClient_LogLevelReappliedAfterInitialize(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server, setLevels := newLoggingServer(t)
+	client, err := NewMCPClient("api", ServerConfig{URL: server.URL, SessionBased: true, LogLevel: "debug"})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.ListTools(); err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+	if reqs := setLevels(); len(reqs) != 1 {
+		t.Fatalf("Expected level applied after initialize, got %d requests", len(reqs))
+	}
+
+	// A reconnect runs a fresh handshake, which re-applies the level
+	client.Close()
+	if _, err := client.ListTools(); err != nil {
+		t.Fatalf("ListTools after reconnect failed: %v", err)
+	}
+	reqs := setLevels()
+	if len(reqs) != 2 {
+		t.Fatalf("Expected level re-applied after reconnect, got %d requests", len(reqs))
+	}
+	if params, _ := reqs[1].Params.(map[string]any); params["level"] != "debug" {
+		t.Errorf("Expected level 'debug', got %v", params["level"])
+	}
+}
+
+func TestMCPClient_SetLogLevel_Invalid(t *testing.T) {
+	client, err := NewMCPClient("api", ServerConfig{URL: "http://127.0.0.1:1"})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	err = client.SetLogLevel(context.Background(), "verbose")
+	if errorCodeOf(err, "") != ErrInvalidArgs {
+		t.Errorf("Expected INVALID_ARGS, got %v", err)
+	}
+}
+
+func TestMCPDaemon_SetLogLevel(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server, setLevels := newLoggingServer(t)
+	if err := SaveConfig(&Config{Servers: map[string]ServerConfig{"api": {URL: server.URL}}}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+
+	resp := daemon.handleCommand(DaemonCommand{Action: "set-log-level", Server: "api", Level: "error"})
+	if !resp.OK {
+		t.Fatalf("set-log-level failed: %+v", resp.Error)
+	}
+	if reqs := setLevels(); len(reqs) != 1 {
+		t.Errorf("Expected 1 logging/setLevel request, got %d", len(reqs))
+	}
+
+	resp = daemon.handleCommand(DaemonCommand{Action: "set-log-level", Server: "api"})
+	if resp.OK || resp.Error.Code != ErrInvalidArgs {
+		t.Errorf("Expected INVALID_ARGS without a level, got %+v", resp)
+	}
+}
+
+// logMessage is a notifications/message a server emits while handling a call
+var logMessage = MCPNotification{
+	JSONRPC: "2.0",
+	Method:  "notifications/message",
+	Params:  map[string]any{"level": "info", "logger": "db", "data": "query took 12ms"},
+}
+
+func TestMCPClient_ServerLogs_SSEResponse(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ID == "" {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		result := map[string]any{"protocolVersion": ProtocolVersion}
+		w.Header().Set("Content-Type", "text/event-stream")
+		if req.Method == "tools/call" {
+			// The log message arrives on the same stream, ahead of the result
+			note, _ := json.Marshal(logMessage)
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", note)
+			result = map[string]any{"content": []any{map[string]any{"type": "text", "text": "ok"}}}
+		}
+		resp, _ := json.Marshal(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", resp)
+	}))
+	defer server.Close()
+
+	client, err := NewMCPClient("db", ServerConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+
+	result, err := client.CallTool("query", nil)
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if _, ok := result["content"]; !ok {
+		t.Errorf("Expected the tool result, not the log notification, got %v", result)
+	}
+
+	logs := client.ServerLogs()
+	if len(logs) != 1 {
+		t.Fatalf("Expected 1 captured log entry, got %+v", logs)
+	}
+	if logs[0].Level != "info" || logs[0].Logger != "db" || logs[0].Data != "query took 12ms" {
+		t.Errorf("Unexpected log entry: %+v", logs[0])
+	}
+}
+
+func TestMCPClient_ServerLogs_WebSocket(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var req MCPRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			if req.ID == "" {
+				continue
+			}
+			if req.Method == "tools/call" {
+				conn.WriteJSON(logMessage)
+			}
+			conn.WriteJSON(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]any{"protocolVersion": ProtocolVersion}})
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewMCPClient("db", ServerConfig{
+		URL:       "ws" + strings.TrimPrefix(server.URL, "http"),
+		Transport: TransportWebSocket,
+	})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.CallTool("query", nil); err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+
+	// The notification is read before the response on the same connection
+	deadline := time.Now().Add(2 * time.Second)
+	for len(client.ServerLogs()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	logs := client.ServerLogs()
+	if len(logs) != 1 || logs[0].Data != "query took 12ms" {
+		t.Errorf("Expected captured log entry, got %+v", logs)
+	}
+}
+
+func TestLogRing_KeepsMostRecent(t *testing.T) {
+	var ring logRing
+	for i := 0; i < serverLogBuffer+5; i++ {
+		ring.add(ServerLogEntry{Data: i})
+	}
+	entries := ring.snapshot()
+	if len(entries) != serverLogBuffer {
+		t.Fatalf("Expected %d entries, got %d", serverLogBuffer, len(entries))
+	}
+	if entries[0].Data != 5 || entries[len(entries)-1].Data != serverLogBuffer+4 {
+		t.Errorf("Expected oldest entries dropped, got first=%v last=%v", entries[0].Data, entries[len(entries)-1].Data)
+	}
+}
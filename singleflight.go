@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+)
+
+// flightGroup coalesces concurrent requests with the same key: the first
+// caller starts the request and every caller waits for and shares its
+// result. The zero value is ready to use.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is one in-progress request and, once done is closed, its result
+type flight struct {
+	done    chan struct{}
+	value   any
+	err     error
+	waiters int                // Callers still waiting, guarded by flightGroup.mu
+	cancel  context.CancelFunc // Cancels the request once every waiter gives up
+}
+
+// Do runs fn for key unless a request with that key is already in flight,
+// in which case it waits for that one. shared reports whether the result
+// came from another caller's request. fn gets a context that keeps the first
+// caller's values but is cancelled only when every waiter has given up, so
+// one caller disconnecting doesn't fail the others. A caller that gives up
+// returns its ctx.Err().
+func (g *flightGroup) Do(ctx context.Context, key string, fn func(ctx context.Context) (any, error)) (value any, shared bool, err error) {
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = make(map[string]*flight)
+	}
+	f, shared := g.flights[key]
+	if !shared {
+		fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		g.flights[key] = f
+		go func() {
+			defer func() {
+				g.mu.Lock()
+				if g.flights[key] == f {
+					delete(g.flights, key)
+				}
+				g.mu.Unlock()
+				cancel()
+				close(f.done)
+			}()
+			f.value, f.err = fn(fctx)
+		}()
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.value, shared, f.err
+	case <-ctx.Done():
+	}
+
+	g.mu.Lock()
+	f.waiters--
+	if f.waiters == 0 {
+		// Nobody wants the result; later callers start a fresh request
+		f.cancel()
+		if g.flights[key] == f {
+			delete(g.flights, key)
+		}
+	}
+	g.mu.Unlock()
+	return nil, shared, ctx.Err()
+}
+
+// flightKey identifies a request by server, method and a hash of its
+// parameters. Map keys are sorted when encoded, so equal arguments hash the
+// same regardless of order.
+func flightKey(serverName, method string, params any) string {
+	data, _ := json.Marshal(params)
+	sum := sha256.Sum256(data)
+	return serverName + "\x00" + method + "\x00" + hex.EncodeToString(sum[:])
+}
+
+// idempotentTool reports whether a tool's annotations say calling it twice
+// has the same effect as calling it once: it is read-only or marked
+// idempotent
+func idempotentTool(tool *Tool) bool {
+	if tool == nil || tool.Annotations == nil {
+		return false
+	}
+	a := tool.Annotations
+	return (a.ReadOnlyHint != nil && *a.ReadOnlyHint) || (a.IdempotentHint != nil && *a.IdempotentHint)
+}
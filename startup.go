@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReadyFDEnv names the inherited file descriptor a --daemon-foreground
+// process writes its StartupReport to once its local servers have started
+const ReadyFDEnv = "MCPX_READY_FD"
+
+// defaultStartupTimeout is how long --daemon waits for local servers to start
+const defaultStartupTimeout = 60 * time.Second
+
+// StartupReport summarizes which local servers came up when the daemon started
+type StartupReport struct {
+	Started        []string          `json:"started"`
+	Failed         map[string]string `json:"failed,omitempty"`          // Server name -> error
+	RequiredFailed []string          `json:"required_failed,omitempty"` // Failed servers marked required
+}
+
+// newStartupReport builds a report from per-server start errors (nil for
+// servers that started)
+func newStartupReport(results map[string]error, servers map[string]ServerConfig) StartupReport {
+	report := StartupReport{Started: []string{}}
+	for name, err := range results {
+		if err == nil {
+			report.Started = append(report.Started, name)
+			continue
+		}
+		if report.Failed == nil {
+			report.Failed = make(map[string]string)
+		}
+		report.Failed[name] = err.Error()
+		if local := servers[name].Local; local != nil && local.Required {
+			report.RequiredFailed = append(report.RequiredFailed, name)
+		}
+	}
+	sort.Strings(report.Started)
+	sort.Strings(report.RequiredFailed)
+	return report
+}
+
+// requiredError describes the required servers that failed, or returns nil
+func (r StartupReport) requiredError() error {
+	if len(r.RequiredFailed) == 0 {
+		return nil
+	}
+	failures := make([]string, len(r.RequiredFailed))
+	for i, name := range r.RequiredFailed {
+		failures[i] = fmt.Sprintf("%s (%s)", name, r.Failed[name])
+	}
+	return fmt.Errorf("required local servers failed to start: %s", strings.Join(failures, "; "))
+}
+
+// signalReady sends the startup report to the waiting --daemon process
+func signalReady(f *os.File, report StartupReport) {
+	if f == nil {
+		return
+	}
+	json.NewEncoder(f).Encode(report)
+	f.Close()
+}
+
+// readStartupReport waits up to timeout for the daemon's startup report. An
+// EOF means the daemon exited before it finished starting.
+func readStartupReport(r io.Reader, timeout time.Duration) (StartupReport, error) {
+	type result struct {
+		report StartupReport
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var report StartupReport
+		err := json.NewDecoder(r).Decode(&report)
+		done <- result{report, err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return StartupReport{}, fmt.Errorf("daemon exited during startup; see %s", LogFile)
+		}
+		return res.report, nil
+	case <-time.After(timeout):
+		return StartupReport{}, fmt.Errorf("daemon did not finish starting local servers within %s; it is still starting, see %s", timeout, LogFile)
+	}
+}
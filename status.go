@@ -0,0 +1,104 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// Token states reported by the daemon status
+const (
+	TokenNone     = "none"
+	TokenValid    = "valid"
+	TokenExpiring = "expiring" // Within the refresh buffer; refreshed on next use
+	TokenExpired  = "expired"
+)
+
+// serverHealth is what the daemon last saw when talking to a server
+type serverHealth struct {
+	reachable   bool
+	lastCheck   time.Time
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// ServerStatus is one configured server's entry in the daemon status
+type ServerStatus struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`                // "local" or "remote"
+	Reachable   *bool  `json:"reachable,omitempty"` // Omitted until the daemon has talked to the server
+	LastCheck   string `json:"last_check,omitempty"`
+	LastError   string `json:"last_error,omitempty"`
+	LastErrorAt string `json:"last_error_at,omitempty"`
+	Token       string `json:"token"`
+	CachedTools int    `json:"cached_tools"` // Tools in the daemon's cache (0 if none cached)
+}
+
+// tokenState classifies a stored token without refreshing it
+func tokenState(tokenData TokenData, ok bool) string {
+	switch {
+	case !ok || tokenData.AccessToken == "":
+		return TokenNone
+	case tokenData.ExpiresAt > 0 && float64(time.Now().Unix()) > tokenData.ExpiresAt:
+		return TokenExpired
+	case tokenExpiring(tokenData.ExpiresAt):
+		return TokenExpiring
+	}
+	return TokenValid
+}
+
+// recordHealth notes the outcome of a request to a server. Only server-side
+// failures mark it unreachable, but any error becomes its last error.
+func (d *MCPDaemon) recordHealth(serverName string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	h, ok := d.health[serverName]
+	if !ok {
+		h = &serverHealth{}
+		d.health[serverName] = h
+	}
+	h.lastCheck = time.Now()
+	h.reachable = !isServerFailure(err)
+	if err != nil {
+		h.lastError = redactText(err.Error())
+		h.lastErrorAt = h.lastCheck
+	}
+}
+
+// serverStatus reports every configured server's health, token state and
+// cached tool count, sorted by name
+func (d *MCPDaemon) serverStatus() []ServerStatus {
+	tokens, _ := LoadTokens()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	statuses := make([]ServerStatus, 0, len(d.config.Servers))
+	for name, cfg := range d.config.Servers {
+		status := ServerStatus{Name: name, Type: "remote"}
+		if cfg.Local != nil {
+			status.Type = "local"
+		}
+
+		if h, ok := d.health[name]; ok {
+			reachable := h.reachable
+			status.Reachable = &reachable
+			status.LastCheck = h.lastCheck.UTC().Format(time.RFC3339)
+			if h.lastError != "" {
+				status.LastError = h.lastError
+				status.LastErrorAt = h.lastErrorAt.UTC().Format(time.RFC3339)
+			}
+		}
+
+		tokenData, ok := tokens[name]
+		status.Token = tokenState(tokenData, ok)
+
+		if cached, ok := d.toolsCache[name]; ok {
+			status.CachedTools = len(cached.Tools)
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// notificationBuffer is how many undelivered notifications a listener can
+// hold before further ones are dropped
+const notificationBuffer = 32
+
+// errNotPersistent is returned when a feature needs a long-lived connection
+var errNotPersistent = errors.New("subscriptions need a persistent connection (session_based or websocket transport)")
+
+// Subscribe asks the server to send notifications/resources/updated for uri.
+// Notifications arrive on channels returned by Events.
+func (c *MCPClient) Subscribe(uri string) error {
+	if !c.persistent {
+		return errNotPersistent
+	}
+	if err := c.Initialize(); err != nil {
+		return err
+	}
+	if err := c.requireResourceSubscribe(); err != nil {
+		return err
+	}
+
+	// Streamable HTTP servers push notifications on a separate GET stream
+	if c.ws == nil {
+		c.startNotificationStream()
+	}
+
+	return c.resourceRequest("resources/subscribe", uri)
+}
+
+// Unsubscribe stops update notifications for uri
+func (c *MCPClient) Unsubscribe(uri string) error {
+	return c.resourceRequest("resources/unsubscribe", uri)
+}
+
+// resourceRequest sends a subscribe/unsubscribe request for uri
+func (c *MCPClient) resourceRequest(method, uri string) error {
+	resp, err := c.requestWithReinit(context.Background(), method, map[string]any{"uri": uri})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("%s failed: %s", method, resp.Error.Message)
+	}
+	return nil
+}
+
+// requireResourceSubscribe checks the server advertised resources.subscribe
+func (c *MCPClient) requireResourceSubscribe() error {
+	if err := c.requireCapability("resources"); err != nil {
+		return err
+	}
+	if c.capabilities == nil {
+		return nil
+	}
+	resources, _ := c.capabilities["resources"].(map[string]any)
+	if subscribe, _ := resources["subscribe"].(bool); !subscribe {
+		return fmt.Errorf("server does not support resource subscriptions")
+	}
+	return nil
+}
+
+// Events registers a listener for server notifications. The returned cancel
+// function unregisters it; the channel is never closed.
+func (c *MCPClient) Events() (<-chan MCPNotification, func()) {
+	ch := make(chan MCPNotification, notificationBuffer)
+
+	c.listenersMu.Lock()
+	if c.listeners == nil {
+		c.listeners = make(map[chan MCPNotification]struct{})
+	}
+	c.listeners[ch] = struct{}{}
+	c.listenersMu.Unlock()
+
+	cancel := func() {
+		c.listenersMu.Lock()
+		delete(c.listeners, ch)
+		c.listenersMu.Unlock()
+	}
+	return ch, cancel
+}
+
+// dispatch delivers a server notification to every listener without
+// blocking. Log messages are also kept for ServerLogs.
+func (c *MCPClient) dispatch(n MCPNotification) {
+	if n.Method == "notifications/message" {
+		c.serverLogs.add(newServerLogEntry(n))
+	}
+
+	c.listenersMu.Lock()
+	defer c.listenersMu.Unlock()
+
+	for ch := range c.listeners {
+		select {
+		case ch <- n:
+		default:
+			// Slow listener; drop rather than stall the connection
+		}
+	}
+}
+
+// startNotificationStream opens the Streamable HTTP GET stream once per session
+func (c *MCPClient) startNotificationStream() {
+	c.listenersMu.Lock()
+	if c.streaming {
+		c.listenersMu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.streaming = true
+	c.stopStream = cancel
+	c.listenersMu.Unlock()
+
+	go func() {
+		c.listenNotifications(ctx)
+		// The stream ended; the next Subscribe reopens it
+		c.listenersMu.Lock()
+		c.streaming = false
+		c.listenersMu.Unlock()
+		cancel()
+	}()
+}
+
+// stopNotificationStream closes the GET stream if one is open
+func (c *MCPClient) stopNotificationStream() {
+	c.listenersMu.Lock()
+	defer c.listenersMu.Unlock()
+	if c.stopStream != nil {
+		c.stopStream()
+		c.stopStream = nil
+	}
+}
+
+// listenNotifications reads server-sent events from a GET on the server URL
+// and dispatches JSON-RPC notifications until the stream ends
+func (c *MCPClient) listenNotifications(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	for k, v := range c.config.Headers {
+		req.Header.Set(k, v)
+	}
+	oauthToken, err := c.bearerToken(ctx)
+	if err != nil {
+		return err
+	}
+	if oauthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+oauthToken)
+	}
+	c.setSession(req)
+	if c.protocol != "" {
+		req.Header.Set("Mcp-Protocol-Version", c.protocol)
+	}
+
+	// The stream is long-lived, so it must not inherit the request timeout
+	client := &http.Client{Transport: c.httpClient.client.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("notification stream rejected: HTTP %d", resp.StatusCode)
+	}
+
+	return readSSENotifications(bufio.NewScanner(resp.Body), c.dispatch)
+}
+
+// readSSENotifications parses an SSE stream, passing each notification to fn.
+// Multi-line data fields are joined per the SSE spec.
+func readSSENotifications(scanner *bufio.Scanner, fn func(MCPNotification)) error {
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(data) > 0 {
+				if n, ok := parseNotification([]byte(strings.Join(data, "\n"))); ok {
+					fn(n)
+				}
+				data = nil
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "data:") {
+			data = append(data, strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		}
+	}
+	return scanner.Err()
+}
+
+// parseNotification decodes a JSON-RPC message that is a notification (a
+// method without an id)
+func parseNotification(data []byte) (MCPNotification, bool) {
+	var msg struct {
+		MCPNotification
+		ID any `json:"id"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Method == "" || msg.ID != nil {
+		return MCPNotification{}, false
+	}
+	return msg.MCPNotification, true
+}
+
+// ResourceUpdate is one streamed resource change from the daemon
+type ResourceUpdate struct {
+	Server string `json:"server"`
+	URI    string `json:"uri"`
+	Method string `json:"method"`
+	Params any    `json:"params,omitempty"`
+	Time   string `json:"time"`
+}
+
+// notificationURI returns the resource uri a notification refers to, if any
+func notificationURI(n MCPNotification) string {
+	params, _ := n.Params.(map[string]any)
+	uri, _ := params["uri"].(string)
+	return uri
+}
+
+// newResourceUpdate wraps a notification for streaming to daemon clients
+func newResourceUpdate(server string, n MCPNotification) ResourceUpdate {
+	return ResourceUpdate{
+		Server: server,
+		URI:    notificationURI(n),
+		Method: n.Method,
+		Params: n.Params,
+		Time:   time.Now().UTC().Format(time.RFC3339),
+	}
+}
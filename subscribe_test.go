@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// subscribeInitResult advertises resource subscriptions
+var subscribeInitResult = map[string]any{
+	"protocolVersion": ProtocolVersion,
+	"capabilities":    map[string]any{"resources": map[string]any{"subscribe": true}},
+}
+
+// newSubscribeServer starts a WebSocket MCP server that pushes a
+// notifications/resources/updated for the uri right after each subscribe,
+// preceded by one for the uri plus each of siblings
+func newSubscribeServer(t *testing.T, siblings ...string) string {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			var req MCPRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			if req.ID == "" {
+				continue
+			}
+
+			var result map[string]any
+			if req.Method == "initialize" {
+				result = subscribeInitResult
+			}
+			conn.WriteJSON(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+
+			if req.Method == "resources/subscribe" {
+				params, _ := req.Params.(map[string]any)
+				uri, _ := params["uri"].(string)
+				for _, sibling := range append(siblings, "") {
+					conn.WriteJSON(MCPNotification{
+						JSONRPC: "2.0",
+						Method:  "notifications/resources/updated",
+						Params:  map[string]any{"uri": uri + sibling},
+					})
+				}
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// waitForNotification returns the next notification or fails after a timeout
+func waitForNotification(t *testing.T, events <-chan MCPNotification) MCPNotification {
+	t.Helper()
+	select {
+	case n := <-events:
+		return n
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for notification")
+		return MCPNotification{}
+	}
+}
+
+func TestMCPClient_Subscribe_WebSocket(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	client, err := NewMCPClient("files", ServerConfig{URL: newSubscribeServer(t), Transport: TransportWebSocket})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	events, cancel := client.Events()
+	defer cancel()
+
+	if err := client.Subscribe("file:///var/log/app.log"); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	n := waitForNotification(t, events)
+	if n.Method != "notifications/resources/updated" {
+		t.Errorf("Unexpected method: %s", n.Method)
+	}
+	if uri := notificationURI(n); uri != "file:///var/log/app.log" {
+		t.Errorf("Unexpected uri: %s", uri)
+	}
+}
+
+func TestMCPClient_Subscribe_StreamableHTTP(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	subscribed := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			if r.Header.Get("Mcp-Session-Id") != "sess-1" {
+				http.Error(w, "missing session", http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			select {
+			case uri := <-subscribed:
+				fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/resources/updated\",\"params\":{\"uri\":%q}}\n\n", uri)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+			}
+			<-r.Context().Done()
+			return
+		}
+
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Mcp-Session-Id", "sess-1")
+		if req.ID == "" {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		var result map[string]any
+		switch req.Method {
+		case "initialize":
+			result = subscribeInitResult
+		case "resources/subscribe":
+			params, _ := req.Params.(map[string]any)
+			uri, _ := params["uri"].(string)
+			subscribed <- uri
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+	}))
+	defer server.Close()
+
+	client, err := NewMCPClient("files", ServerConfig{URL: server.URL, SessionBased: true})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	events, cancel := client.Events()
+	defer cancel()
+
+	if err := client.Subscribe("file:///notes.md"); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	n := waitForNotification(t, events)
+	if uri := notificationURI(n); uri != "file:///notes.md" {
+		t.Errorf("Unexpected uri: %s", uri)
+	}
+}
+
+func TestMCPClient_Subscribe_RequiresPersistent(t *testing.T) {
+	client, err := NewMCPClient("plain", ServerConfig{URL: "http://127.0.0.1:1/mcp"})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	if err := client.Subscribe("file:///x"); err != errNotPersistent {
+		t.Errorf("Expected errNotPersistent, got %v", err)
+	}
+}
+
+func TestReadSSENotifications(t *testing.T) {
+	stream := strings.Join([]string{
+		`data: {"jsonrpc":"2.0","id":"1","result":{}}`,
+		``,
+		`event: message`,
+		`data: {"jsonrpc":"2.0","method":"notifications/resources/updated",`,
+		`data: "params":{"uri":"file:///a"}}`,
+		``,
+		`: keepalive comment`,
+		``,
+	}, "\n")
+
+	var got []MCPNotification
+	err := readSSENotifications(bufio.NewScanner(strings.NewReader(stream)), func(n MCPNotification) {
+		got = append(got, n)
+	})
+	if err != nil {
+		t.Fatalf("readSSENotifications failed: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("Expected 1 notification, got %d", len(got))
+	}
+	if notificationURI(got[0]) != "file:///a" {
+		t.Errorf("Unexpected notification: %+v", got[0])
+	}
+}
+
+func TestMCPDaemon_Subscribe(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	config := &Config{
+		Servers: map[string]ServerConfig{
+			// An update to file:///app.log.1 must not reach a subscriber to file:///app.log
+			"files": {URL: newSubscribeServer(t, ".1"), Transport: TransportWebSocket},
+		},
+	}
+	if err := SaveConfig(config); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+	defer daemon.closeAllClients()
+
+	clientConn, daemonConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		daemon.handleConnection(daemonConn)
+		close(done)
+	}()
+
+	go json.NewEncoder(clientConn).Encode(DaemonCommand{Action: "subscribe", Server: "files", URI: "file:///app.log"})
+	clientConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	decoder := json.NewDecoder(clientConn)
+
+	var first Response
+	if err := decoder.Decode(&first); err != nil {
+		t.Fatalf("Failed to read subscribe response: %v", err)
+	}
+	if !first.OK {
+		t.Fatalf("Expected subscribe to succeed, got %+v", first.Error)
+	}
+	if first.RequestID == "" {
+		t.Error("Expected a request ID on the subscribe response")
+	}
+
+	var update struct {
+		OK        bool           `json:"ok"`
+		Data      ResourceUpdate `json:"data"`
+		RequestID string         `json:"requestId"`
+	}
+	if err := decoder.Decode(&update); err != nil {
+		t.Fatalf("Failed to read update: %v", err)
+	}
+	if update.Data.Server != "files" || update.Data.URI != "file:///app.log" {
+		t.Errorf("Unexpected update: %+v", update.Data)
+	}
+	if update.RequestID != first.RequestID {
+		t.Errorf("Expected updates tagged with the subscription's request ID %q, got %q", first.RequestID, update.RequestID)
+	}
+
+	// Disconnecting ends the stream and releases the subscription
+	clientConn.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected stream to end after client disconnect")
+	}
+	daemon.subMu.Lock()
+	remaining := len(daemon.subscribers)
+	daemon.subMu.Unlock()
+	if remaining != 0 {
+		t.Errorf("Expected no subscribers after disconnect, got %d", remaining)
+	}
+}
+
+func TestMCPDaemon_ShutdownEndsSubscriptions(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+	// Unix socket paths are length-limited, so avoid the long t.TempDir path
+	sockDir, err := os.MkdirTemp("", "mcpx")
+	if err != nil {
+		t.Fatalf("MkdirTemp failed: %v", err)
+	}
+	defer os.RemoveAll(sockDir)
+	origSocket, origPID, origState := SocketPath, PIDFile, LocalState
+	SocketPath = filepath.Join(sockDir, "d.sock")
+	PIDFile = filepath.Join(sockDir, "d.pid")
+	LocalState = filepath.Join(sockDir, "local.json")
+	defer func() { SocketPath, PIDFile, LocalState = origSocket, origPID, origState }()
+
+	SaveConfig(&Config{Servers: map[string]ServerConfig{
+		"files": {URL: newSubscribeServer(t), Transport: TransportWebSocket},
+	}})
+
+	daemon, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+	runErr := make(chan error, 1)
+	go func() { runErr <- daemon.Run() }()
+	deadline := time.Now().Add(5 * time.Second)
+	for !IsDaemonRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("Daemon did not start")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	conn, err := net.Dial("unix", SocketPath)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	json.NewEncoder(conn).Encode(DaemonCommand{Action: "subscribe", Server: "files", URI: "file:///app.log"})
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var first Response
+	if err := json.NewDecoder(conn).Decode(&first); err != nil || !first.OK {
+		t.Fatalf("Expected subscribe to succeed, got %+v, %v", first, err)
+	}
+
+	// The open stream must not hold shutdown for the drain timeout
+	if resp, err := DaemonSend(DaemonCommand{Action: "shutdown"}); err != nil || !resp.OK {
+		t.Fatalf("Expected shutdown to be accepted, got %+v, %v", resp, err)
+	}
+	select {
+	case err := <-runErr:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected shutdown to end the subscription stream")
+	}
+}
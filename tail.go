@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tailPollInterval is how often followLog checks for appended data
+const tailPollInterval = 250 * time.Millisecond
+
+// followLog writes the last n lines of path to w, then streams appended data
+// until stop is closed. Truncation (copytruncate) and rotation (rename +
+// recreate) are detected and followed from the start of the new content.
+func followLog(path string, w io.Writer, n int, stop <-chan struct{}, interval time.Duration) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { f.Close() }()
+
+	offset, err := writeLastLines(f, w, n)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+
+		// Copy whatever was appended since the last poll
+		copied, err := io.Copy(w, io.NewSectionReader(f, offset, 1<<62))
+		if err != nil {
+			return err
+		}
+		offset += copied
+
+		current, err := os.Stat(path)
+		if err != nil {
+			continue // Mid-rotation; try again next tick
+		}
+		opened, err := f.Stat()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case !os.SameFile(current, opened):
+			// Rotated: the old file is drained, switch to the new one
+			newFile, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			f.Close()
+			f, offset = newFile, 0
+		case current.Size() < offset:
+			// Truncated in place
+			offset = 0
+		}
+	}
+}
+
+// writeLastLines writes the final n lines of f to w and returns the file
+// size, i.e. the offset to continue following from
+func writeLastLines(f *os.File, w io.Writer, n int) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+
+	// Read backwards in chunks until we've seen n line breaks
+	const chunk = 4096
+	start := size
+	var buf []byte
+	for start > 0 && bytes.Count(bytes.TrimSuffix(buf, []byte("\n")), []byte("\n")) < n {
+		readSize := int64(chunk)
+		if start < readSize {
+			readSize = start
+		}
+		start -= readSize
+		part := make([]byte, readSize)
+		if _, err := f.ReadAt(part, start); err != nil && err != io.EOF {
+			return 0, err
+		}
+		buf = append(part, buf...)
+	}
+
+	// Drop leading lines beyond the last n
+	body := bytes.TrimSuffix(buf, []byte("\n"))
+	if lines := bytes.Split(body, []byte("\n")); len(lines) > n {
+		skip := len(body) - len(bytes.Join(lines[len(lines)-n:], []byte("\n")))
+		buf = buf[skip:]
+	}
+
+	if _, err := w.Write(buf); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// logColors are cycled through, one per server, in --logs-all output
+var logColors = []string{"\033[36m", "\033[33m", "\033[35m", "\033[32m", "\033[34m", "\033[31m"}
+
+const colorReset = "\033[0m"
+
+// stdoutIsTerminal reports whether stdout is a terminal (and NO_COLOR unset),
+// i.e. whether colored output is wanted
+func stdoutIsTerminal() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// prefixWriter writes each complete line to out with a prefix. Partial lines
+// are held back until their newline arrives, and the shared mutex keeps
+// lines from concurrent followers from interleaving mid-line.
+type prefixWriter struct {
+	prefix  string
+	out     io.Writer
+	mu      *sync.Mutex
+	partial []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.partial = append(p.partial, b...)
+	for {
+		i := bytes.IndexByte(p.partial, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		p.mu.Lock()
+		_, err := fmt.Fprintf(p.out, "%s%s", p.prefix, p.partial[:i+1])
+		p.mu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		p.partial = p.partial[i+1:]
+	}
+}
+
+// followLogs follows several servers' logs (name -> path) at once, prefixing
+// each line with the server name, colored if color is set. Logs that don't
+// exist yet are followed once they are created. A log that can't be read
+// reports the error in its own stream; the rest keep going until stop is
+// closed.
+func followLogs(logs map[string]string, w io.Writer, n int, stop <-chan struct{}, interval time.Duration, color bool) {
+	names := make([]string, 0, len(logs))
+	width := 0
+	for name := range logs {
+		names = append(names, name)
+		width = max(width, len(name))
+	}
+	sort.Strings(names)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, name := range names {
+		prefix := fmt.Sprintf("%-*s | ", width, name)
+		if color {
+			prefix = logColors[i%len(logColors)] + strings.TrimSuffix(prefix, " ") + colorReset + " "
+		}
+		pw := &prefixWriter{prefix: prefix, out: w, mu: &mu}
+
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			if !waitForFile(path, stop, interval) {
+				return
+			}
+			if err := followLog(path, pw, n, stop, interval); err != nil {
+				fmt.Fprintf(pw, "error following %s: %v\n", path, err)
+			}
+		}(logs[name])
+	}
+	wg.Wait()
+}
+
+// waitForFile polls until path exists, returning false if stop closes first
+func waitForFile(path string, stop <-chan struct{}, interval time.Duration) bool {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+		select {
+		case <-stop:
+			return false
+		case <-time.After(interval):
+		}
+	}
+}
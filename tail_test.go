@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent write and read
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func waitForOutput(t *testing.T, out *syncBuffer, want string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if strings.Contains(out.String(), want) {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %q, got %q", want, out.String())
+}
+
+func TestWriteLastLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	var content strings.Builder
+	for i := 1; i <= 150; i++ {
+		fmt.Fprintf(&content, "line %d\n", i)
+	}
+	os.WriteFile(path, []byte(content.String()), 0644)
+
+	f, _ := os.Open(path)
+	defer f.Close()
+	var out bytes.Buffer
+	offset, err := writeLastLines(f, &out, 100)
+	if err != nil {
+		t.Fatalf("writeLastLines failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 100 || lines[0] != "line 51" || lines[99] != "line 150" {
+		t.Errorf("Expected lines 51-150, got %d lines starting %q", len(lines), lines[0])
+	}
+	if offset != int64(content.Len()) {
+		t.Errorf("Expected offset %d, got %d", content.Len(), offset)
+	}
+}
+
+func TestFollowLog_AppendTruncateRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	os.WriteFile(path, []byte("existing\n"), 0644)
+
+	out := &syncBuffer{}
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- followLog(path, out, 100, stop, 5*time.Millisecond) }()
+
+	waitForOutput(t, out, "existing\n")
+
+	// Append
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("appended line\n")
+	f.Close()
+	waitForOutput(t, out, "appended line\n")
+
+	// Truncate in place, then write fresh content
+	os.WriteFile(path, nil, 0644)
+	time.Sleep(30 * time.Millisecond)
+	f, _ = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("after truncate\n")
+	f.Close()
+	waitForOutput(t, out, "after truncate\n")
+
+	// Rotate: rename away and create a new file
+	os.Rename(path, path+".1")
+	os.WriteFile(path, []byte("after rotate\n"), 0644)
+	waitForOutput(t, out, "after rotate\n")
+
+	close(stop)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("followLog returned error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("followLog did not stop")
+	}
+}
+
+func TestFollowLogs_PrefixesAndInterleaves(t *testing.T) {
+	dir := t.TempDir()
+	alpha := filepath.Join(dir, "alpha.log")
+	beta := filepath.Join(dir, "beta.log") // Created only after following starts
+	os.WriteFile(alpha, []byte("a1\n"), 0644)
+
+	out := &syncBuffer{}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		followLogs(map[string]string{"alpha": alpha, "beta": beta}, out, 100, stop, 5*time.Millisecond, false)
+		close(done)
+	}()
+
+	appendLine := func(path, line string) {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(line)
+		f.Close()
+	}
+
+	waitForOutput(t, out, "alpha | a1\n")
+	appendLine(beta, "b1\n")
+	waitForOutput(t, out, "beta  | b1\n")
+	appendLine(alpha, "a2 part")
+	appendLine(beta, "b2\n")
+	waitForOutput(t, out, "beta  | b2\n")
+	appendLine(alpha, " done\n")
+	waitForOutput(t, out, "alpha | a2 part done\n")
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("followLogs did not stop")
+	}
+
+	want := "alpha | a1\nbeta  | b1\nbeta  | b2\nalpha | a2 part done\n"
+	if out.String() != want {
+		t.Errorf("Expected interleaved prefixed lines %q, got %q", want, out.String())
+	}
+}
+
+func TestFollowLogs_StopsWhileWaitingForFile(t *testing.T) {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		followLogs(map[string]string{"missing": filepath.Join(t.TempDir(), "missing.log")}, &syncBuffer{}, 100, stop, 5*time.Millisecond, true)
+		close(done)
+	}()
+
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("followLogs did not stop while waiting for a missing log")
+	}
+}
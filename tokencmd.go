@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultTokenCommandTTL is how long a token_command result is reused when
+// token_command_ttl is not set
+const defaultTokenCommandTTL = 5 * time.Minute
+
+// tokenCommandTimeout bounds how long a token_command may run
+var tokenCommandTimeout = 30 * time.Second
+
+// commandToken caches the bearer token printed by a server's token_command
+type commandToken struct {
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// bearerToken returns the token sent as "Authorization: Bearer": the OAuth
+// token if one is set, otherwise the output of the server's token_command
+// (reused until its TTL passes), otherwise "". Either overrides a static
+// Authorization header.
+func (c *MCPClient) bearerToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	oauthToken := c.oauthToken
+	c.mu.Unlock()
+	if oauthToken != "" || c.config.TokenCommand == "" {
+		return oauthToken, nil
+	}
+
+	c.cmdToken.mu.Lock()
+	defer c.cmdToken.mu.Unlock()
+	if c.cmdToken.token != "" && time.Now().Before(c.cmdToken.expires) {
+		return c.cmdToken.token, nil
+	}
+
+	token, err := runTokenCommand(ctx, c.config.TokenCommand)
+	if err != nil {
+		return "", codedErrorf(ErrAuthExpired, "token_command for '%s' failed: %v", c.serverName, err)
+	}
+	ttl := defaultTokenCommandTTL
+	if c.config.TokenCommandTTL > 0 {
+		ttl = time.Duration(c.config.TokenCommandTTL) * time.Second
+	}
+	c.cmdToken.token = token
+	c.cmdToken.expires = time.Now().Add(ttl)
+	return token, nil
+}
+
+// runTokenCommand runs command through the shell and returns its trimmed
+// stdout. Stderr is included in the error when the command fails.
+func runTokenCommand(ctx context.Context, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, tokenCommandTimeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, command)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", fmt.Errorf("command printed no token")
+	}
+	return token, nil
+}
+
+// shellCommand runs command through the platform shell
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/c", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMCPClient_TokenCommand(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	var auths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auths = append(auths, r.Header.Get("Authorization"))
+		var req MCPRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]any{}})
+	}))
+	defer server.Close()
+
+	// The fake command counts its runs so caching can be checked
+	runs := filepath.Join(t.TempDir(), "runs")
+	client, err := NewMCPClient("test", ServerConfig{
+		URL:          server.URL,
+		Headers:      map[string]string{"Authorization": "Bearer static"},
+		TokenCommand: "echo run >> " + runs + "; echo '  minted-token  '",
+	})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := client.Request("ping", nil); err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+	}
+	for _, auth := range auths {
+		if auth != "Bearer minted-token" {
+			t.Errorf("Expected command token to override the static header, got %q", auth)
+		}
+	}
+	data, _ := os.ReadFile(runs)
+	if n := strings.Count(string(data), "run"); n != 1 {
+		t.Errorf("Expected token command to run once within its TTL, ran %d times", n)
+	}
+
+	// An OAuth token still wins
+	client.SetOAuthToken("oauth-token")
+	client.Request("ping", nil)
+	if got := auths[len(auths)-1]; got != "Bearer oauth-token" {
+		t.Errorf("Expected OAuth token to take precedence, got %q", got)
+	}
+}
+
+func TestMCPClient_TokenCommandFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected no request when the token command fails")
+	}))
+	defer server.Close()
+
+	client, err := NewMCPClient("test", ServerConfig{URL: server.URL, TokenCommand: "echo 'not logged in' >&2; exit 1"})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	_, _, err = client.Request("ping", nil)
+	if errorCodeOf(err, "") != ErrAuthExpired {
+		t.Fatalf("Expected AUTH_EXPIRED, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "not logged in") {
+		t.Errorf("Expected command stderr in error, got %v", err)
+	}
+}
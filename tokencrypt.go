@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// TokenKeyEnv names the environment variable holding the passphrase used to
+// encrypt tokens.json. When unset, tokens are stored in plaintext.
+const TokenKeyEnv = "MCPX_TOKEN_KEY"
+
+// Token file formats. v1 keyed AES with a single SHA-256 of the
+// passphrase; it is still read, and rewritten as v2 on the next save.
+const (
+	encryptedTokensV1      = "aes-256-gcm-v1"
+	encryptedTokensVersion = "aes-256-gcm-scrypt-v2"
+)
+
+// encryptedTokens is the on-disk envelope for an encrypted tokens.json
+type encryptedTokens struct {
+	Encrypted string    `json:"encrypted"`     // Format version
+	KDF       *tokenKDF `json:"kdf,omitempty"` // Key derivation parameters (v2)
+	Salt      []byte    `json:"salt"`
+	Nonce     []byte    `json:"nonce"`
+	Data      []byte    `json:"data"`
+}
+
+// tokenKDF holds the scrypt parameters a token file was encrypted with, so
+// they can be raised later without breaking existing files
+type tokenKDF struct {
+	Name string `json:"name"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+}
+
+// tokenScrypt is the key derivation used for newly written token files
+var tokenScrypt = tokenKDF{Name: "scrypt", N: 1 << 15, R: 8, P: 1}
+
+var plaintextWarning sync.Once
+
+// tokenPassphrase returns the configured token passphrase, if any
+func tokenPassphrase() string {
+	return os.Getenv(TokenKeyEnv)
+}
+
+// deriveTokenKey derives a 256-bit AES key from the passphrase and salt
+func deriveTokenKey(passphrase string, salt []byte, kdf tokenKDF) ([]byte, error) {
+	if kdf.Name != "scrypt" {
+		return nil, fmt.Errorf("unsupported token key derivation %q", kdf.Name)
+	}
+	return scrypt.Key([]byte(passphrase), salt, kdf.N, kdf.R, kdf.P, 32)
+}
+
+// legacyTokenKey derives the key of a v1 token file
+func legacyTokenKey(passphrase string, salt []byte) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(passphrase))
+	return h.Sum(nil)
+}
+
+// encryptTokens seals plaintext token JSON into an encrypted envelope
+func encryptTokens(plaintext []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	key, err := deriveTokenKey(passphrase, salt, tokenScrypt)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newTokenCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(encryptedTokens{
+		Encrypted: encryptedTokensVersion,
+		KDF:       &tokenScrypt,
+		Salt:      salt,
+		Nonce:     nonce,
+		Data:      gcm.Seal(nil, nonce, plaintext, nil),
+	}, "", "  ")
+}
+
+// decryptTokens opens an encrypted envelope. ok is false when data is not
+// an encrypted token file (i.e. legacy plaintext).
+func decryptTokens(data []byte, passphrase string) (plaintext []byte, ok bool, err error) {
+	var env encryptedTokens
+	if json.Unmarshal(data, &env) != nil || env.Encrypted == "" {
+		return nil, false, nil
+	}
+	if env.Encrypted != encryptedTokensVersion && env.Encrypted != encryptedTokensV1 {
+		return nil, true, fmt.Errorf("unsupported token encryption format %q", env.Encrypted)
+	}
+	if passphrase == "" {
+		return nil, true, fmt.Errorf("tokens file is encrypted; set %s to decrypt it", TokenKeyEnv)
+	}
+
+	var key []byte
+	switch {
+	case env.Encrypted == encryptedTokensV1:
+		key = legacyTokenKey(passphrase, env.Salt)
+	case env.KDF == nil:
+		return nil, true, fmt.Errorf("token file is missing its key derivation parameters")
+	default:
+		if key, err = deriveTokenKey(passphrase, env.Salt, *env.KDF); err != nil {
+			return nil, true, err
+		}
+	}
+	gcm, err := newTokenCipher(key)
+	if err != nil {
+		return nil, true, err
+	}
+	if len(env.Nonce) != gcm.NonceSize() {
+		return nil, true, fmt.Errorf("invalid token file nonce")
+	}
+
+	plaintext, err = gcm.Open(nil, env.Nonce, env.Data, nil)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to decrypt tokens (wrong %s?)", TokenKeyEnv)
+	}
+	return plaintext, true, nil
+}
+
+func newTokenCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// warnPlaintextTokens prints a one-time notice that tokens are unencrypted
+func warnPlaintextTokens() {
+	plaintextWarning.Do(func() {
+		fmt.Fprintf(os.Stderr, "warning: storing OAuth tokens unencrypted; set %s to encrypt %s\n", TokenKeyEnv, TokensFile)
+	})
+}
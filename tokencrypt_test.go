@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSaveAndLoadTokens_Encrypted(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+	t.Setenv(TokenKeyEnv, "correct horse battery staple")
+
+	tokens := map[string]TokenData{
+		"server1": {AccessToken: "secret-access", RefreshToken: "secret-refresh", ExpiresAt: 1234567890.0},
+	}
+	if err := SaveTokens(tokens); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+
+	raw, err := os.ReadFile(TokensFile)
+	if err != nil {
+		t.Fatalf("Failed to read tokens file: %v", err)
+	}
+	if strings.Contains(string(raw), "secret-access") || strings.Contains(string(raw), "secret-refresh") {
+		t.Error("Expected tokens file not to contain plaintext tokens")
+	}
+	if !strings.Contains(string(raw), encryptedTokensVersion) {
+		t.Error("Expected tokens file to be tagged with encryption format")
+	}
+
+	loaded, err := LoadTokens()
+	if err != nil {
+		t.Fatalf("LoadTokens failed: %v", err)
+	}
+	if loaded["server1"].AccessToken != "secret-access" || loaded["server1"].RefreshToken != "secret-refresh" {
+		t.Errorf("Round-trip mismatch: %+v", loaded["server1"])
+	}
+}
+
+func TestLoadTokens_EncryptedWrongKey(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	t.Setenv(TokenKeyEnv, "key-one")
+	if err := SaveTokens(map[string]TokenData{"s": {AccessToken: "tok"}}); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+
+	t.Setenv(TokenKeyEnv, "key-two")
+	if _, err := LoadTokens(); err == nil {
+		t.Error("Expected error decrypting with wrong key")
+	}
+
+	t.Setenv(TokenKeyEnv, "")
+	_, err := LoadTokens()
+	if err == nil || !strings.Contains(err.Error(), TokenKeyEnv) {
+		t.Errorf("Expected error mentioning %s, got %v", TokenKeyEnv, err)
+	}
+}
+
+func TestLoadTokens_PlaintextWithKeySet(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	// Legacy plaintext file written before a key was configured
+	if err := os.WriteFile(TokensFile, []byte(`{"s": {"access_token": "legacy"}}`), 0600); err != nil {
+		t.Fatalf("Failed to write tokens: %v", err)
+	}
+	t.Setenv(TokenKeyEnv, "new-key")
+
+	loaded, err := LoadTokens()
+	if err != nil {
+		t.Fatalf("LoadTokens failed: %v", err)
+	}
+	if loaded["s"].AccessToken != "legacy" {
+		t.Errorf("Expected legacy token to load, got %+v", loaded["s"])
+	}
+}
+
+func TestEncryptTokens_StoresKDFParameters(t *testing.T) {
+	data, err := encryptTokens([]byte(`{}`), "passphrase")
+	if err != nil {
+		t.Fatalf("encryptTokens failed: %v", err)
+	}
+	var env encryptedTokens
+	if err := json.Unmarshal(data, &env); err != nil {
+		t.Fatalf("Failed to decode envelope: %v", err)
+	}
+	if env.Encrypted != encryptedTokensVersion || env.KDF == nil || *env.KDF != tokenScrypt {
+		t.Errorf("Expected version %s with scrypt parameters, got %s %+v", encryptedTokensVersion, env.Encrypted, env.KDF)
+	}
+
+	// Files keep working if the defaults are raised later
+	env.KDF = &tokenKDF{Name: "scrypt", N: 1 << 10, R: 8, P: 1}
+	key, _ := deriveTokenKey("passphrase", env.Salt, *env.KDF)
+	gcm, _ := newTokenCipher(key)
+	env.Data = gcm.Seal(nil, env.Nonce, []byte(`{"s":{"access_token":"tok"}}`), nil)
+	data, _ = json.Marshal(env)
+	plaintext, ok, err := decryptTokens(data, "passphrase")
+	if err != nil || !ok || string(plaintext) != `{"s":{"access_token":"tok"}}` {
+		t.Errorf("Expected decryption with the stored parameters, got %q, %v, %v", plaintext, ok, err)
+	}
+}
+
+func TestLoadTokens_LegacyV1Encrypted(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+	t.Setenv(TokenKeyEnv, "old-key")
+
+	// A file written by the SHA-256 keyed format
+	salt := []byte("0123456789abcdef")
+	gcm, _ := newTokenCipher(legacyTokenKey("old-key", salt))
+	nonce := make([]byte, gcm.NonceSize())
+	data, _ := json.Marshal(encryptedTokens{
+		Encrypted: encryptedTokensV1,
+		Salt:      salt,
+		Nonce:     nonce,
+		Data:      gcm.Seal(nil, nonce, []byte(`{"s": {"access_token": "legacy"}}`), nil),
+	})
+	if err := os.WriteFile(TokensFile, data, 0600); err != nil {
+		t.Fatalf("Failed to write tokens: %v", err)
+	}
+
+	loaded, err := LoadTokens()
+	if err != nil {
+		t.Fatalf("LoadTokens failed: %v", err)
+	}
+	if loaded["s"].AccessToken != "legacy" {
+		t.Errorf("Expected the v1 token to load, got %+v", loaded["s"])
+	}
+
+	// The next save upgrades the file
+	if err := SaveTokens(loaded); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+	raw, _ := os.ReadFile(TokensFile)
+	if !strings.Contains(string(raw), encryptedTokensVersion) {
+		t.Errorf("Expected the file rewritten as %s, got %s", encryptedTokensVersion, raw)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// TokenStoreEnv selects where OAuth tokens are kept ("file" or "keychain")
+const TokenStoreEnv = "MCPX_TOKEN_STORE"
+
+// keychainService identifies mcpx items; all of a profile's tokens are
+// stored as a single JSON secret
+const keychainService = "mcpx"
+
+// keychainAccount names the keychain item for the active profile
+func keychainAccount() string {
+	if ActiveProfile == DefaultProfile {
+		return "tokens"
+	}
+	return "tokens-" + ActiveProfile
+}
+
+// TokenStore persists OAuth tokens for all servers
+type TokenStore interface {
+	Load() (map[string]TokenData, error)
+	Save(tokens map[string]TokenData) error
+	Clear() error
+}
+
+// tokenStoreOverride replaces the configured store when set (used by tests)
+var tokenStoreOverride TokenStore
+
+// activeTokenStore returns the store selected by MCPX_TOKEN_STORE (file by default)
+func activeTokenStore() TokenStore {
+	if tokenStoreOverride != nil {
+		return tokenStoreOverride
+	}
+	if strings.EqualFold(os.Getenv(TokenStoreEnv), "keychain") {
+		return keychainTokenStore{}
+	}
+	return fileTokenStore{}
+}
+
+// fileTokenStore keeps tokens in ~/.mcpx/tokens.json (optionally encrypted)
+type fileTokenStore struct{}
+
+func (fileTokenStore) Load() (map[string]TokenData, error)    { return loadTokensFile() }
+func (fileTokenStore) Save(tokens map[string]TokenData) error { return saveTokensFile(tokens) }
+func (fileTokenStore) Clear() error                           { return clearTokensFile() }
+
+// keychainTokenStore keeps tokens in the OS keychain: Keychain on macOS via
+// `security`, Secret Service on Linux via `secret-tool`. Secrets are passed
+// on stdin so they never appear in the process list.
+type keychainTokenStore struct{}
+
+func (keychainTokenStore) Load() (map[string]TokenData, error) {
+	var out []byte
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		out, err = runKeychainTool("", "security", "find-generic-password", "-s", keychainService, "-a", keychainAccount(), "-w")
+	case "linux":
+		out, err = runKeychainTool("", "secret-tool", "lookup", "service", keychainService, "account", keychainAccount())
+	default:
+		return nil, fmt.Errorf("keychain token store not supported on %s", runtime.GOOS)
+	}
+
+	tokens := make(map[string]TokenData)
+	if err != nil {
+		// Anything but "no such item" (a locked keychain, a denied prompt, no
+		// Secret Service) must fail, or the next Save overwrites the tokens
+		if keychainItemMissing(err, out) {
+			return tokens, nil
+		}
+		return nil, keychainError(err)
+	}
+
+	data := bytes.TrimSpace(out)
+	if len(data) == 0 {
+		return tokens, nil
+	}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("invalid tokens in keychain: %w", err)
+	}
+	return tokens, nil
+}
+
+func (keychainTokenStore) Save(tokens map[string]TokenData) error {
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		// security -i reads commands from stdin; -X takes the secret hex-encoded
+		cmd := fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n", keychainService, keychainAccount(), hex.EncodeToString(data))
+		_, err = runKeychainTool(cmd, "security", "-i")
+	case "linux":
+		_, err = runKeychainTool(string(data), "secret-tool", "store", "--label=mcpx OAuth tokens", "service", keychainService, "account", keychainAccount())
+	default:
+		err = fmt.Errorf("keychain token store not supported on %s", runtime.GOOS)
+	}
+	return err
+}
+
+func (keychainTokenStore) Clear() error {
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		_, err = runKeychainTool("", "security", "delete-generic-password", "-s", keychainService, "-a", keychainAccount())
+	case "linux":
+		_, err = runKeychainTool("", "secret-tool", "clear", "service", keychainService, "account", keychainAccount())
+	default:
+		return fmt.Errorf("keychain token store not supported on %s", runtime.GOOS)
+	}
+	// Nothing stored is not an error
+	if err == nil || keychainItemMissing(err, nil) {
+		return nil
+	}
+	return keychainError(err)
+}
+
+// keychainItemMissing reports whether a keychain tool failed only because
+// the item doesn't exist: `security` exits 44 (errSecItemNotFound) and
+// `secret-tool` exits 1 without printing anything
+func keychainItemMissing(err error, out []byte) bool {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return false
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return exitErr.ExitCode() == 44
+	case "linux":
+		return exitErr.ExitCode() == 1 && len(bytes.TrimSpace(out)) == 0 && len(bytes.TrimSpace(exitErr.Stderr)) == 0
+	}
+	return false
+}
+
+// keychainError adds the tool's stderr to a failed keychain command
+func keychainError(err error) error {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if msg := strings.TrimSpace(string(exitErr.Stderr)); msg != "" {
+			return fmt.Errorf("keychain: %s", msg)
+		}
+	}
+	return fmt.Errorf("keychain: %w", err)
+}
+
+// runKeychainTool runs a keychain helper with the given stdin
+func runKeychainTool(stdin string, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	return cmd.Output()
+}
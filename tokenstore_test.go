@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryTokenStore is an in-memory TokenStore for tests
+type memoryTokenStore struct {
+	tokens map[string]TokenData
+}
+
+func (m *memoryTokenStore) Load() (map[string]TokenData, error) {
+	out := make(map[string]TokenData, len(m.tokens))
+	for k, v := range m.tokens {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (m *memoryTokenStore) Save(tokens map[string]TokenData) error {
+	m.tokens = tokens
+	return nil
+}
+
+func (m *memoryTokenStore) Clear() error {
+	m.tokens = nil
+	return nil
+}
+
+func useMemoryTokenStore(t *testing.T) *memoryTokenStore {
+	store := &memoryTokenStore{}
+	tokenStoreOverride = store
+	t.Cleanup(func() { tokenStoreOverride = nil })
+	return store
+}
+
+func TestTokenStore_RoutesThroughInterface(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+	store := useMemoryTokenStore(t)
+
+	if err := SaveTokens(map[string]TokenData{"srv": {AccessToken: "mem-token"}}); err != nil {
+		t.Fatalf("SaveTokens failed: %v", err)
+	}
+	if store.tokens["srv"].AccessToken != "mem-token" {
+		t.Errorf("Expected token in memory store, got %+v", store.tokens)
+	}
+	if _, err := os.Stat(TokensFile); !os.IsNotExist(err) {
+		t.Error("Expected no tokens file when using a non-file store")
+	}
+
+	loaded, err := LoadTokens()
+	if err != nil {
+		t.Fatalf("LoadTokens failed: %v", err)
+	}
+	if loaded["srv"].AccessToken != "mem-token" {
+		t.Errorf("Expected mem-token, got %+v", loaded["srv"])
+	}
+
+	if err := ClearTokens(); err != nil {
+		t.Fatalf("ClearTokens failed: %v", err)
+	}
+	loaded, _ = LoadTokens()
+	if len(loaded) != 0 {
+		t.Errorf("Expected no tokens after clear, got %d", len(loaded))
+	}
+}
+
+func TestTokenStore_GetTokenForServer(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+	store := useMemoryTokenStore(t)
+	store.tokens = map[string]TokenData{
+		"srv": {AccessToken: "stored", ExpiresAt: float64(time.Now().Unix() + 3600)},
+	}
+
+	token, err := GetTokenForServer("srv", ServerConfig{URL: "http://example.com"})
+	if err != nil {
+		t.Fatalf("GetTokenForServer failed: %v", err)
+	}
+	if token != "stored" {
+		t.Errorf("Expected stored, got %q", token)
+	}
+}
+
+func TestActiveTokenStore_Selection(t *testing.T) {
+	t.Setenv(TokenStoreEnv, "")
+	if _, ok := activeTokenStore().(fileTokenStore); !ok {
+		t.Error("Expected file store by default")
+	}
+
+	t.Setenv(TokenStoreEnv, "keychain")
+	if _, ok := activeTokenStore().(keychainTokenStore); !ok {
+		t.Error("Expected keychain store when MCPX_TOKEN_STORE=keychain")
+	}
+}
+
+func TestKeychainTokenStore_LoadFailures(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("fake secret-tool is tested on Linux")
+	}
+
+	tests := []struct {
+		name    string
+		script  string
+		wantErr string
+		want    int
+	}{
+		{"not stored", "exit 1", "", 0},
+		{"stored", `echo '{"s": {"access_token": "tok"}}'`, "", 1},
+		{"no secret service", "echo 'Cannot autolaunch D-Bus' >&2; exit 1", "D-Bus", 0},
+		{"other failure", "exit 2", "exit status 2", 0},
+	}
+	for _, tt := range tests {
+		// A fake secret-tool on PATH stands in for the real one
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "secret-tool"), []byte("#!/bin/sh\n"+tt.script+"\n"), 0755); err != nil {
+			t.Fatalf("Failed to write fake secret-tool: %v", err)
+		}
+		t.Setenv("PATH", dir)
+
+		tokens, err := keychainTokenStore{}.Load()
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		} else if len(tokens) != tt.want {
+			t.Errorf("%s: expected %d tokens, got %v", tt.name, tt.want, tokens)
+		}
+	}
+}
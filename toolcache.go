@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// loadToolsCache reads the daemon's tool lists saved by a previous run,
+// keeping only entries that are unexpired and still match the configured
+// server. A missing or unreadable file gives an empty cache.
+func loadToolsCache(config *Config) map[string]*CachedTools {
+	cache := make(map[string]*CachedTools)
+	data, err := os.ReadFile(ToolsCacheFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warn("could not read tools cache; starting empty", "path", ToolsCacheFile, "error", err)
+		}
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		logger.Warn("tools cache is corrupt; starting empty", "path", ToolsCacheFile, "error", err)
+		return make(map[string]*CachedTools)
+	}
+	pruneToolsCache(cache, config)
+	return cache
+}
+
+// pruneToolsCache drops entries that have expired or whose server has been
+// removed or now points at a different URL
+func pruneToolsCache(cache map[string]*CachedTools, config *Config) {
+	now := time.Now()
+	for name, cached := range cache {
+		serverConfig, ok := config.Servers[name]
+		if cached == nil || !ok || serverConfig.URL != cached.URL || !now.Before(cached.Expires) {
+			delete(cache, name)
+		}
+	}
+}
+
+// saveToolsCache writes the tool cache to ToolsCacheFile so a restarted
+// daemon can answer from it. Failures are logged; the in-memory cache is
+// still used.
+func (d *MCPDaemon) saveToolsCache() {
+	d.cacheMu.Lock()
+	defer d.cacheMu.Unlock()
+
+	d.mu.RLock()
+	data, err := json.Marshal(d.toolsCache)
+	d.mu.RUnlock()
+	if err == nil {
+		err = os.MkdirAll(ConfigDir, 0755)
+	}
+	if err == nil {
+		err = writeFileAtomic(ToolsCacheFile, data, 0644)
+	}
+	if err != nil {
+		logger.Warn("could not save tools cache", "path", ToolsCacheFile, "error", err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestToolsCache_SurvivesDaemonRestart(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	lists := &atomic.Int32{}
+	server := newSlowToolsServer(t, []any{map[string]any{"name": "search"}}, map[string]*atomic.Int32{"tools/list": lists})
+	SaveConfig(&Config{Servers: map[string]ServerConfig{"cached": {URL: server.URL}}})
+
+	first, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+	if _, err := first.getTools(context.Background(), "cached"); err != nil {
+		t.Fatalf("getTools failed: %v", err)
+	}
+	first.closeAllClients()
+	if lists.Load() != 1 {
+		t.Fatalf("Expected 1 tools/list request, got %d", lists.Load())
+	}
+
+	// A fresh daemon answers from the saved cache
+	second, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+	tools, err := second.getTools(context.Background(), "cached")
+	if err != nil {
+		t.Fatalf("getTools failed: %v", err)
+	}
+	if len(tools) != 1 || tools[0].Name != "search" {
+		t.Errorf("Expected the cached search tool, got %+v", tools)
+	}
+	if n := lists.Load(); n != 1 {
+		t.Errorf("Expected the restarted daemon to use the saved cache, got %d tools/list requests", n)
+	}
+
+	// Once the saved entry expires it is discarded on load
+	var saved map[string]*CachedTools
+	data, _ := os.ReadFile(ToolsCacheFile)
+	if err := json.Unmarshal(data, &saved); err != nil || saved["cached"] == nil {
+		t.Fatalf("Expected a saved entry for 'cached', got %s (%v)", data, err)
+	}
+	saved["cached"].Expires = time.Now().Add(-time.Second)
+	data, _ = json.Marshal(saved)
+	os.WriteFile(ToolsCacheFile, data, 0644)
+
+	third, err := NewMCPDaemon()
+	if err != nil {
+		t.Fatalf("NewMCPDaemon failed: %v", err)
+	}
+	if _, err := third.getTools(context.Background(), "cached"); err != nil {
+		t.Fatalf("getTools failed: %v", err)
+	}
+	third.closeAllClients()
+	if n := lists.Load(); n != 2 {
+		t.Errorf("Expected an expired entry to be refetched, got %d tools/list requests", n)
+	}
+}
+
+func TestLoadToolsCache_DropsChangedServers(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	expires := time.Now().Add(time.Minute)
+	data, _ := json.Marshal(map[string]*CachedTools{
+		"kept":    {Tools: []Tool{{Name: "a"}}, Expires: expires, URL: "http://kept"},
+		"moved":   {Tools: []Tool{{Name: "b"}}, Expires: expires, URL: "http://old"},
+		"removed": {Tools: []Tool{{Name: "c"}}, Expires: expires, URL: "http://gone"},
+	})
+	os.WriteFile(ToolsCacheFile, data, 0644)
+
+	cache := loadToolsCache(&Config{Servers: map[string]ServerConfig{
+		"kept":  {URL: "http://kept"},
+		"moved": {URL: "http://new"},
+	}})
+
+	if len(cache) != 1 || cache["kept"] == nil {
+		t.Errorf("Expected only 'kept' to survive, got %v", cache)
+	}
+}
+
+func TestLoadToolsCache_CorruptFile(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	os.WriteFile(ToolsCacheFile, []byte("{not json"), 0644)
+
+	if cache := loadToolsCache(&Config{}); cache == nil || len(cache) != 0 {
+		t.Errorf("Expected an empty cache, got %v", cache)
+	}
+}
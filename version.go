@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Build metadata, injected at build time:
+//
+//	go build -ldflags "-X main.Version=1.2.0 -X main.Commit=$(git rev-parse --short HEAD) -X main.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Without ldflags, values fall back to Go's embedded module and VCS info.
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	// Set by `go install github.com/lakshminp/mcpx@vX.Y.Z`
+	if Version == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		Version = info.Main.Version
+	}
+	for _, s := range info.Settings {
+		switch {
+		case s.Key == "vcs.revision" && Commit == "":
+			Commit = s.Value
+			if len(Commit) > 12 {
+				Commit = Commit[:12]
+			}
+		case s.Key == "vcs.time" && BuildDate == "":
+			BuildDate = s.Value
+		}
+	}
+}
+
+// versionString formats the version and available build metadata
+func versionString() string {
+	s := "mcpx " + Version
+	if Commit != "" {
+		s += fmt.Sprintf(" (commit %s", Commit)
+		if BuildDate != "" {
+			s += ", built " + BuildDate
+		}
+		s += ")"
+	} else if BuildDate != "" {
+		s += fmt.Sprintf(" (built %s)", BuildDate)
+	}
+	return s
+}
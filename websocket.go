@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Transport values for ServerConfig.Transport
+const (
+	TransportHTTP      = "http"
+	TransportWebSocket = "websocket"
+)
+
+// WebSocket keepalive settings
+var (
+	wsPingInterval = 30 * time.Second // How often to ping an idle connection
+	wsPongWait     = 60 * time.Second // How long without a pong before the connection is dead
+	wsWriteWait    = 10 * time.Second // Deadline for a single frame write
+)
+
+// errWebSocketClosed is returned to requests pending when the connection drops
+var errWebSocketClosed = errors.New("websocket connection closed")
+
+// WebSocketTransport carries JSON-RPC over a single persistent WebSocket
+// connection, correlating responses to requests by id. The connection is
+// dialed lazily and redialed after it drops.
+type WebSocketTransport struct {
+	url     string
+	dialer  *websocket.Dialer
+	timeout time.Duration
+
+	// onNotify receives server notifications; set before the first request
+	onNotify func(MCPNotification)
+
+	mu      sync.Mutex
+	conn    *websocket.Conn
+	dropped bool // A previous connection was lost; its MCP session is gone
+	pending map[string]chan *MCPResponse
+	done    chan struct{} // Closed when the current connection's reader exits
+
+	writeMu sync.Mutex
+}
+
+// NewWebSocketTransport creates a transport for a ws:// or wss:// server
+func NewWebSocketTransport(timeout time.Duration, config ServerConfig) (*WebSocketTransport, error) {
+	tlsConfig, err := tlsConfigForServer(config)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := proxyForServer(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebSocketTransport{
+		url: config.URL,
+		dialer: &websocket.Dialer{
+			Proxy:            proxy,
+			TLSClientConfig:  tlsConfig,
+			HandshakeTimeout: timeout,
+			NetDialContext:   connectDialer(config.connectTimeout()),
+		},
+		timeout: timeout,
+		pending: make(map[string]chan *MCPResponse),
+	}, nil
+}
+
+// connect dials the server if there is no live connection. header carries the
+// server and auth headers for the upgrade request.
+func (w *WebSocketTransport) connect(header http.Header) (*websocket.Conn, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn != nil {
+		return w.conn, nil
+	}
+
+	conn, resp, err := w.dialer.Dial(w.url, header)
+	if err != nil {
+		if resp != nil && resp.StatusCode >= 400 {
+			return nil, newHTTPError(resp.StatusCode, nil)
+		}
+		return nil, fmt.Errorf("websocket dial failed: %w", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	w.conn = conn
+	w.done = make(chan struct{})
+	go w.readLoop(conn, w.done)
+	go w.keepalive(conn, w.done)
+
+	return conn, nil
+}
+
+// readLoop delivers responses to their waiting requests until the connection fails
+func (w *WebSocketTransport) readLoop(conn *websocket.Conn, done chan struct{}) {
+	defer close(done)
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			w.drop(conn)
+			return
+		}
+		// Any traffic proves the connection is alive
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		if n, ok := parseNotification(data); ok {
+			if w.onNotify != nil {
+				w.onNotify(n)
+			}
+			continue
+		}
+
+		var resp MCPResponse
+		if err := json.Unmarshal(data, &resp); err != nil || resp.ID == "" {
+			// Server-to-client requests are not supported
+			continue
+		}
+		resp.responseBytes = len(data)
+
+		w.mu.Lock()
+		ch, ok := w.pending[resp.ID]
+		delete(w.pending, resp.ID)
+		w.mu.Unlock()
+		if ok {
+			ch <- &resp
+		}
+	}
+}
+
+// keepalive pings the server until the connection's reader exits
+func (w *WebSocketTransport) keepalive(conn *websocket.Conn, done chan struct{}) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			w.writeMu.Lock()
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+			w.writeMu.Unlock()
+			if err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// drop forgets a failed connection and fails every pending request
+func (w *WebSocketTransport) drop(conn *websocket.Conn) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	conn.Close()
+	if w.conn != conn {
+		return
+	}
+	w.conn = nil
+	w.dropped = true
+	for id, ch := range w.pending {
+		close(ch)
+		delete(w.pending, id)
+	}
+}
+
+// write sends one text frame
+func (w *WebSocketTransport) write(conn *websocket.Conn, body []byte) error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+
+	conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	if err := conn.WriteMessage(websocket.TextMessage, body); err != nil {
+		return fmt.Errorf("websocket write failed: %w", err)
+	}
+	return nil
+}
+
+// Request sends a JSON-RPC request and waits for the response with the same id.
+// If an earlier connection was lost it redials and returns errSessionExpired
+// so the caller re-initializes on the new connection.
+func (w *WebSocketTransport) Request(ctx context.Context, id string, body []byte, header http.Header) (*MCPResponse, error) {
+	conn, err := w.connect(header)
+	if err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	if w.dropped {
+		w.dropped = false
+		w.mu.Unlock()
+		return nil, errSessionExpired
+	}
+	ch := make(chan *MCPResponse, 1)
+	w.pending[id] = ch
+	timeout := w.timeout
+	w.mu.Unlock()
+
+	if err := w.write(conn, body); err != nil {
+		w.forget(id)
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return nil, errWebSocketClosed
+		}
+		return resp, nil
+	case <-timer.C:
+		w.forget(id)
+		return nil, fmt.Errorf("request timed out after %s", timeout)
+	case <-ctx.Done():
+		w.forget(id)
+		return nil, ctx.Err()
+	}
+}
+
+// Notify sends a JSON-RPC notification; there is no reply to wait for
+func (w *WebSocketTransport) Notify(body []byte, header http.Header) error {
+	conn, err := w.connect(header)
+	if err != nil {
+		return err
+	}
+	return w.write(conn, body)
+}
+
+// forget stops waiting for a response
+func (w *WebSocketTransport) forget(id string) {
+	w.mu.Lock()
+	delete(w.pending, id)
+	w.mu.Unlock()
+}
+
+// resetDropped clears the lost-connection marker before a fresh handshake
+func (w *WebSocketTransport) resetDropped() {
+	w.mu.Lock()
+	w.dropped = false
+	w.mu.Unlock()
+}
+
+// SetTimeout changes how long a request waits for its response
+func (w *WebSocketTransport) SetTimeout(timeout time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timeout = timeout
+	w.dialer.HandshakeTimeout = timeout
+}
+
+// Close sends a close frame and tears down the connection
+func (w *WebSocketTransport) Close() {
+	w.mu.Lock()
+	conn, done := w.conn, w.done
+	w.mu.Unlock()
+
+	if conn == nil {
+		return
+	}
+	w.writeMu.Lock()
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(wsWriteWait))
+	w.writeMu.Unlock()
+	conn.Close()
+	<-done
+
+	// A deliberate close is not a lost session
+	w.resetDropped()
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newWebSocketServer starts a WebSocket JSON-RPC server that answers each
+// request with a canned result per method. Notifications are recorded.
+func newWebSocketServer(t *testing.T, results map[string]map[string]any) (string, *[]string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var notifications []string
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			var req MCPRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			if req.ID == "" {
+				mu.Lock()
+				notifications = append(notifications, req.Method)
+				mu.Unlock()
+				continue
+			}
+			conn.WriteJSON(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: results[req.Method]})
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return "ws" + strings.TrimPrefix(server.URL, "http"), &notifications
+}
+
+func TestMCPClient_WebSocket(t *testing.T) {
+	_, cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	url, notifications := newWebSocketServer(t, map[string]map[string]any{
+		"initialize": {
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "ws-test"},
+		},
+		"tools/list": {
+			"tools": []any{map[string]any{"name": "echo", "description": "Echo input"}},
+		},
+	})
+
+	client, err := NewMCPClient("ws", ServerConfig{
+		URL:       url,
+		Transport: TransportWebSocket,
+		Headers:   map[string]string{"Authorization": "Bearer secret"},
+	})
+	if err != nil {
+		t.Fatalf("NewMCPClient failed: %v", err)
+	}
+	defer client.Close()
+
+	if !client.IsPersistent() {
+		t.Error("Expected websocket client to be persistent")
+	}
+	if err := client.Initialize(); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	if client.ProtocolVersion() != ProtocolVersion {
+		t.Errorf("Expected protocol %s, got %s", ProtocolVersion, client.ProtocolVersion())
+	}
+
+	tools, err := client.ListTools()
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+	if len(tools) != 1 || tools[0].Name != "echo" {
+		t.Errorf("Unexpected tools: %+v", tools)
+	}
+
+	// Sessions are connection-scoped and must not be cached on disk
+	sessions, _ := LoadSessions()
+	if _, ok := sessions["ws"]; ok {
+		t.Error("Expected no cached session for websocket server")
+	}
+
+	client.Close()
+	if len(*notifications) == 0 || (*notifications)[0] != "notifications/initialized" {
+		t.Errorf("Expected initialized notification, got %v", *notifications)
+	}
+}
+
+func TestWebSocketTransport_CorrelatesResponses(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// Answer two requests in reverse order
+		var first, second MCPRequest
+		if conn.ReadJSON(&first) != nil || conn.ReadJSON(&second) != nil {
+			return
+		}
+		for _, req := range []MCPRequest{second, first} {
+			conn.WriteJSON(MCPResponse{JSONRPC: "2.0", ID: req.ID, Result: map[string]any{"method": req.Method}})
+		}
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	transport, err := NewWebSocketTransport(5*time.Second, ServerConfig{URL: "ws" + strings.TrimPrefix(server.URL, "http")})
+	if err != nil {
+		t.Fatalf("NewWebSocketTransport failed: %v", err)
+	}
+	defer transport.Close()
+
+	var wg sync.WaitGroup
+	for _, method := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(method string) {
+			defer wg.Done()
+			body, _ := json.Marshal(MCPRequest{JSONRPC: "2.0", ID: "id-" + method, Method: method})
+			resp, err := transport.Request(context.Background(), "id-"+method, body, nil)
+			if err != nil {
+				t.Errorf("Request %s failed: %v", method, err)
+				return
+			}
+			if resp.Result["method"] != method {
+				t.Errorf("Request %s got response for %v", method, resp.Result["method"])
+			}
+		}(method)
+	}
+	wg.Wait()
+}
+
+func TestWebSocketTransport_DialUnauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	transport, err := NewWebSocketTransport(5*time.Second, ServerConfig{URL: "ws" + strings.TrimPrefix(server.URL, "http")})
+	if err != nil {
+		t.Fatalf("NewWebSocketTransport failed: %v", err)
+	}
+
+	_, err = transport.Request(context.Background(), "1", []byte(`{}`), nil)
+	if code := errorCodeOf(err, ""); code != ErrAuthExpired {
+		t.Errorf("Expected %s, got %s (%v)", ErrAuthExpired, code, err)
+	}
+}